@@ -10,6 +10,7 @@ import (
 	"reflect"
 	"regexp"
 	"strings"
+	"sync"
 
 	"github.com/blues/jsonata-go/jlib"
 	"github.com/blues/jsonata-go/jparse"
@@ -281,6 +282,25 @@ func (c *goCallable) validateArgCount(argv []reflect.Value) ([]reflect.Value, er
 	return argv, nil
 }
 
+// validArgCount returns true if the function can be called
+// with argc arguments. Unlike validateArgCount, it does not
+// have access to the argument values, so it assumes that the
+// context handler (if any) inserts the evaluation context.
+func (c *goCallable) validArgCount(argc int) bool {
+
+	valid := func(n int) bool {
+		for n < len(c.params) && c.params[n].isOpt {
+			n++
+		}
+		if c.isVariadic {
+			return n >= len(c.params)-1
+		}
+		return n == len(c.params)
+	}
+
+	return valid(argc) || (c.contextHandler != nil && valid(argc+1))
+}
+
 func (c *goCallable) validateArgTypes(argv []reflect.Value) ([]reflect.Value, error) {
 
 	var ok bool
@@ -288,8 +308,27 @@ func (c *goCallable) validateArgTypes(argv []reflect.Value) ([]reflect.Value, er
 
 	for i, v := range argv {
 
+		raw := indirect(v)
 		v = jtypes.Resolve(v)
 
+		j := i
+		// Variadic functions can have more arguments than
+		// parameters. Use the type of the final parameter
+		// to process any extra arguments.
+		if j >= paramCount {
+			j = paramCount - 1
+		}
+
+		// Resolve converts values that implement json.Marshaler
+		// or encoding.TextMarshaler (e.g. time.Time) to their
+		// JSON equivalents. Pass the original value to functions
+		// that accept it as is.
+		if raw.IsValid() && (!v.IsValid() || raw.Type() != v.Type()) &&
+			acceptsConcreteArg(c.params[j], raw.Type()) {
+			argv[i] = raw
+			continue
+		}
+
 		// The preceding call to Resolve dereferences pointers.
 		// This is fine for most types but we need to restore
 		// pointer type Callables.
@@ -300,14 +339,6 @@ func (c *goCallable) validateArgTypes(argv []reflect.Value) ([]reflect.Value, er
 			}
 		}
 
-		j := i
-		// Variadic functions can have more arguments than
-		// parameters. Use the type of the final parameter
-		// to process any extra arguments.
-		if j >= paramCount {
-			j = paramCount - 1
-		}
-
 		v, ok = processGoCallableArg(v, c.params[j])
 		if !ok {
 			return nil, newArgTypeError(c, i+1)
@@ -320,10 +351,37 @@ func (c *goCallable) validateArgTypes(argv []reflect.Value) ([]reflect.Value, er
 }
 
 var (
-	typeString    = reflect.TypeOf((*string)(nil)).Elem()
-	typeByteSlice = reflect.TypeOf((*[]byte)(nil)).Elem()
+	typeString     = reflect.TypeOf((*string)(nil)).Elem()
+	typeByteSlice  = reflect.TypeOf((*[]byte)(nil)).Elem()
+	typeJSONNumber = reflect.TypeOf((*json.Number)(nil)).Elem()
+
+	typeEvalCallable = reflect.TypeOf((*evalCallable)(nil))
 )
 
+// indirect dereferences pointers and interfaces. Unlike
+// jtypes.Resolve, it does not convert values that implement
+// json.Marshaler or encoding.TextMarshaler.
+func indirect(v reflect.Value) reflect.Value {
+	for (v.Kind() == reflect.Interface || v.Kind() == reflect.Ptr) && !v.IsNil() {
+		v = v.Elem()
+	}
+	return v
+}
+
+// acceptsConcreteArg returns true if param is a concrete type
+// (i.e. not an interface or a reflect.Value) that can be
+// assigned a value of type t.
+func acceptsConcreteArg(param goCallableParam, t reflect.Type) bool {
+
+	if param.isOpt || param.isVar {
+		return false
+	}
+
+	return param.t.Kind() != reflect.Interface &&
+		param.t != jtypes.TypeValue &&
+		t.AssignableTo(param.t)
+}
+
 func processGoCallableArg(arg reflect.Value, param goCallableParam) (reflect.Value, bool) {
 
 	if arg == undefined {
@@ -348,12 +406,20 @@ func processGoCallableArg(arg reflect.Value, param goCallableParam) (reflect.Val
 		return arg, true
 	case paramType == jtypes.TypeValue:
 		return reflect.ValueOf(arg), true
+	case argType == typeJSONNumber && isNumberKind(paramType.Kind()):
+		// Go can't convert a json.Number to a numeric type.
+		// Parse it instead.
+		n, ok := jtypes.AsNumber(arg)
+		if !ok {
+			break
+		}
+		return reflect.ValueOf(n).Convert(paramType), true
 	case argType.ConvertibleTo(paramType):
 		// Only allow conversion to a string if the source type
 		// is a byte slice. Go can convert other types (such as
 		// integers) to strings but this is not supported in
 		// JSONata.
-		if paramType == typeString && argType != typeByteSlice {
+		if paramType.Kind() == reflect.String && argType != typeByteSlice {
 			break
 		}
 		return arg.Convert(paramType), true
@@ -366,6 +432,17 @@ func processGoCallableArg(arg reflect.Value, param goCallableParam) (reflect.Val
 	return undefined, false
 }
 
+func isNumberKind(k reflect.Kind) bool {
+	switch k {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64,
+		reflect.Float32, reflect.Float64:
+		return true
+	default:
+		return false
+	}
+}
+
 func processUndefinedArg(param goCallableParam) (reflect.Value, bool) {
 
 	switch {
@@ -419,6 +496,19 @@ func (f *lambdaCallable) ParamCount() int {
 
 func (f *lambdaCallable) Call(argv []reflect.Value) (reflect.Value, error) {
 
+	// Stop here if the evaluation that created this function
+	// has been cancelled. This prevents recursive functions
+	// and higher order functions like $map from running on
+	// after the caller has given up.
+	if err := f.env.checkState(); err != nil {
+		return undefined, err
+	}
+
+	if err := f.env.enterCall(); err != nil {
+		return undefined, err
+	}
+	defer f.env.exitCall()
+
 	argv, err := f.validateArgs(argv)
 	if err != nil {
 		return undefined, err
@@ -513,6 +603,27 @@ func (f *lambdaCallable) validateArgCount(argv []reflect.Value) ([]reflect.Value
 	return argv, nil
 }
 
+// validLambdaArgCount returns true if a lambda with the given
+// type signature can be called with argc arguments. It follows
+// the same rules as lambdaCallable.validateArgCount.
+func validLambdaArgCount(params []jparse.Param, argc int) bool {
+
+	paramCount := len(params)
+
+	if argc < paramCount && params[0].Option == jparse.ParamContextable {
+		argc++
+	}
+
+	for argc < paramCount && params[argc].Option == jparse.ParamOptional {
+		argc++
+	}
+
+	isVar := paramCount > 0 &&
+		params[paramCount-1].Option == jparse.ParamVariadic
+
+	return argc >= paramCount && (argc == paramCount || isVar)
+}
+
 func (f *lambdaCallable) validateArgTypes(argv []reflect.Value) ([]reflect.Value, error) {
 
 	paramCount := len(f.params)
@@ -961,3 +1072,120 @@ func (f *chainCallable) Call(argv []reflect.Value) (reflect.Value, error) {
 
 	return v, nil
 }
+
+// An evalCallable represents the built-in $eval function. It
+// parses a string as a JSONata expression and evaluates it in
+// the environment of the caller, so the expression can see
+// the caller's variables, extensions and evaluation limits.
+// Errors are reported at the location of the $eval call, as
+// locations in the evaluated expression would not make sense
+// in the context of the caller's expression.
+type evalCallable struct {
+	callableName
+	callableMarshaler
+	env     *environment
+	context reflect.Value
+}
+
+func (f *evalCallable) SetContext(context reflect.Value) {
+	f.context = context
+}
+
+func (f *evalCallable) ParamCount() int {
+	return 2
+}
+
+func (f *evalCallable) Call(argv []reflect.Value) (reflect.Value, error) {
+
+	if len(argv) < 1 || len(argv) > 2 {
+		return undefined, f.argCountError(len(argv))
+	}
+
+	if argv[0] == undefined {
+		return undefined, nil
+	}
+
+	if !jtypes.IsString(argv[0]) {
+		return undefined, newArgTypeError(f, 1)
+	}
+
+	input := f.context
+	if len(argv) > 1 && argv[1] != undefined {
+		input = argv[1]
+	}
+
+	expr, _ := jtypes.AsString(argv[0])
+
+	node, err := parseEvalExpr(expr)
+	if err != nil {
+		return undefined, newEvalError(ErrEvalSyntax, nil, err.Error())
+	}
+
+	if err := f.env.enterCall(); err != nil {
+		return undefined, err
+	}
+	defer f.env.exitCall()
+
+	// Evaluate the expression in a child scope so that any
+	// variables it assigns do not leak into the caller.
+	v, err := eval(node, input, newEnvironment(f.env, 0))
+	if err != nil {
+		clearLocation(err)
+		return undefined, err
+	}
+
+	return v, nil
+}
+
+// argCountError returns an ArgCountError for a call to $eval
+// with argc arguments. The second argument is optional.
+func (f *evalCallable) argCountError(argc int) *ArgCountError {
+	err := newArgCountError(f, argc)
+	err.Optional = 1
+	return err
+}
+
+// maxEvalCacheSize is the maximum number of expressions held
+// in the $eval cache.
+const maxEvalCacheSize = 1024
+
+var evalCache = struct {
+	sync.Mutex
+	nodes map[string]jparse.Node
+}{
+	nodes: map[string]jparse.Node{},
+}
+
+// parseEvalExpr parses an expression passed to $eval. Parsed
+// expressions are cached, so an expression that is evaluated
+// repeatedly (e.g. once per item in an array) is only parsed
+// once. When the cache is full, an arbitrary entry is evicted.
+func parseEvalExpr(expr string) (jparse.Node, error) {
+
+	evalCache.Lock()
+	node, ok := evalCache.nodes[expr]
+	evalCache.Unlock()
+
+	if ok {
+		return node, nil
+	}
+
+	node, err := jparse.Parse(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	evalCache.Lock()
+	defer evalCache.Unlock()
+
+	if len(evalCache.nodes) >= maxEvalCacheSize {
+		for k := range evalCache.nodes {
+			delete(evalCache.nodes, k)
+			break
+		}
+	}
+
+	evalCache.nodes[expr] = node
+
+	return node, nil
+}
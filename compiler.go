@@ -0,0 +1,176 @@
+// Copyright 2018 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package jsonata
+
+import (
+	"reflect"
+
+	"github.com/blues/jsonata-go/jparse"
+)
+
+// A Compiler compiles JSONata expressions with a fixed set of
+// custom functions, custom variables and resource limits.
+// Unlike an Expr configured with its RegisterExts, RegisterVars
+// and SetLimits methods, an Expr returned by a Compiler cannot
+// be modified after it is created. It is safe for concurrent
+// use by multiple goroutines.
+//
+// Compilers are built up by chaining calls to the With methods:
+//
+//	c := jsonata.NewCompiler().
+//		WithExts(exts).
+//		WithVars(vars).
+//		WithLimits(limits)
+//
+//	e, err := c.Compile(`$myfunc(a.b)`)
+//
+// Each With method returns a new Compiler and leaves the
+// original unchanged, so a Compiler can be shared and used
+// as the base for other Compilers.
+type Compiler struct {
+	registry map[string]reflect.Value
+	limits   Limits
+	exact    bool
+	strict   bool
+	err      error
+}
+
+// NewCompiler returns a Compiler with no custom functions or
+// variables and no resource limits. Expressions compiled by
+// the Compiler also have access to any functions and variables
+// registered at the package level when Compile is called.
+func NewCompiler() *Compiler {
+	return &Compiler{}
+}
+
+// WithExts returns a copy of the Compiler that adds the given
+// custom functions to compiled expressions. If any of the
+// functions are invalid, the returned Compiler's Compile
+// method returns an error.
+func (c *Compiler) WithExts(exts map[string]Extension) *Compiler {
+
+	values, err := processExts(exts)
+	return c.with(values, err)
+}
+
+// WithVars returns a copy of the Compiler that adds the given
+// custom variables to compiled expressions. If any of the
+// variables are invalid, the returned Compiler's Compile
+// method returns an error.
+func (c *Compiler) WithVars(vars map[string]interface{}) *Compiler {
+
+	values, err := processVars(vars)
+	return c.with(values, err)
+}
+
+// WithLimits returns a copy of the Compiler that applies the
+// given resource limits to each evaluation of compiled
+// expressions. See the Limits type for details.
+func (c *Compiler) WithLimits(limits Limits) *Compiler {
+
+	c2 := c.clone()
+	c2.limits = limits
+	return c2
+}
+
+// WithStrict returns a copy of the Compiler that validates
+// compiled expressions (see Expr.Validate) if strict is true.
+func (c *Compiler) WithStrict(strict bool) *Compiler {
+
+	c2 := c.clone()
+	c2.strict = strict
+	return c2
+}
+
+// WithExactNumbers returns a copy of the Compiler that enables
+// exact arithmetic in compiled expressions if exact is true
+// (see Expr.SetExactNumbers).
+func (c *Compiler) WithExactNumbers(exact bool) *Compiler {
+
+	c2 := c.clone()
+	c2.exact = exact
+	return c2
+}
+
+// Compile parses a JSONata expression and returns an Expr
+// configured with the Compiler's functions, variables and
+// limits. Functions and variables added to the Compiler take
+// precedence over those registered at the package level.
+//
+// The returned Expr is immutable: its RegisterExts and
+// RegisterVars methods return ErrImmutable and its SetLimits
+// and SetExactNumbers methods panic. Use EvalWith to supply
+// variables for a single evaluation.
+func (c *Compiler) Compile(expr string) (*Expr, error) {
+
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	node, err := jparse.Parse(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	e := &Expr{
+		node:      node,
+		limits:    c.limits,
+		exact:     c.exact,
+		immutable: true,
+	}
+
+	globalRegistryMutex.RLock()
+	e.updateRegistry(globalRegistry)
+	globalRegistryMutex.RUnlock()
+
+	e.updateRegistry(c.registry)
+
+	if c.strict {
+		if err := e.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	return e, nil
+}
+
+// MustCompile is like Compile except it panics if given an
+// invalid expression.
+func (c *Compiler) MustCompile(expr string) *Expr {
+
+	e, err := c.Compile(expr)
+	if err != nil {
+		panicf("could not compile %s: %s", expr, err)
+	}
+
+	return e
+}
+
+func (c *Compiler) with(values map[string]reflect.Value, err error) *Compiler {
+
+	c2 := c.clone()
+
+	if c2.err == nil {
+		c2.err = err
+	}
+
+	if len(values) > 0 {
+		registry := make(map[string]reflect.Value, len(c.registry)+len(values))
+		for name, v := range c.registry {
+			registry[name] = v
+		}
+		for name, v := range values {
+			registry[name] = v
+		}
+		c2.registry = registry
+	}
+
+	return c2
+}
+
+func (c *Compiler) clone() *Compiler {
+	c2 := *c
+	return &c2
+}
@@ -0,0 +1,309 @@
+// Copyright 2018 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package jsonata
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestCompiler(t *testing.T) {
+
+	base := NewCompiler().WithExts(map[string]Extension{
+		"greet": {
+			Func: func(s string) string { return "hello " + s },
+		},
+	})
+
+	c := base.WithVars(map[string]interface{}{
+		"name": "world",
+	})
+
+	e, err := c.Compile(`$greet($name)`)
+	if err != nil {
+		t.Fatalf("Compile: %s", err)
+	}
+
+	output, err := e.Eval(nil)
+	if err != nil {
+		t.Fatalf("Eval: %s", err)
+	}
+
+	if output != "hello world" {
+		t.Errorf("expected output %q, got %v", "hello world", output)
+	}
+
+	// Adding variables to c must not affect base.
+	e, err = base.Compile(`$name`)
+	if err != nil {
+		t.Fatalf("Compile: %s", err)
+	}
+
+	if _, err := e.Eval(nil); err != ErrUndefined {
+		t.Errorf("expected ErrUndefined, got %v", err)
+	}
+}
+
+func TestCompilerErrors(t *testing.T) {
+
+	tests := []struct {
+		Compiler   *Compiler
+		Expression string
+		Error      string
+	}{
+		{
+			Compiler: NewCompiler().WithExts(map[string]Extension{
+				"bad name": {
+					Func: strings.ToUpper,
+				},
+			}),
+			Expression: `1`,
+			Error:      "bad name is not a valid name",
+		},
+		{
+			// The first error is reported.
+			Compiler: NewCompiler().WithVars(map[string]interface{}{
+				"first name": 1,
+			}).WithVars(map[string]interface{}{
+				"last name": 1,
+			}),
+			Expression: `1`,
+			Error:      "first name is not a valid name",
+		},
+		{
+			Compiler:   NewCompiler(),
+			Expression: `$sum(`,
+			Error:      "unexpected end of expression",
+		},
+		{
+			Compiler:   NewCompiler().WithStrict(true),
+			Expression: `$nosuchfunc()`,
+			Error:      "cannot call non-function $nosuchfunc",
+		},
+	}
+
+	for _, test := range tests {
+
+		_, err := test.Compiler.Compile(test.Expression)
+		if err == nil {
+			t.Errorf("%s: expected error, got nil", test.Expression)
+			continue
+		}
+
+		if !strings.Contains(err.Error(), test.Error) {
+			t.Errorf("%s: expected error containing %q, got %q", test.Expression, test.Error, err)
+		}
+	}
+
+	// Without strict mode, unknown functions are only
+	// reported during evaluation.
+	if _, err := NewCompiler().Compile(`$nosuchfunc()`); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+}
+
+func TestCompilerLimits(t *testing.T) {
+
+	e := NewCompiler().WithLimits(Limits{
+		MaxSteps: 3,
+	}).MustCompile(`1 + 2 + 3`)
+
+	_, err := e.Eval(nil)
+
+	exp := &EvalError{
+		Type:  ErrMaxSteps,
+		Value: "3",
+	}
+
+	clearErrorSpan(err, exp)
+
+	if !reflect.DeepEqual(err, exp) {
+		t.Errorf("expected error %v, got %v", exp, err)
+	}
+}
+
+func TestCompilerImmutable(t *testing.T) {
+
+	e := NewCompiler().MustCompile(`$x`)
+
+	if err := e.RegisterVars(map[string]interface{}{"x": 1}); err != ErrImmutable {
+		t.Errorf("RegisterVars: expected ErrImmutable, got %v", err)
+	}
+
+	if err := e.RegisterExts(map[string]Extension{"f": {Func: strings.ToUpper}}); err != ErrImmutable {
+		t.Errorf("RegisterExts: expected ErrImmutable, got %v", err)
+	}
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Errorf("SetLimits: expected panic")
+			}
+		}()
+		e.SetLimits(Limits{})
+	}()
+
+	// Variables can still be supplied per evaluation.
+	output, err := e.EvalWith(nil, EvalOptions{
+		Vars: map[string]interface{}{
+			"x": 1.0,
+		},
+	})
+	if err != nil {
+		t.Fatalf("EvalWith: %s", err)
+	}
+
+	if output != 1.0 {
+		t.Errorf("expected output 1, got %v", output)
+	}
+}
+
+// TestCompilerConcurrent evaluates expressions created by a
+// shared Compiler from many goroutines at once. It is designed
+// to be run with the race detector (go test -race), which fails
+// the test if evaluations share mutable state.
+func TestCompilerConcurrent(t *testing.T) {
+
+	c := NewCompiler().WithExts(map[string]Extension{
+		"prefix": {
+			Func: func(s string, p string) string { return p + s },
+			// Use the evaluation context if the first
+			// argument is missing.
+			EvalContextHandler: func(argv []reflect.Value) bool {
+				return len(argv) == 1
+			},
+		},
+	}).WithVars(map[string]interface{}{
+		"scale": 10.0,
+	}).WithLimits(Limits{
+		MaxDepth: 100,
+	})
+
+	expressions := []string{
+		`$sum(items.price) * $scale`,
+		`items[price > 1].name ~> $join(",")`,
+		`items.name ~> $map($uppercase) ~> $join("-")`,
+		`items^(>price).name.$prefix($tenant & ":")`,
+		`items.name.$prefix(">")`,
+		`items{name: price * $scale}`,
+		`$count(items) ~> $string() & $tenant`,
+		`$sort(items, function($a, $b) { $a.price < $b.price }).name`,
+		`$reduce(items.price, function($x, $y) { $x + $y }, 0)`,
+		`(
+			$fact := function($n) { $n <= 1 ? 1 : $n * $fact($n - 1) };
+			$fact($count(items) + 3)
+		)`,
+		`$filter(items, function($v) { $contains($v.name, /[ae]/) }).name`,
+		`$substringAfter(?, "-")($tenant)`,
+		`items ~> | $ | {"tenant": $tenant}, ["price"] |`,
+		`$replace($tenant, /(\w+)-(\d+)/, "$2-$1")`,
+		`$formatNumber($sum(items.price), "#,##0.00")`,
+		`$type($now()) & $type($millis())`,
+	}
+
+	data := map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{"name": "apple", "price": 1.5},
+			map[string]interface{}{"name": "pear", "price": 0.5},
+			map[string]interface{}{"name": "fig", "price": 2.0},
+		},
+	}
+
+	type result struct {
+		output interface{}
+		err    error
+	}
+
+	const goroutines = 8
+	const iterations = 10
+
+	var exprs []*Expr
+	var expected [goroutines][]result
+
+	for _, s := range expressions {
+
+		e, err := c.Compile(s)
+		if err != nil {
+			t.Fatalf("%s: Compile: %s", s, err)
+		}
+
+		exprs = append(exprs, e)
+
+		for i := 0; i < goroutines; i++ {
+			output, err := e.EvalWith(data, EvalOptions{
+				Vars: tenantVars(i),
+			})
+			expected[i] = append(expected[i], result{output, err})
+		}
+	}
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < goroutines; i++ {
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			for n := 0; n < iterations; n++ {
+				for j, e := range exprs {
+
+					output, err := e.EvalWith(data, EvalOptions{
+						Vars: tenantVars(i),
+					})
+
+					exp := expected[i][j]
+					if !reflect.DeepEqual(output, exp.output) || !reflect.DeepEqual(err, exp.err) {
+						t.Errorf("%s: expected %v (%v), got %v (%v)", expressions[j], exp.output, exp.err, output, err)
+						return
+					}
+				}
+			}
+		}(i)
+	}
+
+	// Compile from the shared Compiler while registering
+	// package level variables, which Compile reads.
+	for i := 0; i < goroutines; i++ {
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			for n := 0; n < iterations; n++ {
+
+				if err := RegisterVars(map[string]interface{}{
+					"compilerTestGlobal": float64(i),
+				}); err != nil {
+					t.Errorf("RegisterVars: %s", err)
+					return
+				}
+
+				e, err := c.Compile(`$scale * 2`)
+				if err != nil {
+					t.Errorf("Compile: %s", err)
+					return
+				}
+
+				output, err := e.Eval(nil)
+				if err != nil || output != 20.0 {
+					t.Errorf("expected 20, got %v (%v)", output, err)
+					return
+				}
+			}
+		}(i)
+	}
+
+	wg.Wait()
+}
+
+func tenantVars(i int) map[string]interface{} {
+	return map[string]interface{}{
+		"tenant": fmt.Sprintf("tenant-%d", i),
+	}
+}
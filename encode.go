@@ -0,0 +1,682 @@
+// Copyright 2018 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package jsonata
+
+import (
+	"bytes"
+	"context"
+	"encoding"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/blues/jsonata-go/jtypes"
+)
+
+// A KeyOrder specifies the order in which Encode writes the
+// keys of a map.
+type KeyOrder int
+
+const (
+	// KeysSorted writes map keys in sorted order, as the
+	// encoding/json package does.
+	KeysSorted KeyOrder = iota
+
+	// KeysUnsorted writes map keys in an unspecified order.
+	// It is faster than KeysSorted but the output for a
+	// given map may differ from one call to the next.
+	KeysUnsorted
+)
+
+// A NonFiniteAction specifies how Encode writes the floating
+// point values NaN, +Inf and -Inf, which have no representation
+// in JSON.
+type NonFiniteAction int
+
+const (
+	// NonFiniteError returns an error, as the encoding/json
+	// package does.
+	NonFiniteError NonFiniteAction = iota
+
+	// NonFiniteNull writes null.
+	NonFiniteNull
+
+	// NonFiniteString writes the strings "NaN", "+Inf" and
+	// "-Inf".
+	NonFiniteString
+)
+
+// EncodeOptions contains settings for Encode and EvalTo.
+type EncodeOptions struct {
+
+	// Prefix and Indent, if either is non-empty, cause the
+	// output to be indented as by json.MarshalIndent. Each
+	// line after the first begins with Prefix followed by
+	// one copy of Indent per level of nesting.
+	Prefix string
+	Indent string
+
+	// EscapeHTML, if true, escapes the characters <, > and &
+	// in strings so that the output can be embedded in HTML.
+	EscapeHTML bool
+
+	// KeyOrder determines the order of keys in objects that
+	// are encoded from maps. The default is KeysSorted. The
+	// fields of a struct are always written in the order in
+	// which they are declared.
+	KeyOrder KeyOrder
+
+	// NonFinite determines how NaN and infinite values are
+	// encoded. The default is NonFiniteError.
+	NonFinite NonFiniteAction
+}
+
+// marshalOptions produce the same output as json.Marshal.
+var marshalOptions = EncodeOptions{
+	EscapeHTML: true,
+}
+
+// maxEncodeDepth is the maximum level of nesting that Encode
+// accepts. It stops Encode from recursing forever if given a
+// value that contains a cycle.
+const maxEncodeDepth = 1000
+
+// Encode writes the JSON encoding of v to w. It is intended
+// for values returned by Eval, which it encodes in the same
+// way as the encoding/json package (subject to the options)
+// but without the overhead of json.Marshal. Struct fields are
+// encoded according to their json tags and types that
+// implement json.Marshaler or encoding.TextMarshaler encode
+// themselves.
+//
+// Unlike json.Encoder, Encode does not write a newline after
+// the encoded value.
+func Encode(w io.Writer, v interface{}, opts EncodeOptions) error {
+
+	rv, ok := v.(reflect.Value)
+	if !ok {
+		rv = reflect.ValueOf(v)
+	}
+
+	b, err := appendJSON(nil, rv, &opts)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b)
+	return err
+}
+
+// EvalTo is like Eval but it writes the JSON encoding of the
+// result to w (see Encode). This is more efficient than calling
+// Eval and encoding the result separately. If the result is
+// undefined, EvalTo writes nothing and returns ErrUndefined.
+func (e *Expr) EvalTo(w io.Writer, data interface{}, opts EncodeOptions) error {
+
+	result, err := e.evalValue(context.Background(), data, nil)
+	if err != nil {
+		return err
+	}
+
+	b, err := appendJSON(nil, result, &opts)
+	if err != nil {
+		return err
+	}
+
+	_, err = w.Write(b)
+	return err
+}
+
+// appendJSON appends the JSON encoding of v to dst.
+func appendJSON(dst []byte, v reflect.Value, opts *EncodeOptions) ([]byte, error) {
+
+	enc := encoder{
+		opts:     opts,
+		indented: opts.Prefix != "" || opts.Indent != "",
+	}
+
+	return enc.encode(dst, v, 0)
+}
+
+var (
+	typeJSONMarshaler = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	typeTextMarshaler = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+)
+
+type encoder struct {
+	opts     *EncodeOptions
+	indented bool
+}
+
+func (enc *encoder) encode(dst []byte, v reflect.Value, depth int) ([]byte, error) {
+
+	if !v.IsValid() {
+		return append(dst, "null"...), nil
+	}
+
+	if depth > maxEncodeDepth {
+		return nil, fmt.Errorf("cannot encode value: nesting exceeds %d levels", maxEncodeDepth)
+	}
+
+	if m, ok := asMarshaler(v, typeJSONMarshaler); ok {
+		if m == nil {
+			return append(dst, "null"...), nil
+		}
+		return enc.encodeMarshaler(dst, m.(json.Marshaler), depth)
+	}
+
+	if m, ok := asMarshaler(v, typeTextMarshaler); ok {
+		if m == nil {
+			return append(dst, "null"...), nil
+		}
+		b, err := m.(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return nil, &json.MarshalerError{Type: v.Type(), Err: err}
+		}
+		return enc.appendString(dst, string(b)), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Bool:
+		return strconv.AppendBool(dst, v.Bool()), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.AppendInt(dst, v.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.AppendUint(dst, v.Uint(), 10), nil
+	case reflect.Float32, reflect.Float64:
+		return enc.appendFloat(dst, v.Float(), v.Type().Bits())
+	case reflect.String:
+		if v.Type() == typeJSONNumber {
+			return appendNumber(dst, v.String())
+		}
+		return enc.appendString(dst, v.String()), nil
+	case reflect.Interface, reflect.Ptr:
+		if v.IsNil() {
+			return append(dst, "null"...), nil
+		}
+		return enc.encode(dst, v.Elem(), depth)
+	case reflect.Slice:
+		if v.IsNil() {
+			return append(dst, "null"...), nil
+		}
+		if v.Type().Elem().Kind() == reflect.Uint8 && !isMarshaler(reflect.PtrTo(v.Type().Elem())) {
+			return enc.appendBytes(dst, v.Bytes()), nil
+		}
+		return enc.encodeArray(dst, v, depth)
+	case reflect.Array:
+		return enc.encodeArray(dst, v, depth)
+	case reflect.Map:
+		if v.IsNil() {
+			return append(dst, "null"...), nil
+		}
+		return enc.encodeMap(dst, v, depth)
+	case reflect.Struct:
+		return enc.encodeStruct(dst, v, depth)
+	default:
+		return nil, &json.UnsupportedTypeError{Type: v.Type()}
+	}
+}
+
+func (enc *encoder) encodeArray(dst []byte, v reflect.Value, depth int) ([]byte, error) {
+
+	n := v.Len()
+	if n == 0 {
+		return append(dst, "[]"...), nil
+	}
+
+	var err error
+	dst = append(dst, '[')
+
+	for i := 0; i < n; i++ {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = enc.appendNewline(dst, depth+1)
+		dst, err = enc.encode(dst, v.Index(i), depth+1)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	dst = enc.appendNewline(dst, depth)
+	return append(dst, ']'), nil
+}
+
+type mapEntry struct {
+	key   string
+	value reflect.Value
+}
+
+func (enc *encoder) encodeMap(dst []byte, v reflect.Value, depth int) ([]byte, error) {
+
+	if v.Len() == 0 {
+		return append(dst, "{}"...), nil
+	}
+
+	entries := make([]mapEntry, 0, v.Len())
+
+	for it := v.MapRange(); it.Next(); {
+		key, err := mapKey(it.Key())
+		if err != nil {
+			return nil, err
+		}
+		entries = append(entries, mapEntry{
+			key:   key,
+			value: it.Value(),
+		})
+	}
+
+	if enc.opts.KeyOrder == KeysSorted {
+		sort.Slice(entries, func(i, j int) bool {
+			return entries[i].key < entries[j].key
+		})
+	}
+
+	var err error
+	dst = append(dst, '{')
+
+	for i, e := range entries {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		dst = enc.appendKey(dst, e.key, depth+1)
+		dst, err = enc.encode(dst, e.value, depth+1)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	dst = enc.appendNewline(dst, depth)
+	return append(dst, '}'), nil
+}
+
+func (enc *encoder) encodeStruct(dst []byte, v reflect.Value, depth int) ([]byte, error) {
+
+	var err error
+	empty := true
+	dst = append(dst, '{')
+
+	for _, f := range jtypes.StructFields(v.Type()) {
+
+		fv := f.Value(v)
+		if !fv.IsValid() {
+			continue
+		}
+
+		if !empty {
+			dst = append(dst, ',')
+		}
+		empty = false
+
+		dst = enc.appendKey(dst, f.Name, depth+1)
+		if f.Quoted {
+			dst, err = enc.encodeQuoted(dst, fv, depth+1)
+		} else {
+			dst, err = enc.encode(dst, fv, depth+1)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if !empty {
+		dst = enc.appendNewline(dst, depth)
+	}
+
+	return append(dst, '}'), nil
+}
+
+// encodeQuoted encodes the value of a struct field that has
+// the string option (see jtypes.StructField). As with the
+// encoding/json package, the value is encoded as JSON and the
+// result is written as a JSON string. Nil pointers and types
+// that marshal themselves are encoded as usual.
+func (enc *encoder) encodeQuoted(dst []byte, v reflect.Value, depth int) ([]byte, error) {
+
+	if v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return append(dst, "null"...), nil
+		}
+		v = v.Elem()
+	}
+
+	if _, ok := asMarshaler(v, typeJSONMarshaler); ok {
+		return enc.encode(dst, v, depth)
+	}
+
+	if _, ok := asMarshaler(v, typeTextMarshaler); ok {
+		return enc.encode(dst, v, depth)
+	}
+
+	b, err := enc.encode(nil, v, depth)
+	if err != nil {
+		return nil, err
+	}
+
+	return enc.appendString(dst, string(b)), nil
+}
+
+func (enc *encoder) encodeMarshaler(dst []byte, m json.Marshaler, depth int) ([]byte, error) {
+
+	b, err := m.MarshalJSON()
+	if err != nil {
+		return nil, &json.MarshalerError{Type: reflect.TypeOf(m), Err: err}
+	}
+
+	var buf bytes.Buffer
+
+	if err := json.Compact(&buf, b); err != nil {
+		return nil, &json.MarshalerError{Type: reflect.TypeOf(m), Err: err}
+	}
+
+	if enc.opts.EscapeHTML {
+		b = buf.Bytes()
+		buf = bytes.Buffer{}
+		json.HTMLEscape(&buf, b)
+	}
+
+	if enc.indented {
+		b = buf.Bytes()
+		buf = bytes.Buffer{}
+		prefix := enc.opts.Prefix + strings.Repeat(enc.opts.Indent, depth)
+		if err := json.Indent(&buf, b, prefix, enc.opts.Indent); err != nil {
+			return nil, err
+		}
+	}
+
+	return append(dst, buf.Bytes()...), nil
+}
+
+func (enc *encoder) appendFloat(dst []byte, f float64, bits int) ([]byte, error) {
+
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		switch enc.opts.NonFinite {
+		case NonFiniteNull:
+			return append(dst, "null"...), nil
+		case NonFiniteString:
+			return enc.appendString(dst, nonFiniteString(f)), nil
+		}
+		return nil, &json.UnsupportedValueError{
+			Value: reflect.ValueOf(f),
+			Str:   nonFiniteString(f),
+		}
+	}
+
+	// Use the same format as the encoding/json package:
+	// exponents for very large and very small numbers and
+	// decimals for everything else.
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 {
+		if bits == 64 && (abs < 1e-6 || abs >= 1e21) ||
+			bits == 32 && (float32(abs) < 1e-6 || float32(abs) >= 1e21) {
+			format = 'e'
+		}
+	}
+
+	dst = strconv.AppendFloat(dst, f, format, -1, bits)
+
+	if format == 'e' {
+		// Convert e-09 to e-9.
+		n := len(dst)
+		if n >= 4 && dst[n-4] == 'e' && dst[n-3] == '-' && dst[n-2] == '0' {
+			dst[n-2] = dst[n-1]
+			dst = dst[:n-1]
+		}
+	}
+
+	return dst, nil
+}
+
+func nonFiniteString(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	default:
+		return "NaN"
+	}
+}
+
+const hexDigits = "0123456789abcdef"
+
+func (enc *encoder) appendString(dst []byte, s string) []byte {
+
+	dst = append(dst, '"')
+	start := 0
+
+	for i := 0; i < len(s); {
+
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' &&
+				(!enc.opts.EscapeHTML || (b != '<' && b != '>' && b != '&')) {
+				i++
+				continue
+			}
+
+			dst = append(dst, s[start:i]...)
+
+			switch b {
+			case '"', '\\':
+				dst = append(dst, '\\', b)
+			case '\b':
+				dst = append(dst, '\\', 'b')
+			case '\f':
+				dst = append(dst, '\\', 'f')
+			case '\n':
+				dst = append(dst, '\\', 'n')
+			case '\r':
+				dst = append(dst, '\\', 'r')
+			case '\t':
+				dst = append(dst, '\\', 't')
+			default:
+				dst = append(dst, '\\', 'u', '0', '0', hexDigits[b>>4], hexDigits[b&0xF])
+			}
+
+			i++
+			start = i
+			continue
+		}
+
+		r, size := utf8.DecodeRuneInString(s[i:])
+
+		switch {
+		case r == utf8.RuneError && size == 1:
+			// Replace invalid UTF-8 with the Unicode
+			// replacement character.
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, `\ufffd`...)
+			start = i + size
+		case r == '\u2028' || r == '\u2029':
+			// Line and paragraph separators are valid in
+			// JSON but not in JavaScript source.
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, '\\', 'u', '2', '0', '2', hexDigits[r&0xF])
+			start = i + size
+		}
+
+		i += size
+	}
+
+	dst = append(dst, s[start:]...)
+	return append(dst, '"')
+}
+
+func (enc *encoder) appendBytes(dst []byte, b []byte) []byte {
+
+	dst = append(dst, '"')
+
+	n := base64.StdEncoding.EncodedLen(len(b))
+	dst = append(dst, make([]byte, n)...)
+	base64.StdEncoding.Encode(dst[len(dst)-n:], b)
+
+	return append(dst, '"')
+}
+
+func (enc *encoder) appendKey(dst []byte, key string, depth int) []byte {
+
+	dst = enc.appendNewline(dst, depth)
+	dst = enc.appendString(dst, key)
+	dst = append(dst, ':')
+
+	if enc.indented {
+		dst = append(dst, ' ')
+	}
+
+	return dst
+}
+
+func (enc *encoder) appendNewline(dst []byte, depth int) []byte {
+
+	if !enc.indented {
+		return dst
+	}
+
+	dst = append(dst, '\n')
+	dst = append(dst, enc.opts.Prefix...)
+
+	for i := 0; i < depth; i++ {
+		dst = append(dst, enc.opts.Indent...)
+	}
+
+	return dst
+}
+
+// appendNumber appends a json.Number to dst. As with the
+// encoding/json package, an empty number is encoded as 0.
+func appendNumber(dst []byte, s string) ([]byte, error) {
+
+	if s == "" {
+		s = "0"
+	}
+
+	if !isValidNumber(s) {
+		return nil, fmt.Errorf("json: invalid number literal %q", s)
+	}
+
+	return append(dst, s...), nil
+}
+
+// isValidNumber returns true if s is a valid JSON number.
+func isValidNumber(s string) bool {
+
+	if s != "" && s[0] == '-' {
+		s = s[1:]
+	}
+
+	if s == "" {
+		return false
+	}
+
+	switch {
+	case s[0] == '0':
+		s = s[1:]
+	case '1' <= s[0] && s[0] <= '9':
+		s = skipDigits(s[1:])
+	default:
+		return false
+	}
+
+	if len(s) >= 2 && s[0] == '.' && isDigitByte(s[1]) {
+		s = skipDigits(s[2:])
+	}
+
+	if len(s) >= 2 && (s[0] == 'e' || s[0] == 'E') {
+		s = s[1:]
+		if s[0] == '+' || s[0] == '-' {
+			s = s[1:]
+		}
+		if s == "" || !isDigitByte(s[0]) {
+			return false
+		}
+		s = skipDigits(s)
+	}
+
+	return s == ""
+}
+
+func skipDigits(s string) string {
+	for s != "" && isDigitByte(s[0]) {
+		s = s[1:]
+	}
+	return s
+}
+
+func isDigitByte(b byte) bool {
+	return '0' <= b && b <= '9'
+}
+
+// mapKey returns the JSON object key for a map key. As with
+// the encoding/json package, keys must be strings, integers
+// or types that implement encoding.TextMarshaler.
+func mapKey(k reflect.Value) (string, error) {
+
+	if k.Kind() == reflect.String {
+		return k.String(), nil
+	}
+
+	if m, ok := asMarshaler(k, typeTextMarshaler); ok {
+		if m == nil {
+			return "", nil
+		}
+		b, err := m.(encoding.TextMarshaler).MarshalText()
+		if err != nil {
+			return "", &json.MarshalerError{Type: k.Type(), Err: err}
+		}
+		return string(b), nil
+	}
+
+	switch k.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return strconv.FormatInt(k.Int(), 10), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return strconv.FormatUint(k.Uint(), 10), nil
+	default:
+		return "", &json.UnsupportedTypeError{Type: k.Type()}
+	}
+}
+
+// asMarshaler returns v as an implementation of the interface
+// type iface if possible. As with the encoding/json package,
+// methods with pointer receivers are only used if v is
+// addressable. If v is a nil pointer, asMarshaler returns
+// a nil interface and true.
+func asMarshaler(v reflect.Value, iface reflect.Type) (interface{}, bool) {
+
+	if !v.CanInterface() {
+		return nil, false
+	}
+
+	t := v.Type()
+
+	if t.Implements(iface) {
+		if t.Kind() == reflect.Ptr && v.IsNil() {
+			return nil, true
+		}
+		if t.Kind() == reflect.Interface {
+			// Let encode dereference the interface so
+			// that nil interfaces are handled correctly.
+			return nil, false
+		}
+		return v.Interface(), true
+	}
+
+	if t.Kind() != reflect.Ptr && v.CanAddr() && reflect.PtrTo(t).Implements(iface) {
+		return v.Addr().Interface(), true
+	}
+
+	return nil, false
+}
+
+func isMarshaler(t reflect.Type) bool {
+	return t.Implements(typeJSONMarshaler) || t.Implements(typeTextMarshaler)
+}
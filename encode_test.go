@@ -0,0 +1,303 @@
+// Copyright 2018 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package jsonata
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"strings"
+	"testing"
+	"time"
+)
+
+type encodeEmbedded struct {
+	ID   int    `json:"id"`
+	Note string `json:"note,omitempty"`
+}
+
+type encodeStruct struct {
+	encodeEmbedded
+	Name    string            `json:"name"`
+	Tags    []string          `json:"tags"`
+	Attrs   map[string]string `json:"attrs,omitempty"`
+	Ignored string            `json:"-"`
+	Raw     []byte
+	Ptr     *float64
+	private int
+}
+
+type encodeQuoted struct {
+	Int    int            `json:"int,string"`
+	Float  float64        `json:"float,string"`
+	Bool   bool           `json:",string"`
+	String string         `json:"string,string"`
+	Number json.Number    `json:"number,string"`
+	Ptr    *int           `json:"ptr,string"`
+	Nil    *int           `json:"nil,string"`
+	Empty  int            `json:"empty,omitempty,string"`
+	Time   time.Time      `json:"time,string"`
+	Slice  []int          `json:"slice,string"`
+	Map    map[string]int `json:"map,string"`
+	Iface  interface{}    `json:"iface,string"`
+}
+
+type encodeKey int
+
+func (k encodeKey) MarshalText() ([]byte, error) {
+	return []byte("key-" + string(rune('a'+k))), nil
+}
+
+func TestEncodeMarshalCompatible(t *testing.T) {
+
+	pi := 3.14
+
+	values := []interface{}{
+		nil,
+		true,
+		0,
+		-42,
+		uint8(255),
+		0.0,
+		1.5,
+		-0.000001,
+		0.0000001,
+		1e20,
+		1e21,
+		123456789.125,
+		float32(0.1),
+		float32(1e-7),
+		"",
+		"hello, world",
+		"quotes \" and \\ backslashes",
+		"tabs\tand\nnewlines\r",
+		"<html> & </html>",
+		"control \x01 \x1f chars",
+		"line\u2028separator\u2029",
+		"unicode: é世\U0001f600",
+		json.Number("12345678901234567890"),
+		json.Number("-1.5e-10"),
+		[]interface{}{},
+		[]interface{}{1.0, "two", []interface{}{3.0}, map[string]interface{}{}},
+		[]byte("bytes"),
+		[]int(nil),
+		[2]string{"a", "b"},
+		map[string]interface{}{
+			"z": 1.0,
+			"a": []interface{}{true, nil},
+			"m": map[string]interface{}{
+				"nested": "value",
+			},
+		},
+		map[int]string{10: "ten", 2: "two", -1: "minus one"},
+		map[encodeKey]int{2: 2, 0: 0, 1: 1},
+		map[string]interface{}(nil),
+		encodeStruct{
+			encodeEmbedded: encodeEmbedded{ID: 7},
+			Name:           "name",
+			Tags:           []string{"x", "y"},
+			Raw:            []byte{0, 1, 2, 3, 4},
+			Ptr:            &pi,
+		},
+		&encodeStruct{
+			Attrs: map[string]string{"k": "v"},
+		},
+		encodeQuoted{
+			Int:    7,
+			Float:  1.5e-7,
+			Bool:   true,
+			String: `<"quoted">`,
+			Number: json.Number("12345678901234567890"),
+			Ptr:    new(int),
+			Time:   time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+			Slice:  []int{1, 2},
+			Map:    map[string]int{"a": 1},
+			Iface:  8,
+		},
+		time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		json.RawMessage(`{ "a" : [ 1, 2 ], "b" : "<b>" }`),
+		struct{}{},
+	}
+
+	for _, v := range values {
+
+		exp, err := json.Marshal(v)
+		if err != nil {
+			t.Fatalf("json.Marshal(%#v): %s", v, err)
+		}
+
+		var got bytes.Buffer
+		if err := Encode(&got, v, marshalOptions); err != nil {
+			t.Errorf("Encode(%#v): %s", v, err)
+			continue
+		}
+
+		if got.String() != string(exp) {
+			t.Errorf("Encode(%#v): expected %s, got %s", v, exp, got.String())
+		}
+
+		exp, err = json.MarshalIndent(v, "> ", "\t")
+		if err != nil {
+			t.Fatalf("json.MarshalIndent(%#v): %s", v, err)
+		}
+
+		got.Reset()
+		if err := Encode(&got, v, EncodeOptions{
+			Prefix:     "> ",
+			Indent:     "\t",
+			EscapeHTML: true,
+		}); err != nil {
+			t.Errorf("Encode(%#v): %s", v, err)
+			continue
+		}
+
+		if got.String() != string(exp) {
+			t.Errorf("Encode(%#v) with indent: expected\n%s\ngot\n%s", v, exp, got.String())
+		}
+	}
+}
+
+func TestEncodeOptions(t *testing.T) {
+
+	tests := []struct {
+		Value   interface{}
+		Options EncodeOptions
+		Output  string
+		Error   string
+	}{
+		{
+			Value:  "<a & b>",
+			Output: `"<a & b>"`,
+		},
+		{
+			Value: "<a & b>",
+			Options: EncodeOptions{
+				EscapeHTML: true,
+			},
+			Output: `"\u003ca \u0026 b\u003e"`,
+		},
+		{
+			// Invalid UTF-8 is replaced with U+FFFD.
+			Value:  "invalid \xff utf-8",
+			Output: `"invalid \ufffd utf-8"`,
+		},
+		{
+			Value: map[string]interface{}{
+				"b": 1,
+				"a": 2,
+			},
+			Options: EncodeOptions{
+				Indent: "  ",
+			},
+			Output: "{\n  \"a\": 2,\n  \"b\": 1\n}",
+		},
+		{
+			Value:  []interface{}{math.NaN()},
+			Output: "",
+			Error:  "json: unsupported value: NaN",
+		},
+		{
+			Value: []interface{}{math.NaN(), math.Inf(1), math.Inf(-1)},
+			Options: EncodeOptions{
+				NonFinite: NonFiniteNull,
+			},
+			Output: `[null,null,null]`,
+		},
+		{
+			Value: []interface{}{math.NaN(), math.Inf(1), math.Inf(-1)},
+			Options: EncodeOptions{
+				NonFinite: NonFiniteString,
+			},
+			Output: `["NaN","+Inf","-Inf"]`,
+		},
+		{
+			Value: map[string]interface{}{
+				"a": 1,
+			},
+			Options: EncodeOptions{
+				KeyOrder: KeysUnsorted,
+			},
+			Output: `{"a":1}`,
+		},
+		{
+			Value: json.Number("1x"),
+			Error: `json: invalid number literal "1x"`,
+		},
+		{
+			Value: func() {},
+			Error: "json: unsupported type: func()",
+		},
+	}
+
+	for _, test := range tests {
+
+		var w bytes.Buffer
+		err := Encode(&w, test.Value, test.Options)
+
+		if w.String() != test.Output {
+			t.Errorf("Encode(%#v): expected output %q, got %q", test.Value, test.Output, w.String())
+		}
+
+		switch {
+		case test.Error == "" && err != nil:
+			t.Errorf("Encode(%#v): unexpected error: %s", test.Value, err)
+		case test.Error != "" && (err == nil || err.Error() != test.Error):
+			t.Errorf("Encode(%#v): expected error %q, got %v", test.Value, test.Error, err)
+		}
+	}
+}
+
+func TestEncodeUnsortedKeys(t *testing.T) {
+
+	m := map[string]interface{}{}
+	for _, k := range strings.Split("abcdefghijklmnopqrstuvwxyz", "") {
+		m[k] = k
+	}
+
+	var w bytes.Buffer
+	if err := Encode(&w, m, EncodeOptions{KeyOrder: KeysUnsorted}); err != nil {
+		t.Fatalf("Encode: %s", err)
+	}
+
+	var got map[string]interface{}
+	if err := json.Unmarshal(w.Bytes(), &got); err != nil {
+		t.Fatalf("Unmarshal: %s", err)
+	}
+
+	if len(got) != len(m) {
+		t.Errorf("expected %d keys, got %d", len(m), len(got))
+	}
+}
+
+func TestEvalTo(t *testing.T) {
+
+	e := MustCompile(`{"total": $sum(items.price), "names": items.name}`)
+
+	data := map[string]interface{}{
+		"items": []interface{}{
+			map[string]interface{}{"name": "a", "price": 1.5},
+			map[string]interface{}{"name": "b", "price": 2.0},
+		},
+	}
+
+	var w bytes.Buffer
+	if err := e.EvalTo(&w, data, EncodeOptions{}); err != nil {
+		t.Fatalf("EvalTo: %s", err)
+	}
+
+	exp := `{"names":["a","b"],"total":3.5}`
+	if w.String() != exp {
+		t.Errorf("expected %s, got %s", exp, w.String())
+	}
+
+	w.Reset()
+	if err := MustCompile(`nothing`).EvalTo(&w, data, EncodeOptions{}); err != ErrUndefined {
+		t.Errorf("expected ErrUndefined, got %v", err)
+	}
+
+	if w.Len() != 0 {
+		t.Errorf("expected no output, got %s", w.String())
+	}
+}
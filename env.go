@@ -5,9 +5,11 @@
 package jsonata
 
 import (
+	"context"
 	"errors"
 	"math"
 	"reflect"
+	"strconv"
 	"strings"
 	"unicode/utf8"
 
@@ -19,13 +21,21 @@ import (
 type environment struct {
 	parent  *environment
 	symbols map[string]reflect.Value
+	state   *evalState
 }
 
 func newEnvironment(parent *environment, size int) *environment {
-	return &environment{
+
+	env := &environment{
 		parent:  parent,
 		symbols: make(map[string]reflect.Value, size),
 	}
+
+	if parent != nil {
+		env.state = parent.state
+	}
+
+	return env
 }
 
 func (s *environment) bind(name string, value reflect.Value) {
@@ -46,6 +56,15 @@ func (s *environment) bindAll(values map[string]reflect.Value) {
 	}
 }
 
+// checkState returns an error if the evaluation that this
+// environment belongs to should not continue.
+func (s *environment) checkState() error {
+	if s == nil || s.state == nil {
+		return nil
+	}
+	return s.state.check()
+}
+
 func (s *environment) lookup(name string) reflect.Value {
 
 	if v, ok := s.symbols[name]; ok {
@@ -58,6 +77,160 @@ func (s *environment) lookup(name string) reflect.Value {
 	return undefined
 }
 
+// enterCall records the start of a function call. It returns
+// an error if the call would exceed the maximum call depth.
+// Every successful call to enterCall must be paired with a
+// call to exitCall.
+func (s *environment) enterCall() error {
+
+	if s == nil || s.state == nil {
+		return nil
+	}
+
+	st := s.state
+	if st.limits.MaxDepth > 0 && st.depth >= st.limits.MaxDepth {
+		return newEvalError(ErrMaxDepth, nil, strconv.Itoa(st.limits.MaxDepth))
+	}
+
+	st.depth++
+	return nil
+}
+
+// exitCall records the end of a function call started with
+// enterCall.
+func (s *environment) exitCall() {
+	if s != nil && s.state != nil {
+		s.state.depth--
+	}
+}
+
+// maxArrayLength returns the maximum number of items that an
+// array may hold during the current evaluation. A return value
+// of zero means that there is no limit.
+func (s *environment) maxArrayLength() int {
+	if s == nil || s.state == nil {
+		return 0
+	}
+	return s.state.limits.MaxArrayLength
+}
+
+// maxStringLength returns the maximum length, in bytes, of
+// any string produced during the current evaluation. A return
+// value of zero means that there is no limit.
+func (s *environment) maxStringLength() int {
+	if s == nil || s.state == nil {
+		return 0
+	}
+	return s.state.limits.MaxStringLength
+}
+
+// exactNumbers returns true if the current evaluation uses
+// exact arithmetic (see Expr.SetExactNumbers).
+func (s *environment) exactNumbers() bool {
+	return s != nil && s.state != nil && s.state.exact
+}
+
+// ancestry returns the ancestors of the current context
+// value.
+func (s *environment) ancestry() *ancestor {
+	if s == nil || s.state == nil {
+		return nil
+	}
+	return s.state.ancestry
+}
+
+// setAncestry sets the ancestors of the current context
+// value. Callers that change the context value should use
+// this method to record its ancestors and restore the
+// previous ancestry when they are done.
+func (s *environment) setAncestry(a *ancestor) {
+	if s != nil && s.state != nil {
+		s.state.ancestry = a
+	}
+}
+
+// An evalState holds information about a single evaluation
+// of an Expr. It is shared by all of the environments that
+// are created during the evaluation.
+type evalState struct {
+	ctx    context.Context
+	done   <-chan struct{}
+	limits Limits
+	exact  bool
+	steps  int
+	depth  int
+
+	// ancestry holds the ancestors of the value currently
+	// being evaluated. See the parent operator (%).
+	ancestry *ancestor
+}
+
+func newEvalState(ctx context.Context, limits Limits, exact bool) *evalState {
+	return &evalState{
+		ctx:    ctx,
+		done:   ctx.Done(),
+		limits: limits,
+		exact:  exact,
+	}
+}
+
+// check returns an error if the evaluation's context has
+// been cancelled, its deadline has passed or it has used
+// up its allowance of steps.
+func (s *evalState) check() error {
+
+	s.steps++
+	if s.limits.MaxSteps > 0 && s.steps > s.limits.MaxSteps {
+		return newEvalError(ErrMaxSteps, nil, strconv.Itoa(s.limits.MaxSteps))
+	}
+
+	select {
+	case <-s.done:
+	default:
+		return nil
+	}
+
+	return contextError(s.ctx)
+}
+
+// contextError returns an EvalError describing why the given
+// context is done, or nil if the context is not done.
+func contextError(ctx context.Context) error {
+
+	switch ctx.Err() {
+	case nil:
+		return nil
+	case context.DeadlineExceeded:
+		return newEvalError(ErrDeadlineExceeded, nil, nil)
+	default:
+		return newEvalError(ErrCanceled, nil, nil)
+	}
+}
+
+// checkResult returns an error if the given value exceeds
+// the evaluation's array or string length limits.
+func (s *evalState) checkResult(v reflect.Value) error {
+
+	if s.limits.MaxArrayLength == 0 && s.limits.MaxStringLength == 0 {
+		return nil
+	}
+
+	v = jtypes.Resolve(v)
+
+	switch {
+	case s.limits.MaxArrayLength > 0 && v.Kind() == reflect.Slice:
+		if v.Len() > s.limits.MaxArrayLength {
+			return newEvalError(ErrMaxArrayLength, nil, strconv.Itoa(s.limits.MaxArrayLength))
+		}
+	case s.limits.MaxStringLength > 0 && v.Kind() == reflect.String:
+		if v.Len() > s.limits.MaxStringLength {
+			return newEvalError(ErrMaxStringLength, nil, strconv.Itoa(s.limits.MaxStringLength))
+		}
+	}
+
+	return nil
+}
+
 var (
 	defaultUndefinedHandler = jtypes.ArgUndefined(0)
 	defaultContextHandler   = jtypes.ArgCountEquals(0)
@@ -149,6 +322,16 @@ var baseEnv = initBaseEnv(map[string]Extension{
 		UndefinedHandler:   defaultUndefinedHandler,
 		EvalContextHandler: defaultContextHandler,
 	},
+	"formatInteger": {
+		Func:               jlib.FormatInteger,
+		UndefinedHandler:   defaultUndefinedHandler,
+		EvalContextHandler: argCountEquals1,
+	},
+	"parseInteger": {
+		Func:               jlib.ParseInteger,
+		UndefinedHandler:   defaultUndefinedHandler,
+		EvalContextHandler: argCountEquals1,
+	},
 	"base64encode": {
 		Func:               jlib.Base64Encode,
 		UndefinedHandler:   defaultUndefinedHandler,
@@ -362,12 +545,42 @@ var baseEnv = initBaseEnv(map[string]Extension{
 	// runtime.
 
 	"fromMillis": {
-		Func:               jlib.FromMillis,
+		Func:               jlib.FromMillisLanguage,
 		UndefinedHandler:   defaultUndefinedHandler,
 		EvalContextHandler: defaultContextHandler,
 	},
 	"toMillis": {
-		Func:               jlib.ToMillis,
+		Func:               jlib.ToMillisLanguage,
+		UndefinedHandler:   defaultUndefinedHandler,
+		EvalContextHandler: defaultContextHandler,
+	},
+	"dateAdd": {
+		Func:               jlib.DateAdd,
+		UndefinedHandler:   defaultUndefinedHandler,
+		EvalContextHandler: nil,
+	},
+	"dateTrunc": {
+		Func:               jlib.DateTrunc,
+		UndefinedHandler:   defaultUndefinedHandler,
+		EvalContextHandler: nil,
+	},
+	"dateDiff": {
+		Func:               jlib.DateDiff,
+		UndefinedHandler:   undefinedHandlerDateDiff,
+		EvalContextHandler: nil,
+	},
+	"datePart": {
+		Func:               jlib.DatePart,
+		UndefinedHandler:   defaultUndefinedHandler,
+		EvalContextHandler: nil,
+	},
+	"parseDuration": {
+		Func:               jlib.ParseDuration,
+		UndefinedHandler:   defaultUndefinedHandler,
+		EvalContextHandler: defaultContextHandler,
+	},
+	"formatDuration": {
+		Func:               jlib.FormatDuration,
 		UndefinedHandler:   defaultUndefinedHandler,
 		EvalContextHandler: defaultContextHandler,
 	},
@@ -387,9 +600,150 @@ var baseEnv = initBaseEnv(map[string]Extension{
 	},
 })
 
+// exactEnv replaces the numeric functions in baseEnv with
+// versions that operate on exact values. It is used in place
+// of baseEnv when an Expr uses exact numbers.
+var exactEnv = initEnv(baseEnv, map[string]Extension{
+	"formatNumber": {
+		Func:               jlib.FormatNumberExact,
+		UndefinedHandler:   defaultUndefinedHandler,
+		EvalContextHandler: contextHandlerFormatNumber,
+	},
+	"round": {
+		Func:               jlib.RoundExact,
+		UndefinedHandler:   defaultUndefinedHandler,
+		EvalContextHandler: defaultContextHandler,
+	},
+	"sum": {
+		Func:               jlib.SumExact,
+		UndefinedHandler:   defaultUndefinedHandler,
+		EvalContextHandler: nil,
+	},
+	"max": {
+		Func:               jlib.MaxExact,
+		UndefinedHandler:   defaultUndefinedHandler,
+		EvalContextHandler: nil,
+	},
+	"min": {
+		Func:               jlib.MinExact,
+		UndefinedHandler:   defaultUndefinedHandler,
+		EvalContextHandler: nil,
+	},
+	"average": {
+		Func:               jlib.AverageExact,
+		UndefinedHandler:   defaultUndefinedHandler,
+		EvalContextHandler: nil,
+	},
+	"number": {
+		Func:               jlib.NumberExact,
+		UndefinedHandler:   defaultUndefinedHandler,
+		EvalContextHandler: defaultContextHandler,
+	},
+})
+
+// limitedStringExts returns versions of the string functions
+// in baseEnv that check the MaxStringLength limit before they
+// allocate their results. The evaluator checks the length of
+// every result (see checkResult), but by then a string that
+// is far too long has already been allocated.
+func limitedStringExts(max int) map[string]Extension {
+	return map[string]Extension{
+		"pad": {
+			Func: func(s string, width int, chars jtypes.OptionalString) (string, error) {
+				if padExceeds(s, width, chars.String, max) {
+					return "", newEvalError(ErrMaxStringLength, nil, strconv.Itoa(max))
+				}
+				return jlib.Pad(s, width, chars), nil
+			},
+			UndefinedHandler:   defaultUndefinedHandler,
+			EvalContextHandler: contextHandlerPad,
+		},
+		"join": {
+			Func: func(values reflect.Value, separator jtypes.OptionalString) (string, error) {
+				if joinExceeds(values, separator.String, max) {
+					return "", newEvalError(ErrMaxStringLength, nil, strconv.Itoa(max))
+				}
+				return jlib.Join(values, separator)
+			},
+			UndefinedHandler:   defaultUndefinedHandler,
+			EvalContextHandler: nil,
+		},
+	}
+}
+
+// padExceeds returns true if the string returned by jlib.Pad
+// would be longer than max bytes.
+func padExceeds(s string, width int, chars string, max int) bool {
+
+	if width < 0 {
+		width = -width
+	}
+
+	padlen := width - utf8.RuneCountInString(s)
+	if padlen <= 0 {
+		return len(s) > max
+	}
+
+	if chars == "" {
+		chars = " "
+	}
+
+	// The padding is made of whole copies of chars plus the
+	// first few runes of chars. Check the number of copies
+	// first so that the size calculation cannot overflow.
+	runes := []rune(chars)
+	copies := padlen / len(runes)
+	if copies > max {
+		return true
+	}
+
+	size := len(s) + copies*len(chars) + len(string(runes[:padlen%len(runes)]))
+	return size > max
+}
+
+// joinExceeds returns true if the string returned by jlib.Join
+// would be longer than max bytes.
+func joinExceeds(values reflect.Value, separator string, max int) bool {
+
+	if !jtypes.IsArray(values) {
+		return false
+	}
+
+	values = jtypes.Resolve(values)
+
+	var size int
+	for i := 0; i < values.Len(); i++ {
+		s, _ := jtypes.AsString(values.Index(i))
+		if i > 0 {
+			size += len(separator)
+		}
+		size += len(s)
+		if size > max {
+			return true
+		}
+	}
+
+	return false
+}
+
 func initBaseEnv(exts map[string]Extension) *environment {
 
-	env := newEnvironment(nil, len(exts))
+	env := initEnv(nil, exts)
+
+	// $eval is not a Go extension because it needs access
+	// to the evaluator. See evalCallable.
+	env.bind("eval", reflect.ValueOf(&evalCallable{
+		callableName: callableName{
+			name: "eval",
+		},
+	}))
+
+	return env
+}
+
+func initEnv(parent *environment, exts map[string]Extension) *environment {
+
+	env := newEnvironment(parent, len(exts))
 
 	for name, ext := range exts {
 		fn := mustGoCallable(name, ext)
@@ -439,6 +793,13 @@ func undefinedHandlerAppend(argv []reflect.Value) bool {
 	return len(argv) == 2 && argv[0] == undefined && argv[1] == undefined
 }
 
+func undefinedHandlerDateDiff(argv []reflect.Value) bool {
+
+	// If either of the times passed to dateDiff() is
+	// undefined, return undefined.
+	return len(argv) >= 2 && (argv[0] == undefined || argv[1] == undefined)
+}
+
 // Context handlers
 
 func contextHandlerSubstring(argv []reflect.Value) bool {
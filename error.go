@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"regexp"
 
+	"github.com/blues/jsonata-go/jparse"
 	"github.com/blues/jsonata-go/jtypes"
 )
 
@@ -22,6 +23,11 @@ import (
 // called with undefined inputs.
 var ErrUndefined = errors.New("no results found")
 
+// ErrImmutable is returned by the RegisterExts and RegisterVars
+// methods of an Expr that was created by a Compiler. Such an
+// Expr cannot be modified.
+var ErrImmutable = errors.New("cannot modify an Expr created by a Compiler")
+
 // ErrType indicates the reason for an error.
 type ErrType uint
 
@@ -47,6 +53,13 @@ const (
 	ErrIllegalDelete
 	ErrNonSortable
 	ErrSortMismatch
+	ErrCanceled
+	ErrDeadlineExceeded
+	ErrMaxDepth
+	ErrMaxSteps
+	ErrMaxArrayLength
+	ErrMaxStringLength
+	ErrEvalSyntax
 )
 
 var errmsgs = map[ErrType]string{
@@ -70,16 +83,60 @@ var errmsgs = map[ErrType]string{
 	ErrIllegalDelete:      `the delete clause of an object transformation must evaluate to an array of strings`,
 	ErrNonSortable:        `expressions in a sort term must evaluate to strings or numbers`,
 	ErrSortMismatch:       `expressions in a sort term must have the same type`,
+	ErrCanceled:           `evaluation was cancelled`,
+	ErrDeadlineExceeded:   `evaluation exceeded its deadline`,
+	ErrMaxDepth:           `function calls exceeded the maximum depth of {{value}}`,
+	ErrMaxSteps:           `evaluation exceeded the maximum of {{value}} steps`,
+	ErrMaxArrayLength:     `array exceeded the maximum length of {{value}} items`,
+	ErrMaxStringLength:    `string exceeded the maximum length of {{value}} bytes`,
+	ErrEvalSyntax:         `syntax error in expression passed to function eval: {{value}}`,
+}
+
+// errcodes maps error types to the corresponding jsonata-js
+// error codes. Where jsonata-js has no exact equivalent, the
+// closest code is used. Errors that jsonata-js does not have
+// at all (cancellation and the resource limits) have codes
+// starting with "U" that are specific to this library.
+var errcodes = map[ErrType]string{
+	ErrNonIntegerLHS:      "T2003",
+	ErrNonIntegerRHS:      "T2004",
+	ErrNonNumberLHS:       "T2001",
+	ErrNonNumberRHS:       "T2002",
+	ErrNonComparableLHS:   "T2010",
+	ErrNonComparableRHS:   "T2010",
+	ErrTypeMismatch:       "T2009",
+	ErrNonCallable:        "T1006",
+	ErrNonCallableApply:   "T2006",
+	ErrNonCallablePartial: "T1008",
+	ErrNumberInf:          "D1001",
+	ErrNumberNaN:          "D1001",
+	ErrMaxRangeItems:      "D2014",
+	ErrIllegalKey:         "T1003",
+	ErrDuplicateKey:       "D1009",
+	ErrClone:              "T2013",
+	ErrIllegalUpdate:      "T2011",
+	ErrIllegalDelete:      "T2012",
+	ErrNonSortable:        "T2008",
+	ErrSortMismatch:       "T2007",
+	ErrCanceled:           "U1005",
+	ErrDeadlineExceeded:   "U1006",
+	ErrMaxDepth:           "U1001",
+	ErrMaxSteps:           "U1002",
+	ErrMaxArrayLength:     "U1003",
+	ErrMaxStringLength:    "U1004",
+	ErrEvalSyntax:         "D3120",
 }
 
 var reErrMsg = regexp.MustCompile("{{(token|value)}}")
 
 // An EvalError represents an error during evaluation of a
-// JSONata expression.
+// JSONata expression. The embedded Span identifies the part
+// of the expression that caused the error.
 type EvalError struct {
 	Type  ErrType
 	Token string
 	Value string
+	jparse.Span
 }
 
 func newEvalError(typ ErrType, token interface{}, value interface{}) *EvalError {
@@ -121,13 +178,34 @@ func (e EvalError) Error() string {
 	})
 }
 
+// Code returns the jsonata-js error code for the error, e.g.
+// "T2001" for a non-numeric left operand. Codes starting with
+// "U" are not jsonata-js codes. They are specific to this
+// library and identify errors that jsonata-js does not have:
+//
+//	U1001  ErrMaxDepth
+//	U1002  ErrMaxSteps
+//	U1003  ErrMaxArrayLength
+//	U1004  ErrMaxStringLength
+//	U1005  ErrCanceled
+//	U1006  ErrDeadlineExceeded
+func (e EvalError) Code() string {
+	return errcodes[e.Type]
+}
+
 // ArgCountError is returned by the evaluation methods when an
 // expression contains a function call with the wrong number of
-// arguments.
+// arguments. The embedded Span identifies the function call.
 type ArgCountError struct {
 	Func     string
 	Expected int
 	Received int
+
+	// Optional is the number of trailing arguments that may
+	// be omitted, if known.
+	Optional int
+
+	jparse.Span
 }
 
 func newArgCountError(f jtypes.Callable, received int) *ArgCountError {
@@ -139,15 +217,26 @@ func newArgCountError(f jtypes.Callable, received int) *ArgCountError {
 }
 
 func (e ArgCountError) Error() string {
+	if e.Optional > 0 {
+		return fmt.Sprintf("function %q takes %d to %d arguments, got %d", e.Func, e.Expected-e.Optional, e.Expected, e.Received)
+	}
 	return fmt.Sprintf("function %q takes %d argument(s), got %d", e.Func, e.Expected, e.Received)
 }
 
+// Code returns the jsonata-js error code for the error. Like
+// jsonata-js, it does not distinguish between argument count
+// and argument type errors.
+func (e ArgCountError) Code() string {
+	return "T0410"
+}
+
 // ArgTypeError is returned by the evaluation methods when an
 // expression contains a function call with the wrong argument
-// type.
+// type. The embedded Span identifies the function call.
 type ArgTypeError struct {
 	Func  string
 	Which int
+	jparse.Span
 }
 
 func newArgTypeError(f jtypes.Callable, which int) *ArgTypeError {
@@ -160,3 +249,61 @@ func newArgTypeError(f jtypes.Callable, which int) *ArgTypeError {
 func (e ArgTypeError) Error() string {
 	return fmt.Sprintf("argument %d of function %q does not match function signature", e.Which, e.Func)
 }
+
+// Code returns the jsonata-js error code for the error.
+func (e ArgTypeError) Code() string {
+	return "T0410"
+}
+
+// ErrorList is a list of errors. It is returned by Validate
+// and CompileStrict when an expression has one or more
+// problems.
+type ErrorList []error
+
+func (l ErrorList) Error() string {
+	switch len(l) {
+	case 0:
+		return "no errors"
+	case 1:
+		return l[0].Error()
+	default:
+		return fmt.Sprintf("%s (and %d more errors)", l[0], len(l)-1)
+	}
+}
+
+// clearLocation removes the source location from an evaluation
+// error, so that locateError attributes it to the next node
+// up the syntax tree.
+func clearLocation(err error) {
+
+	switch e := err.(type) {
+	case *EvalError:
+		e.Span = jparse.Span{}
+	case *ArgCountError:
+		e.Span = jparse.Span{}
+	case *ArgTypeError:
+		e.Span = jparse.Span{}
+	}
+}
+
+// locateError sets the source location of an evaluation error
+// that does not already have one. Because eval calls this for
+// every node, errors are attributed to the innermost node that
+// returned them.
+func locateError(err error, node jparse.Node) {
+
+	switch e := err.(type) {
+	case *EvalError:
+		if !e.IsValid() {
+			e.Span = node.Location()
+		}
+	case *ArgCountError:
+		if !e.IsValid() {
+			e.Span = node.Location()
+		}
+	case *ArgTypeError:
+		if !e.IsValid() {
+			e.Span = node.Location()
+		}
+	}
+}
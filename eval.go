@@ -5,10 +5,13 @@
 package jsonata
 
 import (
+	"encoding/json"
 	"fmt"
 	"math"
+	"math/big"
 	"reflect"
 	"sort"
+	"strconv"
 
 	"github.com/blues/jsonata-go/jlib"
 	"github.com/blues/jsonata-go/jparse"
@@ -23,6 +26,10 @@ func eval(node jparse.Node, input reflect.Value, env *environment) (reflect.Valu
 	var err error
 	var v reflect.Value
 
+	if err = env.checkState(); err != nil {
+		return undefined, err
+	}
+
 	switch node := node.(type) {
 	case *jparse.StringNode:
 		v, err = evalString(node, input, env)
@@ -58,6 +65,12 @@ func eval(node jparse.Node, input reflect.Value, env *environment) (reflect.Valu
 		v, err = evalWildcard(node, input, env)
 	case *jparse.DescendentNode:
 		v, err = evalDescendent(node, input, env)
+	case *jparse.ParentNode:
+		v, err = evalParent(node, input, env)
+	case *jparse.FocusNode:
+		v, err = evalFocus(node, input, env)
+	case *jparse.IndexNode:
+		v, err = evalIndex(node, input, env)
 	case *jparse.GroupNode:
 		v, err = evalGroup(node, input, env)
 	case *jparse.PredicateNode:
@@ -82,20 +95,40 @@ func eval(node jparse.Node, input reflect.Value, env *environment) (reflect.Valu
 		v, err = evalComparisonOperator(node, input, env)
 	case *jparse.BooleanOperatorNode:
 		v, err = evalBooleanOperator(node, input, env)
+	case *jparse.CoalescingOperatorNode:
+		v, err = evalCoalescingOperator(node, input, env)
 	case *jparse.StringConcatenationNode:
 		v, err = evalStringConcatenation(node, input, env)
 	default:
 		panicf("eval: unexpected node type %T", node)
 	}
 
+	if err == nil {
+		v, err = evalResult(v, env)
+	}
+
 	if err != nil {
+		locateError(err, node)
 		return undefined, err
 	}
 
+	return v, nil
+}
+
+// evalResult converts the value returned by one of the evalX
+// functions into a result suitable for returning from eval.
+func evalResult(v reflect.Value, env *environment) (reflect.Value, error) {
+
 	if seq, ok := asSequence(v); ok {
 		v = seq.Value()
 	}
 
+	if env != nil && env.state != nil {
+		if err := env.state.checkResult(v); err != nil {
+			return undefined, err
+		}
+	}
+
 	return v, nil
 }
 
@@ -104,6 +137,16 @@ func evalString(node *jparse.StringNode, data reflect.Value, env *environment) (
 }
 
 func evalNumber(node *jparse.NumberNode, data reflect.Value, env *environment) (reflect.Value, error) {
+
+	// In exact mode, use the literal text for numbers that
+	// cannot be represented exactly as a float64.
+	if env.exactNumbers() && node.Literal != "" {
+		lit, ok := jtypes.AsRat(reflect.ValueOf(json.Number(node.Literal)))
+		if f, _ := jtypes.AsRat(reflect.ValueOf(node.Value)); ok && lit.Cmp(f) != 0 {
+			return reflect.ValueOf(jlib.NumberFromRat(lit)), nil
+		}
+	}
+
 	return reflect.ValueOf(node.Value), nil
 }
 
@@ -136,7 +179,7 @@ func evalName(node *jparse.NameNode, data reflect.Value, env *environment) (refl
 
 	switch {
 	case jtypes.IsStruct(data):
-		v = data.FieldByName(node.Value)
+		v = jtypes.FieldByName(data, node.Value)
 	case jtypes.IsMap(data):
 		v = data.MapIndex(reflect.ValueOf(node.Value))
 	case jtypes.IsArray(data):
@@ -168,16 +211,27 @@ func evalNameArray(node *jparse.NameNode, data reflect.Value, env *environment)
 }
 
 func evalPath(node *jparse.PathNode, data reflect.Value, env *environment) (reflect.Value, error) {
+	v, _, err := evalPathTuples(node, data, env)
+	return v, err
+}
+
+// evalPathTuples evaluates a path expression. Along with the
+// result of the path, it returns a tuple for each item in the
+// result (see evalTuples).
+func evalPathTuples(node *jparse.PathNode, data reflect.Value, env *environment) (reflect.Value, []tuple, error) {
 	if len(node.Steps) == 0 {
-		return undefined, nil
+		return undefined, nil, nil
 	}
 
 	var isVar bool
 	switch step0 := node.Steps[0].(type) {
-	case (*jparse.VariableNode):
+	case (*jparse.VariableNode), (*jparse.ParentNode):
 		isVar = true
 	case (*jparse.PredicateNode):
-		_, isVar = step0.Expr.(*jparse.VariableNode)
+		switch step0.Expr.(type) {
+		case (*jparse.VariableNode), (*jparse.ParentNode):
+			isVar = true
+		}
 	}
 
 	output := data
@@ -189,60 +243,133 @@ func evalPath(node *jparse.PathNode, data reflect.Value, env *environment) (refl
 	}
 
 	var err error
+	var tuples []tuple
 	lastIndex := len(node.Steps) - 1
 	for i, step := range node.Steps {
 
 		if step0, ok := step.(*jparse.ArrayNode); ok && i == 0 {
 			output, err = eval(step0, output, env)
+			tuples = nil
 		} else {
-			output, err = evalPathStep(step, output, env, i == lastIndex)
+			output, tuples, err = evalPathStep(step, output, tuples, env, i == lastIndex)
 		}
 
 		if err != nil || output == undefined {
-			return undefined, err
+			return undefined, nil, err
 		}
 
 		if jtypes.IsArray(output) && jtypes.Resolve(output).Len() == 0 {
-			return undefined, nil
+			return undefined, nil, nil
 		}
 	}
 
 	if node.KeepArrays {
 		if seq, ok := asSequence(output); ok {
 			seq.keepSingletons = true
-			return reflect.ValueOf(seq), nil
+			return reflect.ValueOf(seq), tuples, nil
 		}
 	}
 
-	return output, nil
+	return output, tuples, nil
 }
 
-func evalPathStep(step jparse.Node, data reflect.Value, env *environment, lastStep bool) (reflect.Value, error) {
-	var err error
-	var results []reflect.Value
+// evalPathStep evaluates a path step against each item in
+// data. The tuples argument holds a tuple for each item in
+// data. If it is nil, the items are assumed to share the
+// current ancestry and environment.
+func evalPathStep(step jparse.Node, data reflect.Value, tuples []tuple, env *environment, lastStep bool) (reflect.Value, []tuple, error) {
 
-	if seq, ok := asSequence(data); ok {
-		results, err = evalOverSequence(step, seq, env)
-	} else {
-		results, err = evalOverArray(step, data, env)
+	type stepResult struct {
+		value  reflect.Value
+		tuple  tuple
+		tuples []tuple
 	}
 
-	if err != nil {
-		return undefined, err
+	var items []interface{}
+	seq, isSeq := asSequence(data)
+	if isSeq {
+		items = seq.values
 	}
 
-	if lastStep && len(results) == 1 && jtypes.IsArray(results[0]) {
-		return results[0], nil
+	n := len(items)
+	if !isSeq {
+		n = data.Len()
+	}
+
+	current := env.ancestry()
+	defer env.setAncestry(current)
+
+	var results []stepResult
+
+	for i := 0; i < n; i++ {
+
+		var item reflect.Value
+		if isSeq {
+			item = reflect.ValueOf(items[i])
+		} else {
+			item = data.Index(i)
+		}
+
+		t := tuple{
+			parent: current,
+		}
+		if tuples != nil {
+			t = tuples[i]
+		}
+
+		stepEnv := t.scope(env)
+		stepEnv.setAncestry(t.parent)
+
+		res, resTuples, err := evalTuples(step, item, stepEnv)
+		if err != nil {
+			return undefined, nil, err
+		}
+
+		if res.IsValid() {
+			if results == nil {
+				results = make([]stepResult, 0, n)
+			}
+			r := stepResult{
+				value:  res,
+				tuples: resTuples,
+			}
+			if resTuples == nil {
+				r.tuple = defaultTuple(step, item, stepEnv)
+			}
+			// Tuples without an environment were evaluated in
+			// stepEnv. Make sure they keep the input's bindings.
+			r.tuple.inherit(t)
+			for j := range r.tuples {
+				r.tuples[j].inherit(t)
+			}
+			results = append(results, r)
+		}
+	}
+
+	if lastStep && len(results) == 1 && jtypes.IsArray(results[0].value) {
+		r := results[0]
+		if r.tuples == nil {
+			r.tuples = repeatTuple(r.tuple, arrayify(r.value).Len())
+		}
+		return r.value, r.tuples, nil
 	}
 
 	_, isCons := step.(*jparse.ArrayNode)
 	resultSequence := newSequence(len(results))
+	resultTuples := make([]tuple, 0, len(results))
+
+	for _, r := range results {
 
-	for _, v := range results {
+		v := r.value
 
 		if isCons || !jtypes.IsArray(v) {
 			if v.CanInterface() {
 				resultSequence.Append(v.Interface())
+				if r.tuples != nil {
+					resultTuples = append(resultTuples, r.tuples[0])
+				} else {
+					resultTuples = append(resultTuples, r.tuple)
+				}
 			}
 			continue
 		}
@@ -251,57 +378,302 @@ func evalPathStep(step jparse.Node, data reflect.Value, env *environment, lastSt
 		for i, N := 0, v.Len(); i < N; i++ {
 			if vi := v.Index(i); vi.IsValid() && vi.CanInterface() {
 				resultSequence.Append(vi.Interface())
+				if r.tuples != nil {
+					resultTuples = append(resultTuples, r.tuples[i])
+				} else {
+					resultTuples = append(resultTuples, r.tuple)
+				}
 			}
 		}
 	}
 
 	if resultSequence.Len() == 0 {
-		return undefined, nil
+		return undefined, nil, nil
 	}
 
-	return reflect.ValueOf(resultSequence), nil
+	return reflect.ValueOf(resultSequence), resultTuples, nil
+}
+
+// An ancestor is a value that a path step was evaluated
+// against. Ancestors are chained together to keep track
+// of the parent, grandparent etc. of the values produced
+// by a path. This is what the parent operator (%) uses to
+// find its result.
+type ancestor struct {
+	value  reflect.Value
+	parent *ancestor
+}
+
+// A tuple holds information about a value produced by a
+// path step: the value's ancestors and the environment
+// containing any variables bound by the focus (@) and index
+// (#) operators.
+type tuple struct {
+	parent *ancestor
+	env    *environment
 }
 
-func evalOverArray(node jparse.Node, data reflect.Value, env *environment) ([]reflect.Value, error) {
-	var results []reflect.Value
+// scope returns the environment in which to evaluate the
+// value described by the tuple.
+func (t tuple) scope(env *environment) *environment {
+	if t.env != nil {
+		return t.env
+	}
+	return env
+}
 
-	for i, N := 0, data.Len(); i < N; i++ {
+// inherit sets the tuple's environment to that of another
+// tuple if it does not already have one.
+func (t *tuple) inherit(other tuple) {
+	if t.env == nil {
+		t.env = other.env
+	}
+}
 
-		res, err := eval(node, data.Index(i), env)
-		if err != nil {
-			return nil, err
+// bind returns a copy of the tuple with an environment
+// that binds the given variable.
+func (t tuple) bind(env *environment, name string, value reflect.Value) tuple {
+
+	scope := newEnvironment(t.scope(env), 1)
+	scope.bind(name, value)
+
+	return tuple{
+		parent: t.parent,
+		env:    scope,
+	}
+}
+
+// evalTuples is like eval but it also returns a tuple for each
+// item in the result. This is only possible for node types that
+// evaluate path steps (paths, predicates, sort expressions and
+// the focus and index operators). For other node types, the
+// returned tuples are nil and the caller should use defaultTuple
+// instead.
+func evalTuples(node jparse.Node, data reflect.Value, env *environment) (reflect.Value, []tuple, error) {
+	var err error
+	var v reflect.Value
+	var tuples []tuple
+
+	switch node := node.(type) {
+	case *jparse.PathNode:
+		if err = env.checkState(); err != nil {
+			return undefined, nil, err
+		}
+		v, tuples, err = evalPathTuples(node, data, env)
+	case *jparse.PredicateNode:
+		if err = env.checkState(); err != nil {
+			return undefined, nil, err
+		}
+		v, tuples, err = evalPredicateTuples(node, data, env)
+	case *jparse.SortNode:
+		if err = env.checkState(); err != nil {
+			return undefined, nil, err
+		}
+		v, tuples, err = evalSortTuples(node, data, env)
+	case *jparse.FocusNode:
+		if err = env.checkState(); err != nil {
+			return undefined, nil, err
 		}
+		v, tuples, err = evalFocusTuples(node, data, env)
+	case *jparse.IndexNode:
+		if err = env.checkState(); err != nil {
+			return undefined, nil, err
+		}
+		v, tuples, err = evalIndexTuples(node, data, env)
+	default:
+		v, err = eval(node, data, env)
+		return v, nil, err
+	}
 
-		if res.IsValid() {
-			if results == nil {
-				results = make([]reflect.Value, 0, N)
-			}
-			results = append(results, res)
+	if err != nil {
+		return undefined, nil, err
+	}
+
+	v, err = evalResult(v, env)
+	if err != nil || v == undefined {
+		return undefined, nil, err
+	}
+
+	// The tuples should line up with the items in the result.
+	// They may not if the result is a single value that is also
+	// an array, in which case the array's tuple applies to all
+	// of its items.
+	n := 1
+	if jtypes.IsArray(v) {
+		n = jtypes.Resolve(v).Len()
+	}
+
+	if len(tuples) != n {
+		if len(tuples) != 1 {
+			return v, nil, nil
 		}
+		tuples = repeatTuple(tuples[0], n)
 	}
 
-	return results, nil
+	return v, tuples, nil
 }
 
-func evalOverSequence(node jparse.Node, seq *sequence, env *environment) ([]reflect.Value, error) {
-	var results []reflect.Value
+// evalItemTuples evaluates a node against data and returns
+// the results as an array, along with a tuple for each item
+// in the array.
+func evalItemTuples(node jparse.Node, data reflect.Value, env *environment) (reflect.Value, []tuple, error) {
 
-	for i, N := 0, len(seq.values); i < N; i++ {
+	v, tuples, err := evalTuples(node, data, env)
+	if err != nil || v == undefined {
+		return undefined, nil, err
+	}
 
-		res, err := eval(node, reflect.ValueOf(seq.values[i]), env)
-		if err != nil {
-			return nil, err
+	v = arrayify(v)
+	if tuples == nil {
+		tuples = repeatTuple(defaultTuple(node, data, env), v.Len())
+	}
+
+	return v, tuples, nil
+}
+
+// defaultTuple returns the tuple for values produced by
+// evaluating the given node against data, for node types
+// not handled by evalTuples. Usually, the data itself is
+// the parent of these values. The exceptions are variables,
+// which do not derive from the data, and the context variable
+// and parent operator, which refer to the data and its
+// ancestors.
+func defaultTuple(node jparse.Node, data reflect.Value, env *environment) tuple {
+
+	anc := env.ancestry()
+
+	switch node := node.(type) {
+	case *jparse.VariableNode:
+		if node.Name == "" {
+			return tuple{parent: anc}
+		}
+		return tuple{}
+	case *jparse.ParentNode:
+		if anc == nil {
+			return tuple{}
+		}
+		return tuple{parent: anc.parent}
+	default:
+		return tuple{
+			parent: &ancestor{
+				value:  data,
+				parent: anc,
+			},
 		}
+	}
+}
 
-		if res.IsValid() {
-			if results == nil {
-				results = make([]reflect.Value, 0, N)
+func repeatTuple(t tuple, n int) []tuple {
+
+	results := make([]tuple, n)
+	for i := range results {
+		results[i] = t
+	}
+
+	return results
+}
+
+// reduceTuples returns an environment for evaluating a group
+// of values with the given tuples. Each variable bound by one
+// or more of the tuples is bound to the sequence of its values.
+func reduceTuples(tuples []tuple, env *environment) *environment {
+
+	switch len(tuples) {
+	case 0:
+		return env
+	case 1:
+		return tuples[0].scope(env)
+	}
+
+	var names []string
+	values := map[string]*sequence{}
+
+	for _, t := range tuples {
+		for scope := t.env; scope != nil && scope != env; scope = scope.parent {
+			for name, v := range scope.symbols {
+				seq, ok := values[name]
+				if !ok {
+					seq = newSequence(len(tuples))
+					values[name] = seq
+					names = append(names, name)
+				}
+				if v.IsValid() && v.CanInterface() {
+					seq.Append(v.Interface())
+				}
 			}
-			results = append(results, res)
 		}
 	}
 
-	return results, nil
+	if len(names) == 0 {
+		return env
+	}
+
+	scope := newEnvironment(env, len(names))
+	for _, name := range names {
+		scope.bind(name, values[name].Value())
+	}
+
+	return scope
+}
+
+func evalParent(node *jparse.ParentNode, data reflect.Value, env *environment) (reflect.Value, error) {
+	if anc := env.ancestry(); anc != nil {
+		return anc.value, nil
+	}
+	return undefined, nil
+}
+
+func evalFocus(node *jparse.FocusNode, data reflect.Value, env *environment) (reflect.Value, error) {
+	v, _, err := evalFocusTuples(node, data, env)
+	return v, err
+}
+
+// evalFocusTuples evaluates a focus expression (e.g. loans@$l).
+// Each value produced by the expression is bound to a variable
+// but the context value does not change. The result holds the
+// context value once for each variable binding.
+func evalFocusTuples(node *jparse.FocusNode, data reflect.Value, env *environment) (reflect.Value, []tuple, error) {
+
+	items, tuples, err := evalItemTuples(node.Expr, data, env)
+	if err != nil || items == undefined || !data.IsValid() {
+		return undefined, nil, err
+	}
+
+	n := items.Len()
+	results := reflect.MakeSlice(typeInterfaceSlice, n, n)
+	resultTuples := make([]tuple, n)
+	current := tuple{
+		parent: env.ancestry(),
+	}
+
+	for i := 0; i < n; i++ {
+		results.Index(i).Set(data)
+		resultTuples[i] = current.bind(tuples[i].scope(env), node.Name, items.Index(i))
+	}
+
+	return results, resultTuples, nil
+}
+
+func evalIndex(node *jparse.IndexNode, data reflect.Value, env *environment) (reflect.Value, error) {
+	v, _, err := evalIndexTuples(node, data, env)
+	return v, err
+}
+
+// evalIndexTuples evaluates an index expression (e.g. items#$i).
+// The position of each value produced by the expression is bound
+// to a variable.
+func evalIndexTuples(node *jparse.IndexNode, data reflect.Value, env *environment) (reflect.Value, []tuple, error) {
+
+	items, tuples, err := evalItemTuples(node.Expr, data, env)
+	if err != nil || items == undefined {
+		return undefined, nil, err
+	}
+
+	for i := range tuples {
+		tuples[i] = tuples[i].bind(env, node.Name, reflect.ValueOf(float64(i)))
+	}
+
+	return items, tuples, nil
 }
 
 func evalNegation(node *jparse.NegationNode, data reflect.Value, env *environment) (reflect.Value, error) {
@@ -310,6 +682,14 @@ func evalNegation(node *jparse.NegationNode, data reflect.Value, env *environmen
 		return undefined, err
 	}
 
+	if env.exactNumbers() {
+		r, ok := jtypes.AsRat(rhs)
+		if !ok {
+			return undefined, newEvalError(ErrNonNumberRHS, node.RHS, "-")
+		}
+		return reflect.ValueOf(jlib.NumberFromRat(r.Neg(r))), nil
+	}
+
 	n, ok := jtypes.AsNumber(rhs)
 	if !ok {
 		return undefined, newEvalError(ErrNonNumberRHS, node.RHS, "-")
@@ -366,10 +746,21 @@ func evalRange(node *jparse.RangeNode, data reflect.Value, env *environment) (re
 		return undefined, nil
 	}
 
+	// A MaxArrayLength limit can lower the maximum range size
+	// but not raise it.
+	max := maxRangeItems
+	if limit := env.maxArrayLength(); limit > 0 && limit < max {
+		max = limit
+	}
+
 	size := int(rhs-lhs) + 1
 	// Check for integer overflow or an array size that exceeds
-	// our upper bound.
-	if size < 0 || size > maxRangeItems {
+	// our upper bound. Report the MaxArrayLength limit if that
+	// is the one that applies.
+	if size < 0 || size > max {
+		if max != maxRangeItems {
+			return undefined, newEvalError(ErrMaxArrayLength, nil, strconv.Itoa(max))
+		}
 		return undefined, newEvalError(ErrMaxRangeItems, "..", nil)
 	}
 
@@ -426,8 +817,18 @@ func evalArray(node *jparse.ArrayNode, data reflect.Value, env *environment) (re
 
 func evalObject(node *jparse.ObjectNode, data reflect.Value, env *environment) (reflect.Value, error) {
 	data = makeArray(data)
+	return evalObjectTuples(node, data, repeatTuple(tuple{parent: env.ancestry()}, data.Len()), env)
+}
 
-	keys, err := groupItemsByKey(node, data, env)
+// evalObjectTuples evaluates an object constructor against
+// an array of items. The tuples argument holds a tuple for
+// each item.
+func evalObjectTuples(node *jparse.ObjectNode, data reflect.Value, tuples []tuple, env *environment) (reflect.Value, error) {
+
+	current := env.ancestry()
+	defer env.setAncestry(current)
+
+	keys, err := groupItemsByKey(node, data, tuples, env)
 	if err != nil {
 		return undefined, err
 	}
@@ -438,14 +839,24 @@ func evalObject(node *jparse.ObjectNode, data reflect.Value, env *environment) (
 	for key, idx := range keys {
 
 		items := data
+		group := tuples
 		if n := len(idx.items); n != 0 && n != nItems {
 			items = reflect.MakeSlice(typeInterfaceSlice, n, n)
+			group = make([]tuple, n)
 			for i, j := range idx.items {
 				items.Index(i).Set(data.Index(j))
+				group[i] = tuples[j]
 			}
 		}
 
-		value, err := eval(node.Pairs[idx.pair][1], items, env)
+		// Where several items share the same key, use the
+		// parent of the first item.
+		env.setAncestry(current)
+		if len(group) != 0 {
+			env.setAncestry(group[0].parent)
+		}
+
+		value, err := eval(node.Pairs[idx.pair][1], items, reduceTuples(group, env))
 		if err != nil {
 			return undefined, err
 		}
@@ -463,7 +874,7 @@ type keyIndexes struct {
 	items []int
 }
 
-func groupItemsByKey(obj *jparse.ObjectNode, items reflect.Value, env *environment) (map[string]keyIndexes, error) {
+func groupItemsByKey(obj *jparse.ObjectNode, items reflect.Value, tuples []tuple, env *environment) (map[string]keyIndexes, error) {
 	nItems := items.Len()
 	results := make(map[string]keyIndexes, len(obj.Pairs))
 
@@ -486,7 +897,8 @@ func groupItemsByKey(obj *jparse.ObjectNode, items reflect.Value, env *environme
 
 		for j := 0; j < nItems; j++ {
 
-			v, err := eval(keyNode, items.Index(j), env)
+			env.setAncestry(tuples[j].parent)
+			v, err := eval(keyNode, items.Index(j), tuples[j].scope(env))
 			if err != nil {
 				return nil, err
 			}
@@ -612,18 +1024,28 @@ func recurseDescendents(seq *sequence, v reflect.Value) {
 }
 
 func evalGroup(node *jparse.GroupNode, data reflect.Value, env *environment) (reflect.Value, error) {
-	items, err := eval(node.Expr, data, env)
+	items, tuples, err := evalTuples(node.Expr, data, env)
 	if err != nil {
 		return undefined, err
 	}
 
-	return evalObject(node.ObjectNode, items, env)
+	items = makeArray(items)
+	if tuples == nil {
+		tuples = repeatTuple(defaultTuple(node.Expr, data, env), items.Len())
+	}
+
+	return evalObjectTuples(node.ObjectNode, items, tuples, env)
 }
 
 func evalPredicate(node *jparse.PredicateNode, data reflect.Value, env *environment) (reflect.Value, error) {
-	items, err := eval(node.Expr, data, env)
+	v, _, err := evalPredicateTuples(node, data, env)
+	return v, err
+}
+
+func evalPredicateTuples(node *jparse.PredicateNode, data reflect.Value, env *environment) (reflect.Value, []tuple, error) {
+	items, tuples, err := evalItemTuples(node.Expr, data, env)
 	if err != nil || items == undefined {
-		return undefined, err
+		return undefined, nil, err
 	}
 
 	for _, filter := range node.Filters {
@@ -632,31 +1054,35 @@ func evalPredicate(node *jparse.PredicateNode, data reflect.Value, env *environm
 		// we should access the indexed item directly instead
 		// of calling applyFilter.
 
-		items, err = applyFilter(filter, arrayify(items), env)
+		items, tuples, err = applyFilter(filter, items, tuples, env)
 		if err != nil {
-			return undefined, err
+			return undefined, nil, err
 		}
 
 		if items.Len() == 0 {
-			items = undefined
-			break
+			return undefined, nil, nil
 		}
 	}
 
-	return normalizeArray(items), nil
+	return normalizeArray(items), tuples, nil
 }
 
-func applyFilter(filter jparse.Node, items reflect.Value, env *environment) (reflect.Value, error) {
+func applyFilter(filter jparse.Node, items reflect.Value, tuples []tuple, env *environment) (reflect.Value, []tuple, error) {
 	nItems := items.Len()
 	results := reflect.MakeSlice(typeInterfaceSlice, 0, 0)
+	resultTuples := make([]tuple, 0)
+
+	current := env.ancestry()
+	defer env.setAncestry(current)
 
 	for i := 0; i < nItems; i++ {
 
 		item := items.Index(i)
 
-		res, err := eval(filter, item, env)
+		env.setAncestry(tuples[i].parent)
+		res, err := eval(filter, item, tuples[i].scope(env))
 		if err != nil {
-			return undefined, err
+			return undefined, nil, err
 		}
 
 		if jtypes.IsNumber(res) {
@@ -675,14 +1101,16 @@ func applyFilter(filter jparse.Node, items reflect.Value, env *environment) (ref
 
 				if index == i {
 					results = reflect.Append(results, item)
+					resultTuples = append(resultTuples, tuples[i])
 				}
 			}
 		case jlib.Boolean(res):
 			results = reflect.Append(results, item)
+			resultTuples = append(resultTuples, tuples[i])
 		}
 	}
 
-	return results, nil
+	return results, resultTuples, nil
 }
 
 type sortinfo struct {
@@ -690,20 +1118,26 @@ type sortinfo struct {
 	values []reflect.Value
 }
 
-func buildSortInfo(items reflect.Value, terms []jparse.SortTerm, env *environment) ([]*sortinfo, error) {
+func buildSortInfo(items reflect.Value, tuples []tuple, terms []jparse.SortTerm, env *environment) ([]*sortinfo, error) {
 	info := make([]*sortinfo, items.Len())
 
 	isNumberTerm := make([]bool, len(terms))
 	isStringTerm := make([]bool, len(terms))
 
+	current := env.ancestry()
+	defer env.setAncestry(current)
+
 	for i, N := 0, items.Len(); i < N; i++ {
 
 		item := items.Index(i)
 		values := make([]reflect.Value, len(terms))
 
+		env.setAncestry(tuples[i].parent)
+		scope := tuples[i].scope(env)
+
 		for j, term := range terms {
 
-			v, err := eval(term.Expr, item, env)
+			v, err := eval(term.Expr, item, scope)
 			if err != nil {
 				return nil, err
 			}
@@ -712,6 +1146,10 @@ func buildSortInfo(items reflect.Value, terms []jparse.SortTerm, env *environmen
 				continue
 			}
 
+			// Resolve the value once here rather than in every
+			// comparison.
+			v = jtypes.Resolve(v)
+
 			switch {
 			case jtypes.IsNumber(v):
 				if isStringTerm[j] {
@@ -773,27 +1211,32 @@ func makeLessFunc(info []*sortinfo, terms []jparse.SortTerm) func(int, int) bool
 }
 
 func evalSort(node *jparse.SortNode, data reflect.Value, env *environment) (reflect.Value, error) {
-	items, err := eval(node.Expr, data, env)
+	v, _, err := evalSortTuples(node, data, env)
+	return v, err
+}
+
+func evalSortTuples(node *jparse.SortNode, data reflect.Value, env *environment) (reflect.Value, []tuple, error) {
+	items, tuples, err := evalItemTuples(node.Expr, data, env)
 	if err != nil || items == undefined {
-		return undefined, err
+		return undefined, nil, err
 	}
 
-	items = arrayify(items)
-
-	info, err := buildSortInfo(items, node.Terms, env)
+	info, err := buildSortInfo(items, tuples, node.Terms, env)
 	if err != nil {
-		return undefined, err
+		return undefined, nil, err
 	}
 
 	sort.SliceStable(info, makeLessFunc(info, node.Terms))
 
 	results := reflect.MakeSlice(typeInterfaceSlice, len(info), len(info))
+	resultTuples := make([]tuple, len(info))
 
 	for i := range info {
 		results.Index(i).Set(items.Index(info[i].index))
+		resultTuples[i] = tuples[info[i].index]
 	}
 
-	return normalizeArray(results), nil
+	return normalizeArray(results), resultTuples, nil
 }
 
 func evalLambda(node *jparse.LambdaNode, data reflect.Value, env *environment) (reflect.Value, error) {
@@ -883,6 +1326,22 @@ func evalFunctionCall(node *jparse.FunctionCallNode, data reflect.Value, env *en
 		return undefined, newEvalError(ErrNonCallable, node.Func, nil)
 	}
 
+	// Built-in functions and extensions are shared by every
+	// evaluation of every Expr. Set the name and context on
+	// a copy so that concurrent evaluations don't collide.
+	switch f := fn.(type) {
+	case *goCallable:
+		clone := *f
+		fn = &clone
+	case *evalCallable:
+		// $eval also needs the caller's environment so
+		// that the expression it evaluates can see local
+		// variables and shares the evaluation's limits.
+		clone := *f
+		clone.env = env
+		fn = &clone
+	}
+
 	if setter, ok := fn.(nameSetter); ok {
 		if sym, ok := node.Func.(*jparse.VariableNode); ok {
 			setter.SetName(sym.Name)
@@ -910,11 +1369,13 @@ func evalFunctionCall(node *jparse.FunctionCallNode, data reflect.Value, env *en
 func evalFunctionApplication(node *jparse.FunctionApplicationNode, data reflect.Value, env *environment) (reflect.Value, error) {
 	// If the right hand side is a function call, insert
 	// the left hand side into the argument list and
-	// evaluate it.
+	// evaluate it. The call is copied so that the syntax
+	// tree is unchanged for subsequent evaluations.
 	if f, ok := node.RHS.(*jparse.FunctionCallNode); ok {
 
-		f.Args = append([]jparse.Node{node.LHS}, f.Args...)
-		return evalFunctionCall(f, data, env)
+		call := *f
+		call.Args = append([]jparse.Node{node.LHS}, f.Args...)
+		return evalFunctionCall(&call, data, env)
 	}
 
 	// Evaluate both sides and return any errors.
@@ -954,24 +1415,29 @@ func evalFunctionApplication(node *jparse.FunctionApplicationNode, data reflect.
 }
 
 func evalNumericOperator(node *jparse.NumericOperatorNode, data reflect.Value, env *environment) (reflect.Value, error) {
-	evaluate := func(node jparse.Node) (float64, bool, bool, error) {
+	evaluate := func(node jparse.Node) (reflect.Value, bool, bool, error) {
 
 		v, err := eval(node, data, env)
 		if err != nil || v == undefined {
-			return 0, false, false, err
+			return undefined, false, false, err
 		}
 
-		n, isNum := jtypes.AsNumber(v)
-		return n, true, isNum, nil
+		// In exact mode, numbers beyond the range of float64
+		// are still numbers.
+		isNum := jtypes.IsNumber(v)
+		if !env.exactNumbers() {
+			_, isNum = jtypes.AsNumber(v)
+		}
+		return v, true, isNum, nil
 	}
 
 	// Evaluate both sides and return any errors.
-	lhs, lhsOK, lhsNumber, err := evaluate(node.LHS)
+	lhsValue, lhsOK, lhsNumber, err := evaluate(node.LHS)
 	if err != nil {
 		return undefined, err
 	}
 
-	rhs, rhsOK, rhsNumber, err := evaluate(node.RHS)
+	rhsValue, rhsOK, rhsNumber, err := evaluate(node.RHS)
 	if err != nil {
 		return undefined, err
 	}
@@ -990,6 +1456,13 @@ func evalNumericOperator(node *jparse.NumericOperatorNode, data reflect.Value, e
 		return undefined, nil
 	}
 
+	if env.exactNumbers() {
+		return evalExactNumericOperator(node, lhsValue, rhsValue)
+	}
+
+	lhs, _ := jtypes.AsNumber(lhsValue)
+	rhs, _ := jtypes.AsNumber(rhsValue)
+
 	var x float64
 
 	switch node.Type {
@@ -1018,6 +1491,63 @@ func evalNumericOperator(node *jparse.NumericOperatorNode, data reflect.Value, e
 	return reflect.ValueOf(x), nil
 }
 
+// evalExactNumericOperator applies a numeric operator to two
+// numbers using exact arithmetic. The result is a json.Number.
+func evalExactNumericOperator(node *jparse.NumericOperatorNode, lhsValue, rhsValue reflect.Value) (reflect.Value, error) {
+
+	// The operands are known to be numbers, so AsRat only
+	// fails if they are too large or too small to compute
+	// with.
+	lhs, ok := jtypes.AsRat(lhsValue)
+	if !ok {
+		return undefined, newEvalError(ErrNumberInf, nil, node.Type)
+	}
+
+	rhs, ok := jtypes.AsRat(rhsValue)
+	if !ok {
+		return undefined, newEvalError(ErrNumberInf, nil, node.Type)
+	}
+
+	// Division by zero produces the same errors as the
+	// floating point operators: Inf for a non-zero dividend
+	// and NaN otherwise.
+	if rhs.Sign() == 0 {
+		switch {
+		case node.Type == jparse.NumericDivide && lhs.Sign() != 0:
+			return undefined, newEvalError(ErrNumberInf, nil, node.Type)
+		case node.Type == jparse.NumericDivide, node.Type == jparse.NumericModulo:
+			return undefined, newEvalError(ErrNumberNaN, nil, node.Type)
+		}
+	}
+
+	x := new(big.Rat)
+
+	switch node.Type {
+	case jparse.NumericAdd:
+		x.Add(lhs, rhs)
+	case jparse.NumericSubtract:
+		x.Sub(lhs, rhs)
+	case jparse.NumericMultiply:
+		x.Mul(lhs, rhs)
+	case jparse.NumericDivide:
+		x.Quo(lhs, rhs)
+	case jparse.NumericModulo:
+		// As with math.Mod, the result has the same sign
+		// as the dividend.
+		q := new(big.Rat).Quo(lhs, rhs)
+		n := new(big.Int).Quo(q.Num(), q.Denom())
+		x.Sub(lhs, q.SetInt(n).Mul(q, rhs))
+	default:
+		panicf("unrecognised numeric operator %q", node.Type)
+	}
+
+	if !jlib.RatInRange(x) {
+		return undefined, newEvalError(ErrNumberInf, nil, node.Type)
+	}
+
+	return reflect.ValueOf(jlib.NumberFromRat(x)), nil
+}
+
 // See https://docs.jsonata.org/expressions#comparison-expressions
 func evalComparisonOperator(node *jparse.ComparisonOperatorNode, data reflect.Value, env *environment) (reflect.Value, error) {
 	evaluate := func(node jparse.Node) (reflect.Value, bool, bool, error) {
@@ -1065,6 +1595,12 @@ func evalComparisonOperator(node *jparse.ComparisonOperatorNode, data reflect.Va
 		return reflect.ValueOf(false), nil
 	}
 
+	if lhsNumber && rhsNumber && env.exactNumbers() {
+		if b, ok := compareExact(node.Type, lhs, rhs); ok {
+			return reflect.ValueOf(b), nil
+		}
+	}
+
 	var b bool
 
 	switch node.Type {
@@ -1089,6 +1625,42 @@ func evalComparisonOperator(node *jparse.ComparisonOperatorNode, data reflect.Va
 	return reflect.ValueOf(b), nil
 }
 
+// compareExact compares two numbers using exact arithmetic.
+// It returns false if either value cannot be represented
+// exactly (e.g. NaN).
+func compareExact(op jparse.ComparisonOperator, lhs, rhs reflect.Value) (bool, bool) {
+
+	v1, ok := jtypes.AsRat(lhs)
+	if !ok {
+		return false, false
+	}
+
+	v2, ok := jtypes.AsRat(rhs)
+	if !ok {
+		return false, false
+	}
+
+	c := v1.Cmp(v2)
+
+	switch op {
+	case jparse.ComparisonEqual, jparse.ComparisonIn:
+		return c == 0, true
+	case jparse.ComparisonNotEqual:
+		return c != 0, true
+	case jparse.ComparisonLess:
+		return c < 0, true
+	case jparse.ComparisonLessEqual:
+		return c <= 0, true
+	case jparse.ComparisonGreater:
+		return c > 0, true
+	case jparse.ComparisonGreaterEqual:
+		return c >= 0, true
+	default:
+		panicf("unrecognised comparison operator %q", op)
+		return false, false
+	}
+}
+
 func needComparableTypes(op jparse.ComparisonOperator) bool {
 	switch op {
 	case jparse.ComparisonEqual, jparse.ComparisonNotEqual, jparse.ComparisonIn:
@@ -1199,10 +1771,36 @@ func evalBooleanOperator(node *jparse.BooleanOperatorNode, data reflect.Value, e
 	return reflect.ValueOf(b), nil
 }
 
+func evalCoalescingOperator(node *jparse.CoalescingOperatorNode, data reflect.Value, env *environment) (reflect.Value, error) {
+	// Evaluate the left side once and only evaluate the
+	// right side if the left side is not usable.
+	lhs, err := eval(node.LHS, data, env)
+	if err != nil {
+		return undefined, err
+	}
+
+	switch node.Type {
+	case jparse.CoalesceUndefined:
+		if lhs != undefined {
+			return lhs, nil
+		}
+	case jparse.CoalesceFalsy:
+		if jlib.Boolean(lhs) {
+			return lhs, nil
+		}
+	default:
+		panicf("unrecognised coalescing operator %q", node.Type)
+	}
+
+	return eval(node.RHS, data, env)
+}
+
 func evalStringConcatenation(node *jparse.StringConcatenationNode, data reflect.Value, env *environment) (reflect.Value, error) {
 	stringify := func(v reflect.Value) (string, error) {
 
-		if v == undefined || !v.CanInterface() {
+		// Resolve values such as a time.Time to their JSON
+		// equivalents so that they are not quoted.
+		if v = jtypes.Resolve(v); v == undefined || !v.CanInterface() {
 			return "", nil
 		}
 		return jlib.String(v.Interface())
@@ -1230,6 +1828,11 @@ func evalStringConcatenation(node *jparse.StringConcatenationNode, data reflect.
 		return undefined, err
 	}
 
+	// Check the length of the result before allocating it.
+	if max := env.maxStringLength(); max > 0 && len(s1)+len(s2) > max {
+		return undefined, newEvalError(ErrMaxStringLength, nil, strconv.Itoa(max))
+	}
+
 	return reflect.ValueOf(s1 + s2), nil
 }
 
@@ -1246,8 +1849,10 @@ func walkObjectValues(v reflect.Value, fn func(reflect.Value)) {
 			fn(v.MapIndex(k))
 		}
 	case jtypes.IsStruct(v):
-		for i, N := 0, v.NumField(); i < N; i++ {
-			fn(v.Field(i))
+		for _, f := range jtypes.StructFields(v.Type()) {
+			if fv := f.Value(v); fv.IsValid() {
+				fn(fv)
+			}
 		}
 	}
 }
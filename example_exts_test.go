@@ -48,3 +48,29 @@ func ExampleExpr_RegisterExts() {
 	fmt.Println(res)
 	// Output: Beneath The Underdog
 }
+
+func ExampleCompiler() {
+
+	// Create a Compiler with the titlecase function. The
+	// Compiler can be shared and used to compile many
+	// expressions.
+	c := jsonata.NewCompiler().WithExts(exts)
+
+	// Create an expression that uses the titlecase function.
+	// The Expr is immutable and safe for concurrent use.
+	e := c.MustCompile(`$titlecase($album)`)
+
+	// Evaluate with a variable that applies to this
+	// evaluation only.
+	res, err := e.EvalWith(nil, jsonata.EvalOptions{
+		Vars: map[string]interface{}{
+			"album": "mingus ah um",
+		},
+	})
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println(res)
+	// Output: Mingus Ah Um
+}
@@ -5,7 +5,6 @@
 package jlib
 
 import (
-	"fmt"
 	"reflect"
 
 	"github.com/blues/jsonata-go/jtypes"
@@ -19,7 +18,7 @@ func Sum(v reflect.Value) (float64, error) {
 		if n, ok := jtypes.AsNumber(v); ok {
 			return n, nil
 		}
-		return 0, fmt.Errorf("cannot call sum on a non-array type")
+		return 0, newError("sum", ErrNonArray)
 	}
 
 	v = jtypes.Resolve(v)
@@ -29,7 +28,7 @@ func Sum(v reflect.Value) (float64, error) {
 	for i := 0; i < v.Len(); i++ {
 		n, ok := jtypes.AsNumber(v.Index(i))
 		if !ok {
-			return 0, fmt.Errorf("cannot call sum on an array with non-number types")
+			return 0, newError("sum", ErrNonNumberArray)
 		}
 		sum += n
 	}
@@ -45,7 +44,7 @@ func Max(v reflect.Value) (float64, error) {
 		if n, ok := jtypes.AsNumber(v); ok {
 			return n, nil
 		}
-		return 0, fmt.Errorf("cannot call max on a non-array type")
+		return 0, newError("max", ErrNonArray)
 	}
 
 	v = jtypes.Resolve(v)
@@ -58,7 +57,7 @@ func Max(v reflect.Value) (float64, error) {
 	for i := 0; i < v.Len(); i++ {
 		n, ok := jtypes.AsNumber(v.Index(i))
 		if !ok {
-			return 0, fmt.Errorf("cannot call max on an array with non-number types")
+			return 0, newError("max", ErrNonNumberArray)
 		}
 		if i == 0 || n > max {
 			max = n
@@ -76,7 +75,7 @@ func Min(v reflect.Value) (float64, error) {
 		if n, ok := jtypes.AsNumber(v); ok {
 			return n, nil
 		}
-		return 0, fmt.Errorf("cannot call min on a non-array type")
+		return 0, newError("min", ErrNonArray)
 	}
 
 	v = jtypes.Resolve(v)
@@ -89,7 +88,7 @@ func Min(v reflect.Value) (float64, error) {
 	for i := 0; i < v.Len(); i++ {
 		n, ok := jtypes.AsNumber(v.Index(i))
 		if !ok {
-			return 0, fmt.Errorf("cannot call min on an array with non-number types")
+			return 0, newError("min", ErrNonNumberArray)
 		}
 		if i == 0 || n < min {
 			min = n
@@ -107,7 +106,7 @@ func Average(v reflect.Value) (float64, error) {
 		if n, ok := jtypes.AsNumber(v); ok {
 			return n, nil
 		}
-		return 0, fmt.Errorf("cannot call average on a non-array type")
+		return 0, newError("average", ErrNonArray)
 	}
 
 	v = jtypes.Resolve(v)
@@ -120,7 +119,7 @@ func Average(v reflect.Value) (float64, error) {
 	for i := 0; i < v.Len(); i++ {
 		n, ok := jtypes.AsNumber(v.Index(i))
 		if !ok {
-			return 0, fmt.Errorf("cannot call average on an array with non-number types")
+			return 0, newError("average", ErrNonNumberArray)
 		}
 		sum += n
 	}
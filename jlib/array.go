@@ -130,6 +130,14 @@ func Sort(v reflect.Value, swap jtypes.OptionalCallable) (interface{}, error) {
 		if v.CanInterface() {
 			return []interface{}{v.Interface()}, nil
 		}
+	}
+
+	// Resolve the items up front so that items that implement
+	// json.Marshaler or encoding.TextMarshaler are decoded once
+	// rather than in every comparison.
+	v = resolveItems(v)
+
+	switch {
 	case swap.Callable != nil:
 		return sortArrayFunc(v, swap.Callable)
 	case jtypes.IsArrayOf(v, jtypes.IsNumber):
@@ -138,7 +146,27 @@ func Sort(v reflect.Value, swap jtypes.OptionalCallable) (interface{}, error) {
 		return sortStringArray(v), nil
 	}
 
-	return nil, fmt.Errorf("argument 1 of function sort must be an array of strings or numbers")
+	return nil, newError("sort", ErrSortMismatch)
+}
+
+func resolveItems(v reflect.Value) reflect.Value {
+	size := v.Len()
+	results := make([]interface{}, 0, size)
+
+	for i := 0; i < size; i++ {
+		item := v.Index(i)
+		if !item.CanInterface() {
+			continue
+		}
+
+		var r interface{}
+		if item = jtypes.Resolve(item); item.IsValid() {
+			r = item.Interface()
+		}
+		results = append(results, r)
+	}
+
+	return reflect.ValueOf(results)
 }
 
 func sortNumberArray(v reflect.Value) []interface{} {
@@ -199,7 +227,7 @@ func sortArrayFunc(v reflect.Value, fn jtypes.Callable) (interface{}, error) {
 
 		b, ok := jtypes.AsBool(v)
 		if !ok {
-			return false, fmt.Errorf("argument 2 of function sort must be a function that returns a boolean, got %v (%s)", v, v.Kind())
+			return false, newErrorValue("sort", ErrSortComparator, fmt.Sprintf("%v (%s)", v, v.Kind()))
 		}
 
 		return b, nil
@@ -288,7 +316,7 @@ func Zip(vs ...reflect.Value) (interface{}, error) {
 	var size int
 
 	if len(vs) == 0 {
-		return nil, fmt.Errorf("cannot call zip with no arguments")
+		return nil, newError("zip", ErrNoArgs)
 	}
 
 	for i := 0; i < len(vs); i++ {
@@ -5,11 +5,16 @@
 package jlib
 
 import (
-	"fmt"
-	"regexp"
+	"math"
 	"strconv"
+	"strings"
+	"sync"
 	"time"
 
+	// Embed the IANA time zone database so that named
+	// time zones work on systems that don't have one.
+	_ "time/tzdata"
+
 	"github.com/blues/jsonata-go/jlib/jxpath"
 	"github.com/blues/jsonata-go/jtypes"
 )
@@ -27,6 +32,13 @@ var defaultParseTimeLayouts = []string{
 
 // FromMillis (golint)
 func FromMillis(ms int64, picture jtypes.OptionalString, tz jtypes.OptionalString) (string, error) {
+	return FromMillisLanguage(ms, picture, tz, jtypes.OptionalString{})
+}
+
+// FromMillisLanguage is like FromMillis but formats names
+// (e.g. of months) in the given language (see
+// jxpath.RegisterLanguage). The default language is English.
+func FromMillisLanguage(ms int64, picture jtypes.OptionalString, tz jtypes.OptionalString, language jtypes.OptionalString) (string, error) {
 
 	t := msToTime(ms).UTC()
 
@@ -39,22 +51,40 @@ func FromMillis(ms int64, picture jtypes.OptionalString, tz jtypes.OptionalStrin
 		t = t.In(loc)
 	}
 
+	if language.String != "" {
+		if _, ok := jxpath.LookupLanguage(language.String); !ok {
+			return "", newErrorValue("fromMillis", ErrUnknownLanguage, language.String)
+		}
+	}
+
 	layout := picture.String
 	if layout == "" {
 		layout = defaultFormatTimeLayout
 	}
 
-	return jxpath.FormatTime(t, layout)
+	s, err := jxpath.FormatTimeLanguage(t, layout, language.String)
+	if err != nil {
+		return "", newErrorValue("fromMillis", ErrDatePicture, err.Error())
+	}
+
+	return s, nil
 }
 
 // parseTimeZone parses a JSONata timezone.
 //
-// The format is a "+" or "-" character, followed by four digits, the first two
-// denoting the hour offset, and the last two denoting the minute offset.
+// The format is either an IANA time zone name such as
+// "America/New_York", or a "+" or "-" character followed by
+// four digits, the first two denoting the hour offset, and the
+// last two denoting the minute offset.
 func parseTimeZone(tz string) (*time.Location, error) {
+
+	if tz != "" && tz[0] != '+' && tz[0] != '-' {
+		return loadLocation(tz)
+	}
+
 	// must be exactly 5 characters
 	if len(tz) != 5 {
-		return nil, fmt.Errorf("invalid timezone")
+		return nil, newError("", ErrInvalidTimeZone)
 	}
 
 	plusOrMinus := string(tz[0])
@@ -68,19 +98,19 @@ func parseTimeZone(tz string) (*time.Location, error) {
 	case "+":
 		offsetMultiplier = 1
 	default:
-		return nil, fmt.Errorf("invalid timezone")
+		return nil, newError("", ErrInvalidTimeZone)
 	}
 
 	// take the first two digits as "HH"
 	hours, err := strconv.Atoi(tz[1:3])
 	if err != nil {
-		return nil, fmt.Errorf("invalid timezone")
+		return nil, newError("", ErrInvalidTimeZone)
 	}
 
 	// take the last two digits as "MM"
 	minutes, err := strconv.Atoi(tz[3:5])
 	if err != nil {
-		return nil, fmt.Errorf("invalid timezone")
+		return nil, newError("", ErrInvalidTimeZone)
 	}
 
 	// convert to seconds
@@ -92,50 +122,410 @@ func parseTimeZone(tz string) (*time.Location, error) {
 	return loc, nil
 }
 
+// locations caches the time zones loaded by loadLocation.
+var locations sync.Map
+
+// loadLocation returns the IANA time zone with the given name.
+// Unlike time.LoadLocation, it does not accept "Local", so the
+// result of an evaluation doesn't depend on the host system.
+func loadLocation(name string) (*time.Location, error) {
+
+	if loc, ok := locations.Load(name); ok {
+		return loc.(*time.Location), nil
+	}
+
+	if name == "Local" {
+		return nil, newErrorValue("", ErrUnknownTimeZone, name)
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return nil, newErrorValue("", ErrUnknownTimeZone, name)
+	}
+
+	locations.Store(name, loc)
+	return loc, nil
+}
+
 // ToMillis (golint)
 func ToMillis(s string, picture jtypes.OptionalString, tz jtypes.OptionalString) (int64, error) {
+	return ToMillisLanguage(s, picture, tz, jtypes.OptionalString{})
+}
+
+// ToMillisLanguage is like ToMillis but parses names (e.g. of
+// months) in the given language (see jxpath.RegisterLanguage).
+// The default language is English.
+func ToMillisLanguage(s string, picture jtypes.OptionalString, tz jtypes.OptionalString, language jtypes.OptionalString) (int64, error) {
+
 	layouts := defaultParseTimeLayouts
 	if picture.String != "" {
 		layouts = []string{picture.String}
 	}
 
-	// TODO: How are timezones used for parsing?
+	if language.String != "" {
+		if _, ok := jxpath.LookupLanguage(language.String); !ok {
+			return 0, newErrorValue("toMillis", ErrUnknownLanguage, language.String)
+		}
+	}
+
+	// The timezone applies to times without a timezone of
+	// their own.
+	loc := time.UTC
+	if tz.String != "" {
+		var err error
+		if loc, err = parseTimeZone(tz.String); err != nil {
+			return 0, err
+		}
+	}
 
 	for _, l := range layouts {
-		if t, err := parseTime(s, l); err == nil {
+		if t, err := jxpath.ParseTimeIn(s, l, language.String, loc); err == nil {
 			return timeToMS(t), nil
 		}
 	}
 
-	return 0, fmt.Errorf("could not parse time %q", s)
+	return 0, newErrorValue("toMillis", ErrParseTime, s)
 }
 
-var reMinus7 = regexp.MustCompile("-(0*7)")
+// A dateUnit is a unit of time used by the date arithmetic
+// functions.
+type dateUnit int
 
-func parseTime(s string, picture string) (time.Time, error) {
-	// Go's reference time: Mon Jan 2 15:04:05 MST 2006
-	refTime := time.Date(2006, time.January, 2, 15, 4, 5, 0, time.FixedZone("MST", -7*60*60))
+const (
+	_ dateUnit = iota
+	unitMillisecond
+	unitSecond
+	unitMinute
+	unitHour
+	unitDay
+	unitWeek
+	unitMonth
+	unitYear
+)
 
-	layout, err := jxpath.FormatTime(refTime, picture)
+var dateUnits = map[string]dateUnit{
+	"millisecond": unitMillisecond,
+	"second":      unitSecond,
+	"minute":      unitMinute,
+	"hour":        unitHour,
+	"day":         unitDay,
+	"week":        unitWeek,
+	"month":       unitMonth,
+	"year":        unitYear,
+}
+
+// unitMillis holds the length in milliseconds of the units
+// that have a fixed length. Days, weeks, months and years
+// vary in length (e.g. on daylight saving transitions) so
+// they are handled in terms of the calendar.
+var unitMillis = map[dateUnit]int64{
+	unitMillisecond: 1,
+	unitSecond:      1000,
+	unitMinute:      60 * 1000,
+	unitHour:        60 * 60 * 1000,
+}
+
+// parseDateUnit parses the name of a date unit. Names are
+// case insensitive and may be singular or plural.
+func parseDateUnit(s string) (dateUnit, error) {
+
+	name := strings.ToLower(s)
+
+	unit, ok := dateUnits[name]
+	if !ok {
+		unit, ok = dateUnits[strings.TrimSuffix(name, "s")]
+	}
+
+	if !ok {
+		return 0, newErrorValue("", ErrUnknownDateUnit, s)
+	}
+
+	return unit, nil
+}
+
+// millisInZone converts a number of milliseconds since the
+// Unix epoch to a time in the given JSONata timezone (or UTC
+// if no timezone is given).
+func millisInZone(ms int64, tz jtypes.OptionalString) (time.Time, error) {
+
+	t := msToTime(ms).UTC()
+
+	if tz.String != "" {
+		loc, err := parseTimeZone(tz.String)
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		t = t.In(loc)
+	}
+
+	return t, nil
+}
+
+// DateAdd adds an amount of the given unit to a time expressed
+// in milliseconds since the Unix epoch. Days, weeks, months and
+// years are added to the local date in the given timezone, so
+// that adding a day across a daylight saving transition keeps
+// the same wall clock time. Adding months to a day that does
+// not exist in the resulting month (e.g. adding one month to
+// the 31st of January) returns the last day of that month.
+func DateAdd(ms int64, amount float64, unit string, tz jtypes.OptionalString) (int64, error) {
+
+	u, err := parseDateUnit(unit)
 	if err != nil {
-		return time.Time{}, fmt.Errorf("the second argument of the toMillis function must be a valid date format")
+		return 0, err
+	}
+
+	if size, ok := unitMillis[u]; ok {
+		return ms + int64(math.Round(amount*float64(size))), nil
 	}
 
-	// Replace -07:00 with Z07:00
-	layout = reMinus7.ReplaceAllString(layout, "Z$1")
+	n := int(amount)
+	if float64(n) != amount {
+		return 0, newErrorValue("dateAdd", ErrNonIntegerAmount, unit)
+	}
 
-	t, err := time.Parse(layout, s)
+	t, err := millisInZone(ms, tz)
 	if err != nil {
-		return time.Time{}, fmt.Errorf("could not parse time %q", s)
+		return 0, err
 	}
 
-	return t, nil
+	switch u {
+	case unitDay:
+		t = t.AddDate(0, 0, n)
+	case unitWeek:
+		t = t.AddDate(0, 0, 7*n)
+	case unitMonth:
+		t = addMonths(t, n)
+	case unitYear:
+		t = addMonths(t, 12*n)
+	}
+
+	return timeToMS(t), nil
+}
+
+// DateTrunc truncates a time expressed in milliseconds since
+// the Unix epoch to the start of the given unit in the given
+// timezone. Weeks start on Monday, as in ISO 8601.
+func DateTrunc(ms int64, unit string, tz jtypes.OptionalString) (int64, error) {
+
+	u, err := parseDateUnit(unit)
+	if err != nil {
+		return 0, err
+	}
+
+	t, err := millisInZone(ms, tz)
+	if err != nil {
+		return 0, err
+	}
+
+	// Truncate fixed length units relative to the local
+	// time so that, for example, truncating to the hour
+	// works in timezones with a half hour offset.
+	if size, ok := unitMillis[u]; ok {
+		_, offset := t.Zone()
+		local := ms + int64(offset)*1000
+		return local - floorMod(local, size) - int64(offset)*1000, nil
+	}
+
+	year, month, day := t.Date()
+
+	switch u {
+	case unitWeek:
+		day -= (int(t.Weekday()) + 6) % 7
+	case unitMonth:
+		day = 1
+	case unitYear:
+		month, day = time.January, 1
+	}
+
+	return timeToMS(time.Date(year, month, day, 0, 0, 0, 0, t.Location())), nil
+}
+
+// DateDiff returns the number of whole units between two times
+// expressed in milliseconds since the Unix epoch. The result is
+// negative if the second time is before the first. Days, weeks,
+// months and years are counted on the calendar in the given
+// timezone, so a day that is shortened or lengthened by a
+// daylight saving transition still counts as one day.
+func DateDiff(from int64, to int64, unit string, tz jtypes.OptionalString) (int64, error) {
+
+	u, err := parseDateUnit(unit)
+	if err != nil {
+		return 0, err
+	}
+
+	if size, ok := unitMillis[u]; ok {
+		return (to - from) / size, nil
+	}
+
+	t1, err := millisInZone(from, tz)
+	if err != nil {
+		return 0, err
+	}
+
+	t2 := msToTime(to).In(t1.Location())
+
+	switch u {
+	case unitDay:
+		return int64(diffDays(t1, t2)), nil
+	case unitWeek:
+		return int64(diffDays(t1, t2) / 7), nil
+	case unitMonth:
+		return int64(diffMonths(t1, t2)), nil
+	default:
+		return int64(diffMonths(t1, t2) / 12), nil
+	}
+}
+
+// dateParts maps the lower case names of date components to
+// functions that extract them from a time.
+var dateParts = map[string]func(time.Time) int{
+	"year": func(t time.Time) int {
+		return t.Year()
+	},
+	"month": func(t time.Time) int {
+		return int(t.Month())
+	},
+	"day": func(t time.Time) int {
+		return t.Day()
+	},
+	"hour": func(t time.Time) int {
+		return t.Hour()
+	},
+	"minute": func(t time.Time) int {
+		return t.Minute()
+	},
+	"second": func(t time.Time) int {
+		return t.Second()
+	},
+	"millisecond": func(t time.Time) int {
+		return t.Nanosecond() / int(time.Millisecond)
+	},
+	"dayofweek": func(t time.Time) int {
+		return (int(t.Weekday())+6)%7 + 1
+	},
+	"dayofyear": func(t time.Time) int {
+		return t.YearDay()
+	},
+	"isoweek": func(t time.Time) int {
+		_, w := t.ISOWeek()
+		return w
+	},
+	"isoyear": func(t time.Time) int {
+		y, _ := t.ISOWeek()
+		return y
+	},
+	"quarter": func(t time.Time) int {
+		return (int(t.Month())-1)/3 + 1
+	},
+}
+
+// DatePart returns a component of a time expressed in
+// milliseconds since the Unix epoch, in the given timezone.
+// The components are year, month, day, hour, minute, second,
+// millisecond, dayOfWeek (1 for Monday to 7 for Sunday),
+// dayOfYear, isoWeek, isoYear (the year that the ISO week
+// belongs to) and quarter. As with date units, the names are
+// case insensitive and may be singular or plural.
+func DatePart(ms int64, part string, tz jtypes.OptionalString) (int, error) {
+
+	name := strings.ToLower(part)
+
+	fn, ok := dateParts[name]
+	if !ok {
+		fn, ok = dateParts[strings.TrimSuffix(name, "s")]
+	}
+
+	if !ok {
+		return 0, newErrorValue("datePart", ErrUnknownDatePart, part)
+	}
+
+	t, err := millisInZone(ms, tz)
+	if err != nil {
+		return 0, err
+	}
+
+	return fn(t), nil
+}
+
+// addMonths adds n months to a time. Unlike time.AddDate,
+// it clamps the day to the end of the resulting month rather
+// than overflowing into the next one.
+func addMonths(t time.Time, n int) time.Time {
+
+	year, month, day := t.Date()
+	hour, min, sec := t.Clock()
+
+	first := time.Date(year, month+time.Month(n), 1, 0, 0, 0, 0, time.UTC)
+	if last := daysIn(first.Month(), first.Year()); day > last {
+		day = last
+	}
+
+	return time.Date(first.Year(), first.Month(), day, hour, min, sec, t.Nanosecond(), t.Location())
+}
+
+func daysIn(month time.Month, year int) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+// diffDays returns the number of whole calendar days from
+// t1 to t2. Both times must be in the same location.
+func diffDays(t1, t2 time.Time) int {
+
+	y1, m1, d1 := t1.Date()
+	y2, m2, d2 := t2.Date()
+
+	days := time.Date(y2, m2, d2, 0, 0, 0, 0, time.UTC).Sub(time.Date(y1, m1, d1, 0, 0, 0, 0, time.UTC))
+	n := int(days.Hours() / 24)
+
+	// Don't count the last day if it's incomplete.
+	switch c := t1.AddDate(0, 0, n); {
+	case n > 0 && c.After(t2):
+		n--
+	case n < 0 && c.Before(t2):
+		n++
+	}
+
+	return n
+}
+
+// diffMonths returns the number of whole calendar months from
+// t1 to t2. Both times must be in the same location.
+func diffMonths(t1, t2 time.Time) int {
+
+	n := (t2.Year()-t1.Year())*12 + int(t2.Month()-t1.Month())
+
+	// Don't count the last month if it's incomplete.
+	switch c := addMonths(t1, n); {
+	case n > 0 && c.After(t2):
+		n--
+	case n < 0 && c.Before(t2):
+		n++
+	}
+
+	return n
+}
+
+// floorMod returns the remainder of a divided by b, with the
+// same sign as b.
+func floorMod(a, b int64) int64 {
+
+	m := a % b
+	if m != 0 && (m < 0) != (b < 0) {
+		m += b
+	}
+
+	return m
 }
 
 func msToTime(ms int64) time.Time {
 	return time.Unix(ms/1000, (ms%1000)*int64(time.Millisecond))
 }
 
+// timeToMS converts a time to milliseconds since the Unix
+// epoch. Unlike UnixNano, it does not overflow for times
+// after the year 2262.
 func timeToMS(t time.Time) int64 {
-	return t.UnixNano() / int64(time.Millisecond)
+	return t.Unix()*1000 + int64(t.Nanosecond())/int64(time.Millisecond)
 }
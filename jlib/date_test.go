@@ -21,6 +21,7 @@ func TestFromMillis(t *testing.T) {
 	data := []struct {
 		Picture       string
 		TZ            string
+		Language      string
 		Output        string
 		ExpectedError bool
 	}{
@@ -85,6 +86,28 @@ func TestFromMillis(t *testing.T) {
 			TZ:            "-0",
 			ExpectedError: true,
 		},
+		{
+			Picture: "[H01]:[m01]:[s01] [Z]",
+			TZ:      "America/New_York",
+			Output:  "11:58:05 -04:00",
+		},
+		{
+			Picture: "[H01]:[m01]:[s01] [Z]",
+			TZ:      "Asia/Kathmandu",
+			Output:  "21:43:05 +05:45",
+		},
+		{
+			Picture: "[H01]:[m01]:[s01] [Z]",
+			// Unknown TZ
+			TZ:            "Mars/Olympus_Mons",
+			ExpectedError: true,
+		},
+		{
+			Picture: "[H01]:[m01]:[s01] [Z]",
+			// The host's TZ is not allowed
+			TZ:            "Local",
+			ExpectedError: true,
+		},
 		{
 			Picture: "[h].[m01][Pn] on [FNn], [D1o] [MNn]",
 			Output:  "3.58pm on Sunday, 30th September",
@@ -93,6 +116,28 @@ func TestFromMillis(t *testing.T) {
 			Picture: "[M01]/[D01]/[Y0001] at [H01]:[m01]:[s01]",
 			Output:  "09/30/2018 at 15:58:05",
 		},
+		{
+			Picture:  "[FNn], [D]. [MNn] [Y] [H01]:[m01]",
+			Language: "de",
+			Output:   "Sonntag, 30. September 2018 15:58",
+		},
+		{
+			Picture:  "[FNn,*-3] [D] [MNn,*-3] [Y]",
+			TZ:       "Europe/Paris",
+			Language: "fr-FR",
+			Output:   "Dim 30 Sep 2018",
+		},
+		{
+			Picture:  "[h]:[m01] [PN]",
+			Language: "es",
+			Output:   "3:58 P. M.",
+		},
+		{
+			Picture: "[MNn]",
+			// Unknown language
+			Language:      "xx",
+			ExpectedError: true,
+		},
 	}
 
 	for _, test := range data {
@@ -108,12 +153,569 @@ func TestFromMillis(t *testing.T) {
 			tz.Set(reflect.ValueOf(test.TZ))
 		}
 
-		got, err := jlib.FromMillis(input, picture, tz)
+		got, err := jlib.FromMillisLanguage(input, picture, tz, optionalString(test.Language))
 
 		if test.ExpectedError && err == nil {
 			t.Errorf("%s: Expected error, got nil", test.Picture)
 		} else if got != test.Output {
 			t.Errorf("%s: Expected %q, got %q", test.Picture, test.Output, got)
 		}
+
+		// FromMillis uses the default language.
+		if test.Language == "" {
+			if s, _ := jlib.FromMillis(input, picture, tz); s != got {
+				t.Errorf("%s: FromMillis: Expected %q, got %q", test.Picture, got, s)
+			}
+		}
+	}
+}
+
+func millis(s string) int64 {
+
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		panic(err)
+	}
+
+	return t.Unix()*1000 + int64(t.Nanosecond())/int64(time.Millisecond)
+}
+
+func optionalString(s string) jtypes.OptionalString {
+
+	var opt jtypes.OptionalString
+	if s != "" {
+		opt.Set(reflect.ValueOf(s))
+	}
+
+	return opt
+}
+
+func TestToMillis(t *testing.T) {
+
+	data := []struct {
+		Input         string
+		Picture       string
+		TZ            string
+		Language      string
+		Output        string
+		ExpectedError bool
+	}{
+		{
+			Input:  "2018-09-30T15:58:05.762Z",
+			Output: "2018-09-30T15:58:05.762Z",
+		},
+		{
+			// The timezone doesn't apply to times that
+			// have their own.
+			Input:  "2018-09-30T15:58:05.762Z",
+			TZ:     "Asia/Tokyo",
+			Output: "2018-09-30T15:58:05.762Z",
+		},
+		{
+			Input:  "2018-09-30",
+			TZ:     "+0530",
+			Output: "2018-09-30T00:00:00+05:30",
+		},
+		{
+			Input:   "2024-07-01 09:15",
+			Picture: "[Y]-[M]-[D] [H]:[m]",
+			TZ:      "America/New_York",
+			Output:  "2024-07-01T09:15:00-04:00",
+		},
+		{
+			// Times skipped when the clocks go forward
+			// are moved forward...
+			Input:   "2024-03-10 02:30",
+			Picture: "[Y]-[M]-[D] [H]:[m]",
+			TZ:      "America/New_York",
+			Output:  "2024-03-10T03:30:00-04:00",
+		},
+		{
+			// ...and times repeated when they go back
+			// resolve to the first occurrence.
+			Input:   "2024-11-03 01:30",
+			Picture: "[Y]-[M]-[D] [H]:[m]",
+			TZ:      "America/New_York",
+			Output:  "2024-11-03T01:30:00-04:00",
+		},
+		{
+			Input:         "2024-03-10 02:30",
+			Picture:       "[Y]-[M]-[D] [H]:[m]",
+			TZ:            "Mars/Base",
+			ExpectedError: true,
+		},
+		{
+			Input:   "30th September, 2018",
+			Picture: "[D1o] [MNn], [Y]",
+			Output:  "2018-09-30T00:00:00Z",
+		},
+		{
+			Input:    "Sonntag, 30. September 2018 15:58",
+			Picture:  "[FNn], [D]. [MNn] [Y] [H01]:[m01]",
+			Language: "de",
+			Output:   "2018-09-30T15:58:00Z",
+		},
+		{
+			Input:    "30 mars 2018",
+			Picture:  "[D] [MNn] [Y]",
+			Language: "fr-CA",
+			Output:   "2018-03-30T00:00:00Z",
+		},
+		{
+			Input:    "30 März 2018",
+			Picture:  "[D] [MNn] [Y]",
+			Language: "de",
+			Output:   "2018-03-30T00:00:00Z",
+		},
+		{
+			// English names are not recognised
+			// in other languages.
+			Input:         "30 March 2018",
+			Picture:       "[D] [MNn] [Y]",
+			Language:      "de",
+			ExpectedError: true,
+		},
+		{
+			// Unknown language
+			Input:         "2018-09-30",
+			Picture:       "[Y]-[M]-[D]",
+			Language:      "xx",
+			ExpectedError: true,
+		},
+	}
+
+	for _, test := range data {
+
+		got, err := jlib.ToMillisLanguage(test.Input, optionalString(test.Picture), optionalString(test.TZ), optionalString(test.Language))
+
+		if test.ExpectedError {
+			if err == nil {
+				t.Errorf("%s: Expected error, got nil", test.Input)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s: Expected nil error, got %s", test.Input, err)
+			continue
+		}
+
+		if exp := millis(test.Output); got != exp {
+			t.Errorf("%s: Expected %d, got %d", test.Input, exp, got)
+		}
+	}
+}
+
+func TestDateAdd(t *testing.T) {
+
+	data := []struct {
+		Time          string
+		Amount        float64
+		Unit          string
+		TZ            string
+		Output        string
+		ExpectedError bool
+	}{
+		{
+			Time:   "2018-09-30T15:58:05.762Z",
+			Amount: 250,
+			Unit:   "millisecond",
+			Output: "2018-09-30T15:58:06.012Z",
+		},
+		{
+			Time:   "2018-09-30T15:58:05Z",
+			Amount: -90,
+			Unit:   "minutes",
+			Output: "2018-09-30T14:28:05Z",
+		},
+		{
+			Time:   "2018-09-30T15:58:05Z",
+			Amount: 1.5,
+			Unit:   "Hour",
+			Output: "2018-09-30T17:28:05Z",
+		},
+		{
+			// A day is 23 hours when the clocks go forward...
+			Time:   "2024-03-09T12:00:00-05:00",
+			Amount: 1,
+			Unit:   "day",
+			TZ:     "America/New_York",
+			Output: "2024-03-10T12:00:00-04:00",
+		},
+		{
+			// ...and 25 hours when they go back.
+			Time:   "2024-11-02T12:00:00-04:00",
+			Amount: 1,
+			Unit:   "day",
+			TZ:     "America/New_York",
+			Output: "2024-11-03T12:00:00-05:00",
+		},
+		{
+			// Without a timezone, days are added in UTC.
+			Time:   "2024-03-09T12:00:00-05:00",
+			Amount: 1,
+			Unit:   "day",
+			Output: "2024-03-10T12:00:00-05:00",
+		},
+		{
+			Time:   "2024-03-09T12:00:00-05:00",
+			Amount: 24,
+			Unit:   "hours",
+			TZ:     "America/New_York",
+			Output: "2024-03-10T13:00:00-04:00",
+		},
+		{
+			Time:   "2024-01-01T00:00:00Z",
+			Amount: -2,
+			Unit:   "weeks",
+			Output: "2023-12-18T00:00:00Z",
+		},
+		{
+			Time:   "2024-01-31T10:00:00Z",
+			Amount: 1,
+			Unit:   "month",
+			Output: "2024-02-29T10:00:00Z",
+		},
+		{
+			Time:   "2024-01-31T10:00:00Z",
+			Amount: 13,
+			Unit:   "months",
+			Output: "2025-02-28T10:00:00Z",
+		},
+		{
+			Time:   "2024-02-29T10:00:00Z",
+			Amount: -1,
+			Unit:   "year",
+			Output: "2023-02-28T10:00:00Z",
+		},
+		{
+			// Times after 2262 are beyond the range of
+			// time.Duration.
+			Time:   "2262-04-11T00:00:00Z",
+			Amount: 1,
+			Unit:   "day",
+			Output: "2262-04-12T00:00:00Z",
+		},
+		{
+			Time:          "2024-01-01T00:00:00Z",
+			Amount:        1.5,
+			Unit:          "days",
+			ExpectedError: true,
+		},
+		{
+			Time:          "2024-01-01T00:00:00Z",
+			Amount:        1,
+			Unit:          "fortnight",
+			ExpectedError: true,
+		},
+		{
+			Time:          "2024-01-01T00:00:00Z",
+			Amount:        1,
+			Unit:          "day",
+			TZ:            "+5",
+			ExpectedError: true,
+		},
+	}
+
+	for _, test := range data {
+
+		got, err := jlib.DateAdd(millis(test.Time), test.Amount, test.Unit, optionalString(test.TZ))
+
+		switch {
+		case test.ExpectedError && err == nil:
+			t.Errorf("%s + %v %s: Expected error, got nil", test.Time, test.Amount, test.Unit)
+		case !test.ExpectedError && err != nil:
+			t.Errorf("%s + %v %s: Unexpected error: %s", test.Time, test.Amount, test.Unit, err)
+		case !test.ExpectedError && got != millis(test.Output):
+			t.Errorf("%s + %v %s: Expected %d, got %d", test.Time, test.Amount, test.Unit, millis(test.Output), got)
+		}
+	}
+}
+
+func TestDateTrunc(t *testing.T) {
+
+	data := []struct {
+		Time          string
+		Unit          string
+		TZ            string
+		Output        string
+		ExpectedError bool
+	}{
+		{
+			Time:   "2018-09-30T15:58:05.762Z",
+			Unit:   "second",
+			Output: "2018-09-30T15:58:05Z",
+		},
+		{
+			Time:   "2018-09-30T15:58:05.762Z",
+			Unit:   "minute",
+			Output: "2018-09-30T15:58:00Z",
+		},
+		{
+			Time:   "2018-09-30T15:58:05.762Z",
+			Unit:   "hour",
+			TZ:     "Asia/Kolkata",
+			Output: "2018-09-30T21:00:00+05:30",
+		},
+		{
+			Time:   "2018-09-30T15:58:05.762Z",
+			Unit:   "day",
+			Output: "2018-09-30T00:00:00Z",
+		},
+		{
+			Time:   "2018-09-30T15:58:05.762Z",
+			Unit:   "day",
+			TZ:     "Asia/Tokyo",
+			Output: "2018-10-01T00:00:00+09:00",
+		},
+		{
+			// The second 1:30am on the day the clocks go back
+			// is truncated to the second 1am.
+			Time:   "2024-11-03T01:30:00-05:00",
+			Unit:   "hour",
+			TZ:     "America/New_York",
+			Output: "2024-11-03T01:00:00-05:00",
+		},
+		{
+			Time:   "2024-11-03T18:00:00-05:00",
+			Unit:   "day",
+			TZ:     "America/New_York",
+			Output: "2024-11-03T00:00:00-04:00",
+		},
+		{
+			// Weeks start on Monday.
+			Time:   "2018-09-30T15:58:05.762Z",
+			Unit:   "week",
+			Output: "2018-09-24T00:00:00Z",
+		},
+		{
+			Time:   "2018-09-24T00:00:00Z",
+			Unit:   "week",
+			Output: "2018-09-24T00:00:00Z",
+		},
+		{
+			Time:   "2018-09-30T15:58:05.762Z",
+			Unit:   "month",
+			Output: "2018-09-01T00:00:00Z",
+		},
+		{
+			Time:   "2018-09-30T15:58:05.762Z",
+			Unit:   "year",
+			TZ:     "Europe/Berlin",
+			Output: "2018-01-01T00:00:00+01:00",
+		},
+		{
+			Time:   "1969-12-31T23:59:59.500Z",
+			Unit:   "second",
+			Output: "1969-12-31T23:59:59Z",
+		},
+		{
+			Time:          "2018-09-30T15:58:05.762Z",
+			Unit:          "decade",
+			ExpectedError: true,
+		},
+	}
+
+	for _, test := range data {
+
+		got, err := jlib.DateTrunc(millis(test.Time), test.Unit, optionalString(test.TZ))
+
+		switch {
+		case test.ExpectedError && err == nil:
+			t.Errorf("%s to %s: Expected error, got nil", test.Time, test.Unit)
+		case !test.ExpectedError && err != nil:
+			t.Errorf("%s to %s: Unexpected error: %s", test.Time, test.Unit, err)
+		case !test.ExpectedError && got != millis(test.Output):
+			t.Errorf("%s to %s: Expected %d, got %d", test.Time, test.Unit, millis(test.Output), got)
+		}
+	}
+}
+
+func TestDateDiff(t *testing.T) {
+
+	data := []struct {
+		From          string
+		To            string
+		Unit          string
+		TZ            string
+		Output        int64
+		ExpectedError bool
+	}{
+		{
+			From:   "2018-09-30T15:58:05.762Z",
+			To:     "2018-09-30T15:58:06.761Z",
+			Unit:   "second",
+			Output: 0,
+		},
+		{
+			From:   "2018-09-30T15:58:05Z",
+			To:     "2018-09-30T12:00:00Z",
+			Unit:   "minutes",
+			Output: -238,
+		},
+		{
+			// 23 hours is one day when the clocks go forward.
+			From:   "2024-03-09T12:00:00-05:00",
+			To:     "2024-03-10T12:00:00-04:00",
+			Unit:   "days",
+			TZ:     "America/New_York",
+			Output: 1,
+		},
+		{
+			From:   "2024-03-09T12:00:00-05:00",
+			To:     "2024-03-10T12:00:00-04:00",
+			Unit:   "days",
+			Output: 0,
+		},
+		{
+			From:   "2024-03-09T12:00:00-05:00",
+			To:     "2024-03-10T12:00:00-04:00",
+			Unit:   "hours",
+			TZ:     "America/New_York",
+			Output: 23,
+		},
+		{
+			From:   "2024-01-10T00:00:00Z",
+			To:     "2024-01-01T12:00:00Z",
+			Unit:   "days",
+			Output: -8,
+		},
+		{
+			From:   "2024-01-01T00:00:00Z",
+			To:     "2024-01-15T00:00:00Z",
+			Unit:   "week",
+			Output: 2,
+		},
+		{
+			From:   "2024-01-31T00:00:00Z",
+			To:     "2024-02-29T00:00:00Z",
+			Unit:   "month",
+			Output: 1,
+		},
+		{
+			From:   "2024-01-15T00:00:00Z",
+			To:     "2024-02-14T23:59:59Z",
+			Unit:   "months",
+			Output: 0,
+		},
+		{
+			From:   "2024-02-29T00:00:00Z",
+			To:     "2020-03-01T00:00:00Z",
+			Unit:   "years",
+			Output: -3,
+		},
+		{
+			From:          "2024-02-29T00:00:00Z",
+			To:            "2020-03-01T00:00:00Z",
+			Unit:          "years",
+			TZ:            "Nowhere",
+			ExpectedError: true,
+		},
+	}
+
+	for _, test := range data {
+
+		got, err := jlib.DateDiff(millis(test.From), millis(test.To), test.Unit, optionalString(test.TZ))
+
+		switch {
+		case test.ExpectedError && err == nil:
+			t.Errorf("%s to %s in %s: Expected error, got nil", test.From, test.To, test.Unit)
+		case !test.ExpectedError && err != nil:
+			t.Errorf("%s to %s in %s: Unexpected error: %s", test.From, test.To, test.Unit, err)
+		case got != test.Output:
+			t.Errorf("%s to %s in %s: Expected %d, got %d", test.From, test.To, test.Unit, test.Output, got)
+		}
+	}
+}
+
+func TestDatePart(t *testing.T) {
+
+	input := millis("2021-01-03T23:30:05.762Z")
+
+	data := []struct {
+		Part          string
+		TZ            string
+		Output        int
+		ExpectedError bool
+	}{
+		{
+			Part:   "year",
+			Output: 2021,
+		},
+		{
+			Part:   "isoYear",
+			Output: 2020,
+		},
+		{
+			Part:   "isoWeek",
+			Output: 53,
+		},
+		{
+			Part:   "dayOfWeek",
+			Output: 7,
+		},
+		{
+			Part:   "dayOfWeek",
+			TZ:     "Europe/Paris",
+			Output: 1,
+		},
+		{
+			Part:   "isoWeek",
+			TZ:     "Europe/Paris",
+			Output: 1,
+		},
+		{
+			Part:   "day",
+			TZ:     "America/Sao_Paulo",
+			Output: 3,
+		},
+		{
+			Part:   "hour",
+			TZ:     "America/Sao_Paulo",
+			Output: 20,
+		},
+		{
+			Part:   "millisecond",
+			Output: 762,
+		},
+		{
+			Part:   "dayOfYear",
+			Output: 3,
+		},
+		{
+			Part:   "quarter",
+			Output: 1,
+		},
+		{
+			// Names are case insensitive and may be plural.
+			Part:   "DayOfWeek",
+			Output: 7,
+		},
+		{
+			Part:   "hours",
+			Output: 23,
+		},
+		{
+			Part:   "ISOWEEKS",
+			Output: 53,
+		},
+		{
+			Part:          "century",
+			ExpectedError: true,
+		},
+	}
+
+	for _, test := range data {
+
+		got, err := jlib.DatePart(input, test.Part, optionalString(test.TZ))
+
+		switch {
+		case test.ExpectedError && err == nil:
+			t.Errorf("%s: Expected error, got nil", test.Part)
+		case !test.ExpectedError && err != nil:
+			t.Errorf("%s: Unexpected error: %s", test.Part, err)
+		case got != test.Output:
+			t.Errorf("%s (%s): Expected %d, got %d", test.Part, test.TZ, test.Output, got)
+		}
 	}
 }
@@ -0,0 +1,183 @@
+// Copyright 2018 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package jlib
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/blues/jsonata-go/jlib/jxpath"
+	"github.com/blues/jsonata-go/jtypes"
+)
+
+const (
+	msPerSecond = 1000
+	msPerMinute = 60 * msPerSecond
+	msPerHour   = 60 * msPerMinute
+	msPerDay    = 24 * msPerHour
+	msPerWeek   = 7 * msPerDay
+)
+
+// reDuration matches an ISO 8601 duration, with an optional
+// leading minus sign. Any component may have a fractional
+// part, separated by a full stop or a comma.
+var reDuration = regexp.MustCompile(`^(-)?P` +
+	`(?:([0-9]+(?:[.,][0-9]+)?)Y)?` +
+	`(?:([0-9]+(?:[.,][0-9]+)?)M)?` +
+	`(?:([0-9]+(?:[.,][0-9]+)?)W)?` +
+	`(?:([0-9]+(?:[.,][0-9]+)?)D)?` +
+	`(?:T` +
+	`(?:([0-9]+(?:[.,][0-9]+)?)H)?` +
+	`(?:([0-9]+(?:[.,][0-9]+)?)M)?` +
+	`(?:([0-9]+(?:[.,][0-9]+)?)S)?` +
+	`)?$`)
+
+// durationUnits holds the length in milliseconds of each
+// component matched by reDuration. Years and months vary
+// in length so they cannot be converted to milliseconds.
+var durationUnits = []int64{
+	0, // years
+	0, // months
+	msPerWeek,
+	msPerDay,
+	msPerHour,
+	msPerMinute,
+	msPerSecond,
+}
+
+// ParseDuration converts an ISO 8601 duration such as "PT15M"
+// or "P1DT2H" to a number of milliseconds. Days are treated as
+// 24 hours and weeks as 7 days. Durations with a non-zero number
+// of years or months are rejected because their length depends
+// on the date they are applied to.
+func ParseDuration(s string) (int64, error) {
+
+	matches := reDuration.FindStringSubmatch(s)
+
+	// The duration must have at least one component, and
+	// the "T" separator must be followed by a component.
+	if matches == nil || strings.HasSuffix(s, "P") || strings.HasSuffix(s, "T") {
+		return 0, newErrorValue("parseDuration", ErrParseDuration, s)
+	}
+
+	var total float64
+
+	for i, unit := range durationUnits {
+
+		value := matches[i+2]
+		if value == "" {
+			continue
+		}
+
+		n, err := strconv.ParseFloat(strings.Replace(value, ",", ".", 1), 64)
+		if err != nil {
+			return 0, newErrorValue("parseDuration", ErrParseDuration, s)
+		}
+
+		if unit == 0 {
+			if n != 0 {
+				return 0, newErrorValue("parseDuration", ErrDurationMonths, s)
+			}
+			continue
+		}
+
+		total += n * float64(unit)
+	}
+
+	total = math.Round(total)
+	if total >= math.MaxInt64 {
+		return 0, newErrorValue("parseDuration", ErrDurationRange, s)
+	}
+
+	ms := int64(total)
+	if matches[1] != "" {
+		ms = -ms
+	}
+
+	return ms, nil
+}
+
+// maxDurationMillis is the largest number of milliseconds
+// that can be held by a time.Duration.
+const maxDurationMillis = math.MaxInt64 / int64(time.Millisecond)
+
+// FormatDuration converts a number of milliseconds to a
+// duration string. If no picture string is provided, the
+// duration is formatted in ISO 8601 format with days, hours,
+// minutes and seconds (e.g. "P1DT2H"). Otherwise it is
+// formatted using jxpath.FormatDuration.
+func FormatDuration(ms int64, picture jtypes.OptionalString) (string, error) {
+
+	if picture.String == "" {
+		return formatISODuration(ms), nil
+	}
+
+	if ms > maxDurationMillis || ms < -maxDurationMillis {
+		return "", newErrorValue("formatDuration", ErrDurationMillisRange, strconv.FormatInt(ms, 10))
+	}
+
+	s, err := jxpath.FormatDuration(time.Duration(ms)*time.Millisecond, picture.String)
+	if err != nil {
+		return "", newErrorValue("formatDuration", ErrDatePicture, err.Error())
+	}
+
+	return s, nil
+}
+
+func formatISODuration(ms int64) string {
+
+	if ms == 0 {
+		return "PT0S"
+	}
+
+	var b strings.Builder
+
+	// Work with an unsigned value so that the minimum
+	// int64 can be negated.
+	n := uint64(ms)
+	if ms < 0 {
+		b.WriteByte('-')
+		n = uint64(-ms)
+	}
+
+	b.WriteByte('P')
+
+	if days := n / msPerDay; days > 0 {
+		b.WriteString(strconv.FormatUint(days, 10))
+		b.WriteByte('D')
+	}
+
+	n %= msPerDay
+	if n == 0 {
+		return b.String()
+	}
+
+	b.WriteByte('T')
+
+	if hours := n / msPerHour; hours > 0 {
+		b.WriteString(strconv.FormatUint(hours, 10))
+		b.WriteByte('H')
+	}
+
+	if minutes := n % msPerHour / msPerMinute; minutes > 0 {
+		b.WriteString(strconv.FormatUint(minutes, 10))
+		b.WriteByte('M')
+	}
+
+	if n %= msPerMinute; n > 0 {
+		b.WriteString(strconv.FormatUint(n/msPerSecond, 10))
+		if frac := n % msPerSecond; frac > 0 {
+			s := strconv.FormatUint(frac+msPerSecond, 10)[1:]
+			b.WriteByte('.')
+			b.WriteString(strings.TrimRight(s, "0"))
+		}
+		b.WriteByte('S')
+	}
+
+	return b.String()
+}
@@ -0,0 +1,179 @@
+// Copyright 2018 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package jlib_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/blues/jsonata-go/jlib"
+)
+
+func TestParseDuration(t *testing.T) {
+
+	data := []struct {
+		Input         string
+		Output        int64
+		ExpectedError bool
+	}{
+		{
+			Input:  "PT15M",
+			Output: 15 * 60 * 1000,
+		},
+		{
+			Input:  "P1DT2H",
+			Output: 26 * 60 * 60 * 1000,
+		},
+		{
+			Input:  "P2W",
+			Output: 14 * 24 * 60 * 60 * 1000,
+		},
+		{
+			Input:  "PT1.5S",
+			Output: 1500,
+		},
+		{
+			Input:  "PT0,25H",
+			Output: 15 * 60 * 1000,
+		},
+		{
+			Input:  "-PT1M30S",
+			Output: -90 * 1000,
+		},
+		{
+			Input:  "P0Y0M1D",
+			Output: 24 * 60 * 60 * 1000,
+		},
+		{
+			Input:  "PT0S",
+			Output: 0,
+		},
+		{
+			Input:         "P1M",
+			ExpectedError: true,
+		},
+		{
+			Input:         "P1Y",
+			ExpectedError: true,
+		},
+		{
+			Input:         "P",
+			ExpectedError: true,
+		},
+		{
+			Input:         "P1DT",
+			ExpectedError: true,
+		},
+		{
+			Input:         "PT1H1D",
+			ExpectedError: true,
+		},
+		{
+			Input:         "15M",
+			ExpectedError: true,
+		},
+		{
+			Input:         "P999999999999999W",
+			ExpectedError: true,
+		},
+	}
+
+	for _, test := range data {
+
+		got, err := jlib.ParseDuration(test.Input)
+
+		if test.ExpectedError && err == nil {
+			t.Errorf("%s: Expected error, got nil", test.Input)
+		} else if !test.ExpectedError && err != nil {
+			t.Errorf("%s: Unexpected error: %s", test.Input, err)
+		} else if got != test.Output {
+			t.Errorf("%s: Expected %d, got %d", test.Input, test.Output, got)
+		}
+	}
+}
+
+func TestFormatDuration(t *testing.T) {
+
+	data := []struct {
+		Input         int64
+		Picture       string
+		Output        string
+		ExpectedError bool
+	}{
+		{
+			Input:  0,
+			Output: "PT0S",
+		},
+		{
+			Input:  15 * 60 * 1000,
+			Output: "PT15M",
+		},
+		{
+			Input:  26 * 60 * 60 * 1000,
+			Output: "P1DT2H",
+		},
+		{
+			Input:  3 * 24 * 60 * 60 * 1000,
+			Output: "P3D",
+		},
+		{
+			Input:  -(60*60*1000 + 1500),
+			Output: "-PT1H1.5S",
+		},
+		{
+			Input:  20,
+			Output: "PT0.02S",
+		},
+		{
+			Input:  math.MinInt64,
+			Output: "-P106751991167DT7H12M55.808S",
+		},
+		{
+			Input:   (26*60+5)*60*1000 + 9000,
+			Picture: "[H]:[m01]:[s01]",
+			Output:  "26:05:09",
+		},
+		{
+			Input:         math.MaxInt64,
+			Picture:       "[H]",
+			ExpectedError: true,
+		},
+	}
+
+	for _, test := range data {
+
+		got, err := jlib.FormatDuration(test.Input, optionalString(test.Picture))
+
+		if test.ExpectedError && err == nil {
+			t.Errorf("%d: Expected error, got nil", test.Input)
+		} else if !test.ExpectedError && err != nil {
+			t.Errorf("%d: Unexpected error: %s", test.Input, err)
+		} else if got != test.Output {
+			t.Errorf("%d: Expected %q, got %q", test.Input, test.Output, got)
+		}
+	}
+}
+
+func TestDurationRoundTrip(t *testing.T) {
+
+	for _, ms := range []int64{1, 999, 1000, 59999, 60000, 3599999, 3600000, 86399999, 86400000, 90061001, -90061001} {
+
+		s, err := jlib.FormatDuration(ms, optionalString(""))
+		if err != nil {
+			t.Errorf("%d: %s", ms, err)
+			continue
+		}
+
+		got, err := jlib.ParseDuration(s)
+		if err != nil {
+			t.Errorf("%s: %s", s, err)
+			continue
+		}
+
+		if got != ms {
+			t.Errorf("%d formats as %q, which parses as %d", ms, s, got)
+		}
+	}
+}
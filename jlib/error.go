@@ -4,7 +4,9 @@
 
 package jlib
 
-import "fmt"
+import (
+	"regexp"
+)
 
 // ErrType (golint)
 type ErrType uint
@@ -13,27 +15,183 @@ type ErrType uint
 const (
 	_ ErrType = iota
 	ErrNaNInf
+	ErrNonArray
+	ErrNonNumberArray
+	ErrNonStringArray
+	ErrNonObject
+	ErrNonObjectArray
+	ErrNonStringKey
+	ErrNoArgs
+	ErrCallbackArity
+	ErrReduceArity
+	ErrSortMismatch
+	ErrSortComparator
+	ErrSingleNone
+	ErrSingleMany
+	ErrCastNumber
+	ErrPowerRange
+	ErrSqrtNegative
+	ErrFormatBase
+	ErrDecimalFormat
+	ErrDecimalFormatOption
+	ErrDecimalFormatValue
+	ErrNumberPicture
+	ErrIntegerPicture
+	ErrIntegerRange
+	ErrSplitLimit
+	ErrMatchLimit
+	ErrMatcher
+	ErrReplaceLimit
+	ErrReplaceEmpty
+	ErrReplaceString
+	ErrReplaceResult
+	ErrMalformedURL
+	ErrDatePicture
+	ErrParseTime
+	ErrInvalidTimeZone
+	ErrUnknownTimeZone
+	ErrUnknownLanguage
+	ErrUnknownDateUnit
+	ErrUnknownDatePart
+	ErrNonIntegerAmount
+	ErrParseDuration
+	ErrDurationMonths
+	ErrDurationRange
+	ErrDurationMillisRange
+	ErrExactRange
 )
 
+var errmsgs = map[ErrType]string{
+	ErrNaNInf:              `{{func}}: cannot convert NaN/Infinity to string`,
+	ErrNonArray:            `cannot call {{func}} on a non-array type`,
+	ErrNonNumberArray:      `cannot call {{func}} on an array with non-number types`,
+	ErrNonStringArray:      `function {{func}} takes an array of strings`,
+	ErrNonObject:           `argument must be an object`,
+	ErrNonObjectArray:      `argument must be an object or an array of objects`,
+	ErrNonStringKey:        `object key must evaluate to a string, got {{value}}`,
+	ErrNoArgs:              `cannot call {{func}} with no arguments`,
+	ErrCallbackArity:       `function must take 1, 2 or 3 arguments`,
+	ErrReduceArity:         `second argument of function "{{func}}" must be a function that takes two arguments`,
+	ErrSortMismatch:        `argument 1 of function {{func}} must be an array of strings or numbers`,
+	ErrSortComparator:      `argument 2 of function {{func}} must be a function that returns a boolean, got {{value}}`,
+	ErrSingleNone:          `number of matching values returned by {{func}}() must be 1, got: 0`,
+	ErrSingleMany:          `number of matching values returned by {{func}}() must be 1, got: {{value}}`,
+	ErrCastNumber:          `unable to cast "{{value}}" to a number`,
+	ErrPowerRange:          `the power function has resulted in a value that cannot be represented as a JSON number`,
+	ErrSqrtNegative:        `the sqrt function cannot be applied to a negative number`,
+	ErrFormatBase:          `the second argument to {{func}} must be between 2 and 36`,
+	ErrDecimalFormat:       `decimal format options must be {{value}}`,
+	ErrDecimalFormatOption: `unknown option "{{value}}"`,
+	ErrDecimalFormatValue:  `invalid value {{value}}`,
+	ErrNumberPicture:       `{{value}}`,
+	ErrIntegerPicture:      `{{value}}`,
+	ErrIntegerRange:        `cannot format {{value}} as an integer`,
+	ErrSplitLimit:          `third argument of the {{func}} function must evaluate to a positive number`,
+	ErrMatchLimit:          `third argument of function {{func}} must evaluate to a positive number`,
+	ErrMatcher:             `match function must return {{value}}`,
+	ErrReplaceLimit:        `fourth argument of function {{func}} must evaluate to a positive number`,
+	ErrReplaceEmpty:        `second argument of function {{func}} can't be an empty string`,
+	ErrReplaceString:       `third argument of function {{func}} must be a string when pattern is a string`,
+	ErrReplaceResult:       `third argument of function {{func}} must be a function that returns a string`,
+	ErrMalformedURL:        `malformed URL passed to function {{func}}: {{value}}`,
+	ErrDatePicture:         `{{value}}`,
+	ErrParseTime:           `could not parse time "{{value}}"`,
+	ErrInvalidTimeZone:     `invalid timezone`,
+	ErrUnknownTimeZone:     `unknown time zone "{{value}}"`,
+	ErrUnknownLanguage:     `unknown language "{{value}}"`,
+	ErrUnknownDateUnit:     `unknown date unit "{{value}}"`,
+	ErrUnknownDatePart:     `unknown date component "{{value}}"`,
+	ErrNonIntegerAmount:    `amount must be an integer for unit "{{value}}"`,
+	ErrParseDuration:       `could not parse duration "{{value}}"`,
+	ErrDurationMonths:      `duration "{{value}}" has years or months, which cannot be converted to milliseconds`,
+	ErrDurationRange:       `duration "{{value}}" is out of range`,
+	ErrDurationMillisRange: `duration of {{value}} milliseconds is out of range`,
+	ErrExactRange:          `{{func}}: number is too large or too precise to represent exactly`,
+}
+
+// errcodes maps error types to the corresponding jsonata-js
+// error codes. Where jsonata-js has no exact equivalent, the
+// closest code is used.
+var errcodes = map[ErrType]string{
+	ErrNaNInf:              "D3001",
+	ErrNonArray:            "T0412",
+	ErrNonNumberArray:      "T0412",
+	ErrNonStringArray:      "T0412",
+	ErrNonObject:           "T0410",
+	ErrNonObjectArray:      "T0412",
+	ErrNonStringKey:        "T1003",
+	ErrNoArgs:              "T0410",
+	ErrCallbackArity:       "T0410",
+	ErrReduceArity:         "D3050",
+	ErrSortMismatch:        "D3070",
+	ErrSortComparator:      "T0410",
+	ErrSingleNone:          "D3139",
+	ErrSingleMany:          "D3138",
+	ErrCastNumber:          "D3030",
+	ErrPowerRange:          "D3061",
+	ErrSqrtNegative:        "D3060",
+	ErrFormatBase:          "D3100",
+	ErrDecimalFormat:       "T0410",
+	ErrDecimalFormatOption: "T0410",
+	ErrDecimalFormatValue:  "T0410",
+	ErrNumberPicture:       "D3080",
+	ErrIntegerPicture:      "D3130",
+	ErrIntegerRange:        "D1001",
+	ErrSplitLimit:          "D3020",
+	ErrMatchLimit:          "D3040",
+	ErrMatcher:             "T1010",
+	ErrReplaceLimit:        "D3011",
+	ErrReplaceEmpty:        "D3010",
+	ErrReplaceString:       "T0410",
+	ErrReplaceResult:       "D3012",
+	ErrMalformedURL:        "D3140",
+	ErrDatePicture:         "D3132",
+	ErrParseTime:           "D3110",
+	ErrInvalidTimeZone:     "T0410",
+	ErrUnknownTimeZone:     "T0410",
+	ErrUnknownLanguage:     "T0410",
+	ErrUnknownDateUnit:     "T0410",
+	ErrUnknownDatePart:     "T0410",
+	ErrNonIntegerAmount:    "T0410",
+	ErrParseDuration:       "D3110",
+	ErrDurationMonths:      "D3110",
+	ErrDurationRange:       "D1001",
+	ErrDurationMillisRange: "D1001",
+	ErrExactRange:          "D1001",
+}
+
+var reErrMsg = regexp.MustCompile("{{(func|value)}}")
+
 // Error (golint)
 type Error struct {
-	Type ErrType
-	Func string
+	Type  ErrType
+	Func  string
+	Value string
 }
 
 // Error (golint)
 func (e Error) Error() string {
 
-	var msg string
-
-	switch e.Type {
-	case ErrNaNInf:
-		msg = "cannot convert NaN/Infinity to string"
-	default:
-		msg = "unknown error"
+	s := errmsgs[e.Type]
+	if s == "" {
+		return e.Func + ": unknown error"
 	}
 
-	return fmt.Sprintf("%s: %s", e.Func, msg)
+	return reErrMsg.ReplaceAllStringFunc(s, func(match string) string {
+		switch match {
+		case "{{func}}":
+			return e.Func
+		case "{{value}}":
+			return e.Value
+		default:
+			return match
+		}
+	})
+}
+
+// Code returns the jsonata-js error code for the error.
+func (e Error) Code() string {
+	return errcodes[e.Type]
 }
 
 func newError(name string, typ ErrType) *Error {
@@ -42,3 +200,11 @@ func newError(name string, typ ErrType) *Error {
 		Type: typ,
 	}
 }
+
+func newErrorValue(name string, typ ErrType, value string) *Error {
+	return &Error{
+		Func:  name,
+		Type:  typ,
+		Value: value,
+	}
+}
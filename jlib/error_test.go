@@ -0,0 +1,30 @@
+// Copyright 2018 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package jlib_test
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/blues/jsonata-go/jlib"
+)
+
+func TestErrorCodes(t *testing.T) {
+
+	reCode := regexp.MustCompile(`^[TD]\d{4}$`)
+
+	for typ := jlib.ErrNaNInf; typ <= jlib.ErrExactRange; typ++ {
+		e := jlib.Error{
+			Type: typ,
+			Func: "test",
+		}
+		if code := e.Code(); !reCode.MatchString(code) {
+			t.Errorf("%s: invalid error code %q", e, code)
+		}
+		if s := e.Error(); s == "test: unknown error" {
+			t.Errorf("error type %d has no message", typ)
+		}
+	}
+}
@@ -0,0 +1,329 @@
+// Copyright 2018 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package jlib
+
+import (
+	"encoding/json"
+	"math/big"
+	"reflect"
+
+	"github.com/blues/jsonata-go/jlib/jxpath"
+	"github.com/blues/jsonata-go/jtypes"
+)
+
+// This file contains versions of the numeric functions that
+// operate on exact rational numbers rather than float64s. They
+// return their results as json.Numbers, which encode to JSON
+// without loss of precision.
+
+var typeJSONNumber = reflect.TypeOf((*json.Number)(nil)).Elem()
+
+// ExactNumber is a number argument to one of the exact numeric
+// functions. It holds either a json.Number or a float64.
+type ExactNumber reflect.Value
+
+// ValidTypes (golint)
+func (ExactNumber) ValidTypes() []reflect.Type {
+	return []reflect.Type{
+		// Check for json.Number first so that it's not
+		// converted to a float64.
+		typeJSONNumber,
+		typeNumber,
+	}
+}
+
+// ExactStringNumberBool is the argument to NumberExact. It is
+// like StringNumberBool but it holds json.Numbers unchanged.
+type ExactStringNumberBool reflect.Value
+
+// ValidTypes (golint)
+func (ExactStringNumberBool) ValidTypes() []reflect.Type {
+	return []reflect.Type{
+		typeJSONNumber,
+		typeBool,
+		typeString,
+		typeNumber,
+	}
+}
+
+// maxExactDigits is the number of significant digits that
+// NumberFromRat uses for numbers that cannot be represented
+// exactly as decimals (e.g. 1/3).
+const maxExactDigits = 34
+
+// maxExactBits is the maximum size in bits of the numerator
+// and denominator of the result of an exact calculation. It
+// allows numbers with around 10,000 digits, and it stops
+// calculations such as repeated multiplication from using
+// unbounded amounts of time and memory.
+const maxExactBits = 34000
+
+// maxRoundPrecision is the largest number of decimal places
+// (positive or negative) that RoundExact accepts.
+const maxRoundPrecision = 10000
+
+// RatInRange reports whether r is small enough to be the
+// result of an exact calculation. The exact numeric operators
+// and functions return an error rather than a result that is
+// out of range.
+func RatInRange(r *big.Rat) bool {
+	return r.Num().BitLen() <= maxExactBits && r.Denom().BitLen() <= maxExactBits
+}
+
+// NumberFromRat converts a rational number to a json.Number.
+// If the number has a terminating decimal expansion, the result
+// is exact. Otherwise it is rounded to 34 significant digits.
+func NumberFromRat(r *big.Rat) json.Number {
+
+	if r.IsInt() {
+		return json.Number(r.Num().String())
+	}
+
+	if scale, ok := decimalScale(r.Denom()); ok {
+		return json.Number(r.FloatString(scale))
+	}
+
+	f := new(big.Float).SetPrec(256).SetRat(r)
+	return json.Number(f.Text('g', maxExactDigits))
+}
+
+// decimalScale returns the number of decimal places needed
+// to represent a fraction with the given denominator. It
+// returns false if the decimal expansion does not terminate,
+// i.e. if the denominator has prime factors other than 2 and 5.
+func decimalScale(denom *big.Int) (int, bool) {
+
+	n := new(big.Int).Set(denom)
+
+	twos := int(n.TrailingZeroBits())
+	n.Rsh(n, uint(twos))
+
+	fives := 0
+	five := big.NewInt(5)
+	q, m := new(big.Int), new(big.Int)
+
+	for {
+		q.QuoRem(n, five, m)
+		if m.Sign() != 0 {
+			break
+		}
+		n.Set(q)
+		fives++
+	}
+
+	if n.Cmp(big.NewInt(1)) != 0 {
+		return 0, false
+	}
+
+	if twos > fives {
+		return twos, true
+	}
+
+	return fives, true
+}
+
+// SumExact is like Sum but it adds the numbers exactly and
+// returns the total as a json.Number.
+func SumExact(v reflect.Value) (json.Number, error) {
+
+	if !jtypes.IsArray(v) {
+		if r, ok := jtypes.AsRat(v); ok {
+			return NumberFromRat(r), nil
+		}
+		return "", newError("sum", ErrNonArray)
+	}
+
+	v = jtypes.Resolve(v)
+
+	sum := new(big.Rat)
+
+	for i := 0; i < v.Len(); i++ {
+		r, ok := jtypes.AsRat(v.Index(i))
+		if !ok {
+			return "", newError("sum", ErrNonNumberArray)
+		}
+		sum.Add(sum, r)
+	}
+
+	return NumberFromRat(sum), nil
+}
+
+// MaxExact is like Max but it compares the numbers exactly and
+// returns the largest as a json.Number.
+func MaxExact(v reflect.Value) (json.Number, error) {
+	return extremeExact("max", v, 1)
+}
+
+// MinExact is like Min but it compares the numbers exactly and
+// returns the smallest as a json.Number.
+func MinExact(v reflect.Value) (json.Number, error) {
+	return extremeExact("min", v, -1)
+}
+
+// extremeExact returns the largest (if sign is 1) or smallest
+// (if sign is -1) number in an array.
+func extremeExact(name string, v reflect.Value, sign int) (json.Number, error) {
+
+	if !jtypes.IsArray(v) {
+		if r, ok := jtypes.AsRat(v); ok {
+			return NumberFromRat(r), nil
+		}
+		return "", newError(name, ErrNonArray)
+	}
+
+	v = jtypes.Resolve(v)
+	if v.Len() == 0 {
+		return "", jtypes.ErrUndefined
+	}
+
+	var result *big.Rat
+
+	for i := 0; i < v.Len(); i++ {
+		r, ok := jtypes.AsRat(v.Index(i))
+		if !ok {
+			return "", newError(name, ErrNonNumberArray)
+		}
+		if result == nil || r.Cmp(result) == sign {
+			result = r
+		}
+	}
+
+	return NumberFromRat(result), nil
+}
+
+// AverageExact is like Average but it calculates the mean
+// exactly and returns it as a json.Number.
+func AverageExact(v reflect.Value) (json.Number, error) {
+
+	if !jtypes.IsArray(v) {
+		if r, ok := jtypes.AsRat(v); ok {
+			return NumberFromRat(r), nil
+		}
+		return "", newError("average", ErrNonArray)
+	}
+
+	v = jtypes.Resolve(v)
+	if v.Len() == 0 {
+		return "", jtypes.ErrUndefined
+	}
+
+	sum := new(big.Rat)
+
+	for i := 0; i < v.Len(); i++ {
+		r, ok := jtypes.AsRat(v.Index(i))
+		if !ok {
+			return "", newError("average", ErrNonNumberArray)
+		}
+		sum.Add(sum, r)
+	}
+
+	sum.Quo(sum, new(big.Rat).SetInt64(int64(v.Len())))
+	return NumberFromRat(sum), nil
+}
+
+// NumberExact is like Number but it converts numbers and
+// numeric strings to json.Numbers without rounding them to
+// float64 precision.
+func NumberExact(value ExactStringNumberBool) (json.Number, error) {
+	v := reflect.Value(value)
+	if b, ok := jtypes.AsBool(v); ok {
+		if b {
+			return "1", nil
+		}
+		return "0", nil
+	}
+
+	if r, ok := jtypes.AsRat(v); ok {
+		return NumberFromRat(r), nil
+	}
+
+	s, ok := jtypes.AsString(v)
+	if ok && reNumber.MatchString(s) {
+		if r, ok := jtypes.AsRat(reflect.ValueOf(json.Number(s))); ok {
+			return NumberFromRat(r), nil
+		}
+	}
+
+	return "", newErrorValue("number", ErrCastNumber, s)
+}
+
+// RoundExact is like Round but it rounds the exact value of
+// its input and returns the result as a json.Number.
+func RoundExact(x ExactNumber, prec jtypes.OptionalInt) (json.Number, error) {
+
+	r, ok := jtypes.AsRat(reflect.Value(x))
+	if !ok {
+		return "", newError("round", ErrExactRange)
+	}
+
+	if abs(prec.Int) > maxRoundPrecision {
+		return "", newError("round", ErrExactRange)
+	}
+
+	return NumberFromRat(roundRat(r, prec.Int)), nil
+}
+
+// FormatNumberExact is like FormatNumber but it formats the
+// exact value of its input.
+func FormatNumberExact(value ExactNumber, picture string, options jtypes.OptionalValue) (string, error) {
+
+	r, ok := jtypes.AsRat(reflect.Value(value))
+	if !ok {
+		return "", newError("formatNumber", ErrExactRange)
+	}
+
+	if !options.IsSet() {
+		return jxpath.FormatNumberExact(r, picture, defaultDecimalFormat)
+	}
+
+	opts := jtypes.Resolve(options.Value)
+	if !jtypes.IsMap(opts) {
+		return "", newErrorValue("formatNumber", ErrDecimalFormat, "a map")
+	}
+
+	format, err := newDecimalFormat(opts)
+	if err != nil {
+		return "", err
+	}
+
+	return jxpath.FormatNumberExact(r, picture, format)
+}
+
+// roundRat rounds x to prec decimal places, rounding halves
+// to the nearest even digit (as Round does).
+func roundRat(x *big.Rat, prec int) *big.Rat {
+
+	pow := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(prec))), nil)
+
+	scaled := new(big.Rat).Set(x)
+	if prec < 0 {
+		scaled.Quo(scaled, new(big.Rat).SetInt(pow))
+	} else {
+		scaled.Mul(scaled, new(big.Rat).SetInt(pow))
+	}
+
+	// Split the scaled value into an integer part and a
+	// remainder. Compare twice the remainder with the
+	// denominator to determine whether the remainder is
+	// more, less or exactly half.
+	q, r := new(big.Int).QuoRem(scaled.Num(), scaled.Denom(), new(big.Int))
+
+	r.Abs(r).Lsh(r, 1)
+	switch c := r.Cmp(scaled.Denom()); {
+	case c > 0, c == 0 && q.Bit(0) == 1:
+		if scaled.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+
+	result := new(big.Rat).SetInt(q)
+	if prec < 0 {
+		return result.Mul(result, new(big.Rat).SetInt(pow))
+	}
+
+	return result.Quo(result, new(big.Rat).SetInt(pow))
+}
@@ -5,8 +5,8 @@
 package jlib
 
 import (
-	"fmt"
 	"reflect"
+	"strconv"
 
 	"github.com/blues/jsonata-go/jtypes"
 )
@@ -70,7 +70,7 @@ func Reduce(v reflect.Value, f jtypes.Callable, init jtypes.OptionalValue) (inte
 	var res reflect.Value
 
 	if f.ParamCount() != 2 {
-		return nil, fmt.Errorf("second argument of function \"reduce\" must be a function that takes two arguments")
+		return nil, newError("reduce", ErrReduceArity)
 	}
 
 	i := 0
@@ -114,8 +114,11 @@ func Single(v reflect.Value, f jtypes.Callable) (interface{}, error) {
 		// more than one item in the slice, return a error, otherwise
 		// return the item
 		s := reflect.ValueOf(filteredValue)
-		if s.Len() != 1 {
-			return nil, fmt.Errorf("number of matching values returned by single() must be 1, got: %d", s.Len())
+		if s.Len() == 0 {
+			return nil, newError("single", ErrSingleNone)
+		}
+		if s.Len() > 1 {
+			return nil, newErrorValue("single", ErrSingleMany, strconv.Itoa(s.Len()))
 		}
 		return s.Index(0).Interface(), nil
 
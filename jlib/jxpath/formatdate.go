@@ -76,6 +76,8 @@ type variableMarker struct {
 
 var errUnsupported = errors.New("unsupported date format")
 
+var errNoOrdinals = errors.New("ordinal numbers are not supported in this language")
+
 // FormatTime converts a time to a string, formatted according
 // to the given picture string.
 //
@@ -84,20 +86,65 @@ var errUnsupported = errors.New("unsupported date format")
 //
 // https://www.w3.org/TR/xpath-functions-31/#rules-for-datetime-formatting
 func FormatTime(t time.Time, picture string) (string, error) {
-	var start int
-	var inMarker, doubleClosingBracket, expanded bool
+	return FormatTimeLanguage(t, picture, "")
+}
+
+// FormatTimeLanguage is like FormatTime but uses the names of
+// days, months etc. from the given language (see LookupLanguage).
+// An empty language tag selects English.
+func FormatTimeLanguage(t time.Time, picture string, language string) (string, error) {
+
+	lang, err := lookupLanguage(language)
+	if err != nil {
+		return "", err
+	}
+
+	return formatPicture(picture, func(marker string) (string, error) {
+		return expandVariableMarker(t, marker, lang)
+	})
+}
+
+// formatPicture scans a date/time picture string, replacing
+// each variable marker with the result of calling expand on
+// the marker's contents (i.e. the text between the brackets).
+func formatPicture(picture string, expand func(string) (string, error)) (string, error) {
 
 	result := make([]byte, 0, 128)
 
+	err := scanPicture(picture, func(literal string) {
+		result = append(result, literal...)
+	}, func(marker string) error {
+		s, err := expand(marker)
+		if err != nil {
+			return err
+		}
+		result = append(result, s...)
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return string(result), nil
+}
+
+// scanPicture splits a date/time picture string into literal
+// text and variable markers. It calls literal for each piece
+// of literal text and marker for the contents of each variable
+// marker, in the order that they appear in the picture string.
+func scanPicture(picture string, literal func(string), marker func(string) error) error {
+	var start int
+	var inMarker, doubleClosingBracket, expanded bool
+
 	for current, r := range picture {
 		if r == '[' {
 			if inMarker {
 				if current != start {
-					return "", fmt.Errorf("open bracket inside variable marker")
+					return fmt.Errorf("open bracket inside variable marker")
 				}
 				inMarker = false
 			} else {
-				result = append(result, picture[start:current]...)
+				literal(picture[start:current])
 				start = current + 1
 				inMarker = true
 			}
@@ -108,13 +155,11 @@ func FormatTime(t time.Time, picture string) (string, error) {
 		if r == ']' {
 			if inMarker {
 				if current == start {
-					return "", fmt.Errorf("empty variable marker")
+					return fmt.Errorf("empty variable marker")
 				}
-				s, err := expandVariableMarker(t, picture[start:current])
-				if err != nil {
-					return "", err
+				if err := marker(picture[start:current]); err != nil {
+					return err
 				}
-				result = append(result, s...)
 				start = current + 1
 				inMarker = false
 				expanded = true
@@ -125,10 +170,10 @@ func FormatTime(t time.Time, picture string) (string, error) {
 				}
 				next := current + 1
 				if next >= len(picture) || picture[next] != ']' {
-					return "", fmt.Errorf("closing bracket outside variable marker")
+					return fmt.Errorf("closing bracket outside variable marker")
 				}
 				doubleClosingBracket = true
-				result = append(result, picture[start:current]...)
+				literal(picture[start:current])
 				start = next
 			}
 
@@ -137,18 +182,18 @@ func FormatTime(t time.Time, picture string) (string, error) {
 	}
 
 	if inMarker {
-		return "", fmt.Errorf("unterminated variable marker")
+		return fmt.Errorf("unterminated variable marker")
 	}
 
 	if !expanded {
-		return "", fmt.Errorf("no variable markers found")
+		return fmt.Errorf("no variable markers found")
 	}
 
-	result = append(result, picture[start:]...)
-	return string(result), nil
+	literal(picture[start:])
+	return nil
 }
 
-func expandVariableMarker(t time.Time, s string) (string, error) {
+func expandVariableMarker(t time.Time, s string, lang *Language) (string, error) {
 
 	component, marker, err := parseVariableMarker(s)
 	if err != nil {
@@ -163,12 +208,16 @@ func expandVariableMarker(t time.Time, s string) (string, error) {
 		isDefaultFormat = true
 	}
 
-	repl, err := expandDateComponent(t, component, &marker)
+	repl, err := expandDateComponent(t, component, &marker, lang)
 
+	// Fall back to the default format. The ordinal modifier
+	// still applies, so that "[Dwo]" becomes "[D1o]".
 	if err == errUnsupported && !isDefaultFormat {
-		marker.modifier = 0
+		if marker.modifier != modOrdinal {
+			marker.modifier = 0
+		}
 		marker.format = defaultDateFormats[component]
-		repl, err = expandDateComponent(t, component, &marker)
+		repl, err = expandDateComponent(t, component, &marker, lang)
 	}
 
 	return repl, err
@@ -314,38 +363,38 @@ func parseWidth(s string) (int, error) {
 	return n, nil
 }
 
-func expandDateComponent(t time.Time, component dateComponent, marker *variableMarker) (string, error) {
+func expandDateComponent(t time.Time, component dateComponent, marker *variableMarker, lang *Language) (string, error) {
 	switch component {
 	case dateYear:
-		return formatYear(t, marker)
+		return formatYear(t, marker, lang)
 	case dateMonth:
-		return formatMonth(t, marker)
+		return formatMonth(t, marker, lang)
 	case dateDay:
-		return formatDay(t, marker)
+		return formatDay(t, marker, lang)
 	case dateDayOfYear:
-		return formatDayInYear(t, marker)
+		return formatDayInYear(t, marker, lang)
 	case dateDayOfWeek:
-		return formatDayOfWeek(t, marker)
+		return formatDayOfWeek(t, marker, lang)
 	case dateWeekOfYear:
-		return formatWeekInYear(t, marker)
+		return formatWeekInYear(t, marker, lang)
 	case dateWeekOfMonth:
-		return formatWeekInMonth(t, marker)
+		return formatWeekInMonth(t, marker, lang)
 	case dateHour24:
-		return formatHour24(t, marker)
+		return formatHour24(t, marker, lang)
 	case dateHour12:
-		return formatHour12(t, marker)
+		return formatHour12(t, marker, lang)
 	case dateAMPM:
-		return formatAMPM(t, marker)
+		return formatAMPM(t, marker, lang)
 	case dateMinute:
-		return formatMinute(t, marker)
+		return formatMinute(t, marker, lang)
 	case dateSecond:
-		return formatSecond(t, marker)
+		return formatSecond(t, marker, lang)
 	case dateNanosecond:
 		return formatNanosecond(t, marker)
 	case dateTZ:
-		return formatTimezoneUnprefixed(t, marker)
+		return formatTimezoneUnprefixed(t, marker, lang)
 	case dateTZPrefixed:
-		return formatTimezonePrefixed(t, marker)
+		return formatTimezonePrefixed(t, marker, lang)
 	case dateCalendar:
 		return formatCalendar(t, marker)
 	case dateEra:
@@ -355,7 +404,7 @@ func expandDateComponent(t time.Time, component dateComponent, marker *variableM
 	}
 }
 
-func formatYear(t time.Time, marker *variableMarker) (string, error) {
+func formatYear(t time.Time, marker *variableMarker, lang *Language) (string, error) {
 
 	if !isDecimalFormat(marker.format) {
 		return "", errUnsupported
@@ -373,84 +422,84 @@ func formatYear(t time.Time, marker *variableMarker) (string, error) {
 		y = y % pow10(size)
 	}
 
-	return formatIntegerComponent(y, marker)
+	return formatIntegerComponent(y, marker, lang)
 }
 
-func formatMonth(t time.Time, marker *variableMarker) (string, error) {
+func formatMonth(t time.Time, marker *variableMarker, lang *Language) (string, error) {
 
 	month := t.Month()
 
 	if isNameFormat(marker.format) {
-		names := defaultLanguage.months[month]
+		names := lang.Months[month]
 		return formatNameComponent(names, marker)
 	}
 
 	if isDecimalFormat(marker.format) {
-		return formatIntegerComponent(int(month), marker)
+		return formatIntegerComponent(int(month), marker, lang)
 	}
 
 	return "", errUnsupported
 }
 
-func formatDay(t time.Time, marker *variableMarker) (string, error) {
+func formatDay(t time.Time, marker *variableMarker, lang *Language) (string, error) {
 
 	if !isDecimalFormat(marker.format) {
 		return "", errUnsupported
 	}
-	return formatIntegerComponent(t.Day(), marker)
+	return formatIntegerComponent(t.Day(), marker, lang)
 }
 
-func formatDayInYear(t time.Time, marker *variableMarker) (string, error) {
+func formatDayInYear(t time.Time, marker *variableMarker, lang *Language) (string, error) {
 
 	if !isDecimalFormat(marker.format) {
 		return "", errUnsupported
 	}
-	return formatIntegerComponent(t.YearDay(), marker)
+	return formatIntegerComponent(t.YearDay(), marker, lang)
 }
 
-func formatDayOfWeek(t time.Time, marker *variableMarker) (string, error) {
+func formatDayOfWeek(t time.Time, marker *variableMarker, lang *Language) (string, error) {
 
 	day := t.Weekday()
 
 	if isNameFormat(marker.format) {
-		names := defaultLanguage.days[day]
+		names := lang.Days[day]
 		return formatNameComponent(names, marker)
 	}
 
 	if isDecimalFormat(marker.format) {
-		return formatIntegerComponent(int(day)+1, marker)
+		return formatIntegerComponent(int(day)+1, marker, lang)
 	}
 
 	return "", errUnsupported
 }
 
-func formatWeekInYear(t time.Time, marker *variableMarker) (string, error) {
+func formatWeekInYear(t time.Time, marker *variableMarker, lang *Language) (string, error) {
 
 	if !isDecimalFormat(marker.format) {
 		return "", errUnsupported
 	}
 
 	_, w := t.ISOWeek()
-	return formatIntegerComponent(w, marker)
+	return formatIntegerComponent(w, marker, lang)
 }
 
-func formatWeekInMonth(t time.Time, marker *variableMarker) (string, error) {
+func formatWeekInMonth(t time.Time, marker *variableMarker, lang *Language) (string, error) {
 
 	if !isDecimalFormat(marker.format) {
 		return "", errUnsupported
 	}
-	return formatIntegerComponent(daysToWeeks(t.Day()), marker)
+	return formatIntegerComponent(daysToWeeks(t.Day()), marker, lang)
 }
 
-func formatHour24(t time.Time, marker *variableMarker) (string, error) {
-	return formatHour(t, marker, false)
+func formatHour24(t time.Time, marker *variableMarker, lang *Language) (string, error) {
+	return formatHour(t, marker, lang, false)
 }
 
-func formatHour12(t time.Time, marker *variableMarker) (string, error) {
-	return formatHour(t, marker, true)
+func formatHour12(t time.Time, marker *variableMarker, lang *Language) (string, error) {
+	return formatHour(t, marker, lang, true)
 }
 
-func formatHour(t time.Time, marker *variableMarker, hour12 bool) (string, error) {
+func formatHour(t time.Time, marker *variableMarker, lang *Language, hour12 bool) (string, error) {
 
 	if !isDecimalFormat(marker.format) {
 		return "", errUnsupported
@@ -460,40 +509,46 @@ func formatHour(t time.Time, marker *variableMarker, hour12 bool) (string, error
 	if hour12 && h > 12 {
 		h -= 12
 	}
-	return formatIntegerComponent(h, marker)
+	return formatIntegerComponent(h, marker, lang)
 }
 
-func formatAMPM(t time.Time, marker *variableMarker) (string, error) {
+func formatAMPM(t time.Time, marker *variableMarker, lang *Language) (string, error) {
 
 	if !isNameFormat(marker.format) {
 		return "", errUnsupported
 	}
 
-	names := defaultLanguage.am
+	names := lang.AM
 	if t.Hour() >= 12 {
-		names = defaultLanguage.pm
+		names = lang.PM
 	}
 
 	return formatNameComponent(names, marker)
 }
 
-func formatMinute(t time.Time, marker *variableMarker) (string, error) {
+func formatMinute(t time.Time, marker *variableMarker, lang *Language) (string, error) {
 
 	if !isDecimalFormat(marker.format) {
 		return "", errUnsupported
 	}
-	return formatIntegerComponent(t.Minute(), marker)
+	return formatIntegerComponent(t.Minute(), marker, lang)
 }
 
-func formatSecond(t time.Time, marker *variableMarker) (string, error) {
+func formatSecond(t time.Time, marker *variableMarker, lang *Language) (string, error) {
 
 	if !isDecimalFormat(marker.format) {
 		return "", errUnsupported
 	}
-	return formatIntegerComponent(t.Second(), marker)
+	return formatIntegerComponent(t.Second(), marker, lang)
 }
 
 func formatNanosecond(t time.Time, marker *variableMarker) (string, error) {
+	return formatFraction(t.Nanosecond(), marker)
+}
+
+// formatFraction formats a number of nanoseconds as the
+// fractional part of a second.
+func formatFraction(n int, marker *variableMarker) (string, error) {
 
 	if !isDecimalFormat(marker.format) {
 		return "", errUnsupported
@@ -502,10 +557,10 @@ func formatNanosecond(t time.Time, marker *variableMarker) (string, error) {
 	l := utf8.RuneCountInString(marker.format)
 
 	if l == 1 || !isAllDigits(marker.format) {
-		return formatNano(t.Nanosecond(), 9), nil
+		return formatNano(n, 9), nil
 	}
 
-	return formatNano(t.Nanosecond(), l), nil
+	return formatNano(n, l), nil
 }
 
 func formatNano(n, maxlen int) string {
@@ -605,15 +660,15 @@ func getTimezoneStyle(s string) (tzStyle, *tzSplitLayout) {
 	return 0, nil
 }
 
-func formatTimezoneUnprefixed(t time.Time, marker *variableMarker) (string, error) {
-	return formatTimezone(t, marker, false)
+func formatTimezoneUnprefixed(t time.Time, marker *variableMarker, lang *Language) (string, error) {
+	return formatTimezone(t, marker, lang, false)
 }
 
-func formatTimezonePrefixed(t time.Time, marker *variableMarker) (string, error) {
-	return formatTimezone(t, marker, true)
+func formatTimezonePrefixed(t time.Time, marker *variableMarker, lang *Language) (string, error) {
+	return formatTimezone(t, marker, lang, true)
 }
 
-func formatTimezone(t time.Time, marker *variableMarker, prefixed bool) (string, error) {
+func formatTimezone(t time.Time, marker *variableMarker, lang *Language, prefixed bool) (string, error) {
 
 	var tz string
 	var err error
@@ -654,7 +709,7 @@ func formatTimezone(t time.Time, marker *variableMarker, prefixed bool) (string,
 	}
 
 	if prefixed && isNumeric {
-		tz = defaultLanguage.tzPrefix + tz
+		tz = lang.TZPrefix + tz
 	}
 
 	if marker.minWidth > 0 {
@@ -740,7 +795,7 @@ func formatEra(t time.Time, marker *variableMarker) (string, error) {
 	return formatNameComponent(eras, marker)
 }
 
-func formatIntegerComponent(n int, marker *variableMarker) (string, error) {
+func formatIntegerComponent(n int, marker *variableMarker, lang *Language) (string, error) {
 
 	s, err := formatInteger(n, marker.format)
 	if err != nil {
@@ -749,7 +804,10 @@ func formatIntegerComponent(n int, marker *variableMarker) (string, error) {
 
 	switch marker.modifier {
 	case modOrdinal:
-		s += ordinalSuffix(n)
+		if lang.Ordinal == nil {
+			return "", errNoOrdinals
+		}
+		s += lang.Ordinal(n)
 	}
 
 	return s, nil
@@ -0,0 +1,154 @@
+// Copyright 2018 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package jxpath
+
+import (
+	"fmt"
+	"time"
+)
+
+var defaultDurationFormats = map[dateComponent]string{
+	dateDay:        "1",
+	dateHour24:     "1",
+	dateMinute:     "01",
+	dateSecond:     "01",
+	dateNanosecond: "1",
+}
+
+// durationParts holds the components of a duration. Each
+// component is only set if it appears in the picture string.
+type durationParts struct {
+	days    int
+	hours   int
+	minutes int
+	seconds int
+	nanos   int
+}
+
+// FormatDuration converts a duration to a string, formatted
+// according to the given picture string.
+//
+// The picture string uses the same syntax as FormatTime but
+// only the components [D] (days), [H] (hours), [m] (minutes),
+// [s] (seconds) and [f] (fractional seconds) are allowed. The
+// largest unit in the picture string holds the whole of the
+// duration in that unit, so "[H]:[m01]" formats a duration of
+// 26 hours and 5 minutes as "26:05". Negative durations are
+// prefixed with a minus sign.
+func FormatDuration(d time.Duration, picture string) (string, error) {
+
+	present := map[dateComponent]bool{}
+
+	_, err := formatPicture(picture, func(s string) (string, error) {
+
+		component, _, err := parseVariableMarker(s)
+		if err != nil {
+			return "", err
+		}
+
+		if _, ok := defaultDurationFormats[component]; !ok {
+			return "", fmt.Errorf("unknown duration component specifier %c", component)
+		}
+
+		present[component] = true
+		return "", nil
+	})
+	if err != nil {
+		return "", err
+	}
+
+	var sign string
+	var parts durationParts
+
+	// Work with an unsigned value so that the minimum
+	// duration can be negated.
+	rem := uint64(d)
+	if d < 0 {
+		sign = "-"
+		rem = uint64(-d)
+	}
+
+	parts.nanos = int(rem % uint64(time.Second))
+
+	units := []struct {
+		component dateComponent
+		size      time.Duration
+		value     *int
+	}{
+		{dateDay, 24 * time.Hour, &parts.days},
+		{dateHour24, time.Hour, &parts.hours},
+		{dateMinute, time.Minute, &parts.minutes},
+		{dateSecond, time.Second, &parts.seconds},
+	}
+
+	for _, u := range units {
+		if present[u.component] {
+			*u.value = int(rem / uint64(u.size))
+			rem %= uint64(u.size)
+		}
+	}
+
+	s, err := formatPicture(picture, func(s string) (string, error) {
+		return expandDurationMarker(&parts, s)
+	})
+	if err != nil {
+		return "", err
+	}
+
+	return sign + s, nil
+}
+
+func expandDurationMarker(parts *durationParts, s string) (string, error) {
+
+	component, marker, err := parseVariableMarker(s)
+	if err != nil {
+		return "", err
+	}
+
+	var isDefaultFormat bool
+
+	if marker.format == "" {
+		marker.modifier = 0
+		marker.format = defaultDurationFormats[component]
+		isDefaultFormat = true
+	}
+
+	repl, err := expandDurationComponent(parts, component, &marker)
+
+	if err == errUnsupported && !isDefaultFormat {
+		marker.modifier = 0
+		marker.format = defaultDurationFormats[component]
+		repl, err = expandDurationComponent(parts, component, &marker)
+	}
+
+	return repl, err
+}
+
+func expandDurationComponent(parts *durationParts, component dateComponent, marker *variableMarker) (string, error) {
+
+	var n int
+
+	switch component {
+	case dateDay:
+		n = parts.days
+	case dateHour24:
+		n = parts.hours
+	case dateMinute:
+		n = parts.minutes
+	case dateSecond:
+		n = parts.seconds
+	case dateNanosecond:
+		return formatFraction(parts.nanos, marker)
+	default:
+		return "", fmt.Errorf("unknown duration component specifier %c", component)
+	}
+
+	if !isDecimalFormat(marker.format) {
+		return "", errUnsupported
+	}
+
+	// Durations are always formatted in English.
+	return formatIntegerComponent(n, marker, &english)
+}
@@ -0,0 +1,96 @@
+// Copyright 2018 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package jxpath
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestFormatDuration(t *testing.T) {
+
+	data := []struct {
+		Duration time.Duration
+		Picture  string
+		Output   string
+		Error    string
+	}{
+		{
+			Duration: 26*time.Hour + 5*time.Minute + 9*time.Second,
+			Picture:  "[H]:[m01]:[s01]",
+			Output:   "26:05:09",
+		},
+		{
+			Duration: 26*time.Hour + 5*time.Minute + 9*time.Second,
+			Picture:  "[D]d [H01]h [m]m",
+			Output:   "1d 02h 05m",
+		},
+		{
+			// The largest component holds the whole duration.
+			Duration: 2*time.Hour + 30*time.Second,
+			Picture:  "[m]:[s01]",
+			Output:   "120:30",
+		},
+		{
+			Duration: 1500 * time.Millisecond,
+			Picture:  "[s1].[f001]s",
+			Output:   "1.500s",
+		},
+		{
+			Duration: -(90 * time.Minute),
+			Picture:  "[H01]:[m01]",
+			Output:   "-01:30",
+		},
+		{
+			Duration: math.MinInt64,
+			Picture:  "[H]",
+			Output:   "-2562047",
+		},
+		{
+			Duration: 3 * 24 * time.Hour,
+			Picture:  "[D1o] day",
+			Output:   "3rd day",
+		},
+		{
+			// Unsupported formats fall back to the default.
+			Duration: 3 * time.Minute,
+			Picture:  "[mNn] minutes",
+			Output:   "03 minutes",
+		},
+		{
+			Picture: "[H]:[M01]",
+			Error:   "unknown duration component specifier M",
+		},
+		{
+			Picture: "[Y]",
+			Error:   "unknown duration component specifier Y",
+		},
+		{
+			Picture: "hours",
+			Error:   "no variable markers found",
+		},
+		{
+			Picture: "[H",
+			Error:   "unterminated variable marker",
+		},
+	}
+
+	for _, test := range data {
+
+		got, err := FormatDuration(test.Duration, test.Picture)
+
+		if got != test.Output {
+			t.Errorf("%s: expected %q, got %q", test.Picture, test.Output, got)
+		}
+
+		switch {
+		case test.Error == "" && err != nil:
+			t.Errorf("%s: unexpected error: %s", test.Picture, err)
+		case test.Error != "" && (err == nil || err.Error() != test.Error):
+			t.Errorf("%s: expected error %q, got %v", test.Picture, test.Error, err)
+		}
+	}
+}
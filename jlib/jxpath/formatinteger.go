@@ -0,0 +1,691 @@
+// Copyright 2018 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package jxpath
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+type integerFormat uint8
+
+const (
+	formatDecimal integerFormat = iota
+	formatAlphaUpper
+	formatAlphaLower
+	formatRomanUpper
+	formatRomanLower
+	formatWordsUpper
+	formatWordsLower
+	formatWordsTitle
+)
+
+type groupSeparator struct {
+	position int
+	sep      rune
+}
+
+// An integerPicture is the parsed form of a picture string
+// passed to FormatInteger or ParseInteger.
+type integerPicture struct {
+	format  integerFormat
+	ordinal bool
+
+	// The following fields apply to decimal formats only.
+	zero       rune
+	minDigits  int
+	separators []groupSeparator
+	interval   int
+}
+
+// FormatInteger converts an integer to a string, formatted
+// according to the given picture string. See the XPath function
+// format-integer for the syntax of the picture string.
+//
+// https://www.w3.org/TR/xpath-functions-31/#func-format-integer
+//
+// The primary format token can be a decimal digit pattern
+// (e.g. "0001" or "#,##0"), "A" or "a" for alphabetic
+// numbering, "I" or "i" for roman numerals or "W", "w" or
+// "Ww" for words. The format modifier "o" (e.g. "w;o")
+// produces ordinal numbers. Only English is supported.
+func FormatInteger(n int64, picture string) (string, error) {
+
+	pic, err := parseIntegerPicture(picture)
+	if err != nil {
+		return "", err
+	}
+
+	var s string
+
+	switch pic.format {
+	case formatAlphaUpper, formatAlphaLower:
+		if n < 1 {
+			return formatDecimalInteger(n, pic), nil
+		}
+		s = formatAlpha(n)
+		if pic.format == formatAlphaLower {
+			s = strings.ToLower(s)
+		}
+	case formatRomanUpper, formatRomanLower:
+		if n < 1 || n > maxRoman {
+			return formatDecimalInteger(n, pic), nil
+		}
+		s = formatRoman(n)
+		if pic.format == formatRomanLower {
+			s = strings.ToLower(s)
+		}
+	case formatWordsUpper, formatWordsLower, formatWordsTitle:
+		s = formatWords(n, pic.ordinal)
+		switch pic.format {
+		case formatWordsUpper:
+			s = strings.ToUpper(s)
+		case formatWordsLower:
+			s = strings.ToLower(s)
+		}
+	default:
+		s = formatDecimalInteger(n, pic)
+	}
+
+	return s, nil
+}
+
+// ParseInteger is the inverse of FormatInteger. It converts a
+// string formatted according to the given picture string back
+// to an integer.
+func ParseInteger(s string, picture string) (int64, error) {
+
+	pic, err := parseIntegerPicture(picture)
+	if err != nil {
+		return 0, err
+	}
+
+	var n int64
+	var ok bool
+
+	switch pic.format {
+	case formatAlphaUpper, formatAlphaLower:
+		if n, ok = parseAlpha(s); !ok {
+			n, ok = parseDecimalInteger(s, pic)
+		}
+	case formatRomanUpper, formatRomanLower:
+		if n, ok = parseRoman(s); !ok {
+			n, ok = parseDecimalInteger(s, pic)
+		}
+	case formatWordsUpper, formatWordsLower, formatWordsTitle:
+		n, ok = parseWords(s)
+	default:
+		n, ok = parseDecimalInteger(s, pic)
+	}
+
+	if !ok {
+		return 0, fmt.Errorf("cannot parse %q using picture %q", s, picture)
+	}
+
+	return n, nil
+}
+
+func parseIntegerPicture(picture string) (integerPicture, error) {
+
+	var pic integerPicture
+
+	token := picture
+	if pos := strings.LastIndexByte(picture, ';'); pos >= 0 {
+		token = picture[:pos]
+		modifier := picture[pos+1:]
+		if modifier == "" {
+			return pic, fmt.Errorf("empty format modifier")
+		}
+		switch modifier[0] {
+		case 'o':
+			pic.ordinal = true
+		case 'c':
+		default:
+			return pic, fmt.Errorf("invalid format modifier %q", modifier)
+		}
+	}
+
+	if token == "" {
+		return pic, fmt.Errorf("picture string cannot be empty")
+	}
+
+	switch token {
+	case "A":
+		pic.format = formatAlphaUpper
+	case "a":
+		pic.format = formatAlphaLower
+	case "I":
+		pic.format = formatRomanUpper
+	case "i":
+		pic.format = formatRomanLower
+	case "W":
+		pic.format = formatWordsUpper
+	case "w":
+		pic.format = formatWordsLower
+	case "Ww":
+		pic.format = formatWordsTitle
+	}
+
+	if pic.format != formatDecimal || !containsDigit(token) {
+		// Unrecognised tokens default to "1", as the XPath
+		// spec requires. Non-decimal formats also use this
+		// for numbers they cannot represent (e.g. zero).
+		token = "1"
+	}
+
+	if err := parseDecimalPicture(token, &pic); err != nil {
+		return pic, err
+	}
+
+	return pic, nil
+}
+
+// parseDecimalPicture parses a decimal digit pattern such as
+// "#,##0". The pattern is scanned from right to left so that
+// the positions of the grouping separators can be recorded as
+// the number of digits to their right.
+func parseDecimalPicture(token string, pic *integerPicture) error {
+
+	var digits int
+	var seenOptional, lastWasSep bool
+
+	for s := token; s != ""; {
+
+		r, size := utf8.DecodeLastRuneInString(s)
+		s = s[:len(s)-size]
+
+		switch {
+		case r == '#':
+			seenOptional = true
+			digits++
+			lastWasSep = false
+		case unicode.Is(unicode.Nd, r):
+			zero := digitZero(r)
+			if pic.zero != 0 && zero != pic.zero {
+				return fmt.Errorf("picture string %q mixes digits from different families", token)
+			}
+			if seenOptional {
+				return fmt.Errorf("picture string %q has an optional digit after a mandatory digit", token)
+			}
+			pic.zero = zero
+			pic.minDigits++
+			digits++
+			lastWasSep = false
+		case unicode.IsLetter(r) || unicode.IsNumber(r):
+			return fmt.Errorf("invalid character %q in picture string %q", r, token)
+		default:
+			if digits == 0 || lastWasSep || s == "" {
+				return fmt.Errorf("picture string %q has a misplaced grouping separator", token)
+			}
+			pic.separators = append(pic.separators, groupSeparator{
+				position: digits,
+				sep:      r,
+			})
+			lastWasSep = true
+		}
+	}
+
+	if pic.minDigits == 0 {
+		return fmt.Errorf("picture string %q must contain at least one digit", token)
+	}
+
+	// If the separators are all the same and evenly spaced,
+	// they repeat indefinitely.
+	if len(pic.separators) > 0 {
+		interval := pic.separators[0].position
+		regular := true
+		for i, g := range pic.separators {
+			if g.sep != pic.separators[0].sep || g.position != (i+1)*interval {
+				regular = false
+				break
+			}
+		}
+		if regular {
+			pic.interval = interval
+		}
+	}
+
+	return nil
+}
+
+func formatDecimalInteger(n int64, pic integerPicture) string {
+
+	digits := strconv.FormatUint(absInt64(n), 10)
+	if len(digits) < pic.minDigits {
+		digits = strings.Repeat("0", pic.minDigits-len(digits)) + digits
+	}
+
+	// Build the output in reverse so that grouping separators
+	// can be inserted by counting digits from the right.
+	out := make([]rune, 0, 2*len(digits))
+
+	for i := 0; i < len(digits); i++ {
+		if i > 0 {
+			if sep, ok := pic.separatorAt(i); ok {
+				out = append(out, sep)
+			}
+		}
+		out = append(out, pic.zero+rune(digits[len(digits)-1-i]-'0'))
+	}
+
+	if n < 0 {
+		out = append(out, '-')
+	}
+
+	for i, j := 0, len(out)-1; i < j; i, j = i+1, j-1 {
+		out[i], out[j] = out[j], out[i]
+	}
+
+	s := string(out)
+	if pic.ordinal {
+		s += ordinalSuffix(int(absInt64(n) % 100))
+	}
+
+	return s
+}
+
+// separatorAt returns the grouping separator, if any, that
+// precedes the digit at position pos (counting from zero at
+// the right).
+func (pic *integerPicture) separatorAt(pos int) (rune, bool) {
+
+	if pic.interval > 0 {
+		if pos%pic.interval == 0 {
+			return pic.separators[0].sep, true
+		}
+		return 0, false
+	}
+
+	for _, g := range pic.separators {
+		if g.position == pos {
+			return g.sep, true
+		}
+	}
+
+	return 0, false
+}
+
+func (pic *integerPicture) isSeparator(r rune) bool {
+
+	for _, g := range pic.separators {
+		if g.sep == r {
+			return true
+		}
+	}
+
+	return false
+}
+
+var reOrdinalSuffix = regexp.MustCompile(`(st|nd|rd|th)$`)
+
+func parseDecimalInteger(s string, pic integerPicture) (int64, bool) {
+
+	neg := strings.HasPrefix(s, "-")
+	if neg {
+		s = s[1:]
+	}
+
+	if pic.ordinal {
+		s = reOrdinalSuffix.ReplaceAllString(s, "")
+	}
+
+	var n uint64
+	var digits int
+
+	for _, r := range s {
+		switch {
+		case r >= pic.zero && r <= pic.zero+9:
+			d := uint64(r - pic.zero)
+			if n > (math.MaxInt64-d)/10 {
+				return 0, false
+			}
+			n = n*10 + d
+			digits++
+		case pic.isSeparator(r):
+		default:
+			return 0, false
+		}
+	}
+
+	if digits == 0 {
+		return 0, false
+	}
+
+	if neg {
+		return -int64(n), true
+	}
+
+	return int64(n), true
+}
+
+func formatAlpha(n int64) string {
+
+	var b []byte
+
+	for n > 0 {
+		n--
+		b = append(b, byte('A'+n%26))
+		n /= 26
+	}
+
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+
+	return string(b)
+}
+
+func parseAlpha(s string) (int64, bool) {
+
+	if s == "" {
+		return 0, false
+	}
+
+	var n int64
+
+	for _, r := range strings.ToUpper(s) {
+		if r < 'A' || r > 'Z' {
+			return 0, false
+		}
+		d := int64(r - 'A' + 1)
+		if n > (math.MaxInt64-d)/26 {
+			return 0, false
+		}
+		n = n*26 + d
+	}
+
+	return n, true
+}
+
+// maxRoman is the largest number that FormatInteger writes
+// as a roman numeral. Larger numbers would be written as long
+// strings of Ms, so they are written as decimals instead.
+const maxRoman = 1000000
+
+var romanNumerals = []struct {
+	value   int64
+	numeral string
+}{
+	{1000, "M"},
+	{900, "CM"},
+	{500, "D"},
+	{400, "CD"},
+	{100, "C"},
+	{90, "XC"},
+	{50, "L"},
+	{40, "XL"},
+	{10, "X"},
+	{9, "IX"},
+	{5, "V"},
+	{4, "IV"},
+	{1, "I"},
+}
+
+func formatRoman(n int64) string {
+
+	var b strings.Builder
+
+	for _, r := range romanNumerals {
+		for n >= r.value {
+			b.WriteString(r.numeral)
+			n -= r.value
+		}
+	}
+
+	return b.String()
+}
+
+func parseRoman(s string) (int64, bool) {
+
+	upper := strings.ToUpper(s)
+	rest := upper
+
+	var n int64
+
+	for _, r := range romanNumerals {
+		for strings.HasPrefix(rest, r.numeral) {
+			n += r.value
+			rest = rest[len(r.numeral):]
+		}
+	}
+
+	// Only accept numerals in the canonical form produced
+	// by formatRoman (e.g. IV rather than IIII).
+	if rest != "" || n == 0 || formatRoman(n) != upper {
+		return 0, false
+	}
+
+	return n, true
+}
+
+var (
+	wordsFew = []string{
+		"Zero", "One", "Two", "Three", "Four", "Five", "Six",
+		"Seven", "Eight", "Nine", "Ten", "Eleven", "Twelve",
+		"Thirteen", "Fourteen", "Fifteen", "Sixteen",
+		"Seventeen", "Eighteen", "Nineteen",
+	}
+	wordsOrdinals = []string{
+		"Zeroth", "First", "Second", "Third", "Fourth", "Fifth",
+		"Sixth", "Seventh", "Eighth", "Ninth", "Tenth",
+		"Eleventh", "Twelfth", "Thirteenth", "Fourteenth",
+		"Fifteenth", "Sixteenth", "Seventeenth", "Eighteenth",
+		"Nineteenth",
+	}
+	wordsDecades = []string{
+		"Twenty", "Thirty", "Forty", "Fifty", "Sixty",
+		"Seventy", "Eighty", "Ninety",
+	}
+	wordsMagnitudes = []string{
+		"Thousand", "Million", "Billion", "Trillion",
+	}
+)
+
+// formatWords returns the English words for n in title case,
+// e.g. "One Thousand, Two Hundred and Thirty-Four". This is
+// the same format as jsonata-js.
+func formatWords(n int64, ordinal bool) string {
+
+	if n < 0 {
+		return "Minus " + numberToWords(absInt64(n), false, ordinal)
+	}
+
+	return numberToWords(uint64(n), false, ordinal)
+}
+
+func numberToWords(n uint64, prev bool, ordinal bool) string {
+
+	var s string
+
+	switch {
+	case n < 20:
+		if prev {
+			s = " and "
+		}
+		if ordinal {
+			s += wordsOrdinals[n]
+		} else {
+			s += wordsFew[n]
+		}
+
+	case n < 100:
+		if prev {
+			s = " and "
+		}
+		s += wordsDecades[n/10-2]
+		if rem := n % 10; rem > 0 {
+			s += "-" + numberToWords(rem, false, ordinal)
+		} else if ordinal {
+			s = strings.TrimSuffix(s, "y") + "ieth"
+		}
+
+	case n < 1000:
+		if prev {
+			s = ", "
+		}
+		s += wordsFew[n/100] + " Hundred"
+		if rem := n % 100; rem > 0 {
+			s += numberToWords(rem, true, ordinal)
+		} else if ordinal {
+			s += "th"
+		}
+
+	default:
+		mag := 0
+		for m := n / 1000; m > 0 && mag < len(wordsMagnitudes); m /= 1000 {
+			mag++
+		}
+		factor := uint64(math.Pow10(3 * mag))
+		if prev {
+			s = ", "
+		}
+		s += numberToWords(n/factor, false, false) + " " + wordsMagnitudes[mag-1]
+		if rem := n % factor; rem > 0 {
+			s += numberToWords(rem, true, ordinal)
+		} else if ordinal {
+			s += "th"
+		}
+	}
+
+	return s
+}
+
+// wordValues maps the lower case words produced by formatWords
+// to their values.
+var wordValues = func() map[string]uint64 {
+
+	m := map[string]uint64{
+		"hundred":   100,
+		"hundredth": 100,
+	}
+
+	for i, w := range wordsFew {
+		m[strings.ToLower(w)] = uint64(i)
+	}
+
+	for i, w := range wordsOrdinals {
+		m[strings.ToLower(w)] = uint64(i)
+	}
+
+	for i, w := range wordsDecades {
+		w = strings.ToLower(w)
+		m[w] = uint64(i+2) * 10
+		m[strings.TrimSuffix(w, "y")+"ieth"] = uint64(i+2) * 10
+	}
+
+	for i, w := range wordsMagnitudes {
+		w = strings.ToLower(w)
+		m[w] = uint64(math.Pow10(3 * (i + 1)))
+		m[w+"th"] = uint64(math.Pow10(3 * (i + 1)))
+	}
+
+	return m
+}()
+
+var reWordSeparator = regexp.MustCompile(`,\s*|\s+and\s+|[\s-]+`)
+
+func parseWords(s string) (int64, bool) {
+
+	s = strings.ToLower(strings.TrimSpace(s))
+
+	neg := strings.HasPrefix(s, "minus ")
+	if neg {
+		s = s[len("minus "):]
+	}
+
+	if s == "" {
+		return 0, false
+	}
+
+	// Each segment holds the value of a group of words
+	// that ends with a magnitude (e.g. "two hundred and
+	// three thousand"). When a magnitude word is reached,
+	// all of the preceding segments that are smaller than
+	// it are combined and multiplied by it.
+	segs := []uint64{0}
+
+	for _, word := range reWordSeparator.Split(s, -1) {
+
+		value, ok := wordValues[word]
+		if !ok {
+			return 0, false
+		}
+
+		if value < 100 {
+			top := segs[len(segs)-1]
+			if top >= 1000 {
+				segs = append(segs, value)
+			} else {
+				segs[len(segs)-1] = top + value
+			}
+			continue
+		}
+
+		var sum uint64
+		for len(segs) > 0 && segs[len(segs)-1] < value {
+			sum += segs[len(segs)-1]
+			segs = segs[:len(segs)-1]
+		}
+
+		if sum > math.MaxInt64/value {
+			return 0, false
+		}
+
+		segs = append(segs, sum*value)
+	}
+
+	var n uint64
+	for _, v := range segs {
+		n += v
+	}
+
+	if n > math.MaxInt64 {
+		return 0, false
+	}
+
+	if neg {
+		return -int64(n), true
+	}
+
+	return int64(n), true
+}
+
+func containsDigit(s string) bool {
+
+	for _, r := range s {
+		if unicode.Is(unicode.Nd, r) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// digitZero returns the zero digit of the decimal digit family
+// that r belongs to. Unicode arranges decimal digits in blocks
+// of ten consecutive code points, starting with zero, so the
+// zero digit can be found from the start of the run of digits
+// that contains r.
+func digitZero(r rune) rune {
+
+	start := r
+	for unicode.Is(unicode.Nd, start-1) {
+		start--
+	}
+
+	return start + (r-start)/10*10
+}
+
+func absInt64(n int64) uint64 {
+	if n < 0 {
+		return uint64(-n)
+	}
+	return uint64(n)
+}
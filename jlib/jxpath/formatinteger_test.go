@@ -0,0 +1,325 @@
+// Copyright 2018 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package jxpath
+
+import (
+	"math"
+	"testing"
+)
+
+func TestFormatInteger(t *testing.T) {
+
+	tests := []struct {
+		Value   int64
+		Picture string
+		Output  string
+		Error   string
+	}{
+		{
+			Value:   123,
+			Picture: "1",
+			Output:  "123",
+		},
+		{
+			Value:   -12,
+			Picture: "0000",
+			Output:  "-0012",
+		},
+		{
+			Value:   1234567,
+			Picture: "#,##0",
+			Output:  "1,234,567",
+		},
+		{
+			Value:   1234567,
+			Picture: "#,###,##0",
+			Output:  "1,234,567",
+		},
+		{
+			// Irregular grouping separators do not repeat.
+			Value:   123456789,
+			Picture: "#,##,##0",
+			Output:  "1234,56,789",
+		},
+		{
+			Value:   1234567,
+			Picture: "# ##0",
+			Output:  "1 234 567",
+		},
+		{
+			Value:   1234,
+			Picture: "٠٠٠٠٠",
+			Output:  "٠١٢٣٤",
+		},
+		{
+			Value:   42,
+			Picture: "０",
+			Output:  "４２",
+		},
+		{
+			Value:   1,
+			Picture: "1;o",
+			Output:  "1st",
+		},
+		{
+			Value:   112,
+			Picture: "1;o",
+			Output:  "112th",
+		},
+		{
+			Value:   1,
+			Picture: "A",
+			Output:  "A",
+		},
+		{
+			Value:   28,
+			Picture: "a",
+			Output:  "ab",
+		},
+		{
+			Value:   702,
+			Picture: "A",
+			Output:  "ZZ",
+		},
+		{
+			// Alphabetic numbering starts at 1. Other
+			// numbers are formatted as decimals.
+			Value:   0,
+			Picture: "A",
+			Output:  "0",
+		},
+		{
+			Value:   2024,
+			Picture: "I",
+			Output:  "MMXXIV",
+		},
+		{
+			Value:   49,
+			Picture: "i",
+			Output:  "xlix",
+		},
+		{
+			Value:   -5,
+			Picture: "I",
+			Output:  "-5",
+		},
+		{
+			Value:   0,
+			Picture: "w",
+			Output:  "zero",
+		},
+		{
+			Value:   13,
+			Picture: "W",
+			Output:  "THIRTEEN",
+		},
+		{
+			Value:   90,
+			Picture: "w;o",
+			Output:  "ninetieth",
+		},
+		{
+			Value:   100,
+			Picture: "w;o",
+			Output:  "one hundredth",
+		},
+		{
+			Value:   1000000,
+			Picture: "Ww;o",
+			Output:  "One Millionth",
+		},
+		{
+			Value:   101,
+			Picture: "w",
+			Output:  "one hundred and one",
+		},
+		{
+			Value:   1234,
+			Picture: "w",
+			Output:  "one thousand, two hundred and thirty-four",
+		},
+		{
+			Value:   1000001,
+			Picture: "w",
+			Output:  "one million and one",
+		},
+		{
+			Value:   -3000005,
+			Picture: "Ww",
+			Output:  "Minus Three Million and Five",
+		},
+		{
+			Value:   1500000000000000,
+			Picture: "w",
+			Output:  "one thousand, five hundred trillion",
+		},
+		{
+			// Unrecognised format tokens default to "1".
+			Value:   12,
+			Picture: "Z",
+			Output:  "12",
+		},
+		{
+			Picture: "",
+			Error:   "picture string cannot be empty",
+		},
+		{
+			Picture: "1;x",
+			Error:   `invalid format modifier "x"`,
+		},
+		{
+			Picture: "#0#",
+			Error:   `picture string "#0#" has an optional digit after a mandatory digit`,
+		},
+		{
+			Picture: "0,,000",
+			Error:   `picture string "0,,000" has a misplaced grouping separator`,
+		},
+		{
+			Picture: ",000",
+			Error:   `picture string ",000" has a misplaced grouping separator`,
+		},
+		{
+			Picture: "0٠",
+			Error:   `picture string "0٠" mixes digits from different families`,
+		},
+	}
+
+	for _, test := range tests {
+
+		output, err := FormatInteger(test.Value, test.Picture)
+
+		if output != test.Output {
+			t.Errorf("FormatInteger(%d, %q): expected %q, got %q", test.Value, test.Picture, test.Output, output)
+		}
+
+		switch {
+		case test.Error == "" && err != nil:
+			t.Errorf("FormatInteger(%d, %q): unexpected error: %s", test.Value, test.Picture, err)
+		case test.Error != "" && (err == nil || err.Error() != test.Error):
+			t.Errorf("FormatInteger(%d, %q): expected error %q, got %v", test.Value, test.Picture, test.Error, err)
+		}
+	}
+}
+
+func TestParseInteger(t *testing.T) {
+
+	tests := []struct {
+		Input   string
+		Picture string
+		Output  int64
+		Error   bool
+	}{
+		{
+			Input:   "ONE HUNDRED AND ONE",
+			Picture: "W",
+			Output:  101,
+		},
+		{
+			Input:   "one hundred and one thousand",
+			Picture: "w",
+			Output:  101000,
+		},
+		{
+			Input:   "xlix",
+			Picture: "i",
+			Output:  49,
+		},
+		{
+			Input:   "٠١٢٣٤",
+			Picture: "٠",
+			Output:  1234,
+		},
+		{
+			Input:   "IIII",
+			Picture: "I",
+			Error:   true,
+		},
+		{
+			Input:   "1,234",
+			Picture: "0",
+			Error:   true,
+		},
+		{
+			Input:   "",
+			Picture: "0",
+			Error:   true,
+		},
+		{
+			Input:   "99999999999999999999",
+			Picture: "0",
+			Error:   true,
+		},
+		{
+			Input:   "one hundred and fish",
+			Picture: "w",
+			Error:   true,
+		},
+	}
+
+	for _, test := range tests {
+
+		output, err := ParseInteger(test.Input, test.Picture)
+
+		if output != test.Output {
+			t.Errorf("ParseInteger(%q, %q): expected %d, got %d", test.Input, test.Picture, test.Output, output)
+		}
+
+		if (err != nil) != test.Error {
+			t.Errorf("ParseInteger(%q, %q): expected error %t, got %v", test.Input, test.Picture, test.Error, err)
+		}
+	}
+}
+
+func TestIntegerRoundTrip(t *testing.T) {
+
+	pictures := []string{
+		"1",
+		"0001",
+		"#,##0",
+		"#,##,##0",
+		"٠",
+		"1;o",
+		"A",
+		"a",
+		"I",
+		"i",
+		"W",
+		"w",
+		"Ww",
+		"w;o",
+		"Ww;o",
+	}
+
+	values := []int64{
+		1, 2, 9, 10, 11, 12, 13, 19, 20, 21, 30, 42, 99, 100,
+		101, 110, 111, 999, 1000, 1001, 1010, 1100, 1999, 2024,
+		3999, 10000, 12345, 100000, 101000, 999999, 1000000,
+		1000001, 1234567, 20000000, 123456789, 1000000000,
+		1234567890123, 1000000000000000, 1500000000000000,
+		999999999999999999, math.MaxInt64,
+	}
+
+	for _, picture := range pictures {
+		for _, n := range values {
+
+			s, err := FormatInteger(n, picture)
+			if err != nil {
+				t.Errorf("FormatInteger(%d, %q): %s", n, picture, err)
+				continue
+			}
+
+			got, err := ParseInteger(s, picture)
+			if err != nil {
+				t.Errorf("ParseInteger(%q, %q): %s", s, picture, err)
+				continue
+			}
+
+			if got != n {
+				t.Errorf("%q: %d formats as %q, which parses as %d", picture, n, s, got)
+			}
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"bytes"
 	"fmt"
 	"math"
+	"math/big"
 	"strconv"
 	"strings"
 	"unicode/utf8"
@@ -157,21 +158,77 @@ func FormatNumber(value float64, picture string, format DecimalFormat) (string,
 		}
 	}
 
-	var integerPart, fractionalPart, exponentPart string
-
 	value = round(value, vars.MaxFractionalSize)
 	s := makeNumberString(value, vars.MaxFractionalSize, &format)
+
+	return formatNumberString(s, exponent, &vars, &format), nil
+}
+
+// FormatNumberExact is like FormatNumber but it formats an
+// exact rational number. Unlike FormatNumber, it does not
+// lose precision when formatting large integers or values
+// with many decimal places.
+func FormatNumberExact(value *big.Rat, picture string, format DecimalFormat) (string, error) {
+	if picture == "" {
+		return "", fmt.Errorf("picture string cannot be empty")
+	}
+
+	vars, err := processPicture(picture, &format, value.Sign() < 0)
+	if err != nil {
+		return "", err
+	}
+
+	x := new(big.Rat).Abs(value)
+
+	switch vars.NumberType {
+	case typePercent:
+		x.Mul(x, big.NewRat(100, 1))
+	case typePermille:
+		x.Mul(x, big.NewRat(1000, 1))
+	}
+
+	exponent := 0
+	if vars.MinExponentSize != 0 && x.Sign() != 0 {
+
+		ten := big.NewRat(10, 1)
+		maxMantissa := pow10Rat(vars.ScalingFactor)
+		minMantissa := pow10Rat(vars.ScalingFactor - 1)
+
+		for x.Cmp(minMantissa) < 0 {
+			x.Mul(x, ten)
+			exponent--
+		}
+
+		for x.Cmp(maxMantissa) > 0 {
+			x.Quo(x, ten)
+			exponent++
+		}
+	}
+
+	x = roundRat(x, vars.MaxFractionalSize)
+	s := mapDigits([]byte(x.FloatString(vars.MaxFractionalSize)), &format)
+
+	return formatNumberString(s, exponent, &vars, &format), nil
+}
+
+// formatNumberString formats the absolute value of a number,
+// given as a string of digits with an optional decimal point,
+// according to the given picture variables and decimal format.
+func formatNumberString(s string, exponent int, vars *subpictureVariables, format *DecimalFormat) string {
+
+	var integerPart, fractionalPart, exponentPart string
+
 	sint, sfrac := splitStringAtByte(s, '.')
 	if sint != "" {
-		integerPart = formatIntegerPart(sint, &vars, &format)
+		integerPart = formatIntegerPart(sint, vars, format)
 	}
 	if sfrac != "" {
-		fractionalPart = formatFractionalPart(sfrac, &vars, &format)
+		fractionalPart = formatFractionalPart(sfrac, vars, format)
 	}
 
 	if vars.MinExponentSize != 0 {
-		s := makeNumberString(float64(exponent), 0, &format)
-		exponentPart = formatExponentPart(s, &vars, &format)
+		s := makeNumberString(float64(exponent), 0, format)
+		exponentPart = formatExponentPart(s, vars, format)
 	}
 
 	buf := make([]byte, 0, 128)
@@ -194,7 +251,7 @@ func FormatNumber(value float64, picture string, format DecimalFormat) (string,
 
 	buf = append(buf, vars.Suffix...)
 
-	return string(buf), nil
+	return string(buf)
 }
 
 func processPicture(picture string, format *DecimalFormat, isNegative bool) (subpictureVariables, error) {
@@ -596,6 +653,12 @@ func formatExponentPart(exponent string, vars *subpictureVariables, format *Deci
 func makeNumberString(value float64, dp int, format *DecimalFormat) string {
 
 	s := strconv.AppendFloat(make([]byte, 0, 24), math.Abs(value), 'f', dp, 64)
+	return mapDigits(s, format)
+}
+
+// mapDigits converts the ASCII digits in s to the digits of
+// the given decimal format.
+func mapDigits(s []byte, format *DecimalFormat) string {
 
 	if format.ZeroDigit != '0' {
 		s = bytes.Map(func(r rune) rune {
@@ -781,6 +844,43 @@ func round(x float64, prec int) float64 {
 	return x / pow
 }
 
+// roundRat rounds x to prec decimal places, rounding halves
+// to the nearest even digit (as round does).
+func roundRat(x *big.Rat, prec int) *big.Rat {
+
+	pow := pow10Rat(prec)
+
+	// Split x * 10^prec into an integer part and a remainder.
+	scaled := new(big.Rat).Mul(x, pow)
+	q, r := new(big.Int).QuoRem(scaled.Num(), scaled.Denom(), new(big.Int))
+
+	// Compare twice the remainder with the denominator to
+	// determine whether the remainder is more, less or
+	// exactly half.
+	r.Abs(r).Lsh(r, 1)
+	switch c := r.Cmp(scaled.Denom()); {
+	case c > 0, c == 0 && q.Bit(0) == 1:
+		if scaled.Sign() < 0 {
+			q.Sub(q, big.NewInt(1))
+		} else {
+			q.Add(q, big.NewInt(1))
+		}
+	}
+
+	return new(big.Rat).Quo(new(big.Rat).SetInt(q), pow)
+}
+
+// pow10Rat returns 10 to the power of n.
+func pow10Rat(n int) *big.Rat {
+
+	p := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(abs(n))), nil)
+	if n < 0 {
+		return new(big.Rat).SetFrac(big.NewInt(1), p)
+	}
+
+	return new(big.Rat).SetInt(p)
+}
+
 func isHalfway(x float64) bool {
 	_, frac := math.Modf(x)
 	frac = math.Abs(frac)
@@ -5,6 +5,7 @@
 package jxpath
 
 import (
+	"math/big"
 	"reflect"
 	"testing"
 )
@@ -96,3 +97,62 @@ func testFormatNumber(t *testing.T, tests []formatNumberTest) {
 		}
 	}
 }
+
+func TestFormatNumberExact(t *testing.T) {
+
+	tests := []struct {
+		Value   string
+		Picture string
+		Output  string
+	}{
+		{
+			Value:   "12345678901234567890.5",
+			Picture: "#,##0.0",
+			Output:  "12,345,678,901,234,567,890.5",
+		},
+		{
+			Value:   "0.125",
+			Picture: "0.00",
+			Output:  "0.12",
+		},
+		{
+			Value:   "-6",
+			Picture: "000",
+			Output:  "-006",
+		},
+		{
+			Value:   "0.14",
+			Picture: "01%",
+			Output:  "14%",
+		},
+		{
+			Value:   "1234.5678",
+			Picture: "00.000e0",
+			Output:  "12.346e2",
+		},
+		{
+			Value:   "0.000000000000000000001",
+			Picture: "0.0e0",
+			Output:  "1.0e-21",
+		},
+	}
+
+	df := NewDecimalFormat()
+
+	for i, test := range tests {
+
+		r, ok := new(big.Rat).SetString(test.Value)
+		if !ok {
+			t.Fatalf("%d. invalid value %q", i+1, test.Value)
+		}
+
+		output, err := FormatNumberExact(r, test.Picture, df)
+		if err != nil {
+			t.Errorf("%d. FormatNumberExact(%s, %q): unexpected error %s", i+1, test.Value, test.Picture, err)
+		}
+
+		if output != test.Output {
+			t.Errorf("%d. FormatNumberExact(%s, %q): expected %s, got %s", i+1, test.Value, test.Picture, test.Output, output)
+		}
+	}
+}
@@ -5,132 +5,1470 @@
 package jxpath
 
 import (
+	"fmt"
+	"strings"
+	"sync"
 	"time"
 )
 
-type dateLanguage struct {
-	days     [7][]string
-	months   [13][]string
-	am       []string
-	pm       []string
-	tzPrefix string
-}
-
-var dateLanguages = map[string]dateLanguage{
-	"en": {
-		days: [...][]string{
-			time.Sunday: {
-				"Sunday",
-				"Sun",
-				"Su",
-			},
-			time.Monday: {
-				"Monday",
-				"Mon",
-				"Mo",
-			},
-			time.Tuesday: {
-				"Tuesday",
-				"Tues",
-				"Tue",
-				"Tu",
-			},
-			time.Wednesday: {
-				"Wednesday",
-				"Weds",
-				"Wed",
-				"We",
-			},
-			time.Thursday: {
-				"Thursday",
-				"Thurs",
-				"Thur",
-				"Thu",
-				"Th",
-			},
-			time.Friday: {
-				"Friday",
-				"Fri",
-				"Fr",
-			},
-			time.Saturday: {
-				"Saturday",
-				"Sat",
-				"Sa",
-			},
-		},
-		months: [...][]string{
-			time.January: {
-				"January",
-				"Jan",
-				"Ja",
-			},
-			time.February: {
-				"February",
-				"Feb",
-				"Fe",
-			},
-			time.March: {
-				"March",
-				"Mar",
-				"Mr",
-			},
-			time.April: {
-				"April",
-				"Apr",
-				"Ap",
-			},
-			time.May: {
-				"May",
-				"My",
-			},
-			time.June: {
-				"June",
-				"Jun",
-				"Jn",
-			},
-			time.July: {
-				"July",
-				"Jul",
-				"Jl",
-			},
-			time.August: {
-				"August",
-				"Aug",
-				"Au",
-			},
-			time.September: {
-				"September",
-				"Sept",
-				"Sep",
-				"Se",
-			},
-			time.October: {
-				"October",
-				"Oct",
-				"Oc",
-			},
-			time.November: {
-				"November",
-				"Nov",
-				"No",
-			},
-			time.December: {
-				"December",
-				"Dec",
-				"De",
-			},
-		},
-		am: []string{
-			"am",
-			"a",
-		},
-		pm: []string{
-			"pm",
-			"p",
-		},
-		tzPrefix: "GMT",
-	},
-}
-
-var defaultLanguage = dateLanguages["en"]
+// A Language holds the names used to format and parse dates
+// in a particular language.
+//
+// Each day, month and AM/PM designator has a list of names.
+// The first name is the full name. Any others are shorter
+// alternatives, in order of decreasing length, for pictures
+// that specify a maximum width (e.g. "[MNn,*-3]").
+type Language struct {
+
+	// Days holds the names of the days of the week,
+	// indexed by time.Weekday.
+	Days [7][]string
+
+	// Months holds the names of the months, indexed by
+	// time.Month. Index 0 is unused.
+	Months [13][]string
+
+	// StandaloneMonths optionally holds the names of the
+	// months as they appear on their own, in languages where
+	// they differ from the names used in a date (e.g. in
+	// Russian, where Months holds the genitive case and
+	// StandaloneMonths the nominative). ParseTime accepts
+	// them as well as Months. FormatTime does not use them.
+	StandaloneMonths [13][]string
+
+	// AM and PM hold the names for times before and after
+	// midday.
+	AM []string
+	PM []string
+
+	// TZPrefix is the prefix for numeric timezones in
+	// the [z] component (e.g. "GMT").
+	TZPrefix string
+
+	// Ordinal returns the suffix for the ordinal form of
+	// the number n (e.g. "st" for 1 in English), for
+	// pictures with the "o" modifier (e.g. "[D1o]"). If it
+	// is nil, such pictures are an error.
+	Ordinal func(n int) string
+}
+
+func (l *Language) validate() error {
+
+	for day, names := range l.Days {
+		if len(names) == 0 {
+			return fmt.Errorf("no names for %s", time.Weekday(day))
+		}
+	}
+
+	for month := time.January; month <= time.December; month++ {
+		if len(l.Months[month]) == 0 {
+			return fmt.Errorf("no names for %s", month)
+		}
+	}
+
+	if len(l.AM) == 0 || len(l.PM) == 0 {
+		return fmt.Errorf("no names for AM/PM")
+	}
+
+	return nil
+}
+
+// clone returns a deep copy of the language, so that the
+// registry does not share its name lists with the caller.
+func (l Language) clone() Language {
+
+	copyNames := func(names []string) []string {
+		if names == nil {
+			return nil
+		}
+		return append([]string(nil), names...)
+	}
+
+	for i := range l.Days {
+		l.Days[i] = copyNames(l.Days[i])
+	}
+
+	for i := range l.Months {
+		l.Months[i] = copyNames(l.Months[i])
+		l.StandaloneMonths[i] = copyNames(l.StandaloneMonths[i])
+	}
+
+	l.AM = copyNames(l.AM)
+	l.PM = copyNames(l.PM)
+
+	return l
+}
+
+var (
+	languagesMutex sync.RWMutex
+	languages      = map[string]*Language{}
+)
+
+// RegisterLanguage makes a language available to
+// FormatTimeLanguage and ParseTime under the given tag (e.g.
+// "de" or "pt-BR"). It replaces any language previously
+// registered under the same tag. The language is copied, so
+// later changes to lang do not affect the registry.
+func RegisterLanguage(tag string, lang Language) error {
+
+	if err := lang.validate(); err != nil {
+		return fmt.Errorf("invalid language %q: %s", tag, err)
+	}
+
+	lang = lang.clone()
+
+	languagesMutex.Lock()
+	languages[normalizeLanguageTag(tag)] = &lang
+	languagesMutex.Unlock()
+
+	return nil
+}
+
+// LookupLanguage returns the language registered under the
+// given tag. Tags are not case sensitive. If no language is
+// registered under a tag with a region (e.g. "de-AT"), the
+// language registered under the primary tag ("de") is used.
+// The result is a copy that the caller may modify.
+func LookupLanguage(tag string) (Language, bool) {
+
+	if tag == "" {
+		return Language{}, false
+	}
+
+	lang, err := lookupLanguage(tag)
+	if err != nil {
+		return Language{}, false
+	}
+
+	return lang.clone(), true
+}
+
+// lookupLanguage is like LookupLanguage except that it returns
+// an error for an unknown language and English for an empty
+// tag.
+func lookupLanguage(tag string) (*Language, error) {
+
+	if tag == "" {
+		tag = "en"
+	}
+
+	norm := normalizeLanguageTag(tag)
+
+	languagesMutex.RLock()
+	defer languagesMutex.RUnlock()
+
+	if lang, ok := languages[norm]; ok {
+		return lang, nil
+	}
+
+	if pos := strings.IndexByte(norm, '-'); pos > 0 {
+		if lang, ok := languages[norm[:pos]]; ok {
+			return lang, nil
+		}
+	}
+
+	return nil, fmt.Errorf("unknown language %q", tag)
+}
+
+func normalizeLanguageTag(tag string) string {
+	return strings.ToLower(strings.Replace(tag, "_", "-", -1))
+}
+
+func init() {
+	for tag, lang := range builtinLanguages {
+		if err := RegisterLanguage(tag, lang); err != nil {
+			panic(err)
+		}
+	}
+}
+
+var english = Language{
+	Days: [...][]string{
+		time.Sunday: {
+			"Sunday",
+			"Sun",
+			"Su",
+		},
+		time.Monday: {
+			"Monday",
+			"Mon",
+			"Mo",
+		},
+		time.Tuesday: {
+			"Tuesday",
+			"Tues",
+			"Tue",
+			"Tu",
+		},
+		time.Wednesday: {
+			"Wednesday",
+			"Weds",
+			"Wed",
+			"We",
+		},
+		time.Thursday: {
+			"Thursday",
+			"Thurs",
+			"Thur",
+			"Thu",
+			"Th",
+		},
+		time.Friday: {
+			"Friday",
+			"Fri",
+			"Fr",
+		},
+		time.Saturday: {
+			"Saturday",
+			"Sat",
+			"Sa",
+		},
+	},
+	Months: [...][]string{
+		time.January: {
+			"January",
+			"Jan",
+			"Ja",
+		},
+		time.February: {
+			"February",
+			"Feb",
+			"Fe",
+		},
+		time.March: {
+			"March",
+			"Mar",
+			"Mr",
+		},
+		time.April: {
+			"April",
+			"Apr",
+			"Ap",
+		},
+		time.May: {
+			"May",
+			"My",
+		},
+		time.June: {
+			"June",
+			"Jun",
+			"Jn",
+		},
+		time.July: {
+			"July",
+			"Jul",
+			"Jl",
+		},
+		time.August: {
+			"August",
+			"Aug",
+			"Au",
+		},
+		time.September: {
+			"September",
+			"Sept",
+			"Sep",
+			"Se",
+		},
+		time.October: {
+			"October",
+			"Oct",
+			"Oc",
+		},
+		time.November: {
+			"November",
+			"Nov",
+			"No",
+		},
+		time.December: {
+			"December",
+			"Dec",
+			"De",
+		},
+	},
+	AM: []string{
+		"am",
+		"a",
+	},
+	PM: []string{
+		"pm",
+		"p",
+	},
+	TZPrefix: "GMT",
+	Ordinal:  ordinalSuffix,
+}
+
+var builtinLanguages = map[string]Language{
+	"de": german,
+	"en": english,
+	"es": spanish,
+	"fr": french,
+	"it": italian,
+	"ja": japanese,
+	"ko": korean,
+	"nl": dutch,
+	"pl": polish,
+	"pt": portuguese,
+	"ru": russian,
+	"sv": swedish,
+	"zh": chinese,
+}
+
+// suffixOrdinal returns an Ordinal function for languages
+// that use the same suffix for all numbers.
+func suffixOrdinal(suffix string) func(int) string {
+	return func(int) string {
+		return suffix
+	}
+}
+
+func frenchOrdinal(n int) string {
+	if n == 1 {
+		return "er"
+	}
+	return "e"
+}
+
+func swedishOrdinal(n int) string {
+	if mod10 := n % 10; (mod10 == 1 || mod10 == 2) && n%100/10 != 1 {
+		return ":a"
+	}
+	return ":e"
+}
+
+var german = Language{
+	Days: [...][]string{
+		time.Sunday: {
+			"Sonntag",
+			"So",
+		},
+		time.Monday: {
+			"Montag",
+			"Mo",
+		},
+		time.Tuesday: {
+			"Dienstag",
+			"Di",
+		},
+		time.Wednesday: {
+			"Mittwoch",
+			"Mi",
+		},
+		time.Thursday: {
+			"Donnerstag",
+			"Do",
+		},
+		time.Friday: {
+			"Freitag",
+			"Fr",
+		},
+		time.Saturday: {
+			"Samstag",
+			"Sa",
+		},
+	},
+	Months: [...][]string{
+		time.January: {
+			"Januar",
+			"Jan",
+		},
+		time.February: {
+			"Februar",
+			"Feb",
+		},
+		time.March: {
+			"März",
+			"Mär",
+		},
+		time.April: {
+			"April",
+			"Apr",
+		},
+		time.May: {
+			"Mai",
+		},
+		time.June: {
+			"Juni",
+			"Jun",
+		},
+		time.July: {
+			"Juli",
+			"Jul",
+		},
+		time.August: {
+			"August",
+			"Aug",
+		},
+		time.September: {
+			"September",
+			"Sept",
+			"Sep",
+		},
+		time.October: {
+			"Oktober",
+			"Okt",
+		},
+		time.November: {
+			"November",
+			"Nov",
+		},
+		time.December: {
+			"Dezember",
+			"Dez",
+		},
+	},
+	AM: []string{
+		"AM",
+	},
+	PM: []string{
+		"PM",
+	},
+	TZPrefix: "GMT",
+	Ordinal:  suffixOrdinal("."),
+}
+
+var spanish = Language{
+	Days: [...][]string{
+		time.Sunday: {
+			"domingo",
+			"dom",
+		},
+		time.Monday: {
+			"lunes",
+			"lun",
+		},
+		time.Tuesday: {
+			"martes",
+			"mar",
+		},
+		time.Wednesday: {
+			"miércoles",
+			"mié",
+		},
+		time.Thursday: {
+			"jueves",
+			"jue",
+		},
+		time.Friday: {
+			"viernes",
+			"vie",
+		},
+		time.Saturday: {
+			"sábado",
+			"sáb",
+		},
+	},
+	Months: [...][]string{
+		time.January: {
+			"enero",
+			"ene",
+		},
+		time.February: {
+			"febrero",
+			"feb",
+		},
+		time.March: {
+			"marzo",
+			"mar",
+		},
+		time.April: {
+			"abril",
+			"abr",
+		},
+		time.May: {
+			"mayo",
+			"may",
+		},
+		time.June: {
+			"junio",
+			"jun",
+		},
+		time.July: {
+			"julio",
+			"jul",
+		},
+		time.August: {
+			"agosto",
+			"ago",
+		},
+		time.September: {
+			"septiembre",
+			"sept",
+			"sep",
+		},
+		time.October: {
+			"octubre",
+			"oct",
+		},
+		time.November: {
+			"noviembre",
+			"nov",
+		},
+		time.December: {
+			"diciembre",
+			"dic",
+		},
+	},
+	AM: []string{
+		"a. m.",
+		"a.m.",
+	},
+	PM: []string{
+		"p. m.",
+		"p.m.",
+	},
+	TZPrefix: "GMT",
+	Ordinal:  suffixOrdinal("º"),
+}
+
+var french = Language{
+	Days: [...][]string{
+		time.Sunday: {
+			"dimanche",
+			"dim",
+		},
+		time.Monday: {
+			"lundi",
+			"lun",
+		},
+		time.Tuesday: {
+			"mardi",
+			"mar",
+		},
+		time.Wednesday: {
+			"mercredi",
+			"mer",
+		},
+		time.Thursday: {
+			"jeudi",
+			"jeu",
+		},
+		time.Friday: {
+			"vendredi",
+			"ven",
+		},
+		time.Saturday: {
+			"samedi",
+			"sam",
+		},
+	},
+	Months: [...][]string{
+		time.January: {
+			"janvier",
+			"janv",
+			"jan",
+		},
+		time.February: {
+			"février",
+			"févr",
+			"fév",
+		},
+		time.March: {
+			"mars",
+		},
+		time.April: {
+			"avril",
+			"avr",
+		},
+		time.May: {
+			"mai",
+		},
+		time.June: {
+			"juin",
+		},
+		time.July: {
+			"juillet",
+			"juil",
+		},
+		time.August: {
+			"août",
+		},
+		time.September: {
+			"septembre",
+			"sept",
+		},
+		time.October: {
+			"octobre",
+			"oct",
+		},
+		time.November: {
+			"novembre",
+			"nov",
+		},
+		time.December: {
+			"décembre",
+			"déc",
+		},
+	},
+	AM: []string{
+		"AM",
+	},
+	PM: []string{
+		"PM",
+	},
+	TZPrefix: "UTC",
+	Ordinal:  frenchOrdinal,
+}
+
+var italian = Language{
+	Days: [...][]string{
+		time.Sunday: {
+			"domenica",
+			"dom",
+		},
+		time.Monday: {
+			"lunedì",
+			"lun",
+		},
+		time.Tuesday: {
+			"martedì",
+			"mar",
+		},
+		time.Wednesday: {
+			"mercoledì",
+			"mer",
+		},
+		time.Thursday: {
+			"giovedì",
+			"gio",
+		},
+		time.Friday: {
+			"venerdì",
+			"ven",
+		},
+		time.Saturday: {
+			"sabato",
+			"sab",
+		},
+	},
+	Months: [...][]string{
+		time.January: {
+			"gennaio",
+			"gen",
+		},
+		time.February: {
+			"febbraio",
+			"feb",
+		},
+		time.March: {
+			"marzo",
+			"mar",
+		},
+		time.April: {
+			"aprile",
+			"apr",
+		},
+		time.May: {
+			"maggio",
+			"mag",
+		},
+		time.June: {
+			"giugno",
+			"giu",
+		},
+		time.July: {
+			"luglio",
+			"lug",
+		},
+		time.August: {
+			"agosto",
+			"ago",
+		},
+		time.September: {
+			"settembre",
+			"set",
+		},
+		time.October: {
+			"ottobre",
+			"ott",
+		},
+		time.November: {
+			"novembre",
+			"nov",
+		},
+		time.December: {
+			"dicembre",
+			"dic",
+		},
+	},
+	AM: []string{
+		"AM",
+	},
+	PM: []string{
+		"PM",
+	},
+	TZPrefix: "GMT",
+	Ordinal:  suffixOrdinal("º"),
+}
+
+var japanese = Language{
+	Days: [...][]string{
+		time.Sunday: {
+			"日曜日",
+			"日曜",
+			"日",
+		},
+		time.Monday: {
+			"月曜日",
+			"月曜",
+			"月",
+		},
+		time.Tuesday: {
+			"火曜日",
+			"火曜",
+			"火",
+		},
+		time.Wednesday: {
+			"水曜日",
+			"水曜",
+			"水",
+		},
+		time.Thursday: {
+			"木曜日",
+			"木曜",
+			"木",
+		},
+		time.Friday: {
+			"金曜日",
+			"金曜",
+			"金",
+		},
+		time.Saturday: {
+			"土曜日",
+			"土曜",
+			"土",
+		},
+	},
+	Months: [...][]string{
+		time.January: {
+			"1月",
+		},
+		time.February: {
+			"2月",
+		},
+		time.March: {
+			"3月",
+		},
+		time.April: {
+			"4月",
+		},
+		time.May: {
+			"5月",
+		},
+		time.June: {
+			"6月",
+		},
+		time.July: {
+			"7月",
+		},
+		time.August: {
+			"8月",
+		},
+		time.September: {
+			"9月",
+		},
+		time.October: {
+			"10月",
+		},
+		time.November: {
+			"11月",
+		},
+		time.December: {
+			"12月",
+		},
+	},
+	AM: []string{
+		"午前",
+	},
+	PM: []string{
+		"午後",
+	},
+	TZPrefix: "GMT",
+}
+
+var korean = Language{
+	Days: [...][]string{
+		time.Sunday: {
+			"일요일",
+			"일",
+		},
+		time.Monday: {
+			"월요일",
+			"월",
+		},
+		time.Tuesday: {
+			"화요일",
+			"화",
+		},
+		time.Wednesday: {
+			"수요일",
+			"수",
+		},
+		time.Thursday: {
+			"목요일",
+			"목",
+		},
+		time.Friday: {
+			"금요일",
+			"금",
+		},
+		time.Saturday: {
+			"토요일",
+			"토",
+		},
+	},
+	Months: [...][]string{
+		time.January: {
+			"1월",
+		},
+		time.February: {
+			"2월",
+		},
+		time.March: {
+			"3월",
+		},
+		time.April: {
+			"4월",
+		},
+		time.May: {
+			"5월",
+		},
+		time.June: {
+			"6월",
+		},
+		time.July: {
+			"7월",
+		},
+		time.August: {
+			"8월",
+		},
+		time.September: {
+			"9월",
+		},
+		time.October: {
+			"10월",
+		},
+		time.November: {
+			"11월",
+		},
+		time.December: {
+			"12월",
+		},
+	},
+	AM: []string{
+		"오전",
+	},
+	PM: []string{
+		"오후",
+	},
+	TZPrefix: "GMT",
+}
+
+var dutch = Language{
+	Days: [...][]string{
+		time.Sunday: {
+			"zondag",
+			"zo",
+		},
+		time.Monday: {
+			"maandag",
+			"ma",
+		},
+		time.Tuesday: {
+			"dinsdag",
+			"di",
+		},
+		time.Wednesday: {
+			"woensdag",
+			"wo",
+		},
+		time.Thursday: {
+			"donderdag",
+			"do",
+		},
+		time.Friday: {
+			"vrijdag",
+			"vr",
+		},
+		time.Saturday: {
+			"zaterdag",
+			"za",
+		},
+	},
+	Months: [...][]string{
+		time.January: {
+			"januari",
+			"jan",
+		},
+		time.February: {
+			"februari",
+			"feb",
+		},
+		time.March: {
+			"maart",
+			"mrt",
+		},
+		time.April: {
+			"april",
+			"apr",
+		},
+		time.May: {
+			"mei",
+		},
+		time.June: {
+			"juni",
+			"jun",
+		},
+		time.July: {
+			"juli",
+			"jul",
+		},
+		time.August: {
+			"augustus",
+			"aug",
+		},
+		time.September: {
+			"september",
+			"sep",
+		},
+		time.October: {
+			"oktober",
+			"okt",
+		},
+		time.November: {
+			"november",
+			"nov",
+		},
+		time.December: {
+			"december",
+			"dec",
+		},
+	},
+	AM: []string{
+		"a.m.",
+	},
+	PM: []string{
+		"p.m.",
+	},
+	TZPrefix: "GMT",
+	Ordinal:  suffixOrdinal("e"),
+}
+
+var polish = Language{
+	Days: [...][]string{
+		time.Sunday: {
+			"niedziela",
+			"niedz",
+		},
+		time.Monday: {
+			"poniedziałek",
+			"pon",
+		},
+		time.Tuesday: {
+			"wtorek",
+			"wt",
+		},
+		time.Wednesday: {
+			"środa",
+			"śr",
+		},
+		time.Thursday: {
+			"czwartek",
+			"czw",
+		},
+		time.Friday: {
+			"piątek",
+			"pt",
+		},
+		time.Saturday: {
+			"sobota",
+			"sob",
+		},
+	},
+	Months: [...][]string{
+		time.January: {
+			"stycznia",
+			"sty",
+		},
+		time.February: {
+			"lutego",
+			"lut",
+		},
+		time.March: {
+			"marca",
+			"mar",
+		},
+		time.April: {
+			"kwietnia",
+			"kwi",
+		},
+		time.May: {
+			"maja",
+			"maj",
+		},
+		time.June: {
+			"czerwca",
+			"cze",
+		},
+		time.July: {
+			"lipca",
+			"lip",
+		},
+		time.August: {
+			"sierpnia",
+			"sie",
+		},
+		time.September: {
+			"września",
+			"wrz",
+		},
+		time.October: {
+			"października",
+			"paź",
+		},
+		time.November: {
+			"listopada",
+			"lis",
+		},
+		time.December: {
+			"grudnia",
+			"gru",
+		},
+	},
+	StandaloneMonths: [...][]string{
+		time.January: {
+			"styczeń",
+		},
+		time.February: {
+			"luty",
+		},
+		time.March: {
+			"marzec",
+		},
+		time.April: {
+			"kwiecień",
+		},
+		time.May: {
+			"maj",
+		},
+		time.June: {
+			"czerwiec",
+		},
+		time.July: {
+			"lipiec",
+		},
+		time.August: {
+			"sierpień",
+		},
+		time.September: {
+			"wrzesień",
+		},
+		time.October: {
+			"październik",
+		},
+		time.November: {
+			"listopad",
+		},
+		time.December: {
+			"grudzień",
+		},
+	},
+	AM: []string{
+		"AM",
+	},
+	PM: []string{
+		"PM",
+	},
+	TZPrefix: "GMT",
+	Ordinal:  suffixOrdinal("."),
+}
+
+var portuguese = Language{
+	Days: [...][]string{
+		time.Sunday: {
+			"domingo",
+			"dom",
+		},
+		time.Monday: {
+			"segunda-feira",
+			"seg",
+		},
+		time.Tuesday: {
+			"terça-feira",
+			"ter",
+		},
+		time.Wednesday: {
+			"quarta-feira",
+			"qua",
+		},
+		time.Thursday: {
+			"quinta-feira",
+			"qui",
+		},
+		time.Friday: {
+			"sexta-feira",
+			"sex",
+		},
+		time.Saturday: {
+			"sábado",
+			"sáb",
+		},
+	},
+	Months: [...][]string{
+		time.January: {
+			"janeiro",
+			"jan",
+		},
+		time.February: {
+			"fevereiro",
+			"fev",
+		},
+		time.March: {
+			"março",
+			"mar",
+		},
+		time.April: {
+			"abril",
+			"abr",
+		},
+		time.May: {
+			"maio",
+			"mai",
+		},
+		time.June: {
+			"junho",
+			"jun",
+		},
+		time.July: {
+			"julho",
+			"jul",
+		},
+		time.August: {
+			"agosto",
+			"ago",
+		},
+		time.September: {
+			"setembro",
+			"set",
+		},
+		time.October: {
+			"outubro",
+			"out",
+		},
+		time.November: {
+			"novembro",
+			"nov",
+		},
+		time.December: {
+			"dezembro",
+			"dez",
+		},
+	},
+	AM: []string{
+		"AM",
+	},
+	PM: []string{
+		"PM",
+	},
+	TZPrefix: "GMT",
+	Ordinal:  suffixOrdinal("º"),
+}
+
+var russian = Language{
+	Days: [...][]string{
+		time.Sunday: {
+			"воскресенье",
+			"вс",
+		},
+		time.Monday: {
+			"понедельник",
+			"пн",
+		},
+		time.Tuesday: {
+			"вторник",
+			"вт",
+		},
+		time.Wednesday: {
+			"среда",
+			"ср",
+		},
+		time.Thursday: {
+			"четверг",
+			"чт",
+		},
+		time.Friday: {
+			"пятница",
+			"пт",
+		},
+		time.Saturday: {
+			"суббота",
+			"сб",
+		},
+	},
+	Months: [...][]string{
+		time.January: {
+			"января",
+			"янв",
+		},
+		time.February: {
+			"февраля",
+			"фев",
+		},
+		time.March: {
+			"марта",
+			"мар",
+		},
+		time.April: {
+			"апреля",
+			"апр",
+		},
+		time.May: {
+			"мая",
+		},
+		time.June: {
+			"июня",
+			"июн",
+		},
+		time.July: {
+			"июля",
+			"июл",
+		},
+		time.August: {
+			"августа",
+			"авг",
+		},
+		time.September: {
+			"сентября",
+			"сент",
+			"сен",
+		},
+		time.October: {
+			"октября",
+			"окт",
+		},
+		time.November: {
+			"ноября",
+			"нояб",
+			"ноя",
+		},
+		time.December: {
+			"декабря",
+			"дек",
+		},
+	},
+	StandaloneMonths: [...][]string{
+		time.January: {
+			"январь",
+		},
+		time.February: {
+			"февраль",
+		},
+		time.March: {
+			"март",
+		},
+		time.April: {
+			"апрель",
+		},
+		time.May: {
+			"май",
+		},
+		time.June: {
+			"июнь",
+		},
+		time.July: {
+			"июль",
+		},
+		time.August: {
+			"август",
+		},
+		time.September: {
+			"сентябрь",
+		},
+		time.October: {
+			"октябрь",
+		},
+		time.November: {
+			"ноябрь",
+		},
+		time.December: {
+			"декабрь",
+		},
+	},
+	AM: []string{
+		"AM",
+	},
+	PM: []string{
+		"PM",
+	},
+	TZPrefix: "GMT",
+	Ordinal:  suffixOrdinal("-е"),
+}
+
+var swedish = Language{
+	Days: [...][]string{
+		time.Sunday: {
+			"söndag",
+			"sön",
+		},
+		time.Monday: {
+			"måndag",
+			"mån",
+		},
+		time.Tuesday: {
+			"tisdag",
+			"tis",
+		},
+		time.Wednesday: {
+			"onsdag",
+			"ons",
+		},
+		time.Thursday: {
+			"torsdag",
+			"tors",
+			"tor",
+		},
+		time.Friday: {
+			"fredag",
+			"fre",
+		},
+		time.Saturday: {
+			"lördag",
+			"lör",
+		},
+	},
+	Months: [...][]string{
+		time.January: {
+			"januari",
+			"jan",
+		},
+		time.February: {
+			"februari",
+			"feb",
+		},
+		time.March: {
+			"mars",
+			"mar",
+		},
+		time.April: {
+			"april",
+			"apr",
+		},
+		time.May: {
+			"maj",
+		},
+		time.June: {
+			"juni",
+			"jun",
+		},
+		time.July: {
+			"juli",
+			"jul",
+		},
+		time.August: {
+			"augusti",
+			"aug",
+		},
+		time.September: {
+			"september",
+			"sep",
+		},
+		time.October: {
+			"oktober",
+			"okt",
+		},
+		time.November: {
+			"november",
+			"nov",
+		},
+		time.December: {
+			"december",
+			"dec",
+		},
+	},
+	AM: []string{
+		"fm",
+	},
+	PM: []string{
+		"em",
+	},
+	TZPrefix: "GMT",
+	Ordinal:  swedishOrdinal,
+}
+
+var chinese = Language{
+	Days: [...][]string{
+		time.Sunday: {
+			"星期日",
+			"周日",
+		},
+		time.Monday: {
+			"星期一",
+			"周一",
+		},
+		time.Tuesday: {
+			"星期二",
+			"周二",
+		},
+		time.Wednesday: {
+			"星期三",
+			"周三",
+		},
+		time.Thursday: {
+			"星期四",
+			"周四",
+		},
+		time.Friday: {
+			"星期五",
+			"周五",
+		},
+		time.Saturday: {
+			"星期六",
+			"周六",
+		},
+	},
+	Months: [...][]string{
+		time.January: {
+			"一月",
+		},
+		time.February: {
+			"二月",
+		},
+		time.March: {
+			"三月",
+		},
+		time.April: {
+			"四月",
+		},
+		time.May: {
+			"五月",
+		},
+		time.June: {
+			"六月",
+		},
+		time.July: {
+			"七月",
+		},
+		time.August: {
+			"八月",
+		},
+		time.September: {
+			"九月",
+		},
+		time.October: {
+			"十月",
+		},
+		time.November: {
+			"十一月",
+		},
+		time.December: {
+			"十二月",
+		},
+	},
+	AM: []string{
+		"上午",
+	},
+	PM: []string{
+		"下午",
+	},
+	TZPrefix: "GMT",
+}
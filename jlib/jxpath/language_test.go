@@ -0,0 +1,217 @@
+// Copyright 2018 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package jxpath
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFormatTimeLanguage(t *testing.T) {
+
+	tm := time.Date(2018, time.October, 4, 15, 5, 0, 0, time.UTC)
+
+	data := []struct {
+		Picture  string
+		Language string
+		Output   string
+		Error    bool
+	}{
+		{
+			Picture: "[FNn], [D1o] [MNn] [Y]",
+			Output:  "Thursday, 4th October 2018",
+		},
+		{
+			Picture:  "[FNn], [D]. [MNn] [Y]",
+			Language: "de",
+			Output:   "Donnerstag, 4. Oktober 2018",
+		},
+		{
+			Picture:  "[FNn,*-2] [D] [MNn,*-3]",
+			Language: "de-AT",
+			Output:   "Do 4 Okt",
+		},
+		{
+			Picture:  "[Fn] [D] [Mn] [Y]",
+			Language: "fr",
+			Output:   "jeudi 4 octobre 2018",
+		},
+		{
+			Picture:  "[FNn] [D] [MNn] [Y]",
+			Language: "pt_BR",
+			Output:   "Quinta-feira 4 Outubro 2018",
+		},
+		{
+			Picture:  "[D1o] [MNn]",
+			Language: "de",
+			Output:   "4. Oktober",
+		},
+		{
+			Picture:  "[D1o] [MNn]",
+			Language: "fr",
+			Output:   "4e Octobre",
+		},
+		{
+			Picture:  "[D1o] [MNn]",
+			Language: "sv",
+			Output:   "4:e Oktober",
+		},
+		{
+			// Unsupported formats fall back to decimal
+			// numbers, keeping the ordinal modifier.
+			Picture:  "[Dwo] [MNn]",
+			Language: "de",
+			Output:   "4. Oktober",
+		},
+		{
+			Picture: "[Dwo] [MNn]",
+			Output:  "4th October",
+		},
+		{
+			Picture:  "[D1o] [MNn]",
+			Language: "ja",
+			Error:    true,
+		},
+		{
+			Picture:  "[MNn]",
+			Language: "xx",
+			Error:    true,
+		},
+	}
+
+	for _, test := range data {
+
+		output, err := FormatTimeLanguage(tm, test.Picture, test.Language)
+
+		if test.Error {
+			if err == nil {
+				t.Errorf("%s (%s): Expected error, got nil", test.Picture, test.Language)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%s (%s): Expected nil error, got %s", test.Picture, test.Language, err)
+			continue
+		}
+
+		if output != test.Output {
+			t.Errorf("%s (%s): Expected %q, got %q", test.Picture, test.Language, test.Output, output)
+		}
+	}
+}
+
+func TestLookupLanguage(t *testing.T) {
+
+	data := []struct {
+		Tag      string
+		Expected *Language
+	}{
+		{
+			Tag:      "en",
+			Expected: &english,
+		},
+		{
+			Tag:      "DE",
+			Expected: &german,
+		},
+		{
+			Tag:      "de-AT",
+			Expected: &german,
+		},
+		{
+			Tag:      "fr_CA",
+			Expected: &french,
+		},
+		{
+			Tag: "",
+		},
+		{
+			Tag: "xx",
+		},
+		{
+			Tag: "xx-DE",
+		},
+	}
+
+	for _, test := range data {
+
+		lang, ok := LookupLanguage(test.Tag)
+
+		if test.Expected == nil {
+			if ok {
+				t.Errorf("%q: Expected lookup to fail", test.Tag)
+			}
+			continue
+		}
+
+		if !ok {
+			t.Errorf("%q: Expected lookup to succeed", test.Tag)
+			continue
+		}
+
+		if lang.Months[time.March][0] != test.Expected.Months[time.March][0] {
+			t.Errorf("%q: Expected %q, got %q", test.Tag, test.Expected.Months[time.March][0], lang.Months[time.March][0])
+		}
+	}
+}
+
+func TestRegisterLanguage(t *testing.T) {
+
+	lang := english
+	lang.Months[time.March] = []string{"Marchember", "Mar"}
+
+	if err := RegisterLanguage("en-x-test", lang); err != nil {
+		t.Fatalf("RegisterLanguage: Expected nil error, got %s", err)
+	}
+
+	output, err := FormatTimeLanguage(time.Date(2018, time.March, 1, 0, 0, 0, 0, time.UTC), "[MNn]", "en-X-Test")
+	if err != nil {
+		t.Fatalf("FormatTimeLanguage: Expected nil error, got %s", err)
+	}
+
+	if exp := "Marchember"; output != exp {
+		t.Errorf("FormatTimeLanguage: Expected %q, got %q", exp, output)
+	}
+
+	// Registering a region must not affect the
+	// primary language.
+	output, err = FormatTimeLanguage(time.Date(2018, time.March, 1, 0, 0, 0, 0, time.UTC), "[MNn]", "en")
+	if err != nil {
+		t.Fatalf("FormatTimeLanguage: Expected nil error, got %s", err)
+	}
+
+	if exp := "March"; output != exp {
+		t.Errorf("FormatTimeLanguage: Expected %q, got %q", exp, output)
+	}
+
+	// The registry has its own copy of the language.
+	lang.Months[time.March][0] = "Marchuary"
+
+	registered, ok := LookupLanguage("en-x-test")
+	if !ok {
+		t.Fatalf("LookupLanguage: Expected lookup to succeed")
+	}
+
+	if exp := "Marchember"; registered.Months[time.March][0] != exp {
+		t.Errorf("LookupLanguage: Expected %q, got %q", exp, registered.Months[time.March][0])
+	}
+
+	registered.Months[time.March][0] = "Marchuary"
+
+	if again, _ := LookupLanguage("en-x-test"); again.Months[time.March][0] != "Marchember" {
+		t.Errorf("LookupLanguage: Expected a copy, got %q", again.Months[time.March][0])
+	}
+
+	lang.AM = nil
+
+	if err := RegisterLanguage("en-x-invalid", lang); err == nil {
+		t.Errorf("RegisterLanguage: Expected error, got nil")
+	}
+
+	if _, ok := LookupLanguage("en-x-invalid"); !ok {
+		t.Errorf("LookupLanguage: Expected fallback to en")
+	}
+}
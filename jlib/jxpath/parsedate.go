@@ -0,0 +1,629 @@
+// Copyright 2018 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package jxpath
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+)
+
+// maxParseDigits is the maximum number of digits in a
+// numeric date component.
+const maxParseDigits = 9
+
+// A pictureItem is a piece of literal text or a variable
+// marker from a picture string.
+type pictureItem struct {
+	literal   string
+	isMarker  bool
+	component dateComponent
+	marker    variableMarker
+}
+
+// A timeParser holds the state of a call to ParseTime.
+type timeParser struct {
+	s    string
+	pos  int
+	lang *Language
+	loc  *time.Location
+
+	year, month, day, dayOfYear int
+	hour, minute, second, nanos int
+	offset                      int
+	pm                          bool
+
+	// found records which components have been parsed.
+	found map[dateComponent]bool
+}
+
+// ParseTime converts a string to a time, using a picture string
+// to interpret it. The picture string has the same syntax as the
+// one passed to FormatTime, and names of days, months etc. are
+// matched (case insensitively) against the given language. An
+// empty language tag selects English.
+//
+// Components that are missing from the picture string default
+// to the earliest valid value, so that, for example, a picture
+// with no date components returns a time on the 1st of January
+// in year 0. If the picture string has no timezone, the time is
+// in UTC.
+func ParseTime(s string, picture string, language string) (time.Time, error) {
+	return ParseTimeIn(s, picture, language, time.UTC)
+}
+
+// ParseTimeIn is like ParseTime but, if the picture string has
+// no timezone, the time is interpreted as a wall clock time in
+// the given location. Wall clock times that are skipped by a
+// daylight saving transition are moved forward by the length
+// of the transition (e.g. 02:30 becomes 03:30), and wall clock
+// times that occur twice resolve to the earlier of the two.
+func ParseTimeIn(s string, picture string, language string, loc *time.Location) (time.Time, error) {
+
+	lang, err := lookupLanguage(language)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	items, err := parsePictureItems(picture)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	p := &timeParser{
+		s:     s,
+		lang:  lang,
+		loc:   loc,
+		month: 1,
+		day:   1,
+		found: map[dateComponent]bool{},
+	}
+
+	for i, item := range items {
+
+		var next *pictureItem
+		if i+1 < len(items) {
+			next = &items[i+1]
+		}
+
+		if !item.isMarker {
+			if !strings.HasPrefix(p.s[p.pos:], item.literal) {
+				return time.Time{}, p.errorf("expected %q", item.literal)
+			}
+			p.pos += len(item.literal)
+			continue
+		}
+
+		if err := p.parseComponent(&item, next); err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	if p.pos < len(p.s) {
+		return time.Time{}, p.errorf("unexpected text %q", p.s[p.pos:])
+	}
+
+	return p.time()
+}
+
+// parsePictureItems splits a picture string into literal text
+// and parsed variable markers.
+func parsePictureItems(picture string) ([]pictureItem, error) {
+
+	var items []pictureItem
+
+	err := scanPicture(picture, func(literal string) {
+		if literal != "" {
+			items = append(items, pictureItem{
+				literal: literal,
+			})
+		}
+	}, func(s string) error {
+
+		component, marker, err := parseVariableMarker(s)
+		if err != nil {
+			return err
+		}
+
+		if _, ok := defaultDateFormats[component]; !ok {
+			return fmt.Errorf("unknown component specifier %c", component)
+		}
+
+		if marker.format == "" {
+			marker.modifier = 0
+			marker.format = defaultDateFormats[component]
+		}
+
+		items = append(items, pictureItem{
+			isMarker:  true,
+			component: component,
+			marker:    marker,
+		})
+		return nil
+	})
+
+	return items, err
+}
+
+func (p *timeParser) parseComponent(item *pictureItem, next *pictureItem) error {
+
+	marker := &item.marker
+	component := item.component
+
+	// Fall back to the default format for unsupported
+	// formats, as FormatTime does.
+	if !isDecimalFormat(marker.format) && !(isNameFormat(marker.format) && hasNames(component)) &&
+		component != dateTZ && component != dateTZPrefixed {
+		if marker.modifier != modOrdinal {
+			marker.modifier = 0
+		}
+		marker.format = defaultDateFormats[component]
+	}
+
+	var err error
+
+	switch {
+	case component == dateTZ || component == dateTZPrefixed:
+		p.offset, err = p.parseTimezone(marker, component == dateTZPrefixed)
+
+	case component == dateNanosecond:
+		p.nanos, err = p.parseFraction(p.maxDigits(marker, next))
+
+	case isNameFormat(marker.format):
+		err = p.parseNameComponent(component, marker)
+
+	default:
+		err = p.parseNumericComponent(component, marker, next)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	p.found[component] = true
+	return nil
+}
+
+// hasNames returns true if a component can be expressed as
+// a name.
+func hasNames(component dateComponent) bool {
+	switch component {
+	case dateMonth, dateDayOfWeek, dateAMPM, dateCalendar, dateEra:
+		return true
+	default:
+		return false
+	}
+}
+
+func (p *timeParser) parseNameComponent(component dateComponent, marker *variableMarker) error {
+
+	var names [][]string
+
+	switch component {
+	case dateMonth:
+		names = make([][]string, 12)
+		for i := range names {
+			month := i + 1
+			names[i] = append(names[i], p.lang.Months[month]...)
+			names[i] = append(names[i], p.lang.StandaloneMonths[month]...)
+		}
+	case dateDayOfWeek:
+		names = p.lang.Days[:]
+	case dateAMPM:
+		names = [][]string{p.lang.AM, p.lang.PM}
+	case dateCalendar:
+		names = [][]string{calendars}
+	case dateEra:
+		names = [][]string{eras}
+	}
+
+	n, err := p.parseName(names, marker.minWidth, marker.maxWidth)
+	if err != nil {
+		return err
+	}
+
+	switch component {
+	case dateMonth:
+		p.month = n + 1
+	case dateAMPM:
+		p.pm = n == 1
+	}
+
+	return nil
+}
+
+// parseName matches the longest name from the given lists and
+// returns the index of the list that contains it. If maxWidth
+// is set, truncated full names are also matched (see
+// bestFittingString). If minWidth is set, any padding added by
+// FormatTime is skipped.
+func (p *timeParser) parseName(names [][]string, minWidth, maxWidth int) (int, error) {
+
+	index, length, ambiguous := -1, 0, false
+	s := p.s[p.pos:]
+
+	for i, list := range names {
+
+		candidates := list
+		if maxWidth > 0 && len(list) > 0 {
+			candidates = append([]string{bestFittingString(list, maxWidth)}, list...)
+		}
+
+		for _, name := range candidates {
+
+			n, ok := hasPrefixFold(s, name)
+			if !ok || name == "" {
+				continue
+			}
+
+			switch {
+			case n > length:
+				index, length, ambiguous = i, n, false
+			case n == length && i != index:
+				ambiguous = true
+			}
+		}
+	}
+
+	if index < 0 {
+		return 0, p.errorf("expected a name")
+	}
+
+	if ambiguous {
+		return 0, p.errorf("ambiguous name %q", s[:length])
+	}
+
+	p.pos += length
+
+	for padding := minWidth - utf8.RuneCountInString(s[:length]); padding > 0 && p.pos < len(p.s) && p.s[p.pos] == ' '; padding-- {
+		p.pos++
+	}
+
+	return index, nil
+}
+
+func (p *timeParser) parseNumericComponent(component dateComponent, marker *variableMarker, next *pictureItem) error {
+
+	start := p.pos
+
+	n, err := p.parseNumber(p.maxDigits(marker, next))
+	if err != nil {
+		return err
+	}
+
+	digits := p.pos - start
+
+	if marker.modifier == modOrdinal {
+		if p.lang.Ordinal == nil {
+			return errNoOrdinals
+		}
+		// The suffix is optional.
+		if size, ok := hasPrefixFold(p.s[p.pos:], p.lang.Ordinal(n)); ok {
+			p.pos += size
+		}
+	}
+
+	switch component {
+	case dateYear:
+		p.year = n
+		if digits <= 2 && (marker.maxWidth == 2 || countDigits(marker.format) == 2) {
+			// Interpret two digit years in the same way as
+			// the time package.
+			if n >= 69 {
+				p.year += 1900
+			} else {
+				p.year += 2000
+			}
+		}
+	case dateMonth:
+		p.month = n
+	case dateDay:
+		p.day = n
+	case dateDayOfYear:
+		p.dayOfYear = n
+	case dateHour24, dateHour12:
+		p.hour = n
+	case dateMinute:
+		p.minute = n
+	case dateSecond:
+		p.second = n
+
+		// Allow fractional seconds after the seconds, unless
+		// the picture string specifies them separately.
+		separate := next != nil &&
+			(next.isMarker && next.component == dateNanosecond ||
+				!next.isMarker && strings.HasPrefix(p.s[p.pos:], next.literal))
+
+		if !separate {
+			if p.pos+1 < len(p.s) && (p.s[p.pos] == '.' || p.s[p.pos] == ',') && isDigit(p.s[p.pos+1]) {
+				p.pos++
+				if p.nanos, err = p.parseFraction(0); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// maxDigits returns the maximum number of digits to read for
+// a numeric component. If the component is immediately followed
+// by another component (e.g. "[H01][m01]"), the width is taken
+// from the picture string. Otherwise, all digits are read.
+func (p *timeParser) maxDigits(marker *variableMarker, next *pictureItem) int {
+
+	if marker.maxWidth > 0 {
+		return marker.maxWidth
+	}
+
+	if next != nil && next.isMarker {
+		if n := countDigits(marker.format); n > 0 {
+			return n
+		}
+	}
+
+	return 0
+}
+
+func (p *timeParser) parseNumber(maxDigits int) (int, error) {
+
+	start := p.pos
+	n := 0
+
+	for p.pos < len(p.s) && isDigit(p.s[p.pos]) {
+
+		if maxDigits > 0 && p.pos-start == maxDigits {
+			break
+		}
+
+		if p.pos-start == maxParseDigits {
+			return 0, p.errorf("number is too long")
+		}
+
+		n = n*10 + int(p.s[p.pos]-'0')
+		p.pos++
+	}
+
+	if p.pos == start {
+		return 0, p.errorf("expected a number")
+	}
+
+	return n, nil
+}
+
+// parseFraction reads the digits after a decimal point and
+// returns them as a number of nanoseconds. Digits beyond the
+// ninth are ignored.
+func (p *timeParser) parseFraction(maxDigits int) (int, error) {
+
+	start := p.pos
+	n, scale := 0, int(time.Second)
+
+	for p.pos < len(p.s) && isDigit(p.s[p.pos]) {
+
+		if maxDigits > 0 && p.pos-start == maxDigits {
+			break
+		}
+
+		if scale > 1 {
+			scale /= 10
+			n += int(p.s[p.pos]-'0') * scale
+		}
+		p.pos++
+	}
+
+	if p.pos == start {
+		return 0, p.errorf("expected a number")
+	}
+
+	return n, nil
+}
+
+// parseTimezone parses a timezone and returns its offset from
+// UTC in seconds. It accepts "Z", "UTC" and "GMT" as well as
+// numeric offsets such as "+01", "-0800" and "+05:30", and
+// timezone abbreviations for the [ZN] component. For the [z]
+// component, the language's prefix (e.g. "GMT") may precede
+// the offset.
+func (p *timeParser) parseTimezone(marker *variableMarker, prefixed bool) (int, error) {
+
+	s := p.s[p.pos:]
+
+	if prefixed {
+		if n, ok := hasPrefixFold(s, p.lang.TZPrefix); ok && p.lang.TZPrefix != "" {
+			p.pos += n
+			s = p.s[p.pos:]
+		}
+	}
+
+	for _, name := range []string{"Z", "UTC", "GMT"} {
+		if strings.HasPrefix(s, name) {
+			p.pos += len(name)
+			return 0, nil
+		}
+	}
+
+	// Timezone abbreviations are ambiguous (e.g. "IST" is
+	// used in India, Ireland and Israel). Like the time
+	// package, accept them but treat them as UTC.
+	if isNameFormat(marker.format) {
+		n := 0
+		for n < len(s) && s[n] >= 'A' && s[n] <= 'Z' {
+			n++
+		}
+		if n >= 3 && n <= 5 {
+			p.pos += n
+			return 0, nil
+		}
+	}
+
+	if s == "" || (s[0] != '+' && s[0] != '-') {
+		return 0, p.errorf("expected a timezone")
+	}
+
+	sign := 1
+	if s[0] == '-' {
+		sign = -1
+	}
+	p.pos++
+
+	start := p.pos
+	n, err := p.parseNumber(4)
+	if err != nil {
+		return 0, err
+	}
+
+	var hours, minutes int
+
+	switch p.pos - start {
+	case 1, 2:
+		hours = n
+		if p.pos+1 < len(p.s) && (p.s[p.pos] == ':' || p.s[p.pos] == '.') && isDigit(p.s[p.pos+1]) {
+			p.pos++
+			if minutes, err = p.parseNumber(2); err != nil {
+				return 0, err
+			}
+		}
+	default:
+		hours, minutes = n/100, n%100
+	}
+
+	if hours > 23 || minutes > 59 {
+		return 0, p.errorf("timezone offset out of range")
+	}
+
+	return sign * (hours*secondsPerHour + minutes*secondsPerMinute), nil
+}
+
+// time returns the time represented by the parsed components.
+func (p *timeParser) time() (time.Time, error) {
+
+	if p.found[dateDayOfYear] && !p.found[dateMonth] && !p.found[dateDay] {
+		if p.dayOfYear < 1 || p.dayOfYear > daysInYear(p.year) {
+			return time.Time{}, fmt.Errorf("day of year out of range")
+		}
+		p.day = p.dayOfYear
+	}
+
+	if p.found[dateHour12] && !p.found[dateHour24] {
+		if p.hour > 12 {
+			return time.Time{}, fmt.Errorf("hour out of range")
+		}
+		p.hour %= 12
+		if p.pm {
+			p.hour += 12
+		}
+	}
+
+	switch {
+	case p.month < 1 || p.month > 12:
+		return time.Time{}, fmt.Errorf("month out of range")
+	case p.found[dateDay] && (p.day < 1 || p.day > daysInMonth(time.Month(p.month), p.year)):
+		return time.Time{}, fmt.Errorf("day out of range")
+	case p.hour > 23:
+		return time.Time{}, fmt.Errorf("hour out of range")
+	case p.minute > 59:
+		return time.Time{}, fmt.Errorf("minute out of range")
+	case p.second > 59:
+		return time.Time{}, fmt.Errorf("second out of range")
+	}
+
+	if !p.found[dateTZ] && !p.found[dateTZPrefixed] {
+		return wallTime(p.year, time.Month(p.month), p.day, p.hour, p.minute, p.second, p.nanos, p.loc), nil
+	}
+
+	loc := time.UTC
+	if p.offset != 0 {
+		loc = time.FixedZone("", p.offset)
+	}
+
+	return time.Date(p.year, time.Month(p.month), p.day, p.hour, p.minute, p.second, p.nanos, loc), nil
+}
+
+// wallTime returns the time with the given wall clock time
+// in loc, resolving daylight saving transitions as described
+// in ParseTimeIn. Unlike time.Date, whose choice is not
+// guaranteed, the result is well defined. It assumes that
+// transitions are more than a day apart.
+func wallTime(year int, month time.Month, day, hour, min, sec, nsec int, loc *time.Location) time.Time {
+
+	wall := time.Date(year, month, day, hour, min, sec, nsec, time.UTC)
+	if loc == time.UTC {
+		return wall
+	}
+
+	offsetAt := func(t time.Time) time.Duration {
+		_, offset := t.In(loc).Zone()
+		return time.Duration(offset) * time.Second
+	}
+
+	// The offsets in effect a day either side of the wall
+	// clock time. Unless there is a transition, they are
+	// the same.
+	before := offsetAt(wall.Add(-24 * time.Hour))
+	after := offsetAt(wall.Add(24 * time.Hour))
+
+	t1 := wall.Add(-before)
+	t2 := wall.Add(-after)
+
+	ok1 := offsetAt(t1) == before
+	ok2 := offsetAt(t2) == after
+
+	switch {
+	case ok1 && ok2 && t2.Before(t1):
+		// The wall clock time occurs twice.
+		return t2.In(loc)
+	case ok1:
+		return t1.In(loc)
+	case ok2:
+		return t2.In(loc)
+	default:
+		// The wall clock time is skipped. Interpreting it
+		// with the earlier offset moves it forward.
+		return t1.In(loc)
+	}
+}
+
+func (p *timeParser) errorf(format string, args ...interface{}) error {
+	return fmt.Errorf("could not parse %q at position %d: %s", p.s, p.pos, fmt.Sprintf(format, args...))
+}
+
+// hasPrefixFold returns true if s begins with prefix, ignoring
+// case. It also returns the length in bytes of the matching
+// part of s, which may differ from the length of prefix.
+func hasPrefixFold(s, prefix string) (int, bool) {
+
+	n := 0
+	for _, r := range prefix {
+
+		if n >= len(s) {
+			return 0, false
+		}
+
+		c, size := utf8.DecodeRuneInString(s[n:])
+		if c != r && unicode.ToLower(c) != unicode.ToLower(r) {
+			return 0, false
+		}
+
+		n += size
+	}
+
+	return n, true
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func daysInMonth(month time.Month, year int) int {
+	return time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+}
+
+func daysInYear(year int) int {
+	return time.Date(year, time.December, 31, 0, 0, 0, 0, time.UTC).YearDay()
+}
@@ -0,0 +1,338 @@
+// Copyright 2018 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package jxpath
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseTime(t *testing.T) {
+
+	data := []struct {
+		Input    string
+		Picture  string
+		Language string
+		Output   time.Time
+		Error    bool
+	}{
+		{
+			Input:   "2017-10-30T16:25:32.935Z",
+			Picture: "[Y]-[M01]-[D01]T[H01]:[m]:[s][Z01:01t]",
+			Output:  time.Date(2017, time.October, 30, 16, 25, 32, 935000000, time.UTC),
+		},
+		{
+			Input:   "2017-10-30T16:25:32-05:30",
+			Picture: "[Y]-[M01]-[D01]T[H01]:[m]:[s][Z01:01t]",
+			Output:  time.Date(2017, time.October, 30, 21, 55, 32, 0, time.UTC),
+		},
+		{
+			Input:   "20171030162532",
+			Picture: "[Y0001][M01][D01][H01][m01][s01]",
+			Output:  time.Date(2017, time.October, 30, 16, 25, 32, 0, time.UTC),
+		},
+		{
+			Input:   "16:25:32.5",
+			Picture: "[H01]:[m01]:[s01].[f001]",
+			Output:  time.Date(0, time.January, 1, 16, 25, 32, 500000000, time.UTC),
+		},
+		{
+			Input:   "30th September, 2018",
+			Picture: "[D1o] [MNn], [Y]",
+			Output:  time.Date(2018, time.September, 30, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Input:   "SEP 30 18",
+			Picture: "[MN,*-3] [D] [Y01]",
+			Output:  time.Date(2018, time.September, 30, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Input:   "12:05 am",
+			Picture: "[h]:[m01] [Pn]",
+			Output:  time.Date(0, time.January, 1, 0, 5, 0, 0, time.UTC),
+		},
+		{
+			Input:   "3:58 PM GMT+02:00",
+			Picture: "[h]:[m01] [PN] [z]",
+			Output:  time.Date(0, time.January, 1, 13, 58, 0, 0, time.UTC),
+		},
+		{
+			Input:   "2020-366",
+			Picture: "[Y]-[d]",
+			Output:  time.Date(2020, time.December, 31, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Input:    "Sonntag, 30. September 2018",
+			Picture:  "[FNn], [D]. [MNn] [Y]",
+			Language: "de",
+			Output:   time.Date(2018, time.September, 30, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Input:    "3 mär 2024",
+			Picture:  "[D] [MNn,*-3] [Y]",
+			Language: "de",
+			Output:   time.Date(2024, time.March, 3, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Input:    "1er août 2024",
+			Picture:  "[D1o] [MNn] [Y]",
+			Language: "fr",
+			Output:   time.Date(2024, time.August, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Input:    "quarta-feira, 7 de fevereiro de 2024",
+			Picture:  "[FNn], [D] de [Mn] de [Y]",
+			Language: "pt-BR",
+			Output:   time.Date(2024, time.February, 7, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Input:    "2024年11月5日 午後3時",
+			Picture:  "[Y]年[MNn][D]日 [PN][h]時",
+			Language: "ja",
+			Output:   time.Date(2024, time.November, 5, 15, 0, 0, 0, time.UTC),
+		},
+		{
+			Input:    "2024年1月5日",
+			Picture:  "[Y]年[MNn][D]日",
+			Language: "ja",
+			Output:   time.Date(2024, time.January, 5, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			// Both the genitive and nominative forms
+			// of month names are accepted.
+			Input:    "30 сентября 2018",
+			Picture:  "[D] [MNn] [Y]",
+			Language: "ru",
+			Output:   time.Date(2018, time.September, 30, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Input:    "30 сентябрь 2018",
+			Picture:  "[D] [MNn] [Y]",
+			Language: "ru",
+			Output:   time.Date(2018, time.September, 30, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Input:    "październik 2018",
+			Picture:  "[MNn] [Y]",
+			Language: "pl",
+			Output:   time.Date(2018, time.October, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Input:    "1er mars 2018",
+			Picture:  "[D1o] [MNn] [Y]",
+			Language: "fr",
+			Output:   time.Date(2018, time.March, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Input:    "1. März 2018",
+			Picture:  "[Dwo] [MNn] [Y]",
+			Language: "de",
+			Output:   time.Date(2018, time.March, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			Input:   "March 3, 2024",
+			Picture: "[MNn] [D], [Y]",
+			// English names are not recognised in
+			// other languages.
+			Language: "de",
+			Error:    true,
+		},
+		{
+			// "jui" could be juin or juillet.
+			Input:    "3 jui 2024",
+			Picture:  "[D] [MNn,*-3] [Y]",
+			Language: "fr",
+			Error:    true,
+		},
+		{
+			Input:   "Ma 3 2024",
+			Picture: "[MNn,*-2] [D] [Y]",
+			Error:   true,
+		},
+		{
+			Input:    "30 September 2018",
+			Picture:  "[D] [MNn] [Y]",
+			Language: "xx",
+			Error:    true,
+		},
+		{
+			Input:   "2017-02-30",
+			Picture: "[Y]-[M01]-[D01]",
+			Error:   true,
+		},
+		{
+			Input:   "2017-10-30T25:00",
+			Picture: "[Y]-[M01]-[D01]T[H01]:[m01]",
+			Error:   true,
+		},
+		{
+			Input:   "2017-10-30 extra",
+			Picture: "[Y]-[M01]-[D01]",
+			Error:   true,
+		},
+		{
+			Input:   "2017-10",
+			Picture: "[Y]-[M01]-[D01]",
+			Error:   true,
+		},
+		{
+			Input:   "2017",
+			Picture: "[Y]-[M01",
+			Error:   true,
+		},
+		{
+			Input:   "2017",
+			Picture: "[Q]",
+			Error:   true,
+		},
+	}
+
+	for _, test := range data {
+
+		got, err := ParseTime(test.Input, test.Picture, test.Language)
+
+		switch {
+		case test.Error && err == nil:
+			t.Errorf("%s (%s): expected error, got %s", test.Input, test.Picture, got)
+		case !test.Error && err != nil:
+			t.Errorf("%s (%s): unexpected error: %s", test.Input, test.Picture, err)
+		case !test.Error && !got.Equal(test.Output):
+			t.Errorf("%s (%s): expected %s, got %s", test.Input, test.Picture, test.Output, got)
+		}
+	}
+}
+
+func TestParseTimeIn(t *testing.T) {
+
+	newYork, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatalf("LoadLocation: %s", err)
+	}
+
+	sydney, err := time.LoadLocation("Australia/Sydney")
+	if err != nil {
+		t.Fatalf("LoadLocation: %s", err)
+	}
+
+	data := []struct {
+		Input    string
+		Picture  string
+		Location *time.Location
+		Output   time.Time
+	}{
+		{
+			Input:    "2024-07-01 09:15",
+			Picture:  "[Y]-[M]-[D] [H]:[m]",
+			Location: newYork,
+			Output:   time.Date(2024, time.July, 1, 13, 15, 0, 0, time.UTC),
+		},
+		{
+			// Skipped wall clock times move forward.
+			Input:    "2024-03-10 02:30",
+			Picture:  "[Y]-[M]-[D] [H]:[m]",
+			Location: newYork,
+			Output:   time.Date(2024, time.March, 10, 7, 30, 0, 0, time.UTC),
+		},
+		{
+			// Repeated wall clock times resolve to the
+			// first occurrence.
+			Input:    "2024-11-03 01:30",
+			Picture:  "[Y]-[M]-[D] [H]:[m]",
+			Location: newYork,
+			Output:   time.Date(2024, time.November, 3, 5, 30, 0, 0, time.UTC),
+		},
+		{
+			Input:    "2024-04-07 02:30",
+			Picture:  "[Y]-[M]-[D] [H]:[m]",
+			Location: sydney,
+			Output:   time.Date(2024, time.April, 6, 15, 30, 0, 0, time.UTC),
+		},
+		{
+			Input:    "2024-10-06 02:30",
+			Picture:  "[Y]-[M]-[D] [H]:[m]",
+			Location: sydney,
+			Output:   time.Date(2024, time.October, 5, 16, 30, 0, 0, time.UTC),
+		},
+		{
+			// The location doesn't apply to times with a
+			// timezone.
+			Input:    "2024-07-01 09:15 +01:00",
+			Picture:  "[Y]-[M]-[D] [H]:[m] [Z]",
+			Location: newYork,
+			Output:   time.Date(2024, time.July, 1, 8, 15, 0, 0, time.UTC),
+		},
+	}
+
+	for _, test := range data {
+
+		got, err := ParseTimeIn(test.Input, test.Picture, "", test.Location)
+
+		switch {
+		case err != nil:
+			t.Errorf("%s (%s): unexpected error: %s", test.Input, test.Picture, err)
+		case !got.Equal(test.Output):
+			t.Errorf("%s (%s): expected %s, got %s", test.Input, test.Picture, test.Output, got.UTC())
+		}
+	}
+}
+
+func TestParseTimeRoundTrip(t *testing.T) {
+
+	// Names truncated to a maximum width are not included
+	// because they may be ambiguous.
+	pictures := []string{
+		"[FNn], [D1o] [MNn] [Y] [h]:[m01]:[s01].[f001] [PN] [z]",
+		"[FN] [D01] [Mn] [Y0001] [H01]:[m01] [Z]",
+		"[Fn,12-20] [D] [MNn,12-20] [Y]",
+		"[Y0001][M01][D01]T[H01][m01][s01]",
+	}
+
+	zone := time.FixedZone("", -(3*60+30)*60)
+
+	var times []time.Time
+	for month := time.January; month <= time.December; month++ {
+		times = append(times, time.Date(2024, month, 3*int(month), 2*int(month), 59, 7, 123000000, zone))
+	}
+
+	for tag, lang := range builtinLanguages {
+		for i, picture := range pictures {
+
+			// Not all languages have ordinal numbers.
+			if lang.Ordinal == nil {
+				picture = strings.Replace(picture, "[D1o]", "[D1]", 1)
+			}
+
+			for _, tm := range times {
+
+				s, err := FormatTimeLanguage(tm, picture, tag)
+				if err != nil {
+					t.Errorf("%s: FormatTimeLanguage(%s): %s", tag, picture, err)
+					continue
+				}
+
+				got, err := ParseTime(s, picture, tag)
+				if err != nil {
+					t.Errorf("%s: ParseTime(%q, %s): %s", tag, s, picture, err)
+					continue
+				}
+
+				exp := tm
+				switch i {
+				case 1:
+					exp = tm.Truncate(time.Minute)
+				case 2:
+					exp = time.Date(tm.Year(), tm.Month(), tm.Day(), 0, 0, 0, 0, time.UTC)
+				case 3:
+					exp = time.Date(tm.Year(), tm.Month(), tm.Day(), tm.Hour(), tm.Minute(), tm.Second(), 0, time.UTC)
+				}
+
+				if !got.Equal(exp) {
+					t.Errorf("%s: %s formats as %q, which parses as %s", tag, tm, s, got)
+				}
+			}
+		}
+	}
+}
@@ -5,7 +5,9 @@
 package jlib_test
 
 import (
+	"encoding/json"
 	"fmt"
+	"math/big"
 	"testing"
 
 	"github.com/blues/jsonata-go/jlib"
@@ -141,3 +143,42 @@ func TestRound(t *testing.T) {
 		}
 	}
 }
+
+func TestNumberFromRat(t *testing.T) {
+
+	data := []struct {
+		Value  string
+		Output json.Number
+	}{
+		{
+			Value:  "12345678901234567890",
+			Output: "12345678901234567890",
+		},
+		{
+			Value:  "-1/8",
+			Output: "-0.125",
+		},
+		{
+			Value:  "3/10",
+			Output: "0.3",
+		},
+		{
+			Value:  "1/3",
+			Output: "0.3333333333333333333333333333333333",
+		},
+	}
+
+	for _, test := range data {
+
+		r, ok := new(big.Rat).SetString(test.Value)
+		if !ok {
+			t.Fatalf("invalid value %q", test.Value)
+		}
+
+		got := jlib.NumberFromRat(r)
+
+		if got != test.Output {
+			t.Errorf("NumberFromRat(%s): Expected %s, got %s", test.Value, test.Output, got)
+		}
+	}
+}
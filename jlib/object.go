@@ -32,7 +32,8 @@ func toInterfaceMap(v reflect.Value) (map[string]interface{}, bool) {
 // the object obj and returns the results in an array. The
 // order of the items in the array is undefined.
 //
-// obj must be a map or a struct. If it is a struct, any
+// obj must be a map or a struct. If it is a struct, fields
+// are named and omitted according to their json tags, and
 // unexported fields are ignored.
 //
 // fn must be a Callable that takes one, two or three
@@ -51,11 +52,11 @@ func Each(obj reflect.Value, fn jtypes.Callable) (interface{}, error) {
 	case jtypes.IsStruct(obj) && !jtypes.IsCallable(obj):
 		each = eachStruct
 	default:
-		return nil, fmt.Errorf("argument must be an object")
+		return nil, newError("each", ErrNonObject)
 	}
 
 	if argc := fn.ParamCount(); argc < 1 || argc > 3 {
-		return nil, fmt.Errorf("function must take 1, 2 or 3 arguments")
+		return nil, newError("each", ErrCallbackArity)
 	}
 
 	results, err := each(obj, fn)
@@ -115,28 +116,31 @@ func eachMap(v reflect.Value, fn jtypes.Callable) ([]interface{}, error) {
 
 func eachStruct(v reflect.Value, fn jtypes.Callable) ([]interface{}, error) {
 
-	size := v.NumField()
+	fields := jtypes.StructFields(v.Type())
+
+	size := len(fields)
 	if size == 0 {
 		return nil, nil
 	}
 
 	var results []interface{}
 
-	t := v.Type()
 	argv := make([]reflect.Value, fn.ParamCount())
 
-	for i := 0; i < size; i++ {
+	for i := range fields {
 
-		field := t.Field(i)
-		if field.PkgPath != "" {
-			// Skip unexported fields.
+		field := &fields[i]
+
+		val := field.Value(v)
+		if !val.IsValid() {
+			// Skip omitted fields.
 			continue
 		}
 
 		for j := range argv {
 			switch j {
 			case 0:
-				argv[j] = v.Field(i)
+				argv[j] = val
 			case 1:
 				argv[j] = reflect.ValueOf(field.Name)
 			case 2:
@@ -164,7 +168,8 @@ func eachStruct(v reflect.Value, fn jtypes.Callable) ([]interface{}, error) {
 // object obj that satisfy the predicate function fn.
 //
 // obj must be a map or a struct. If it is a map, the keys
-// must be of type string. If it is a struct, any unexported
+// must be of type string. If it is a struct, fields are named
+// and omitted according to their json tags, and unexported
 // fields are ignored.
 //
 // fn must be a Callable that takes one, two or three
@@ -183,11 +188,11 @@ func Sift(obj reflect.Value, fn jtypes.Callable) (interface{}, error) {
 	case jtypes.IsStruct(obj) && !jtypes.IsCallable(obj):
 		sift = siftStruct
 	default:
-		return nil, fmt.Errorf("argument must be an object")
+		return nil, newError("sift", ErrNonObject)
 	}
 
 	if argc := fn.ParamCount(); argc < 1 || argc > 3 {
-		return nil, fmt.Errorf("function must take 1, 2 or 3 arguments")
+		return nil, newError("sift", ErrCallbackArity)
 	}
 
 	results, err := sift(obj, fn)
@@ -217,7 +222,7 @@ func siftMap(v reflect.Value, fn jtypes.Callable) (map[string]interface{}, error
 
 		key, ok := jtypes.AsString(k)
 		if !ok {
-			return nil, fmt.Errorf("object key must evaluate to a string, got %v (%s)", k, k.Kind())
+			return nil, newErrorValue("sift", ErrNonStringKey, fmt.Sprintf("%v (%s)", k, k.Kind()))
 		}
 
 		val := v.MapIndex(k)
@@ -257,26 +262,25 @@ func siftMap(v reflect.Value, fn jtypes.Callable) (map[string]interface{}, error
 
 func siftStruct(v reflect.Value, fn jtypes.Callable) (map[string]interface{}, error) {
 
-	size := v.NumField()
+	fields := jtypes.StructFields(v.Type())
+
+	size := len(fields)
 	if size == 0 {
 		return nil, nil
 	}
 
 	var results map[string]interface{}
 
-	t := v.Type()
 	argv := make([]reflect.Value, fn.ParamCount())
 
-	for i := 0; i < size; i++ {
+	for i := range fields {
 
-		key := t.Field(i).Name
-		val := v.Field(i)
+		key := fields[i].Name
+		val := fields[i].Value(v)
 		if !val.IsValid() || !val.CanInterface() {
-			// Skip undefined or non-interfaceable values. We
+			// Skip omitted or non-interfaceable values. We
 			// already know we don't want them in the results,
-			// so we can bypass the function call. This also
-			// filters out unexported fields (as they are
-			// non-interfaceable).
+			// so we can bypass the function call.
 			continue
 		}
 
@@ -311,8 +315,9 @@ func siftStruct(v reflect.Value, fn jtypes.Callable) (map[string]interface{}, er
 // The order of the returned items is undefined.
 //
 // obj must be a map, a struct or an array. If obj is a map,
-// its keys must be of type string. If obj is a struct, any
-// unexported fields are ignored. And if obj is an array,
+// its keys must be of type string. If obj is a struct, field
+// names are taken from json tags and unexported fields are
+// ignored. And if obj is an array,
 // Keys returns the unique set of names from each object
 // in the array.
 func Keys(obj reflect.Value) (interface{}, error) {
@@ -364,7 +369,7 @@ func keysMap(v reflect.Value) ([]string, error) {
 
 		key, ok := jtypes.AsString(k)
 		if !ok {
-			return nil, fmt.Errorf("object key must evaluate to a string, got %v (%s)", k, k.Kind())
+			return nil, newErrorValue("keys", ErrNonStringKey, fmt.Sprintf("%v (%s)", k, k.Kind()))
 		}
 
 		results[i] = key
@@ -385,26 +390,26 @@ func keysMapFast(m map[string]interface{}) []string {
 
 func keysStruct(v reflect.Value) ([]string, error) {
 
-	size := v.NumField()
+	fields := jtypes.StructFields(v.Type())
+
+	size := len(fields)
 	if size == 0 {
 		return nil, nil
 	}
 
 	var results []string
 
-	t := v.Type()
-	for i := 0; i < size; i++ {
+	for i := range fields {
 
-		field := t.Field(i)
-		if field.PkgPath != "" {
-			// Skip unexported fields.
+		if !fields[i].Value(v).IsValid() {
+			// Skip omitted fields.
 			continue
 		}
 
 		if results == nil {
 			results = make([]string, 0, size)
 		}
-		results = append(results, field.Name)
+		results = append(results, fields[i].Name)
 	}
 
 	return results, nil
@@ -457,7 +462,8 @@ func keysArray(v reflect.Value) ([]string, error) {
 // in the array override those from earlier.
 //
 // objs must be an array of maps or structs. Maps must have
-// keys of type string. Unexported struct fields are ignored.
+// keys of type string. Struct fields are named according to
+// their json tags and unexported fields are ignored.
 func Merge(objs reflect.Value) (interface{}, error) {
 
 	var size int
@@ -481,12 +487,12 @@ func Merge(objs reflect.Value) (interface{}, error) {
 			case jtypes.IsStruct(obj):
 				size += obj.NumField()
 			default:
-				return nil, fmt.Errorf("argument must be an object or an array of objects")
+				return nil, newError("merge", ErrNonObjectArray)
 			}
 		}
 		merge = mergeArray
 	default:
-		return nil, fmt.Errorf("argument must be an object or an array of objects")
+		return nil, newError("merge", ErrNonObjectArray)
 	}
 
 	results := make(map[string]interface{}, size)
@@ -508,7 +514,7 @@ func mergeMap(dest map[string]interface{}, src reflect.Value) error {
 
 		key, ok := jtypes.AsString(k)
 		if !ok {
-			return fmt.Errorf("object key must evaluate to a string, got %v (%s)", k, k.Kind())
+			return newErrorValue("merge", ErrNonStringKey, fmt.Sprintf("%v (%s)", k, k.Kind()))
 		}
 
 		if val := src.MapIndex(k); val.IsValid() && val.CanInterface() {
@@ -529,18 +535,11 @@ func mergeMapFast(dest, src map[string]interface{}) {
 
 func mergeStruct(dest map[string]interface{}, src reflect.Value) error {
 
-	t := src.Type()
-
-	for i := 0; i < src.NumField(); i++ {
-
-		field := t.Field(i)
-		if field.PkgPath != "" {
-			// Skip unexported fields.
-			continue
-		}
+	fields := jtypes.StructFields(src.Type())
 
-		if val := src.Field(i); val.IsValid() && val.CanInterface() {
-			dest[field.Name] = val.Interface()
+	for i := range fields {
+		if val := fields[i].Value(src); val.IsValid() && val.CanInterface() {
+			dest[fields[i].Name] = val.Interface()
 		}
 	}
 
@@ -583,7 +582,7 @@ func Spread(v reflect.Value) (interface{}, error) {
 		keys := v.MapKeys()
 		for _, k := range keys {
 			if k.Kind() != reflect.String {
-				return nil, fmt.Errorf("object key must evaluate to a string, got %v (%s)", k, k.Kind())
+				return nil, newErrorValue("spread", ErrNonStringKey, fmt.Sprintf("%v (%s)", k, k.Kind()))
 			}
 			if v := v.MapIndex(k); v.CanInterface() {
 				results = append(results, map[string]interface{}{
@@ -593,10 +592,11 @@ func Spread(v reflect.Value) (interface{}, error) {
 		}
 	case jtypes.IsStruct(v) && !jtypes.IsCallable(v):
 		v = jtypes.Resolve(v)
-		for i := 0; i < v.NumField(); i++ {
-			k := v.Type().Field(i).Name
-			v := v.FieldByIndex([]int{i})
-			if v.CanInterface() {
+		fields := jtypes.StructFields(v.Type())
+		for i := range fields {
+			k := fields[i].Name
+			v := fields[i].Value(v)
+			if v.IsValid() && v.CanInterface() {
 				results = append(results, map[string]interface{}{
 					k: v.Interface(),
 				})
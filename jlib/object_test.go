@@ -237,24 +237,52 @@ func TestEach(t *testing.T) {
 				"5",
 			},
 		},
+		{
+			// Struct fields are named and omitted according
+			// to their json tags.
+			Input: struct {
+				A int `json:"a"`
+				B int `json:"-"`
+				C int `json:"c,omitempty"`
+				D int `json:",omitempty"`
+			}{
+				A: 1,
+				B: 2,
+				D: 4,
+			},
+			Callable: repeatString,
+			Output: []interface{}{
+				"a",
+				"DDDD",
+			},
+		},
 		{
 			// Invalid input. Return an error.
 			// Note that we don't even get as far as validating the
 			// Callable in this case.
 			Input: "hello",
-			Error: fmt.Errorf("argument must be an object"),
+			Error: &jlib.Error{
+				Func: "each",
+				Type: jlib.ErrNonObject,
+			},
 		},
 		{
 			// Callable has too few parameters.
 			Input:    map[string]interface{}{},
 			Callable: paramCountCallable(0),
-			Error:    fmt.Errorf("function must take 1, 2 or 3 arguments"),
+			Error: &jlib.Error{
+				Func: "each",
+				Type: jlib.ErrCallbackArity,
+			},
 		},
 		{
 			// Callable has too many parameters.
 			Input:    struct{}{},
 			Callable: paramCountCallable(4),
-			Error:    fmt.Errorf("function must take 1, 2 or 3 arguments"),
+			Error: &jlib.Error{
+				Func: "each",
+				Type: jlib.ErrCallbackArity,
+			},
 		},
 		{
 			// If the Callable returns an error, return the error.
@@ -433,12 +461,31 @@ func TestSift(t *testing.T) {
 				"A": 4,
 			},
 		},
+		{
+			// Struct fields are named and omitted according
+			// to their json tags.
+			Input: struct {
+				A string `json:"a"`
+				B string `json:"-"`
+				C string `json:"C,omitempty"`
+			}{
+				A: "a",
+				B: "B",
+			},
+			Callable: valuesAreEqual,
+			Output: map[string]interface{}{
+				"a": "a",
+			},
+		},
 		{
 			// Invalid input. Return an error.
 			// Note that we don't even get as far as validating the
 			// Callable in this case.
 			Input: 3.141592,
-			Error: fmt.Errorf("argument must be an object"),
+			Error: &jlib.Error{
+				Func: "sift",
+				Type: jlib.ErrNonObject,
+			},
 		},
 		{
 			// Invalid key type.
@@ -446,19 +493,29 @@ func TestSift(t *testing.T) {
 				true: "true",
 			},
 			Callable: paramCountCallable(1),
-			Error:    fmt.Errorf("object key must evaluate to a string, got true (bool)"),
+			Error: &jlib.Error{
+				Func:  "sift",
+				Type:  jlib.ErrNonStringKey,
+				Value: "true (bool)",
+			},
 		},
 		{
 			// Callable has too few parameters.
 			Input:    map[string]interface{}{},
 			Callable: paramCountCallable(0),
-			Error:    fmt.Errorf("function must take 1, 2 or 3 arguments"),
+			Error: &jlib.Error{
+				Func: "sift",
+				Type: jlib.ErrCallbackArity,
+			},
 		},
 		{
 			// Callable has too many parameters.
 			Input:    struct{}{},
 			Callable: paramCountCallable(4),
-			Error:    fmt.Errorf("function must take 1, 2 or 3 arguments"),
+			Error: &jlib.Error{
+				Func: "sift",
+				Type: jlib.ErrCallbackArity,
+			},
 		},
 		{
 			// If the Callable returns an error, return the error.
@@ -605,6 +662,21 @@ func TestKeys(t *testing.T) {
 				"C",
 			},
 		},
+		{
+			// Struct fields are named and omitted according
+			// to their json tags. Fields of embedded structs
+			// are promoted.
+			Input: struct {
+				A int `json:"a"`
+				B int `json:"-"`
+				C int `json:"c,omitempty"`
+				embeddedFields
+			}{},
+			Output: []string{
+				"a",
+				"e",
+			},
+		},
 		{
 			Input: "this isn't an object",
 			Error: jtypes.ErrUndefined,
@@ -619,7 +691,11 @@ func TestKeys(t *testing.T) {
 			Input: map[bool]string{
 				true: "true",
 			},
-			Error: fmt.Errorf("object key must evaluate to a string, got true (bool)"),
+			Error: &jlib.Error{
+				Func:  "keys",
+				Type:  jlib.ErrNonStringKey,
+				Value: "true (bool)",
+			},
 		},
 		{
 			Input: []interface{}{
@@ -627,7 +703,11 @@ func TestKeys(t *testing.T) {
 					false: "false",
 				},
 			},
-			Error: fmt.Errorf("object key must evaluate to a string, got false (bool)"),
+			Error: &jlib.Error{
+				Func:  "keys",
+				Type:  jlib.ErrNonStringKey,
+				Value: "false (bool)",
+			},
 		},
 	})
 }
@@ -771,21 +851,58 @@ func TestMerge(t *testing.T) {
 				"Five":  5.0,
 			},
 		},
+		{
+			// Struct fields are named and omitted according
+			// to their json tags. Fields in nil embedded
+			// structs are ignored.
+			Input: []interface{}{
+				struct {
+					A int `json:"a"`
+					B int `json:"-"`
+					C int `json:"c,omitempty"`
+					*embeddedFields
+				}{
+					A: 1,
+					B: 2,
+				},
+				struct {
+					embeddedFields
+				}{
+					embeddedFields{
+						E: 5,
+					},
+				},
+			},
+			Output: map[string]interface{}{
+				"a": 1,
+				"e": 5,
+			},
+		},
 		{
 			Input: "this isn't an object",
-			Error: fmt.Errorf("argument must be an object or an array of objects"),
+			Error: &jlib.Error{
+				Func: "merge",
+				Type: jlib.ErrNonObjectArray,
+			},
 		},
 		{
 			Input: []interface{}{
 				3.141592,
 			},
-			Error: fmt.Errorf("argument must be an object or an array of objects"),
+			Error: &jlib.Error{
+				Func: "merge",
+				Type: jlib.ErrNonObjectArray,
+			},
 		},
 		{
 			Input: map[bool]string{
 				true: "true",
 			},
-			Error: fmt.Errorf("object key must evaluate to a string, got true (bool)"),
+			Error: &jlib.Error{
+				Func:  "merge",
+				Type:  jlib.ErrNonStringKey,
+				Value: "true (bool)",
+			},
 		},
 		{
 			Input: []interface{}{
@@ -793,7 +910,11 @@ func TestMerge(t *testing.T) {
 					false: "false",
 				},
 			},
-			Error: fmt.Errorf("object key must evaluate to a string, got false (bool)"),
+			Error: &jlib.Error{
+				Func:  "merge",
+				Type:  jlib.ErrNonStringKey,
+				Value: "false (bool)",
+			},
 		},
 	})
 }
@@ -814,6 +935,10 @@ func testMerge(t *testing.T, tests []mergeTest) {
 	}
 }
 
+type embeddedFields struct {
+	E int `json:"e"`
+}
+
 var errTest = errors.New("paramCountCallable.Call not implemented")
 
 type paramCountCallable int
@@ -174,7 +174,7 @@ func Contains(s string, pattern StringCallable) (bool, error) {
 func Split(s string, separator StringCallable, limit jtypes.OptionalInt) ([]string, error) {
 
 	if limit.Int < 0 {
-		return nil, fmt.Errorf("third argument of the split function must evaluate to a positive number")
+		return nil, newError("split", ErrSplitLimit)
 	}
 
 	var parts []string
@@ -213,7 +213,7 @@ func Join(values reflect.Value, separator jtypes.OptionalString) (string, error)
 		if s, ok := jtypes.AsString(values); ok {
 			return s, nil
 		}
-		return "", fmt.Errorf("function join takes an array of strings")
+		return "", newError("join", ErrNonStringArray)
 	}
 
 	var vs []string
@@ -240,7 +240,7 @@ func Join(values reflect.Value, separator jtypes.OptionalString) (string, error)
 func Match(s string, pattern jtypes.Callable, limit jtypes.OptionalInt) ([]map[string]interface{}, error) {
 
 	if limit.Int < 0 {
-		return nil, fmt.Errorf("third argument of function match must evaluate to a positive number")
+		return nil, newError("match", ErrMatchLimit)
 	}
 
 	max := -1
@@ -287,7 +287,7 @@ func Match(s string, pattern jtypes.Callable, limit jtypes.OptionalInt) ([]map[s
 func Replace(src string, pattern StringCallable, repl StringCallable, limit jtypes.OptionalInt) (string, error) {
 
 	if limit.Int < 0 {
-		return "", fmt.Errorf("fourth argument of function replace must evaluate to a positive number")
+		return "", newError("replace", ErrReplaceLimit)
 	}
 
 	max := -1
@@ -308,12 +308,12 @@ func Replace(src string, pattern StringCallable, repl StringCallable, limit jtyp
 func replaceString(src string, pattern string, repl StringCallable, limit int) (string, error) {
 
 	if pattern == "" {
-		return "", fmt.Errorf("second argument of function replace can't be an empty string")
+		return "", newError("replace", ErrReplaceEmpty)
 	}
 
 	s, ok := repl.toInterface().(string)
 	if !ok {
-		return "", fmt.Errorf("third argument of function replace must be a string when pattern is a string")
+		return "", newError("replace", ErrReplaceString)
 	}
 
 	return strings.Replace(src, pattern, s, limit), nil
@@ -378,12 +378,12 @@ var defaultDecimalFormat = jxpath.NewDecimalFormat()
 func FormatNumber(value float64, picture string, options jtypes.OptionalValue) (string, error) {
 
 	if !options.IsSet() {
-		return jxpath.FormatNumber(value, picture, defaultDecimalFormat)
+		return formatNumber(value, picture, defaultDecimalFormat)
 	}
 
 	opts := jtypes.Resolve(options.Value)
 	if !jtypes.IsMap(opts) {
-		return "", fmt.Errorf("decimal format options must be a map")
+		return "", newErrorValue("formatNumber", ErrDecimalFormat, "a map")
 	}
 
 	format, err := newDecimalFormat(opts)
@@ -391,7 +391,17 @@ func FormatNumber(value float64, picture string, options jtypes.OptionalValue) (
 		return "", err
 	}
 
-	return jxpath.FormatNumber(value, picture, format)
+	return formatNumber(value, picture, format)
+}
+
+func formatNumber(value float64, picture string, format jxpath.DecimalFormat) (string, error) {
+
+	s, err := jxpath.FormatNumber(value, picture, format)
+	if err != nil {
+		return "", newErrorValue("formatNumber", ErrNumberPicture, err.Error())
+	}
+
+	return s, nil
 }
 
 func newDecimalFormat(opts reflect.Value) (jxpath.DecimalFormat, error) {
@@ -402,12 +412,12 @@ func newDecimalFormat(opts reflect.Value) (jxpath.DecimalFormat, error) {
 
 		k, ok := jtypes.AsString(key)
 		if !ok {
-			return jxpath.DecimalFormat{}, fmt.Errorf("decimal format options must be a map of strings to strings")
+			return jxpath.DecimalFormat{}, newErrorValue("formatNumber", ErrDecimalFormat, "a map of strings to strings")
 		}
 
 		v, ok := jtypes.AsString(opts.MapIndex(key))
 		if !ok {
-			return jxpath.DecimalFormat{}, fmt.Errorf("decimal format options must be a map of strings to strings")
+			return jxpath.DecimalFormat{}, newErrorValue("formatNumber", ErrDecimalFormat, "a map of strings to strings")
 		}
 
 		if err := updateDecimalFormat(&format, k, v); err != nil {
@@ -432,7 +442,7 @@ func updateDecimalFormat(format *jxpath.DecimalFormat, key string, value string)
 	default:
 		r, w := utf8.DecodeRuneInString(value)
 		if r == utf8.RuneError || w != len(value) {
-			return fmt.Errorf("invalid value %q for option %q", value, key)
+			return newErrorValue("formatNumber", ErrDecimalFormatValue, fmt.Sprintf("%q for option %q", value, key))
 		}
 		switch key {
 		case "decimal-separator":
@@ -450,7 +460,7 @@ func updateDecimalFormat(format *jxpath.DecimalFormat, key string, value string)
 		case "pattern-separator":
 			format.PatternSeparator = r
 		default:
-			return fmt.Errorf("unknown option %q", key)
+			return newErrorValue("formatNumber", ErrDecimalFormatOption, key)
 		}
 	}
 
@@ -468,12 +478,57 @@ func FormatBase(value float64, base jtypes.OptionalFloat64) (string, error) {
 	}
 
 	if radix < 2 || radix > 36 {
-		return "", fmt.Errorf("the second argument to formatBase must be between 2 and 36")
+		return "", newError("formatBase", ErrFormatBase)
 	}
 
 	return strconv.FormatInt(int64(Round(value, jtypes.OptionalInt{})), radix), nil
 }
 
+// FormatInteger converts a number to a string, formatted according
+// to the given picture string. See the XPath function format-integer
+// for the syntax of the picture string.
+//
+// https://www.w3.org/TR/xpath-functions-31/#func-format-integer
+//
+// If the number is not an integer, it is rounded down.
+func FormatInteger(value float64, picture string) (string, error) {
+
+	n, err := toInt64(value)
+	if err != nil {
+		return "", err
+	}
+
+	s, err := jxpath.FormatInteger(n, picture)
+	if err != nil {
+		return "", newErrorValue("formatInteger", ErrIntegerPicture, err.Error())
+	}
+
+	return s, nil
+}
+
+// ParseInteger converts a string to a number, using the given
+// picture string to interpret it. It is the inverse of
+// FormatInteger.
+func ParseInteger(value string, picture string) (float64, error) {
+
+	n, err := jxpath.ParseInteger(value, picture)
+	if err != nil {
+		return 0, newErrorValue("parseInteger", ErrIntegerPicture, err.Error())
+	}
+
+	return float64(n), nil
+}
+
+func toInt64(value float64) (int64, error) {
+
+	value = math.Floor(value)
+	if math.IsNaN(value) || value < math.MinInt64 || value >= math.MaxInt64 {
+		return 0, newErrorValue("formatInteger", ErrIntegerRange, fmt.Sprint(value))
+	}
+
+	return int64(value), nil
+}
+
 // Base64Encode returns the base 64 encoding of a string.
 func Base64Encode(s string) (string, error) {
 	return base64.StdEncoding.EncodeToString([]byte(s)), nil
@@ -496,7 +551,7 @@ func Base64Decode(s string) (string, error) {
 func DecodeURL(s string) (string, error) {
 	escaped, err := url.QueryUnescape(s)
 	if err != nil {
-		return "", err
+		return "", newErrorValue("decodeUrl", ErrMalformedURL, err.Error())
 	}
 	return escaped, nil
 }
@@ -508,7 +563,7 @@ func EncodeURL(s string) (string, error) {
 	// but jsonata-js expects the operation to fail, so we'll
 	// provide the same behavior
 	if s == "�" {
-		return "", fmt.Errorf("invalid character")
+		return "", newErrorValue("encodeUrl", ErrMalformedURL, "invalid character")
 	}
 
 	baseURL, err := url.Parse(s)
@@ -527,7 +582,7 @@ func EncodeURLComponent(s string) (string, error) {
 	// but jsonata-js expects the operation to fail, so we'll
 	// provide the same behavior
 	if s == "�" {
-		return "", fmt.Errorf("invalid character")
+		return "", newErrorValue("encodeUrlComponent", ErrMalformedURL, "invalid character")
 	}
 
 	return url.QueryEscape(s), nil
@@ -565,7 +620,7 @@ func callMatchFunc(fn jtypes.Callable, argv []reflect.Value, matches []match) ([
 	}
 
 	if !jtypes.IsMap(res) {
-		return nil, fmt.Errorf("match function must return an object")
+		return nil, newErrorValue("", ErrMatcher, "an object")
 	}
 
 	res = jtypes.Resolve(res)
@@ -573,24 +628,24 @@ func callMatchFunc(fn jtypes.Callable, argv []reflect.Value, matches []match) ([
 	v := res.MapIndex(reflect.ValueOf("match"))
 	value, ok := jtypes.AsString(v)
 	if !ok {
-		return nil, fmt.Errorf("match function must return an object with a string value named 'match'")
+		return nil, newErrorValue("", ErrMatcher, "an object with a string value named 'match'")
 	}
 
 	v = res.MapIndex(reflect.ValueOf("start"))
 	start, ok := jtypes.AsNumber(v)
 	if !ok {
-		return nil, fmt.Errorf("match function must return an object with a number value named 'start'")
+		return nil, newErrorValue("", ErrMatcher, "an object with a number value named 'start'")
 	}
 
 	v = res.MapIndex(reflect.ValueOf("end"))
 	end, ok := jtypes.AsNumber(v)
 	if !ok {
-		return nil, fmt.Errorf("match function must return an object with a number value named 'end'")
+		return nil, newErrorValue("", ErrMatcher, "an object with a number value named 'end'")
 	}
 
 	v = res.MapIndex(reflect.ValueOf("groups"))
 	if !jtypes.IsArrayOf(v, jtypes.IsString) {
-		return nil, fmt.Errorf("match function must return an object with a string array value named 'groups'")
+		return nil, newErrorValue("", ErrMatcher, "an object with a string array value named 'groups'")
 	}
 
 	v = jtypes.Resolve(v)
@@ -603,7 +658,7 @@ func callMatchFunc(fn jtypes.Callable, argv []reflect.Value, matches []match) ([
 	v = res.MapIndex(reflect.ValueOf("next"))
 	next, ok := jtypes.AsCallable(v)
 	if !ok {
-		return nil, fmt.Errorf("match function must return an object with a Callable value named 'next'")
+		return nil, newErrorValue("", ErrMatcher, "an object with a Callable value named 'next'")
 	}
 
 	return callMatchFunc(next, nil, append(matches, match{
@@ -696,7 +751,7 @@ func callReplaceFunc(f jtypes.Callable, m match) (string, error) {
 
 	repl, ok := jtypes.AsString(v)
 	if !ok {
-		return "", fmt.Errorf("third argument of function replace must be a function that returns a string")
+		return "", newError("replace", ErrReplaceResult)
 	}
 
 	return repl, nil
@@ -523,7 +523,10 @@ func TestSplit(t *testing.T) {
 		{
 			Separator: "",
 			Limit:     jtypes.NewOptionalInt(-1),
-			Error:     fmt.Errorf("third argument of the split function must evaluate to a positive number"),
+			Error: &jlib.Error{
+				Func: "split",
+				Type: jlib.ErrSplitLimit,
+			},
 		},
 		{
 			Separator: "muji",
@@ -660,7 +663,10 @@ func TestJoin(t *testing.T) {
 				"four",
 				5,
 			},
-			Error: fmt.Errorf("function join takes an array of strings"),
+			Error: &jlib.Error{
+				Func: "join",
+				Type: jlib.ErrNonStringArray,
+			},
 		},
 	}
 
@@ -816,7 +822,10 @@ func TestMatch(t *testing.T) {
 		{
 			Pattern: abracadabraMatches2(),
 			Limit:   jtypes.NewOptionalInt(-1),
-			Error:   fmt.Errorf("third argument of function match must evaluate to a positive number"),
+			Error: &jlib.Error{
+				Func: "match",
+				Type: jlib.ErrMatchLimit,
+			},
 		},
 		{
 			Pattern: &matchCallable{
@@ -883,7 +892,10 @@ func TestReplace(t *testing.T) {
 			Pattern: "a",
 			Repl:    "å",
 			Limit:   jtypes.NewOptionalInt(-1),
-			Error:   fmt.Errorf("fourth argument of function replace must evaluate to a positive number"),
+			Error: &jlib.Error{
+				Func: "replace",
+				Type: jlib.ErrReplaceLimit,
+			},
 		},
 		{
 			Pattern: "a",
@@ -899,13 +911,19 @@ func TestReplace(t *testing.T) {
 			Pattern: "",
 			Repl:    "å",
 			Limit:   jtypes.NewOptionalInt(0),
-			Error:   fmt.Errorf("second argument of function replace can't be an empty string"),
+			Error: &jlib.Error{
+				Func: "replace",
+				Type: jlib.ErrReplaceEmpty,
+			},
 		},
 		{
 			Pattern: "a",
 			Repl:    replaceCallable(nil),
 			Limit:   jtypes.NewOptionalInt(0),
-			Error:   fmt.Errorf("third argument of function replace must be a string when pattern is a string"),
+			Error: &jlib.Error{
+				Func: "replace",
+				Type: jlib.ErrReplaceString,
+			},
 		},
 
 		// Matching function patterns
@@ -932,7 +950,10 @@ func TestReplace(t *testing.T) {
 			Pattern: abracadabraMatches0(),
 			Repl:    "åå",
 			Limit:   jtypes.NewOptionalInt(-1),
-			Error:   fmt.Errorf("fourth argument of function replace must evaluate to a positive number"),
+			Error: &jlib.Error{
+				Func: "replace",
+				Type: jlib.ErrReplaceLimit,
+			},
 		},
 		{
 			// $0 is replaced by the full matched string.
@@ -1062,7 +1083,10 @@ func TestReplace(t *testing.T) {
 			Repl: replaceCallable(func(m map[string]interface{}) (interface{}, error) {
 				return 100, nil
 			}),
-			Error: fmt.Errorf("third argument of function replace must be a function that returns a string"),
+			Error: &jlib.Error{
+				Func: "replace",
+				Type: jlib.ErrReplaceResult,
+			},
 		},
 		{
 			Pattern: abracadabraMatches2(),
@@ -1288,12 +1312,18 @@ func TestFormatBase(t *testing.T) {
 			Output: "2s",
 		},
 		{
-			Base:  jtypes.NewOptionalFloat64(1),
-			Error: fmt.Errorf("the second argument to formatBase must be between 2 and 36"),
+			Base: jtypes.NewOptionalFloat64(1),
+			Error: &jlib.Error{
+				Func: "formatBase",
+				Type: jlib.ErrFormatBase,
+			},
 		},
 		{
-			Base:  jtypes.NewOptionalFloat64(40),
-			Error: fmt.Errorf("the second argument to formatBase must be between 2 and 36"),
+			Base: jtypes.NewOptionalFloat64(40),
+			Error: &jlib.Error{
+				Func: "formatBase",
+				Type: jlib.ErrFormatBase,
+			},
 		},
 	}
 
@@ -0,0 +1,535 @@
+// Copyright 2018 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package jparse
+
+import (
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// An Analysis describes the inputs that a JSONata expression
+// depends on. The Paths, Variables and Functions fields are
+// sorted and contain no duplicates.
+type Analysis struct {
+
+	// Paths are the fields of the input data that the expression
+	// reads, e.g. "Account.Order.Product.Price". Paths within
+	// predicates, sort terms and object groupings are resolved
+	// against the enclosing path, e.g. the expression
+	// "Order[Status = 'open'].Total" reads "Order.Status" and
+	// "Order.Total". Likewise, "[a, b].c" reads "a.c" and "b.c".
+	// A path step that is a function call, block or object
+	// constructor reads the preceding path, so "Order.$count()"
+	// reads "Order". Wildcards and descendent operators appear
+	// as "*" and "**" respectively. Fields that are read from
+	// variables or from the results of function calls are not
+	// included.
+	Paths []string
+
+	// Variables are the names (without the leading "$") of the
+	// variables that the expression uses but does not define.
+	// These must be provided by the caller, e.g. via RegisterVars.
+	// A function that is referred to but not called (e.g. $string
+	// in "$map(items, $string)") is also reported here. The
+	// context variables $ and $$ are never included.
+	Variables []string
+
+	// Functions are the names (without the leading "$") of the
+	// functions that the expression calls but does not define.
+	// These are either built-in functions or extensions.
+	Functions []string
+
+	// Calls lists every call to a named function (i.e. every
+	// function call or function application whose function is
+	// a variable) in source order.
+	Calls []Call
+}
+
+// A Call describes a call to a named function, e.g. $sum(a)
+// or a ~> $uppercase.
+type Call struct {
+
+	// Span is the location of the call.
+	Span
+
+	// Name is the name of the function without the leading
+	// "$".
+	Name string
+
+	// Args is the number of arguments passed to the function.
+	// For the function application operator, this includes
+	// the value on the left side of the operator.
+	Args int
+
+	// Local is true if the function is a variable defined by
+	// the expression (e.g. a lambda parameter) and false if
+	// it is a built-in function or extension.
+	Local bool
+
+	// Lambda is the *LambdaNode or *TypedLambdaNode assigned
+	// to a local variable, if the call refers to one.
+	Lambda Node
+}
+
+// Analyze returns the input paths, free variables and functions
+// used by a syntax tree.
+//
+// Variables are resolved using the lexical scopes of JSONata.
+// Variables assigned in a block are visible to the subsequent
+// expressions in that block (and in nested blocks), lambda
+// parameters are visible in the body of the lambda, and the
+// focus and index binding operators (@ and #) define variables
+// that are visible to the subsequent steps of the path. Because
+// a lambda's body is evaluated when the lambda is called, it can
+// refer to variables that are assigned after the lambda in an
+// enclosing block. This allows for recursive functions.
+func Analyze(node Node) Analysis {
+
+	a := &analyzer{
+		scopes: []map[string]Node{{}},
+		paths:  map[string]bool{},
+		vars:   map[string]bool{},
+		funcs:  map[string]bool{},
+	}
+
+	a.expr(node, rootContext)
+
+	for len(a.lambdas) > 0 {
+		l := a.lambdas[0]
+		a.lambdas = a.lambdas[1:]
+		a.scopes = append(l.scopes, map[string]Node{})
+		for _, name := range l.node.ParamNames {
+			a.bind(name, nil)
+		}
+		a.expr(l.node.Body, l.ctx)
+	}
+
+	sort.SliceStable(a.calls, func(i, j int) bool {
+		return a.calls[i].Start.Offset < a.calls[j].Start.Offset
+	})
+
+	return Analysis{
+		Paths:     sortedKeys(a.paths),
+		Variables: sortedKeys(a.vars),
+		Functions: sortedKeys(a.funcs),
+		Calls:     a.calls,
+	}
+}
+
+type analyzer struct {
+	scopes  []map[string]Node
+	paths   map[string]bool
+	vars    map[string]bool
+	funcs   map[string]bool
+	calls   []Call
+	lambdas []pendingLambda
+}
+
+// A pendingLambda is a lambda whose body has not yet been
+// analyzed. It holds the scopes and context in which the
+// lambda was defined.
+type pendingLambda struct {
+	node   *LambdaNode
+	scopes []map[string]Node
+	ctx    context
+}
+
+// A context is the set of paths from the root of the input
+// data to the values that an expression is evaluated against.
+// There is more than one path if the values come from an array
+// constructor in a path (e.g. "[a, b].c"). A nil context means
+// that the paths are unknown.
+type context [][]string
+
+// rootContext is the context of the input data.
+var rootContext = context{{}}
+
+// expr analyzes an expression that is evaluated against the
+// given context.
+func (a *analyzer) expr(node Node, ctx context) {
+
+	switch n := node.(type) {
+	case *StringNode, *NumberNode, *BooleanNode, *NullNode,
+		*RegexNode, *PlaceholderNode:
+		// Literals do not depend on their inputs.
+
+	case *VariableNode:
+		a.useVar(n.Name)
+
+	case *NameNode, *WildcardNode, *DescendentNode, *ParentNode,
+		*FocusNode, *IndexNode, *PredicateNode, *GroupNode, *SortNode:
+		a.path([]Node{n}, ctx)
+
+	case *PathNode:
+		a.path(n.Steps, ctx)
+
+	case *NegationNode:
+		a.expr(n.RHS, ctx)
+
+	case *RangeNode:
+		a.expr(n.LHS, ctx)
+		a.expr(n.RHS, ctx)
+
+	case *ArrayNode:
+		a.exprs(n.Items, ctx)
+
+	case *ObjectNode:
+		for _, pair := range n.Pairs {
+			a.expr(pair[0], ctx)
+			a.expr(pair[1], ctx)
+		}
+
+	case *BlockNode:
+		a.push()
+		a.exprs(n.Exprs, ctx)
+		a.pop()
+
+	case *ObjectTransformationNode:
+		// The update and delete clauses are evaluated
+		// against the objects matched by the pattern.
+		a.expr(n.Pattern, ctx)
+		a.expr(n.Updates, nil)
+		if n.Deletes != nil {
+			a.expr(n.Deletes, nil)
+		}
+
+	case *LambdaNode:
+		a.lambda(n, ctx)
+
+	case *TypedLambdaNode:
+		a.lambda(n.LambdaNode, ctx)
+
+	case *PartialNode:
+		a.call(n.Func, len(n.Args), n.Span, ctx)
+		a.exprs(n.Args, ctx)
+
+	case *FunctionCallNode:
+		a.call(n.Func, len(n.Args), n.Span, ctx)
+		a.exprs(n.Args, ctx)
+
+	case *ConditionalNode:
+		a.expr(n.If, ctx)
+		a.expr(n.Then, ctx)
+		if n.Else != nil {
+			a.expr(n.Else, ctx)
+		}
+
+	case *AssignmentNode:
+		a.expr(n.Value, ctx)
+		if isLambda(n.Value) {
+			a.bind(n.Name, n.Value)
+		} else {
+			a.bind(n.Name, nil)
+		}
+
+	case *NumericOperatorNode:
+		a.expr(n.LHS, ctx)
+		a.expr(n.RHS, ctx)
+
+	case *ComparisonOperatorNode:
+		a.expr(n.LHS, ctx)
+		a.expr(n.RHS, ctx)
+
+	case *BooleanOperatorNode:
+		a.expr(n.LHS, ctx)
+		a.expr(n.RHS, ctx)
+
+	case *CoalescingOperatorNode:
+		a.expr(n.LHS, ctx)
+		a.expr(n.RHS, ctx)
+
+	case *StringConcatenationNode:
+		a.expr(n.LHS, ctx)
+		a.expr(n.RHS, ctx)
+
+	case *FunctionApplicationNode:
+		// The value on the left side of the operator is
+		// passed to the function on the right side. If
+		// the right side is a function call, the value is
+		// inserted as the first argument.
+		a.expr(n.LHS, ctx)
+		switch rhs := n.RHS.(type) {
+		case *VariableNode:
+			a.call(rhs, 1, n.Span, ctx)
+		case *FunctionCallNode:
+			a.call(rhs.Func, len(rhs.Args)+1, n.Span, ctx)
+			a.exprs(rhs.Args, ctx)
+		default:
+			a.expr(rhs, ctx)
+		}
+
+	default:
+		panicf("jparse.Analyze: unexpected node type %T", n)
+	}
+}
+
+func (a *analyzer) exprs(nodes []Node, ctx context) {
+	for _, n := range nodes {
+		a.expr(n, ctx)
+	}
+}
+
+// path analyzes the steps of a path. Each step is evaluated
+// against the result of the previous step. Variables bound by
+// the focus and index operators are only visible within the
+// path.
+func (a *analyzer) path(steps []Node, ctx context) {
+
+	a.push()
+	for _, step := range steps {
+		ctx = a.step(step, ctx)
+	}
+	a.pop()
+
+	a.read(ctx)
+}
+
+// step analyzes a path step and returns the context for the
+// next step.
+func (a *analyzer) step(node Node, ctx context) context {
+
+	switch n := node.(type) {
+	case *NameNode:
+		return extend(ctx, n.Value)
+
+	case *WildcardNode:
+		return extend(ctx, "*")
+
+	case *DescendentNode:
+		return extend(ctx, "**")
+
+	case *ParentNode:
+		var parents context
+		for _, path := range ctx {
+			if len(path) > 0 {
+				parents = append(parents, path[:len(path)-1:len(path)-1])
+			}
+		}
+		return parents
+
+	case *VariableNode:
+		switch n.Name {
+		case "":
+			return ctx
+		case "$":
+			return rootContext
+		default:
+			a.useVar(n.Name)
+			return nil
+		}
+
+	case *PredicateNode:
+		ctx = a.step(n.Expr, ctx)
+		a.exprs(n.Filters, ctx)
+		return ctx
+
+	case *FocusNode:
+		// The focus operator does not change the context
+		// of the next step.
+		a.read(a.step(n.Expr, ctx))
+		a.bind(n.Name, nil)
+		return ctx
+
+	case *IndexNode:
+		ctx = a.step(n.Expr, ctx)
+		a.bind(n.Name, nil)
+		return ctx
+
+	case *SortNode:
+		ctx = a.step(n.Expr, ctx)
+		for _, term := range n.Terms {
+			a.expr(term.Expr, ctx)
+		}
+		return ctx
+
+	case *GroupNode:
+		ctx = a.step(n.Expr, ctx)
+		a.read(ctx)
+		a.expr(n.ObjectNode, ctx)
+		return nil
+
+	case *PathNode:
+		for _, step := range n.Steps {
+			ctx = a.step(step, ctx)
+		}
+		return ctx
+
+	case *ArrayNode:
+		// The values of an array constructor in a path are
+		// the values of its items, so the next step reads
+		// from each of them.
+		var items context
+		for _, item := range n.Items {
+			items = append(items, a.step(item, ctx)...)
+		}
+		return items
+
+	case *StringNode, *NumberNode, *BooleanNode, *NullNode, *RegexNode:
+		// Literals do not depend on the context.
+		return nil
+
+	default:
+		// Other steps (e.g. blocks and function calls)
+		// produce values that are not part of the input,
+		// but they are evaluated once for each value in
+		// the context, so the context is read.
+		a.read(ctx)
+		a.expr(n, ctx)
+		return nil
+	}
+}
+
+// lambda defers the analysis of a lambda's body until the
+// rest of the expression has been analyzed, so that the body
+// can see variables that are assigned after the lambda. The
+// current scopes are captured by reference, so they will
+// include those variables.
+func (a *analyzer) lambda(n *LambdaNode, ctx context) {
+	a.lambdas = append(a.lambdas, pendingLambda{
+		node:   n,
+		scopes: append([]map[string]Node(nil), a.scopes...),
+		ctx:    ctx,
+	})
+}
+
+// call analyzes the function in a function call with argc
+// arguments. Calls to variables that are not defined by the
+// expression are reported as functions rather than variables.
+func (a *analyzer) call(fn Node, argc int, span Span, ctx context) {
+
+	v, ok := fn.(*VariableNode)
+	if !ok {
+		a.expr(fn, ctx)
+		return
+	}
+
+	lambda, local := a.lookup(v.Name)
+	if !local {
+		a.funcs[v.Name] = true
+	}
+
+	a.calls = append(a.calls, Call{
+		Span:   span,
+		Name:   v.Name,
+		Args:   argc,
+		Local:  local,
+		Lambda: lambda,
+	})
+}
+
+func (a *analyzer) useVar(name string) {
+	if name == "" || name == "$" {
+		return
+	}
+	if _, ok := a.lookup(name); !ok {
+		a.vars[name] = true
+	}
+}
+
+// read records a path that the expression reads from the
+// input data.
+func (a *analyzer) read(ctx context) {
+
+	for _, path := range ctx {
+
+		if len(path) == 0 {
+			continue
+		}
+
+		names := make([]string, len(path))
+		for i, name := range path {
+			names[i] = quoteName(name)
+		}
+
+		a.paths[strings.Join(names, ".")] = true
+	}
+}
+
+func (a *analyzer) push() {
+	a.scopes = append(a.scopes, map[string]Node{})
+}
+
+func (a *analyzer) pop() {
+	a.scopes = a.scopes[:len(a.scopes)-1]
+}
+
+// bind defines a variable in the current scope. If the
+// variable is assigned a lambda, value is the lambda node.
+// Otherwise value is nil.
+func (a *analyzer) bind(name string, value Node) {
+	a.scopes[len(a.scopes)-1][name] = value
+}
+
+// lookup returns the value bound to a variable and true if
+// the variable is defined by the expression. Otherwise it
+// returns false.
+func (a *analyzer) lookup(name string) (Node, bool) {
+
+	for i := len(a.scopes) - 1; i >= 0; i-- {
+		if value, ok := a.scopes[i][name]; ok {
+			return value, true
+		}
+	}
+
+	return nil, false
+}
+
+// extend returns a new context consisting of the paths in ctx
+// followed by name, or nil if ctx is unknown.
+func extend(ctx context, name string) context {
+
+	if ctx == nil {
+		return nil
+	}
+
+	paths := make(context, len(ctx))
+	for i, path := range ctx {
+		paths[i] = append(path[:len(path):len(path)], name)
+	}
+
+	return paths
+}
+
+func isLambda(n Node) bool {
+	switch n.(type) {
+	case *LambdaNode, *TypedLambdaNode:
+		return true
+	default:
+		return false
+	}
+}
+
+// quoteName returns a field name as it would appear in a path,
+// i.e. enclosed in backticks if it is not a valid identifier.
+func quoteName(name string) string {
+
+	if name == "*" || name == "**" {
+		return name
+	}
+
+	for i, r := range name {
+		if r != '_' && !unicode.IsLetter(r) && (i == 0 || !unicode.IsDigit(r)) {
+			return "`" + name + "`"
+		}
+	}
+
+	return name
+}
+
+func sortedKeys(m map[string]bool) []string {
+
+	if len(m) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
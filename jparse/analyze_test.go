@@ -0,0 +1,317 @@
+// Copyright 2018 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package jparse_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/blues/jsonata-go/jparse"
+)
+
+func TestAnalyze(t *testing.T) {
+
+	data := []struct {
+		Input  string
+		Output jparse.Analysis
+	}{
+		{
+			Input: `1 + 2`,
+		},
+		{
+			Input: `body.env_temp > $threshold`,
+			Output: jparse.Analysis{
+				Paths:     []string{"body.env_temp"},
+				Variables: []string{"threshold"},
+			},
+		},
+		{
+			Input: `Account.Order[OrderID = "order103"].Product.Price`,
+			Output: jparse.Analysis{
+				Paths: []string{
+					"Account.Order.OrderID",
+					"Account.Order.Product.Price",
+				},
+			},
+		},
+		{
+			Input: `$sum(Account.Order.Product.(Price * Quantity))`,
+			Output: jparse.Analysis{
+				Paths: []string{
+					"Account.Order.Product",
+					"Account.Order.Product.Price",
+					"Account.Order.Product.Quantity",
+				},
+				Functions: []string{"sum"},
+			},
+		},
+		{
+			// A function call in a path is called once
+			// for each value of the previous step.
+			Input: `Account.Order.$count()`,
+			Output: jparse.Analysis{
+				Paths:     []string{"Account.Order"},
+				Functions: []string{"count"},
+			},
+		},
+		{
+			Input: `Account.Order.$string()`,
+			Output: jparse.Analysis{
+				Paths:     []string{"Account.Order"},
+				Functions: []string{"string"},
+			},
+		},
+		{
+			Input: `Account.Order.{"x": 1}`,
+			Output: jparse.Analysis{
+				Paths: []string{"Account.Order"},
+			},
+		},
+		{
+			Input: `Account.Order.(1 + 2)`,
+			Output: jparse.Analysis{
+				Paths: []string{"Account.Order"},
+			},
+		},
+		{
+			// The next step reads from each item of an
+			// array constructor in a path.
+			Input: `[a, b].c`,
+			Output: jparse.Analysis{
+				Paths: []string{"a.c", "b.c"},
+			},
+		},
+		{
+			Input: `x.[a, b.d, 1, $v].c`,
+			Output: jparse.Analysis{
+				Paths: []string{
+					"x.a.c",
+					"x.b.d.c",
+				},
+				Variables: []string{"v"},
+			},
+		},
+		{
+			// Local variables are not free.
+			Input: `($x := a; $y := $x + $z; $y)`,
+			Output: jparse.Analysis{
+				Paths:     []string{"a"},
+				Variables: []string{"z"},
+			},
+		},
+		{
+			// Variables are used before they are assigned.
+			Input: `($x := $x + 1; $x)`,
+			Output: jparse.Analysis{
+				Variables: []string{"x"},
+			},
+		},
+		{
+			// Variables assigned in a block are not visible
+			// outside the block.
+			Input: `($x := 1; ($y := 2); $x + $y)`,
+			Output: jparse.Analysis{
+				Variables: []string{"y"},
+			},
+		},
+		{
+			// Lambda parameters are visible in the lambda body.
+			Input: `$map(items, function($v, $i) { $v * $i + $k })`,
+			Output: jparse.Analysis{
+				Paths:     []string{"items"},
+				Variables: []string{"k"},
+				Functions: []string{"map"},
+			},
+		},
+		{
+			// Lambda parameters are not visible outside the
+			// lambda body.
+			Input: `($f := λ($v){$v}; $f($v))`,
+			Output: jparse.Analysis{
+				Variables: []string{"v"},
+			},
+		},
+		{
+			// Recursive lambdas.
+			Input: `($fact := function($n) { $n <= 1 ? 1 : $n * $fact($n - 1) }; $fact(5))`,
+		},
+		{
+			// Mutually recursive lambdas.
+			Input: `($even := function($n) { $n = 0 ? true : $odd($n - 1) }; $odd := function($n) { $n = 0 ? false : $even($n - 1) }; $even(4))`,
+		},
+		{
+			// Lambda bodies see their enclosing scopes but
+			// not the caller's scope.
+			Input: `($f := function() { $a & $b }; ($b := 1; $f()); $a := 2)`,
+			Output: jparse.Analysis{
+				Variables: []string{"b"},
+			},
+		},
+		{
+			// Functions passed as arguments are variables.
+			Input: `$map(items, $string) ~> $join(", ")`,
+			Output: jparse.Analysis{
+				Paths:     []string{"items"},
+				Variables: []string{"string"},
+				Functions: []string{"join", "map"},
+			},
+		},
+		{
+			Input: `items ~> $uppercase ~> $f(?)`,
+			Output: jparse.Analysis{
+				Paths:     []string{"items"},
+				Functions: []string{"f", "uppercase"},
+			},
+		},
+		{
+			// Focus and index bindings are visible in the
+			// rest of the path, but not outside it.
+			Input: `library.loans@$l.books@$b[$l.isbn = $b.isbn].{"title": $b.title, "i": $i}`,
+			Output: jparse.Analysis{
+				Paths: []string{
+					"library",
+					"library.books",
+					"library.loans",
+				},
+				Variables: []string{"i"},
+			},
+		},
+		{
+			Input: `(library.books#$i[$i < 2].title; $i)`,
+			Output: jparse.Analysis{
+				Paths:     []string{"library.books.title"},
+				Variables: []string{"i"},
+			},
+		},
+		{
+			Input: `Account.Order{OrderID: $sum(Product.Price)}`,
+			Output: jparse.Analysis{
+				Paths: []string{
+					"Account.Order",
+					"Account.Order.OrderID",
+					"Account.Order.Product.Price",
+				},
+				Functions: []string{"sum"},
+			},
+		},
+		{
+			Input: `Account.Order.Product^(>Price, Quantity).%.OrderID`,
+			Output: jparse.Analysis{
+				Paths: []string{
+					"Account.Order.OrderID",
+					"Account.Order.Product.Price",
+					"Account.Order.Product.Quantity",
+				},
+			},
+		},
+		{
+			Input: "a.*.**.`b c`.$.$$.d",
+			Output: jparse.Analysis{
+				Paths: []string{"d"},
+			},
+		},
+		{
+			// Fields of variables and function results are
+			// not part of the input.
+			Input: `$x.a & $f().b & $.c`,
+			Output: jparse.Analysis{
+				Paths:     []string{"c"},
+				Variables: []string{"x"},
+				Functions: []string{"f"},
+			},
+		},
+		{
+			Input: "`b c`.d[0]",
+			Output: jparse.Analysis{
+				Paths: []string{"`b c`.d"},
+			},
+		},
+		{
+			Input: `| Account.Order.Product | {"Price": Price * $rate}, ["Tax"] |`,
+			Output: jparse.Analysis{
+				Paths:     []string{"Account.Order.Product"},
+				Variables: []string{"rate"},
+			},
+		},
+	}
+
+	for _, test := range data {
+
+		root, err := jparse.Parse(test.Input)
+		if err != nil {
+			t.Fatalf("%s: Parse: %s", test.Input, err)
+		}
+
+		output := jparse.Analyze(root)
+		output.Calls = nil // see TestAnalyzeCalls
+
+		if !reflect.DeepEqual(output, test.Output) {
+			t.Errorf("%s: expected %+v, got %+v", test.Input, test.Output, output)
+		}
+	}
+}
+
+func TestAnalyzeCalls(t *testing.T) {
+
+	input := `(
+	$f := function($x, $g) { $g($x) };
+	$h := λ($s)<s:s>{ $uppercase($s) };
+	items ~> $map($f) ~> $h;
+	$f(1, $string)
+)`
+
+	root, err := jparse.Parse(input)
+	if err != nil {
+		t.Fatalf("Parse: %s", err)
+	}
+
+	var lambdas []jparse.Node
+
+	jparse.Inspect(root, func(n jparse.Node) bool {
+		switch n.(type) {
+		case *jparse.LambdaNode, *jparse.TypedLambdaNode:
+			lambdas = append(lambdas, n)
+		}
+		return true
+	})
+
+	exp := []jparse.Call{
+		{
+			Span:  span(28, 2, 27, 34, 2, 33),
+			Name:  "g",
+			Args:  1,
+			Local: true,
+		},
+		{
+			Span: span(58, 3, 21, 72, 3, 35),
+			Name: "uppercase",
+			Args: 1,
+		},
+		{
+			Span: span(77, 4, 2, 94, 4, 19),
+			Name: "map",
+			Args: 2,
+		},
+		{
+			Span:   span(77, 4, 2, 100, 4, 25),
+			Name:   "h",
+			Args:   1,
+			Local:  true,
+			Lambda: lambdas[1],
+		},
+		{
+			Span:   span(103, 5, 2, 117, 5, 16),
+			Name:   "f",
+			Args:   2,
+			Local:  true,
+			Lambda: lambdas[0],
+		},
+	}
+
+	got := jparse.Analyze(root).Calls
+	if !reflect.DeepEqual(got, exp) {
+		t.Errorf("expected calls:\n%+v\ngot:\n%+v", exp, got)
+	}
+}
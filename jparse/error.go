@@ -42,46 +42,94 @@ const (
 	ErrUnmatchedSubtype
 	ErrInvalidSubtype
 	ErrInvalidParamType
+	ErrIllegalBinding
+	ErrUnterminatedComment
+	ErrNoParent
+	ErrCommentPosition
 )
 
 var errmsgs = map[ErrType]string{
-	ErrSyntaxError:        "syntax error: '{{token}}'",
-	ErrUnexpectedEOF:      "unexpected end of expression",
-	ErrUnexpectedToken:    "expected token '{{hint}}', got '{{token}}'",
-	ErrMissingToken:       "expected token '{{hint}}' before end of expression",
-	ErrPrefix:             "the symbol '{{token}}' cannot be used as a prefix operator",
-	ErrInfix:              "the symbol '{{token}}' cannot be used as an infix operator",
-	ErrUnterminatedString: "unterminated string literal (no closing '{{hint}}')",
-	ErrUnterminatedRegex:  "unterminated regular expression (no closing '{{hint}}')",
-	ErrUnterminatedName:   "unterminated name (no closing '{{hint}}')",
-	ErrIllegalEscape:      "illegal escape sequence \\{{hint}}",
-	ErrIllegalEscapeHex:   "illegal escape sequence \\{{hint}}: \\u must be followed by a 4-digit hexadecimal code point",
-	ErrInvalidNumber:      "invalid number literal {{token}}",
-	ErrNumberRange:        "invalid number literal {{token}}: value out of range",
-	ErrEmptyRegex:         "invalid regular expression: expression cannot be empty",
-	ErrInvalidRegex:       "invalid regular expression {{token}}: {{hint}}",
-	ErrGroupPredicate:     "a predicate cannot follow a grouping expression in a path step",
-	ErrGroupGroup:         "a path step can only have one grouping expression",
-	ErrPathLiteral:        "invalid path step {{hint}}: paths cannot contain nulls, strings, numbers or booleans",
-	ErrIllegalAssignment:  "illegal assignment: {{hint}} is not a variable",
-	ErrIllegalParam:       "illegal function parameter: {{token}} is not a variable",
-	ErrDuplicateParam:     "duplicate function parameter: {{token}}",
-	ErrParamCount:         "invalid type signature: number of types must match number of function parameters",
-	ErrInvalidUnionType:   "invalid type signature: unsupported union type '{{hint}}'",
-	ErrUnmatchedOption:    "invalid type signature: option '{{hint}}' must follow a parameter",
-	ErrUnmatchedSubtype:   "invalid type signature: subtypes must follow a parameter",
-	ErrInvalidSubtype:     "invalid type signature: parameter type {{hint}} does not support subtypes",
-	ErrInvalidParamType:   "invalid type signature: unknown parameter type '{{hint}}'",
+	ErrSyntaxError:         "syntax error: '{{token}}'",
+	ErrUnexpectedEOF:       "unexpected end of expression",
+	ErrUnexpectedToken:     "expected token '{{hint}}', got '{{token}}'",
+	ErrMissingToken:        "expected token '{{hint}}' before end of expression",
+	ErrPrefix:              "the symbol '{{token}}' cannot be used as a prefix operator",
+	ErrInfix:               "the symbol '{{token}}' cannot be used as an infix operator",
+	ErrUnterminatedString:  "unterminated string literal (no closing '{{hint}}')",
+	ErrUnterminatedRegex:   "unterminated regular expression (no closing '{{hint}}')",
+	ErrUnterminatedName:    "unterminated name (no closing '{{hint}}')",
+	ErrIllegalEscape:       "illegal escape sequence \\{{hint}}",
+	ErrIllegalEscapeHex:    "illegal escape sequence \\{{hint}}: \\u must be followed by a 4-digit hexadecimal code point",
+	ErrInvalidNumber:       "invalid number literal {{token}}",
+	ErrNumberRange:         "invalid number literal {{token}}: value out of range",
+	ErrEmptyRegex:          "invalid regular expression: expression cannot be empty",
+	ErrInvalidRegex:        "invalid regular expression {{token}}: {{hint}}",
+	ErrGroupPredicate:      "a predicate cannot follow a grouping expression in a path step",
+	ErrGroupGroup:          "a path step can only have one grouping expression",
+	ErrPathLiteral:         "invalid path step {{hint}}: paths cannot contain nulls, strings, numbers or booleans",
+	ErrIllegalAssignment:   "illegal assignment: {{hint}} is not a variable",
+	ErrIllegalParam:        "illegal function parameter: {{token}} is not a variable",
+	ErrDuplicateParam:      "duplicate function parameter: {{token}}",
+	ErrParamCount:          "invalid type signature: number of types must match number of function parameters",
+	ErrInvalidUnionType:    "invalid type signature: unsupported union type '{{hint}}'",
+	ErrUnmatchedOption:     "invalid type signature: option '{{hint}}' must follow a parameter",
+	ErrUnmatchedSubtype:    "invalid type signature: subtypes must follow a parameter",
+	ErrInvalidSubtype:      "invalid type signature: parameter type {{hint}} does not support subtypes",
+	ErrInvalidParamType:    "invalid type signature: unknown parameter type '{{hint}}'",
+	ErrIllegalBinding:      "the right side of the '{{token}}' operator must be a variable, got {{hint}}",
+	ErrUnterminatedComment: "unterminated comment (no closing '{{hint}}')",
+	ErrNoParent:            "the parent operator '{{token}}' cannot be used here: the context value has no parent",
+	ErrCommentPosition:     "cannot format the comment {{token}}: comments must be placed between the items of a block, array, object, function call, conditional or function chain",
+}
+
+// errcodes maps error types to the corresponding jsonata-js
+// error codes. Where jsonata-js has no exact equivalent, the
+// closest code is used.
+var errcodes = map[ErrType]string{
+	ErrSyntaxError:         "S0201",
+	ErrUnexpectedEOF:       "S0207",
+	ErrUnexpectedToken:     "S0202",
+	ErrMissingToken:        "S0203",
+	ErrPrefix:              "S0211",
+	ErrInfix:               "S0204",
+	ErrUnterminatedString:  "S0101",
+	ErrUnterminatedRegex:   "S0302",
+	ErrUnterminatedName:    "S0105",
+	ErrIllegalEscape:       "S0103",
+	ErrIllegalEscapeHex:    "S0104",
+	ErrInvalidNumber:       "S0201",
+	ErrNumberRange:         "S0102",
+	ErrEmptyRegex:          "S0301",
+	ErrInvalidRegex:        "S0302",
+	ErrGroupPredicate:      "S0209",
+	ErrGroupGroup:          "S0210",
+	ErrPathLiteral:         "S0213",
+	ErrIllegalAssignment:   "S0212",
+	ErrIllegalParam:        "S0208",
+	ErrDuplicateParam:      "S0208",
+	ErrParamCount:          "S0401",
+	ErrInvalidUnionType:    "S0402",
+	ErrUnmatchedOption:     "S0401",
+	ErrUnmatchedSubtype:    "S0401",
+	ErrInvalidSubtype:      "S0401",
+	ErrInvalidParamType:    "S0401",
+	ErrIllegalBinding:      "S0214",
+	ErrUnterminatedComment: "S0106",
+	ErrNoParent:            "S0217",
+	ErrCommentPosition:     "S0201",
 }
 
 var reErrMsg = regexp.MustCompile("{{(token|hint)}}")
 
-// Error describes an error during parsing.
+// Error describes an error during parsing. Position is the
+// byte offset of the error in the expression. The embedded
+// Span adds line and column information.
 type Error struct {
 	Type     ErrType
 	Token    string
 	Hint     string
 	Position int
+	Span
 }
 
 func newError(typ ErrType, tok token) error {
@@ -116,6 +164,12 @@ func (e Error) Error() string {
 	})
 }
 
+// Code returns the jsonata-js error code for the error, e.g.
+// "S0201" for a syntax error.
+func (e Error) Code() string {
+	return errcodes[e.Type]
+}
+
 func panicf(format string, a ...interface{}) {
 	panic(fmt.Sprintf(format, a...))
 }
@@ -0,0 +1,696 @@
+// Copyright 2018 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package jparse
+
+import (
+	"bytes"
+	"encoding/json"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FormatOptions controls the output of Format.
+type FormatOptions struct {
+
+	// Indent is the string used for each level of indentation.
+	// The default is two spaces.
+	Indent string
+
+	// MaxWidth is the preferred maximum line length. Expressions
+	// that fit within this width (allowing for indentation) are
+	// printed on a single line. The default is 80.
+	MaxWidth int
+
+	// Comments are the comments returned by ParseWithComments.
+	// Each comment is printed on its own line before the next
+	// block expression, object pair, array item, function
+	// argument, function body, conditional branch or function
+	// application that follows it. Comments that follow the
+	// last of these are printed at the end of the enclosing
+	// construct. Comments anywhere else (e.g. between the
+	// operands of a binary operator) cannot be printed in
+	// their original position and cause Format to fail.
+	Comments []Comment
+}
+
+// Format returns a canonical, multi-line rendering of a syntax
+// tree. The output for a given tree and set of options is always
+// the same and parsing it produces an equivalent syntax tree.
+//
+// Blocks with more than one expression are always printed with
+// one expression per line. Object constructors, arrays, function
+// calls, conditionals, lambdas and chains of the function
+// application operator (~>) are printed on a single line if they
+// fit within the maximum width, and are broken across multiple
+// lines otherwise. Number literals are printed as they appear in
+// the source, so that no precision is lost. The returned string
+// does not end in a newline.
+//
+// Format returns an error if one of the comments in opts is in a
+// position that the formatted output cannot preserve.
+func Format(node Node, opts FormatOptions) (string, error) {
+
+	for _, c := range opts.Comments {
+		if contains(node, c.Position) && !commentInPlace(node, c.Position) {
+			return "", &Error{
+				Type:     ErrCommentPosition,
+				Token:    c.Text,
+				Position: c.Position,
+			}
+		}
+	}
+
+	p := &printer{
+		indent:   opts.Indent,
+		width:    opts.MaxWidth,
+		comments: append([]Comment(nil), opts.Comments...),
+	}
+
+	if p.indent == "" {
+		p.indent = "  "
+	}
+
+	if p.width <= 0 {
+		p.width = 80
+	}
+
+	sort.SliceStable(p.comments, func(i, j int) bool {
+		return p.comments[i].Position < p.comments[j].Position
+	})
+
+	s := strings.TrimPrefix(p.commentsBefore(start(node))+p.newline(), "\n")
+	s += p.node(node)
+	s += p.commentsBefore(math.MaxInt32)
+
+	return s, nil
+}
+
+type printer struct {
+	indent   string
+	width    int
+	comments []Comment
+	depth    int
+}
+
+// node returns the rendering of a node at the current depth.
+// The node is printed on a single line if possible.
+func (p *printer) node(n Node) string {
+
+	if !mustBreak(n) && !p.hasComments(n) {
+		if s := p.render(n, false); p.fits(s) {
+			return s
+		}
+	}
+
+	return p.render(n, true)
+}
+
+// render returns the rendering of a node. If broken is false,
+// the node and its descendants are printed on a single line.
+// Otherwise, the node is broken across multiple lines (if it
+// supports it) and its children are printed by p.node.
+func (p *printer) render(n Node, broken bool) string {
+
+	child := p.inline
+	if broken {
+		child = p.node
+	}
+
+	switch n := n.(type) {
+	case *StringNode:
+		return quote(n.Value)
+
+	case *NumberNode:
+		if n.Literal != "" {
+			return n.Literal
+		}
+		return formatNumber(n.Value)
+
+	case *BooleanNode, *NullNode, *VariableNode, *NameNode,
+		*WildcardNode, *DescendentNode, *ParentNode, *PlaceholderNode:
+		return n.String()
+
+	case *RegexNode:
+		return formatRegex(n)
+
+	case *PathNode:
+		steps := make([]string, len(n.Steps))
+		for i, step := range n.Steps {
+			steps[i] = child(step)
+		}
+		s := strings.Join(steps, ".")
+		if n.KeepArrays {
+			s += "[]"
+		}
+		return s
+
+	case *FocusNode:
+		return child(n.Expr) + "@$" + n.Name
+
+	case *IndexNode:
+		return child(n.Expr) + "#$" + n.Name
+
+	case *NegationNode:
+		return "-" + child(n.RHS)
+
+	case *RangeNode:
+		return child(n.LHS) + ".." + child(n.RHS)
+
+	case *ArrayNode:
+		if broken {
+			return p.list("[", "]", ",", n.Items, end(n))
+		}
+		return "[" + p.join(n.Items, ", ", child) + "]"
+
+	case *ObjectNode:
+		if broken && (len(n.Pairs) > 0 || p.hasComments(n)) {
+			return p.pairs(n)
+		}
+		pairs := make([]string, len(n.Pairs))
+		for i, pair := range n.Pairs {
+			pairs[i] = child(pair[0]) + ": " + child(pair[1])
+		}
+		return "{" + strings.Join(pairs, ", ") + "}"
+
+	case *BlockNode:
+		if broken {
+			return p.list("(", ")", ";", n.Exprs, end(n))
+		}
+		return "(" + p.join(n.Exprs, "; ", child) + ")"
+
+	case *ObjectTransformationNode:
+		s := "| " + child(n.Pattern) + " | " + child(n.Updates)
+		if n.Deletes != nil {
+			s += ", " + child(n.Deletes)
+		}
+		return s + " |"
+
+	case *LambdaNode:
+		return p.lambda(n, "", broken)
+
+	case *TypedLambdaNode:
+		return p.lambda(n.LambdaNode, n.signature(), broken)
+
+	case *PartialNode:
+		return p.call(n, n.Func, n.Args, broken)
+
+	case *FunctionCallNode:
+		return p.call(n, n.Func, n.Args, broken)
+
+	case *PredicateNode:
+		s := child(n.Expr)
+		for _, f := range n.Filters {
+			s += "[" + child(f) + "]"
+		}
+		return s
+
+	case *GroupNode:
+		return child(n.Expr) + child(n.ObjectNode)
+
+	case *ConditionalNode:
+		if broken {
+			return p.conditional(n)
+		}
+		s := child(n.If) + " ? " + child(n.Then)
+		if n.Else != nil {
+			s += " : " + child(n.Else)
+		}
+		return s
+
+	case *AssignmentNode:
+		return "$" + n.Name + " := " + child(n.Value)
+
+	case *NumericOperatorNode:
+		return child(n.LHS) + " " + n.Type.String() + " " + child(n.RHS)
+
+	case *ComparisonOperatorNode:
+		return child(n.LHS) + " " + n.Type.String() + " " + child(n.RHS)
+
+	case *BooleanOperatorNode:
+		return child(n.LHS) + " " + n.Type.String() + " " + child(n.RHS)
+
+	case *CoalescingOperatorNode:
+		return child(n.LHS) + " " + n.Type.String() + " " + child(n.RHS)
+
+	case *StringConcatenationNode:
+		return child(n.LHS) + " & " + child(n.RHS)
+
+	case *SortNode:
+		terms := make([]string, len(n.Terms))
+		for i, term := range n.Terms {
+			var sym string
+			switch term.Dir {
+			case SortAscending:
+				sym = "<"
+			case SortDescending:
+				sym = ">"
+			}
+			terms[i] = sym + child(term.Expr)
+		}
+		return child(n.Expr) + "^(" + strings.Join(terms, ", ") + ")"
+
+	case *FunctionApplicationNode:
+		if broken {
+			return p.chain(n)
+		}
+		return child(n.LHS) + " ~> " + child(n.RHS)
+
+	default:
+		panicf("jparse.Format: unexpected node type %T", n)
+		return ""
+	}
+}
+
+func (p *printer) inline(n Node) string {
+	return p.render(n, false)
+}
+
+func (p *printer) join(nodes []Node, sep string, child func(Node) string) string {
+
+	values := make([]string, len(nodes))
+
+	for i, n := range nodes {
+		values[i] = child(n)
+	}
+
+	return strings.Join(values, sep)
+}
+
+// list prints a list of nodes with one node per line, e.g.
+// the expressions in a block or the items in an array.
+func (p *printer) list(open, close, sep string, nodes []Node, end int) string {
+
+	s := open
+
+	if len(nodes) == 0 && !p.hasCommentsBefore(end) {
+		return s + close
+	}
+
+	p.depth++
+	for i, n := range nodes {
+		s += p.commentsBefore(start(n)) + p.newline() + p.node(n)
+		if i < len(nodes)-1 {
+			s += sep
+		}
+	}
+	s += p.commentsBefore(end)
+	p.depth--
+
+	return s + p.newline() + close
+}
+
+// pairs prints the key-value pairs of an object constructor
+// with one pair per line.
+func (p *printer) pairs(n *ObjectNode) string {
+
+	s := "{"
+
+	p.depth++
+	for i, pair := range n.Pairs {
+		s += p.commentsBefore(start(pair[0])) + p.newline()
+		s += p.node(pair[0]) + ": " + p.node(pair[1])
+		if i < len(n.Pairs)-1 {
+			s += ","
+		}
+	}
+	s += p.commentsBefore(end(n))
+	p.depth--
+
+	return s + p.newline() + "}"
+}
+
+// call prints a function call or partial application. If the
+// last argument is a structure that can be broken across lines
+// (e.g. a lambda or object), the other arguments are kept on
+// the first line. Otherwise each argument is printed on its
+// own line.
+func (p *printer) call(n Node, fn Node, args []Node, broken bool) string {
+
+	if !broken {
+		return p.inline(fn) + "(" + p.join(args, ", ", p.inline) + ")"
+	}
+
+	s := p.node(fn) + "("
+
+	if last := len(args) - 1; last >= 0 && isHuggable(args[last]) && !p.hasComments(n) {
+		head := args[:last]
+		ok := true
+		for _, arg := range head {
+			if mustBreak(arg) {
+				ok = false
+				break
+			}
+		}
+		if ok {
+			prefix := s + p.join(head, ", ", p.inline)
+			if len(head) > 0 {
+				prefix += ", "
+			}
+			rest := p.node(args[last])
+			if p.fits(firstLine(prefix + rest)) {
+				return prefix + rest + ")"
+			}
+		}
+	}
+
+	return p.list(s, ")", ",", args, end(n))
+}
+
+// lambda prints a function definition, breaking the body onto
+// its own line(s) if broken is true.
+func (p *printer) lambda(n *LambdaNode, sig string, broken bool) string {
+
+	s := "function"
+	if n.shorthand {
+		s = "λ"
+	}
+
+	names := make([]string, len(n.ParamNames))
+	for i, name := range n.ParamNames {
+		names[i] = "$" + name
+	}
+	s += "(" + strings.Join(names, ", ") + ")" + sig
+
+	if !broken {
+		return s + " { " + p.inline(n.Body) + " }"
+	}
+
+	s += " {"
+
+	p.depth++
+	s += p.commentsBefore(start(n.Body)) + p.newline() + p.node(n.Body)
+	s += p.commentsBefore(end(n))
+	p.depth--
+
+	return s + p.newline() + "}"
+}
+
+// conditional prints a conditional expression with the "then"
+// and "else" branches on separate, indented lines.
+func (p *printer) conditional(n *ConditionalNode) string {
+
+	s := p.node(n.If)
+
+	p.depth++
+	s += p.commentsBefore(start(n.Then)) + p.newline() + "? " + p.node(n.Then)
+	if n.Else != nil {
+		s += p.commentsBefore(start(n.Else)) + p.newline() + ": " + p.node(n.Else)
+	}
+	p.depth--
+
+	return s
+}
+
+// chain prints a sequence of function applications (e.g.
+// "a ~> $f ~> $g") with each function on its own line.
+func (p *printer) chain(n *FunctionApplicationNode) string {
+
+	var funcs []Node
+	var head Node = n
+
+	for {
+		app, ok := head.(*FunctionApplicationNode)
+		if !ok {
+			break
+		}
+		funcs = append(funcs, app.RHS)
+		head = app.LHS
+	}
+
+	s := p.node(head)
+
+	p.depth++
+	for i := len(funcs) - 1; i >= 0; i-- {
+		s += p.commentsBefore(start(funcs[i])) + p.newline() + "~> " + p.node(funcs[i])
+	}
+	p.depth--
+
+	return s
+}
+
+func (p *printer) newline() string {
+	return "\n" + strings.Repeat(p.indent, p.depth)
+}
+
+// fits returns true if s can be printed on a single line at
+// the current depth.
+func (p *printer) fits(s string) bool {
+	if strings.ContainsRune(s, '\n') {
+		return false
+	}
+	return p.depth*utf8.RuneCountInString(p.indent)+utf8.RuneCountInString(s) <= p.width
+}
+
+// hasComments returns true if there are unprinted comments
+// within the source text of a node.
+func (p *printer) hasComments(n Node) bool {
+
+	span := n.Location()
+	if !span.IsValid() {
+		return false
+	}
+
+	for _, c := range p.comments {
+		if c.Position >= span.Start.Offset && c.Position < span.End.Offset {
+			return true
+		}
+	}
+
+	return false
+}
+
+// hasCommentsBefore returns true if there are unprinted
+// comments that start before the given offset.
+func (p *printer) hasCommentsBefore(offset int) bool {
+	return len(p.comments) > 0 && p.comments[0].Position < offset
+}
+
+// commentsBefore removes the unprinted comments that start
+// before the given offset and returns them, each on a new
+// line.
+func (p *printer) commentsBefore(offset int) string {
+
+	var s string
+
+	for p.hasCommentsBefore(offset) {
+		s += p.newline() + p.comments[0].Text
+		p.comments = p.comments[1:]
+	}
+
+	return s
+}
+
+// commentInPlace returns true if a comment at the given offset
+// in the source text of a node is printed in the same position
+// relative to the surrounding nodes, i.e. if it falls between
+// two of the items that the printer puts on separate lines.
+func commentInPlace(n Node, pos int) bool {
+
+	var items []Node
+
+	switch n := n.(type) {
+	case *BlockNode:
+		items = n.Exprs
+
+	case *ArrayNode:
+		items = n.Items
+
+	case *ObjectNode:
+		for _, pair := range n.Pairs {
+			for _, item := range pair {
+				if contains(item, pos) {
+					return commentInPlace(item, pos)
+				}
+			}
+			if start(pair[0]) <= pos && pos < end(pair[1]) {
+				// Between a key and its value.
+				return false
+			}
+		}
+		return true
+
+	case *FunctionCallNode:
+		items = append([]Node{n.Func}, n.Args...)
+
+	case *PartialNode:
+		items = append([]Node{n.Func}, n.Args...)
+
+	case *LambdaNode:
+		items = []Node{n.Body}
+
+	case *TypedLambdaNode:
+		items = []Node{n.Body}
+
+	case *ConditionalNode:
+		items = []Node{n.If, n.Then}
+		if n.Else != nil {
+			items = append(items, n.Else)
+		}
+
+	case *FunctionApplicationNode:
+		var head Node = n
+		for {
+			app, ok := head.(*FunctionApplicationNode)
+			if !ok {
+				break
+			}
+			items = append([]Node{app.RHS}, items...)
+			head = app.LHS
+		}
+		items = append([]Node{head}, items...)
+
+	default:
+		// Other nodes are printed without line breaks between
+		// their children, so the comment must be inside one of
+		// the children.
+		for _, child := range children(n) {
+			if contains(child, pos) {
+				return commentInPlace(child, pos)
+			}
+		}
+		return false
+	}
+
+	for _, item := range items {
+		if contains(item, pos) {
+			return commentInPlace(item, pos)
+		}
+	}
+
+	return true
+}
+
+// children returns the child nodes of a node.
+func children(n Node) []Node {
+
+	var nodes []Node
+
+	Inspect(n, func(child Node) bool {
+		if child == n {
+			return true
+		}
+		if child != nil {
+			nodes = append(nodes, child)
+		}
+		return false
+	})
+
+	return nodes
+}
+
+// contains returns true if the given offset is within the
+// source text of a node.
+func contains(n Node, pos int) bool {
+	return start(n) <= pos && pos < end(n)
+}
+
+// mustBreak returns true if a node cannot be printed on a
+// single line, i.e. if it contains a block with more than
+// one expression.
+func mustBreak(node Node) bool {
+
+	var found bool
+
+	Inspect(node, func(n Node) bool {
+		if b, ok := n.(*BlockNode); ok && len(b.Exprs) > 1 {
+			found = true
+		}
+		return !found
+	})
+
+	return found
+}
+
+// isHuggable returns true for function arguments that may be
+// broken across lines while the rest of the function call stays
+// on the first line.
+func isHuggable(n Node) bool {
+	switch n.(type) {
+	case *LambdaNode, *TypedLambdaNode, *ObjectNode, *ArrayNode, *BlockNode:
+		return true
+	default:
+		return false
+	}
+}
+
+func firstLine(s string) string {
+	if pos := strings.IndexByte(s, '\n'); pos >= 0 {
+		return s[:pos]
+	}
+	return s
+}
+
+// start returns the offset of the start of a node, or -1 if
+// the node has no source location.
+func start(n Node) int {
+	if span := n.Location(); span.IsValid() {
+		return span.Start.Offset
+	}
+	return -1
+}
+
+// end returns the offset of the end of a node, or -1 if the
+// node has no source location.
+func end(n Node) int {
+	if span := n.Location(); span.IsValid() {
+		return span.End.Offset
+	}
+	return -1
+}
+
+// quote returns a JSONata string literal for s. Unlike
+// StringNode.String, it only uses escape sequences that the
+// JSONata lexer understands.
+func quote(s string) string {
+
+	var b bytes.Buffer
+
+	enc := json.NewEncoder(&b)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(s); err != nil {
+		panicf("jparse.Format: cannot quote string %q: %s", s, err)
+	}
+
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// formatNumber formats a number like JavaScript's toString,
+// i.e. without an exponent unless the number is very large or
+// very small.
+func formatNumber(f float64) string {
+
+	if abs := math.Abs(f); abs != 0 && (abs >= 1e21 || abs < 1e-6) {
+		s := strconv.FormatFloat(f, 'e', -1, 64)
+		s = strings.Replace(s, "e+0", "e+", 1)
+		s = strings.Replace(s, "e-0", "e-", 1)
+		return s
+	}
+
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+var reRegexFlags = regexp.MustCompile(`^\(\?([ims]+)\)`)
+
+// formatRegex returns a JSONata regex literal. Flags added
+// by the lexer (e.g. "(?i)") are converted back to suffixes.
+func formatRegex(n *RegexNode) string {
+
+	var expr string
+	if n.Value != nil {
+		expr = n.Value.String()
+	}
+
+	var flags string
+	if m := reRegexFlags.FindStringSubmatch(expr); m != nil {
+		flags = m[1]
+		expr = expr[len(m[0]):]
+	}
+
+	return "/" + expr + "/" + flags
+}
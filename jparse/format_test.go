@@ -0,0 +1,302 @@
+// Copyright 2018 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package jparse_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/blues/jsonata-go/jparse"
+)
+
+type formatTestCase struct {
+	Input   string
+	Options jparse.FormatOptions
+	Output  string
+}
+
+func TestFormat(t *testing.T) {
+	testFormat(t, []formatTestCase{
+		{
+			Input:  `a.b[0]`,
+			Output: `a.b[0]`,
+		},
+		{
+			Input:  `a[0][1]`,
+			Output: `a[0][1]`,
+		},
+		{
+			Input:  `"a\u0001\"b\n" & 123456789 & 0.000001 & 1e300 & -1.5e-7`,
+			Output: `"a\u0001\"b\n" & 123456789 & 0.000001 & 1e300 & -1.5e-7`,
+		},
+		{
+			Input:  `12345678901234567891 + 1.50 - -2E+2`,
+			Output: `12345678901234567891 + 1.50 - -2E+2`,
+		},
+		{
+			Input:  `/ab+/i`,
+			Output: `/ab+/i`,
+		},
+		{
+			Input:  `($x := 1; $y := 2; $x + $y)`,
+			Output: "(\n  $x := 1;\n  $y := 2;\n  $x + $y\n)",
+		},
+		{
+			Input:  `(1 + 2) * 3`,
+			Output: `(1 + 2) * 3`,
+		},
+		{
+			Input:  `{"a": 1, "b": [1,2]}`,
+			Output: `{"a": 1, "b": [1, 2]}`,
+		},
+		{
+			Input: `Account.Order.Product{"name": $.ProductName, "total": Price * Quantity, "description": Description.Colour}`,
+			Output: `Account.Order.Product{
+  "name": $.ProductName,
+  "total": Price * Quantity,
+  "description": Description.Colour
+}`,
+		},
+		{
+			Input: `$map(Account.Order.Product, function($p) { $p.Price * $p.Quantity > 100 ? "expensive product" : "cheap product" })`,
+			Output: `$map(Account.Order.Product, function($p) {
+  $p.Price * $p.Quantity > 100 ? "expensive product" : "cheap product"
+})`,
+		},
+		{
+			Input: `Price * Quantity > 100 ? "an expensive product that costs a lot" : "a cheap product that costs a little"`,
+			Output: `Price * Quantity > 100
+  ? "an expensive product that costs a lot"
+  : "a cheap product that costs a little"`,
+		},
+		{
+			Input: `Account.Order ~> $map(function($o) { $o.Product }) ~> $filter(function($p) { $p.Price > 30 }) ~> $sort()`,
+			Output: `Account.Order
+  ~> $map(function($o) { $o.Product })
+  ~> $filter(function($p) { $p.Price > 30 })
+  ~> $sort()`,
+		},
+		{
+			Input: `$f(argument1, argument2, argument3, argument4, argument5, argument6, argument7, argument8)`,
+			Output: `$f(
+  argument1,
+  argument2,
+  argument3,
+  argument4,
+  argument5,
+  argument6,
+  argument7,
+  argument8
+)`,
+		},
+		{
+			Input:  `λ($x)<n:n>{$x * 2}`,
+			Output: `λ($x)<n:n> { $x * 2 }`,
+		},
+		{
+			// Return types, including those of function
+			// parameters, are kept.
+			Input:  `function($a, $f)<a<n>f<n:n>:a<n>>{$map($a, $f)}`,
+			Output: `function($a, $f)<a<n>f<n:n>:a<n>> { $map($a, $f) }`,
+		},
+		{
+			Input: `$f := function($x) { ($y := $x * 2; $y + 1) }`,
+			Output: `$f := function($x) {
+  (
+    $y := $x * 2;
+    $y + 1
+  )
+}`,
+		},
+		{
+			Input:   `{"a": 1, "b": 2}`,
+			Options: jparse.FormatOptions{MaxWidth: 10, Indent: "\t"},
+			Output:  "{\n\t\"a\": 1,\n\t\"b\": 2\n}",
+		},
+		{
+			Input:  `/* header */ a + b /* trailer */`,
+			Output: "/* header */\na + b\n/* trailer */",
+		},
+		{
+			Input:  `(a; /* before b */ b; c /* after c */)`,
+			Output: "(\n  a;\n  /* before b */\n  b;\n  c\n  /* after c */\n)",
+		},
+		{
+			Input:  `{"a": 1 /* one */}`,
+			Output: "{\n  \"a\": 1\n  /* one */\n}",
+		},
+		{
+			Input:  `{/* empty */}`,
+			Output: "{\n  /* empty */\n}",
+		},
+		{
+			Input:  `$x + $f(/* one */ 1)`,
+			Output: "$x + $f(\n  /* one */\n  1\n)",
+		},
+		{
+			Input:  `$x ? /* yes */ 1 : /* no */ 2`,
+			Output: "$x\n  /* yes */\n  ? 1\n  /* no */\n  : 2",
+		},
+		{
+			Input:  `$f(/* no args */)`,
+			Output: "$f(\n  /* no args */\n)",
+		},
+		{
+			Input:  `[/* empty */]`,
+			Output: "[\n  /* empty */\n]",
+		},
+	})
+}
+
+func TestFormatCommentPosition(t *testing.T) {
+
+	data := []struct {
+		Input    string
+		Position int
+	}{
+		{
+			Input:    `1 + /* c */ 2`,
+			Position: 4,
+		},
+		{
+			Input:    `{"a": /* c */ 1}`,
+			Position: 6,
+		},
+		{
+			Input:    `a./* c */b`,
+			Position: 2,
+		},
+		{
+			Input:    `[1, a[/* c */ 0]]`,
+			Position: 6,
+		},
+		{
+			Input:    `$f(1) ~> $g(2 /* c */ * 3)`,
+			Position: 14,
+		},
+	}
+
+	for _, test := range data {
+
+		root, comments, err := jparse.ParseWithComments(test.Input)
+		if err != nil {
+			t.Fatalf("%s: ParseWithComments: %s", test.Input, err)
+		}
+
+		_, err = jparse.Format(root, jparse.FormatOptions{
+			Comments: comments,
+		})
+
+		e, ok := err.(*jparse.Error)
+		if !ok || e.Type != jparse.ErrCommentPosition || e.Position != test.Position {
+			t.Errorf("%s: expected ErrCommentPosition at position %d, got %v", test.Input, test.Position, err)
+		}
+	}
+}
+
+func TestFormatRoundTrip(t *testing.T) {
+
+	inputs := []string{
+		`(
+			$x := -a.b[0]{"k": %.v};
+			$f := λ($v)<n:n>{ $v * 2 };
+			$g := $f(?);
+			[1..3, "s", true, null, /re/i] ~> $map(function($i) { $i ? $i : **.c });
+			$x ?? *.d & "e" ?: false;
+			a@$l#$i.$l^(>c, <d, e);
+			| x | {"y": 1}, ["z"] |;
+			| x | {"y": 2} |;
+			$x = 1 and $x != 2 or $x in [3, 4]
+		)`,
+		`[]`,
+		`{}`,
+		`()`,
+		`a[]`,
+		`a[].b`,
+		"`escaped name`.x",
+		`λ($a, $f)<a<n>f<n:n>:a>{$map($a, $f)}`,
+		`a.b[[1, 2]][0]`,
+		`x.y{z: $}`,
+		`-(1 + 2)`,
+		`"😀  "`,
+		`/a\/b[/]/ms`,
+		`library.loans@$l.books@$b[$l.isbn=$b.isbn].{"title": $b.title, "customer": $l.customer}`,
+		`$reduce(Account.Order.Product, function($acc, $p) { $acc + $p.Price * $p.Quantity }, 0) > 1000 ? "a very large order indeed" : ($n := $count(Account.Order); $n > 2 ? "several orders" : "a few orders")`,
+		`Account.Order[0].Product ~> $map(function($p, $i) { {"index": $i, "name": $p.` + "`Product Name`" + `, "price": $p.Price, "quantity": $p.Quantity} })`,
+	}
+
+	for _, input := range inputs {
+		for _, opts := range []jparse.FormatOptions{
+			{},
+			{MaxWidth: 1},
+			{MaxWidth: 1000, Indent: "\t"},
+		} {
+
+			root, err := jparse.Parse(input)
+			if err != nil {
+				t.Fatalf("%s: Parse: %s", input, err)
+			}
+
+			output, err := jparse.Format(root, opts)
+			if err != nil {
+				t.Fatalf("%s: Format: %s", input, err)
+			}
+
+			got, err := jparse.Parse(output)
+			if err != nil {
+				t.Errorf("%s: cannot parse formatted output %q: %s", input, output, err)
+				continue
+			}
+
+			clearSpans(reflect.ValueOf(root))
+			clearSpans(reflect.ValueOf(got))
+
+			if !reflect.DeepEqual(got, root) {
+				t.Errorf("%s: formatted output %q is not equivalent to input", input, output)
+			}
+
+			if again, _ := jparse.Format(got, opts); again != output {
+				t.Errorf("%s: formatting is not idempotent: %q became %q", input, output, again)
+			}
+		}
+	}
+}
+
+func testFormat(t *testing.T, data []formatTestCase) {
+
+	for _, test := range data {
+
+		root, comments, err := jparse.ParseWithComments(test.Input)
+		if err != nil {
+			t.Fatalf("%s: ParseWithComments: %s", test.Input, err)
+		}
+
+		opts := test.Options
+		opts.Comments = comments
+
+		output, err := jparse.Format(root, opts)
+		if err != nil {
+			t.Errorf("%s: Format: %s", test.Input, err)
+			continue
+		}
+
+		if output != test.Output {
+			t.Errorf("%s: expected output:\n%s\ngot:\n%s", test.Input, test.Output, output)
+		}
+
+		// Formatting the output should not change it.
+		root, comments, err = jparse.ParseWithComments(output)
+		if err != nil {
+			t.Errorf("%s: cannot parse formatted output: %s", test.Input, err)
+			continue
+		}
+
+		opts.Comments = comments
+
+		if again, _ := jparse.Format(root, opts); again != output {
+			t.Errorf("%s: formatting is not idempotent: expected:\n%s\ngot:\n%s", test.Input, output, again)
+		}
+	}
+}
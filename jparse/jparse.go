@@ -49,6 +49,7 @@ var nuds = [...]nud{
 	typeBraceOpen:   parseObject,
 	typeParenOpen:   parseBlock,
 	typeMult:        parseWildcard,
+	typeMod:         parseParent,
 	typeMinus:       parseNegation,
 	typeDescendent:  parseDescendent,
 	typePipe:        parseObjectTransformation,
@@ -83,6 +84,10 @@ var leds = [...]led{
 	typeIn:           parseComparisonOperator,
 	typeAnd:          parseBooleanOperator,
 	typeOr:           parseBooleanOperator,
+	typeFocus:        parseFocus,
+	typeIndex:        parseIndex,
+	typeCoalesce:     parseCoalescingOperator,
+	typeElvis:        parseCoalescingOperator,
 }
 
 // bps defines binding powers for token types that are valid
@@ -98,6 +103,8 @@ var bps = initBindingPowers([][]tokenType{
 	{
 		typeParenOpen,
 		typeBracketOpen,
+		typeFocus,
+		typeIndex,
 	},
 	{
 		typeDot,
@@ -125,6 +132,8 @@ var bps = initBindingPowers([][]tokenType{
 		typeIn,
 		typeSort,
 		typeApply,
+		typeCoalesce,
+		typeElvis,
 	},
 	{
 		typeAnd,
@@ -170,31 +179,63 @@ func lookupBp(tt tokenType) int {
 // and returns the root node. If the provided expression is not
 // valid, Parse returns an error of type Error.
 func Parse(expr string) (root Node, err error) {
+	root, _, err = ParseWithComments(expr)
+	return root, err
+}
+
+// A Comment represents a block comment in a JSONata expression.
+type Comment struct {
+	// Text is the text of the comment, including the opening
+	// "/*" and closing "*/" delimiters.
+	Text string
+
+	// Position is the byte offset of the start of the comment
+	// in the original expression.
+	Position int
+}
+
+// ParseWithComments is like Parse except that it also returns
+// the comments in the expression, in the order in which they
+// appear.
+func ParseWithComments(expr string) (root Node, comments []Comment, err error) {
+
+	p := newParser(expr)
 
 	// Handle panics from parseExpression.
 	defer func() {
 		if r := recover(); r != nil {
 			if e, ok := r.(*Error); ok {
-				root, err = nil, e
+				root, comments, err = nil, nil, p.locate(e)
 				return
 			}
 			panic(r)
 		}
 	}()
 
-	p := newParser(expr)
+	// Set current token to the first token in the expression.
+	p.advance(true)
 	node := p.parseExpression(0)
 
 	if p.token.Type != typeEOF {
-		return nil, newError(ErrSyntaxError, p.token)
+		return nil, nil, p.locate(newError(ErrSyntaxError, p.token))
+	}
+
+	root, err = node.optimize()
+	if err != nil {
+		return nil, nil, p.locate(err)
 	}
 
-	return node.optimize()
+	if err = checkParents(root); err != nil {
+		return nil, nil, err
+	}
+
+	return root, p.lexer.comments, nil
 }
 
 type parser struct {
 	lexer lexer
 	token token
+	prev  token
 	// The following function pointers are a workaround
 	// for an initialisation loop compile error. See the
 	// comment in newParser.
@@ -205,7 +246,7 @@ type parser struct {
 
 func newParser(input string) parser {
 
-	p := parser{
+	return parser{
 		lexer: newLexer(input),
 
 		// Because the nuds/leds arrays refer to functions that
@@ -226,10 +267,6 @@ func newParser(input string) parser {
 		lookupLed: lookupLed,
 		lookupBp:  lookupBp,
 	}
-
-	// Set current token to the first token in the expression.
-	p.advance(true)
-	return p
 }
 
 // parseExpression is the central function of the Pratt
@@ -249,6 +286,7 @@ func (p *parser) parseExpression(rbp int) Node {
 	}
 
 	t := p.token
+	start := t.Start
 	p.advance(false)
 
 	nud := p.lookupNud(t.Type)
@@ -261,6 +299,8 @@ func (p *parser) parseExpression(rbp int) Node {
 		panic(err)
 	}
 
+	lhs.setSpan(p.lexer.span(start, p.prev.End))
+
 	for rbp < p.lookupBp(p.token.Type) {
 
 		t := p.token
@@ -275,6 +315,8 @@ func (p *parser) parseExpression(rbp int) Node {
 		if err != nil {
 			panic(err)
 		}
+
+		lhs.setSpan(p.lexer.span(start, p.prev.End))
 	}
 
 	return lhs
@@ -284,6 +326,7 @@ func (p *parser) parseExpression(rbp int) Node {
 // the parser's current token pointer. It panics if the lexer
 // returns an error token.
 func (p *parser) advance(allowRegex bool) {
+	p.prev = p.token
 	p.token = p.lexer.next(allowRegex)
 	if p.token.Type == typeError {
 		panic(p.lexer.err)
@@ -308,6 +351,17 @@ func (p *parser) consume(expected tokenType, allowRegex bool) {
 	p.advance(allowRegex)
 }
 
+// locate sets the Span of a parser error that does not
+// already have one. The Span covers the error's token.
+func (p *parser) locate(err error) error {
+
+	if e, ok := err.(*Error); ok && !e.IsValid() {
+		e.Span = p.lexer.span(e.Position, e.Position+len(e.Token))
+	}
+
+	return err
+}
+
 // bp returns the binding power for the given token type.
 func (p *parser) bp(t tokenType) int {
 	return p.lookupBp(t)
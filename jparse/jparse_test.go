@@ -231,6 +231,58 @@ func TestNumberNode(t *testing.T) {
 	})
 }
 
+func TestNumberLiterals(t *testing.T) {
+
+	data := []struct {
+		Input   string
+		Value   float64
+		Literal string
+	}{
+		{
+			Input:   "1.50",
+			Value:   1.5,
+			Literal: "1.50",
+		},
+		{
+			Input:   "12345678901234567891",
+			Value:   12345678901234567891,
+			Literal: "12345678901234567891",
+		},
+		{
+			Input:   "-2E+2",
+			Value:   -200,
+			Literal: "-2E+2",
+		},
+		{
+			Input:   "--0.1",
+			Value:   0.1,
+			Literal: "0.1",
+		},
+	}
+
+	for _, test := range data {
+
+		node, err := jparse.Parse(test.Input)
+		if err != nil {
+			t.Errorf("%s: %s", test.Input, err)
+			continue
+		}
+
+		number, ok := node.(*jparse.NumberNode)
+		if !ok {
+			t.Errorf("%s: expected a NumberNode, got %T", test.Input, node)
+			continue
+		}
+
+		if number.Value != test.Value {
+			t.Errorf("%s: expected value %g, got %g", test.Input, test.Value, number.Value)
+		}
+		if number.Literal != test.Literal {
+			t.Errorf("%s: expected literal %q, got %q", test.Input, test.Literal, number.Literal)
+		}
+	}
+}
+
 func TestBooleanNode(t *testing.T) {
 	testParser(t, []testCase{
 		{
@@ -539,6 +591,268 @@ func TestDescendentNode(t *testing.T) {
 	})
 }
 
+func TestParentNode(t *testing.T) {
+	testParser(t, []testCase{
+		{
+			Input: "a.%",
+			Output: &jparse.PathNode{
+				Steps: []jparse.Node{
+					&jparse.NameNode{
+						Value: "a",
+					},
+					&jparse.ParentNode{},
+				},
+			},
+		},
+		{
+			Input: "a.b.%.%.Field",
+			Output: &jparse.PathNode{
+				Steps: []jparse.Node{
+					&jparse.NameNode{
+						Value: "a",
+					},
+					&jparse.NameNode{
+						Value: "b",
+					},
+					&jparse.ParentNode{},
+					&jparse.ParentNode{},
+					&jparse.NameNode{
+						Value: "Field",
+					},
+				},
+			},
+		},
+		{
+			Input: "Field[%.id = 1]",
+			Output: &jparse.PathNode{
+				Steps: []jparse.Node{
+					&jparse.PredicateNode{
+						Expr: &jparse.NameNode{
+							Value: "Field",
+						},
+						Filters: []jparse.Node{
+							&jparse.ComparisonOperatorNode{
+								Type: jparse.ComparisonEqual,
+								LHS: &jparse.PathNode{
+									Steps: []jparse.Node{
+										&jparse.ParentNode{},
+										&jparse.NameNode{
+											Value: "id",
+										},
+									},
+								},
+								RHS: &jparse.NumberNode{
+									Value: 1,
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			// % is still the modulo operator in the
+			// infix position.
+			Input: "a.(5 % %)",
+			Output: &jparse.PathNode{
+				Steps: []jparse.Node{
+					&jparse.NameNode{
+						Value: "a",
+					},
+					&jparse.BlockNode{
+						Exprs: []jparse.Node{
+							&jparse.NumericOperatorNode{
+								Type: jparse.NumericModulo,
+								LHS: &jparse.NumberNode{
+									Value: 5,
+								},
+								RHS: &jparse.ParentNode{},
+							},
+						},
+					},
+				},
+			},
+		},
+	})
+}
+
+func TestParentCheck(t *testing.T) {
+
+	data := []struct {
+		Input    string
+		Position int // -1 if the input is valid
+	}{
+		{"%", 0},
+		{"%.Field", 0},
+		{"$.%", 2},
+		{"$$.%", 3},
+		{"5 % %", 4},
+		{"Field.%.%", 8},
+		{"Field[%.%.id = 1]", 8},
+		{"[a, b].%", 7},
+		{"{'x': %}", 6},
+		{"Field.{'x': $$.%}", 15},
+		{"Field.%", -1},
+		{"Field[%.id = 1]", -1},
+		{"Field{%.id: $}", -1},
+		{"Field^(%.id)", -1},
+		// The focus operator does not change the
+		// context value.
+		{"Field@$f.%", 9},
+		{"a.Field@$f.%", -1},
+		{"Field#$i.%", -1},
+		{"Field.$.%", -1},
+		{"Field.$count(%.items)", -1},
+		// The context of a lambda body, an object
+		// transformation or a variable is not known
+		// statically, so it is not checked.
+		{"function() { %.a }", -1},
+		{"| % | {} |", -1},
+		{"$x.%", -1},
+	}
+
+	for _, test := range data {
+
+		_, err := jparse.Parse(test.Input)
+
+		if test.Position < 0 {
+			if err != nil {
+				t.Errorf("%s: expected nil error, got %s", test.Input, err)
+			}
+			continue
+		}
+
+		e, ok := err.(*jparse.Error)
+		if !ok || e.Type != jparse.ErrNoParent || e.Position != test.Position {
+			t.Errorf("%s: expected ErrNoParent at position %d, got %v", test.Input, test.Position, err)
+		}
+	}
+}
+
+func TestFocusNode(t *testing.T) {
+	testParser(t, []testCase{
+		{
+			Input: "loans@$l.books@$b",
+			Output: &jparse.PathNode{
+				Steps: []jparse.Node{
+					&jparse.FocusNode{
+						Expr: &jparse.NameNode{
+							Value: "loans",
+						},
+						Name: "l",
+					},
+					&jparse.FocusNode{
+						Expr: &jparse.NameNode{
+							Value: "books",
+						},
+						Name: "b",
+					},
+				},
+			},
+		},
+		{
+			Input: "books@$b[isbn = $b.isbn]",
+			Output: &jparse.PathNode{
+				Steps: []jparse.Node{
+					&jparse.PredicateNode{
+						Expr: &jparse.FocusNode{
+							Expr: &jparse.NameNode{
+								Value: "books",
+							},
+							Name: "b",
+						},
+						Filters: []jparse.Node{
+							&jparse.ComparisonOperatorNode{
+								Type: jparse.ComparisonEqual,
+								LHS: &jparse.PathNode{
+									Steps: []jparse.Node{
+										&jparse.NameNode{
+											Value: "isbn",
+										},
+									},
+								},
+								RHS: &jparse.PathNode{
+									Steps: []jparse.Node{
+										&jparse.VariableNode{
+											Name: "b",
+										},
+										&jparse.NameNode{
+											Value: "isbn",
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+		{
+			Input: "books@title",
+			Error: &jparse.Error{
+				Type:     jparse.ErrIllegalBinding,
+				Token:    "@",
+				Hint:     "title",
+				Position: 5,
+			},
+		},
+		{
+			Input: "books@$",
+			Error: &jparse.Error{
+				Type:     jparse.ErrIllegalBinding,
+				Token:    "@",
+				Hint:     "$",
+				Position: 5,
+			},
+		},
+	})
+}
+
+func TestIndexNode(t *testing.T) {
+	testParser(t, []testCase{
+		{
+			Input: "items#$i",
+			Output: &jparse.PathNode{
+				Steps: []jparse.Node{
+					&jparse.IndexNode{
+						Expr: &jparse.NameNode{
+							Value: "items",
+						},
+						Name: "i",
+					},
+				},
+			},
+		},
+		{
+			Input: "items[0]#$i",
+			Output: &jparse.PathNode{
+				Steps: []jparse.Node{
+					&jparse.IndexNode{
+						Expr: &jparse.PredicateNode{
+							Expr: &jparse.NameNode{
+								Value: "items",
+							},
+							Filters: []jparse.Node{
+								&jparse.NumberNode{},
+							},
+						},
+						Name: "i",
+					},
+				},
+			},
+		},
+		{
+			Input: "items#1",
+			Error: &jparse.Error{
+				Type:     jparse.ErrIllegalBinding,
+				Token:    "#",
+				Hint:     "1",
+				Position: 5,
+			},
+		},
+	})
+}
+
 func TestObjectTransformationNode(t *testing.T) {
 	testParser(t, []testCase{
 		{
@@ -806,6 +1120,12 @@ func TestTypedLambdaNode(t *testing.T) {
 						Option: jparse.ParamOptional,
 					},
 				},
+				Out: []jparse.Param{
+					{
+						Type: jparse.ParamTypeNumber,
+					},
+				},
+				Signature: "nn?:n",
 			},
 		},
 		{
@@ -828,6 +1148,12 @@ func TestTypedLambdaNode(t *testing.T) {
 						},
 					},
 				},
+				Out: []jparse.Param{
+					{
+						Type: jparse.ParamTypeArray,
+					},
+				},
+				Signature: "a<(ns)>-:a",
 			},
 		},
 		{
@@ -843,44 +1169,44 @@ func TestTypedLambdaNode(t *testing.T) {
 			// Unknown parameter type in signature.
 			Input: "λ($x, $y)<nz?:n>{0}",
 			Error: &jparse.Error{
-				// TODO: Add position info.
-				Type: jparse.ErrInvalidParamType,
-				Hint: "z",
+				Type:     jparse.ErrInvalidParamType,
+				Hint:     "z",
+				Position: 10,
 			},
 		},
 		{
 			// Unknown parameter type in union type.
 			Input: "λ($x, $y)<n(y)?:n>{0}",
 			Error: &jparse.Error{
-				// TODO: Add position info.
-				Type: jparse.ErrInvalidUnionType,
-				Hint: "y",
+				Type:     jparse.ErrInvalidUnionType,
+				Hint:     "y",
+				Position: 10,
 			},
 		},
 		{
 			// Option without a parameter.
 			Input: "λ($x, $y)<+nn?:n>{0}",
 			Error: &jparse.Error{
-				// TODO: Add position info.
-				Type: jparse.ErrUnmatchedOption,
-				Hint: "+",
+				Type:     jparse.ErrUnmatchedOption,
+				Hint:     "+",
+				Position: 10,
 			},
 		},
 		{
 			// Subtype without a parameter.
 			Input: "λ($x, $y)<<x>nn?:n>{0}",
 			Error: &jparse.Error{
-				// TODO: Add position info.
-				Type: jparse.ErrUnmatchedSubtype,
+				Type:     jparse.ErrUnmatchedSubtype,
+				Position: 10,
 			},
 		},
 		{
 			// Subtype on a non-array, non-function parameter.
 			Input: "λ($x, $y)<n<x>n?:n>{0}",
 			Error: &jparse.Error{
-				// TODO: Add position info.
-				Type: jparse.ErrInvalidSubtype,
-				Hint: "n",
+				Type:     jparse.ErrInvalidSubtype,
+				Hint:     "n",
+				Position: 10,
 			},
 		},
 	})
@@ -1121,8 +1447,8 @@ func TestPredicateNode(t *testing.T) {
 		{
 			Input: `path{"one": 1}[0]`,
 			Error: &jparse.Error{
-				// TODO: Get position.
-				Type: jparse.ErrGroupPredicate,
+				Type:     jparse.ErrGroupPredicate,
+				Position: 14,
 			},
 		},
 	})
@@ -1426,8 +1752,8 @@ func TestGroupNode(t *testing.T) {
 		{
 			Input: `*{"one": 1}{"two": 2}`,
 			Error: &jparse.Error{
-				// TODO: Get position.
-				Type: jparse.ErrGroupGroup,
+				Type:     jparse.ErrGroupGroup,
+				Position: 11,
 			},
 		},
 	})
@@ -1541,9 +1867,8 @@ func TestNumericOperatorNode(t *testing.T) {
 		{
 			Input: "%",
 			Error: &jparse.Error{
-				Type:     jparse.ErrPrefix,
-				Token:    "%",
-				Position: 0,
+				Type:  jparse.ErrNoParent,
+				Token: "%",
 			},
 		},
 	})
@@ -1800,6 +2125,171 @@ func TestBooleanOperatorNode(t *testing.T) {
 	})
 }
 
+func TestComments(t *testing.T) {
+	testParser(t, []testCase{
+		{
+			Input: "/* leading */ a /* trailing */",
+			Output: &jparse.PathNode{
+				Steps: []jparse.Node{
+					&jparse.NameNode{
+						Value: "a",
+					},
+				},
+			},
+		},
+		{
+			Input: `(
+				/* Comments can go anywhere
+				   whitespace can. */
+				$x := 1 /* one */ + /* two */ 2;
+				$x
+			)`,
+			Output: &jparse.BlockNode{
+				Exprs: []jparse.Node{
+					&jparse.AssignmentNode{
+						Name: "x",
+						Value: &jparse.NumericOperatorNode{
+							Type: jparse.NumericAdd,
+							LHS: &jparse.NumberNode{
+								Value: 1,
+							},
+							RHS: &jparse.NumberNode{
+								Value: 2,
+							},
+						},
+					},
+					&jparse.VariableNode{
+						Name: "x",
+					},
+				},
+			},
+		},
+		{
+			Input: "/* nothing here */",
+			Error: &jparse.Error{
+				Type:     jparse.ErrUnexpectedEOF,
+				Position: 18,
+			},
+		},
+		{
+			Input: "a + /* unterminated",
+			Error: &jparse.Error{
+				Type:     jparse.ErrUnterminatedComment,
+				Token:    "/* unterminated",
+				Hint:     "*/",
+				Position: 4,
+			},
+		},
+	})
+}
+
+func TestParseWithComments(t *testing.T) {
+
+	_, comments, err := jparse.ParseWithComments("/* first */ a /* second */.b")
+	if err != nil {
+		t.Fatalf("ParseWithComments: %s", err)
+	}
+
+	exp := []jparse.Comment{
+		{
+			Text:     "/* first */",
+			Position: 0,
+		},
+		{
+			Text:     "/* second */",
+			Position: 14,
+		},
+	}
+
+	if !reflect.DeepEqual(exp, comments) {
+		t.Errorf("expected comments %v, got %v", exp, comments)
+	}
+}
+
+func TestCoalescingOperatorNode(t *testing.T) {
+	testParser(t, []testCase{
+		{
+			Input: "a ?? 1",
+			Output: &jparse.CoalescingOperatorNode{
+				Type: jparse.CoalesceUndefined,
+				LHS: &jparse.PathNode{
+					Steps: []jparse.Node{
+						&jparse.NameNode{
+							Value: "a",
+						},
+					},
+				},
+				RHS: &jparse.NumberNode{
+					Value: 1,
+				},
+			},
+		},
+		{
+			Input: "a ?: 1",
+			Output: &jparse.CoalescingOperatorNode{
+				Type: jparse.CoalesceFalsy,
+				LHS: &jparse.PathNode{
+					Steps: []jparse.Node{
+						&jparse.NameNode{
+							Value: "a",
+						},
+					},
+				},
+				RHS: &jparse.NumberNode{
+					Value: 1,
+				},
+			},
+		},
+		{
+			// Coalescing operators are left associative.
+			Input: "$a ?? $b ?: $c",
+			Output: &jparse.CoalescingOperatorNode{
+				Type: jparse.CoalesceFalsy,
+				LHS: &jparse.CoalescingOperatorNode{
+					Type: jparse.CoalesceUndefined,
+					LHS: &jparse.VariableNode{
+						Name: "a",
+					},
+					RHS: &jparse.VariableNode{
+						Name: "b",
+					},
+				},
+				RHS: &jparse.VariableNode{
+					Name: "c",
+				},
+			},
+		},
+		{
+			// Coalescing operators bind less tightly than
+			// arithmetic operators.
+			Input: "$a ?? 1 + 2",
+			Output: &jparse.CoalescingOperatorNode{
+				Type: jparse.CoalesceUndefined,
+				LHS: &jparse.VariableNode{
+					Name: "a",
+				},
+				RHS: &jparse.NumericOperatorNode{
+					Type: jparse.NumericAdd,
+					LHS: &jparse.NumberNode{
+						Value: 1,
+					},
+					RHS: &jparse.NumberNode{
+						Value: 2,
+					},
+				},
+			},
+		},
+		{
+			// Missing right hand side.
+			Input: "$a ??",
+			Error: &jparse.Error{
+				Type:     jparse.ErrUnexpectedEOF,
+				Position: 5,
+			},
+		},
+	})
+}
+
 func TestConcatenationNode(t *testing.T) {
 	testParser(t, []testCase{
 		{
@@ -2016,18 +2506,18 @@ func TestPathNode(t *testing.T) {
 			// Literal on rhs of dot operator.
 			Input: "path.0",
 			Error: &jparse.Error{
-				// TODO: Need position info.
-				Type: jparse.ErrPathLiteral,
-				Hint: "0",
+				Type:     jparse.ErrPathLiteral,
+				Hint:     "0",
+				Position: 5,
 			},
 		},
 		{
 			// Literal on lhs of dot operator.
 			Input: `"Product Name".$uppercase()`,
 			Error: &jparse.Error{
-				// TODO: Need position info.
-				Type: jparse.ErrPathLiteral,
-				Hint: `"Product Name"`,
+				Type:     jparse.ErrPathLiteral,
+				Hint:     `"Product Name"`,
+				Position: 0,
 			},
 		},
 		/*
@@ -2046,6 +2536,180 @@ func TestPathNode(t *testing.T) {
 	})
 }
 
+func TestSpans(t *testing.T) {
+
+	input := "(\n  $x := \"hi\";\n  $x & name[0]\n)"
+
+	ast, err := jparse.Parse(input)
+	if err != nil {
+		t.Fatalf("Parse: %s", err)
+	}
+
+	block := ast.(*jparse.BlockNode)
+	assign := block.Exprs[0].(*jparse.AssignmentNode)
+	concat := block.Exprs[1].(*jparse.StringConcatenationNode)
+	path := concat.RHS.(*jparse.PathNode)
+	pred := path.Steps[0].(*jparse.PredicateNode)
+
+	data := []struct {
+		Node jparse.Node
+		Span jparse.Span
+	}{
+		{
+			Node: block,
+			Span: span(0, 1, 1, 32, 4, 2),
+		},
+		{
+			Node: assign,
+			Span: span(4, 2, 3, 14, 2, 13),
+		},
+		{
+			// String spans include the quotes.
+			Node: assign.Value,
+			Span: span(10, 2, 9, 14, 2, 13),
+		},
+		{
+			Node: concat,
+			Span: span(18, 3, 3, 30, 3, 15),
+		},
+		{
+			Node: path,
+			Span: span(23, 3, 8, 30, 3, 15),
+		},
+		{
+			Node: pred,
+			Span: span(23, 3, 8, 30, 3, 15),
+		},
+		{
+			Node: pred.Expr,
+			Span: span(23, 3, 8, 27, 3, 12),
+		},
+		{
+			Node: pred.Filters[0],
+			Span: span(28, 3, 13, 29, 3, 14),
+		},
+	}
+
+	for _, test := range data {
+		if got := test.Node.Location(); got != test.Span {
+			t.Errorf("%s: expected span %v, got %v", test.Node, test.Span, got)
+		}
+	}
+}
+
+func TestErrorCodes(t *testing.T) {
+
+	reCode := regexp.MustCompile(`^S0[1-5]\d\d$`)
+
+	for typ := jparse.ErrSyntaxError; typ <= jparse.ErrUnterminatedComment; typ++ {
+		e := jparse.Error{
+			Type: typ,
+		}
+		if code := e.Code(); !reCode.MatchString(code) {
+			t.Errorf("%s: invalid error code %q", e, code)
+		}
+	}
+
+	data := map[string]string{
+		"1 +":        "S0207",
+		"(1; 2":      "S0203",
+		`"hello`:     "S0101",
+		"/* comment": "S0106",
+		"path.1":     "S0213",
+		"a@b":        "S0214",
+	}
+
+	for input, exp := range data {
+
+		_, err := jparse.Parse(input)
+
+		e, ok := err.(*jparse.Error)
+		if !ok {
+			t.Errorf("%s: expected a parser error, got %v", input, err)
+			continue
+		}
+
+		if code := e.Code(); code != exp {
+			t.Errorf("%s: expected error code %q, got %q", input, exp, code)
+		}
+	}
+}
+
+func TestErrorSpans(t *testing.T) {
+
+	data := []struct {
+		Input   string
+		Span    jparse.Span
+		Excerpt string
+	}{
+		{
+			Input:   "a +\n  1e",
+			Span:    span(6, 2, 3, 8, 2, 5),
+			Excerpt: "  1e\n  ^^",
+		},
+		{
+			Input:   "(\n\t$x := 1;\n\t\"hello\".5\n)",
+			Span:    span(13, 3, 2, 20, 3, 9),
+			Excerpt: "\t\"hello\".5\n\t^^^^^^^",
+		},
+		{
+			Input:   "a + (",
+			Span:    span(5, 1, 6, 5, 1, 6),
+			Excerpt: "a + (\n     ^",
+		},
+	}
+
+	for _, test := range data {
+
+		_, err := jparse.Parse(test.Input)
+
+		e, ok := err.(*jparse.Error)
+		if !ok {
+			t.Errorf("%s: expected a parser error, got %v", test.Input, err)
+			continue
+		}
+
+		if e.Span != test.Span {
+			t.Errorf("%s: expected span %v, got %v", test.Input, test.Span, e.Span)
+		}
+
+		if got := jparse.Excerpt(test.Input, e.Span); got != test.Excerpt {
+			t.Errorf("%s: expected excerpt %q, got %q", test.Input, test.Excerpt, got)
+		}
+	}
+}
+
+func TestExcerpt(t *testing.T) {
+
+	src := "$sum(\n  Account.Order.Product.Price\n)"
+
+	data := []struct {
+		Span    jparse.Span
+		Excerpt string
+	}{
+		{
+			Span:    span(16, 2, 9, 21, 2, 14),
+			Excerpt: "  Account.Order.Product.Price\n          ^^^^^",
+		},
+		{
+			// Multi-line spans are truncated to the first line.
+			Span:    span(0, 1, 1, 39, 3, 2),
+			Excerpt: "$sum(\n^^^^^",
+		},
+		{
+			// Invalid spans produce an empty excerpt.
+			Span:    jparse.Span{},
+			Excerpt: "",
+		},
+	}
+
+	for _, test := range data {
+		if got := jparse.Excerpt(src, test.Span); got != test.Excerpt {
+			t.Errorf("%v: expected excerpt %q, got %q", test.Span, test.Excerpt, got)
+		}
+	}
+}
+
 func TestStringers(t *testing.T) {
 
 	data := []struct {
@@ -2168,6 +2832,22 @@ func TestStringers(t *testing.T) {
 			Input:  "**",
 			String: "**",
 		},
+		{
+			Input:  "a.b.%.%.Field",
+			String: "a.b.%.%.Field",
+		},
+		{
+			Input:  "loans@$l.books@$b[$l.isbn = $b.isbn]",
+			String: "loans@$l.books@$b[$l.isbn = $b.isbn]",
+		},
+		{
+			Input:  "items#$i[$i < 3]",
+			String: "items#$i[$i < 3]",
+		},
+		{
+			Input:  "$a??$b?:$c",
+			String: "$a ?? $b ?: $c",
+		},
 		{
 			Input: `| $ | {
 				"one": 1,
@@ -2335,6 +3015,17 @@ func testParser(t *testing.T, data []testCase) {
 
 			output, err := jparse.Parse(input)
 
+			// Source locations and number literals are tested
+			// separately. Ignore them here unless the test case
+			// specifies them.
+			clearSpans(reflect.ValueOf(output))
+			clearLiterals(output)
+			if e, ok := err.(*jparse.Error); ok {
+				if exp, ok := test.Error.(*jparse.Error); !ok || !exp.IsValid() {
+					e.Span = jparse.Span{}
+				}
+			}
+
 			if !reflect.DeepEqual(output, test.Output) {
 				t.Errorf("%s: expected output %s, got %s", input, test.Output, output)
 			}
@@ -2344,3 +3035,61 @@ func testParser(t *testing.T, data []testCase) {
 		}
 	}
 }
+
+func span(startOffset, startLine, startColumn, endOffset, endLine, endColumn int) jparse.Span {
+	return jparse.Span{
+		Start: jparse.Position{
+			Offset: startOffset,
+			Line:   startLine,
+			Column: startColumn,
+		},
+		End: jparse.Position{
+			Offset: endOffset,
+			Line:   endLine,
+			Column: endColumn,
+		},
+	}
+}
+
+// clearLiterals removes the source text from the number
+// literals in a syntax tree so that it can be compared with a
+// tree built by hand.
+func clearLiterals(node jparse.Node) {
+	if node == nil {
+		return
+	}
+	jparse.Inspect(node, func(n jparse.Node) bool {
+		if number, ok := n.(*jparse.NumberNode); ok {
+			number.Literal = ""
+		}
+		return true
+	})
+}
+
+var typeSpan = reflect.TypeOf(jparse.Span{})
+
+// clearSpans zeroes the source locations in a syntax tree so
+// that it can be compared with a tree built by hand.
+func clearSpans(v reflect.Value) {
+
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			clearSpans(v.Elem())
+		}
+	case reflect.Slice, reflect.Array:
+		for i := 0; i < v.Len(); i++ {
+			clearSpans(v.Index(i))
+		}
+	case reflect.Struct:
+		if v.Type() == typeSpan {
+			v.Set(reflect.Zero(typeSpan))
+			return
+		}
+		for i := 0; i < v.NumField(); i++ {
+			if v.Type().Field(i).PkgPath == "" {
+				clearSpans(v.Field(i))
+			}
+		}
+	}
+}
@@ -0,0 +1,835 @@
+// Copyright 2018 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package jparse
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// ASTVersion is the version of the JSON format produced by
+// MarshalJSON. UnmarshalJSON rejects documents with a different
+// version. The version will be incremented whenever the format
+// changes in a way that is not backwards compatible.
+const ASTVersion = 1
+
+// MarshalJSON returns a JSON representation of a syntax tree.
+// The output is an object with two fields: "version", which
+// holds the value of ASTVersion, and "ast", which holds the
+// root node.
+//
+// Each node is an object with a "type" field. Where possible,
+// node types and field names follow the abstract syntax trees
+// produced by jsonata-js. For example, the expression "a + 1"
+// is encoded as:
+//
+//	{
+//	    "type": "binary",
+//	    "value": "+",
+//	    "lhs": {
+//	        "type": "path",
+//	        "steps": [{"type": "name", "value": "a"}]
+//	    },
+//	    "rhs": {"type": "number", "value": 1}
+//	}
+//
+// Nodes with a valid source location also have a "span" field.
+// Regular expressions are encoded using Go's regexp syntax.
+func MarshalJSON(node Node) ([]byte, error) {
+
+	root, err := toJSONNode(node)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(jsonAST{
+		Version: ASTVersion,
+		AST:     root,
+	})
+}
+
+// UnmarshalJSON parses the output of MarshalJSON and returns
+// the syntax tree that it represents.
+func UnmarshalJSON(data []byte) (Node, error) {
+
+	var ast jsonAST
+
+	if err := json.Unmarshal(data, &ast); err != nil {
+		return nil, err
+	}
+
+	if ast.Version != ASTVersion {
+		return nil, fmt.Errorf("unsupported AST version %d (expected %d)", ast.Version, ASTVersion)
+	}
+
+	return fromJSONNode(ast.AST)
+}
+
+type jsonAST struct {
+	Version int       `json:"version"`
+	AST     *jsonNode `json:"ast"`
+}
+
+// jsonNode is the JSON representation of a syntax tree node.
+// Fields that a node type does not use are left empty.
+type jsonNode struct {
+	Type        string          `json:"type"`
+	Value       json.RawMessage `json:"value,omitempty"`
+	Escaped     bool            `json:"escaped,omitempty"`
+	Steps       []*jsonNode     `json:"steps,omitempty"`
+	KeepArray   bool            `json:"keepSingletonArray,omitempty"`
+	Expression  *jsonNode       `json:"expression,omitempty"`
+	Expressions []*jsonNode     `json:"expressions,omitempty"`
+	LHS         *jsonNode       `json:"lhs,omitempty"`
+	RHS         *jsonNode       `json:"rhs,omitempty"`
+	Pairs       [][2]*jsonNode  `json:"pairs,omitempty"`
+	Group       *jsonNode       `json:"group,omitempty"`
+	Predicates  []*jsonNode     `json:"predicates,omitempty"`
+	Terms       []*jsonSortTerm `json:"terms,omitempty"`
+	Condition   *jsonNode       `json:"condition,omitempty"`
+	Then        *jsonNode       `json:"then,omitempty"`
+	Else        *jsonNode       `json:"else,omitempty"`
+	Procedure   *jsonNode       `json:"procedure,omitempty"`
+	Arguments   []*jsonNode     `json:"arguments,omitempty"`
+	Body        *jsonNode       `json:"body,omitempty"`
+	Signature   string          `json:"signature,omitempty"`
+	Shorthand   bool            `json:"shorthand,omitempty"`
+	Pattern     *jsonNode       `json:"pattern,omitempty"`
+	Update      *jsonNode       `json:"update,omitempty"`
+	Delete      *jsonNode       `json:"delete,omitempty"`
+	Span        *jsonSpan       `json:"span,omitempty"`
+}
+
+type jsonSortTerm struct {
+	Descending bool      `json:"descending,omitempty"`
+	Ascending  bool      `json:"ascending,omitempty"`
+	Expression *jsonNode `json:"expression"`
+}
+
+type jsonSpan struct {
+	Start jsonPosition `json:"start"`
+	End   jsonPosition `json:"end"`
+}
+
+type jsonPosition struct {
+	Offset int `json:"offset"`
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+func toJSONNode(node Node) (*jsonNode, error) {
+
+	var j *jsonNode
+	var err error
+
+	switch n := node.(type) {
+	case *StringNode:
+		j = &jsonNode{Type: "string", Value: mustMarshal(n.Value)}
+
+	case *NumberNode:
+		// The lexer only accepts JSON number syntax, so the
+		// literal can be written as is.
+		value := json.RawMessage(n.Literal)
+		if n.Literal == "" {
+			value = mustMarshal(n.Value)
+		}
+		j = &jsonNode{Type: "number", Value: value}
+
+	case *BooleanNode:
+		j = &jsonNode{Type: "value", Value: mustMarshal(n.Value)}
+
+	case *NullNode:
+		j = &jsonNode{Type: "value", Value: json.RawMessage("null")}
+
+	case *RegexNode:
+		j = &jsonNode{Type: "regex", Value: mustMarshal(n.Value.String())}
+
+	case *VariableNode:
+		j = &jsonNode{Type: "variable", Value: mustMarshal(n.Name)}
+
+	case *NameNode:
+		j = &jsonNode{Type: "name", Value: mustMarshal(n.Value), Escaped: n.escaped}
+
+	case *PathNode:
+		j = &jsonNode{Type: "path", KeepArray: n.KeepArrays}
+		j.Steps, err = toJSONNodes(n.Steps)
+
+	case *FocusNode:
+		j = &jsonNode{Type: "focus", Value: mustMarshal(n.Name)}
+		j.Expression, err = toJSONNode(n.Expr)
+
+	case *IndexNode:
+		j = &jsonNode{Type: "index", Value: mustMarshal(n.Name)}
+		j.Expression, err = toJSONNode(n.Expr)
+
+	case *NegationNode:
+		j = &jsonNode{Type: "unary", Value: mustMarshal("-")}
+		j.Expression, err = toJSONNode(n.RHS)
+
+	case *RangeNode:
+		j, err = toJSONBinary("binary", "..", n.LHS, n.RHS)
+
+	case *ArrayNode:
+		j = &jsonNode{Type: "unary", Value: mustMarshal("[")}
+		j.Expressions, err = toJSONNodes(n.Items)
+
+	case *ObjectNode:
+		j = &jsonNode{Type: "unary", Value: mustMarshal("{")}
+		j.Pairs, err = toJSONPairs(n.Pairs)
+
+	case *BlockNode:
+		j = &jsonNode{Type: "block"}
+		j.Expressions, err = toJSONNodes(n.Exprs)
+
+	case *WildcardNode:
+		j = &jsonNode{Type: "wildcard", Value: mustMarshal("*")}
+
+	case *DescendentNode:
+		j = &jsonNode{Type: "descendant", Value: mustMarshal("**")}
+
+	case *ParentNode:
+		j = &jsonNode{Type: "parent", Value: mustMarshal("%")}
+
+	case *ObjectTransformationNode:
+		j = &jsonNode{Type: "transform"}
+		if j.Pattern, err = toJSONNode(n.Pattern); err != nil {
+			return nil, err
+		}
+		if j.Update, err = toJSONNode(n.Updates); err != nil {
+			return nil, err
+		}
+		if n.Deletes != nil {
+			j.Delete, err = toJSONNode(n.Deletes)
+		}
+
+	case *LambdaNode:
+		j, err = toJSONLambda(n)
+
+	case *TypedLambdaNode:
+		j, err = toJSONLambda(n.LambdaNode)
+		if err == nil {
+			j.Signature = n.signature()
+		}
+
+	case *PartialNode:
+		j = &jsonNode{Type: "partial", Value: mustMarshal("(")}
+		if j.Procedure, err = toJSONNode(n.Func); err != nil {
+			return nil, err
+		}
+		j.Arguments, err = toJSONNodes(n.Args)
+
+	case *PlaceholderNode:
+		j = &jsonNode{Type: "operator", Value: mustMarshal("?")}
+
+	case *FunctionCallNode:
+		j = &jsonNode{Type: "function", Value: mustMarshal("(")}
+		if j.Procedure, err = toJSONNode(n.Func); err != nil {
+			return nil, err
+		}
+		j.Arguments, err = toJSONNodes(n.Args)
+
+	case *PredicateNode:
+		j = &jsonNode{Type: "filter"}
+		if j.Expression, err = toJSONNode(n.Expr); err != nil {
+			return nil, err
+		}
+		j.Predicates, err = toJSONNodes(n.Filters)
+
+	case *GroupNode:
+		j = &jsonNode{Type: "group"}
+		if j.Expression, err = toJSONNode(n.Expr); err != nil {
+			return nil, err
+		}
+		j.Group, err = toJSONNode(n.ObjectNode)
+
+	case *ConditionalNode:
+		j = &jsonNode{Type: "condition"}
+		if j.Condition, err = toJSONNode(n.If); err != nil {
+			return nil, err
+		}
+		if j.Then, err = toJSONNode(n.Then); err != nil {
+			return nil, err
+		}
+		if n.Else != nil {
+			j.Else, err = toJSONNode(n.Else)
+		}
+
+	case *AssignmentNode:
+		j = &jsonNode{
+			Type:  "bind",
+			Value: mustMarshal(":="),
+			LHS:   &jsonNode{Type: "variable", Value: mustMarshal(n.Name)},
+		}
+		j.RHS, err = toJSONNode(n.Value)
+
+	case *NumericOperatorNode:
+		j, err = toJSONBinary("binary", n.Type.String(), n.LHS, n.RHS)
+
+	case *ComparisonOperatorNode:
+		j, err = toJSONBinary("binary", n.Type.String(), n.LHS, n.RHS)
+
+	case *BooleanOperatorNode:
+		j, err = toJSONBinary("binary", n.Type.String(), n.LHS, n.RHS)
+
+	case *CoalescingOperatorNode:
+		j, err = toJSONBinary("binary", n.Type.String(), n.LHS, n.RHS)
+
+	case *StringConcatenationNode:
+		j, err = toJSONBinary("binary", "&", n.LHS, n.RHS)
+
+	case *SortNode:
+		j = &jsonNode{Type: "sort"}
+		if j.Expression, err = toJSONNode(n.Expr); err != nil {
+			return nil, err
+		}
+		j.Terms = make([]*jsonSortTerm, len(n.Terms))
+		for i, term := range n.Terms {
+			j.Terms[i] = &jsonSortTerm{
+				Descending: term.Dir == SortDescending,
+				Ascending:  term.Dir == SortAscending,
+			}
+			if j.Terms[i].Expression, err = toJSONNode(term.Expr); err != nil {
+				return nil, err
+			}
+		}
+
+	case *FunctionApplicationNode:
+		j, err = toJSONBinary("apply", "~>", n.LHS, n.RHS)
+
+	default:
+		return nil, fmt.Errorf("cannot marshal node of type %T", node)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if span := node.Location(); span.IsValid() {
+		j.Span = &jsonSpan{
+			Start: jsonPosition(span.Start),
+			End:   jsonPosition(span.End),
+		}
+	}
+
+	return j, nil
+}
+
+func toJSONNodes(nodes []Node) ([]*jsonNode, error) {
+
+	if nodes == nil {
+		return nil, nil
+	}
+
+	js := make([]*jsonNode, len(nodes))
+
+	for i, node := range nodes {
+		j, err := toJSONNode(node)
+		if err != nil {
+			return nil, err
+		}
+		js[i] = j
+	}
+
+	return js, nil
+}
+
+func toJSONPairs(pairs [][2]Node) ([][2]*jsonNode, error) {
+
+	if pairs == nil {
+		return nil, nil
+	}
+
+	js := make([][2]*jsonNode, len(pairs))
+
+	for i, pair := range pairs {
+		for k := range pair {
+			j, err := toJSONNode(pair[k])
+			if err != nil {
+				return nil, err
+			}
+			js[i][k] = j
+		}
+	}
+
+	return js, nil
+}
+
+func toJSONBinary(typ string, op string, lhs, rhs Node) (*jsonNode, error) {
+
+	var err error
+
+	j := &jsonNode{
+		Type:  typ,
+		Value: mustMarshal(op),
+	}
+
+	if j.LHS, err = toJSONNode(lhs); err != nil {
+		return nil, err
+	}
+	if j.RHS, err = toJSONNode(rhs); err != nil {
+		return nil, err
+	}
+
+	return j, nil
+}
+
+func toJSONLambda(n *LambdaNode) (*jsonNode, error) {
+
+	var err error
+
+	j := &jsonNode{
+		Type:      "lambda",
+		Shorthand: n.shorthand,
+	}
+
+	if n.ParamNames != nil {
+		j.Arguments = make([]*jsonNode, len(n.ParamNames))
+		for i, name := range n.ParamNames {
+			j.Arguments[i] = &jsonNode{Type: "variable", Value: mustMarshal(name)}
+		}
+	}
+
+	if j.Body, err = toJSONNode(n.Body); err != nil {
+		return nil, err
+	}
+
+	return j, nil
+}
+
+func mustMarshal(v interface{}) json.RawMessage {
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		panicf("jparse: cannot marshal %v: %s", v, err)
+	}
+
+	return data
+}
+
+func fromJSONNode(j *jsonNode) (Node, error) {
+
+	if j == nil {
+		return nil, fmt.Errorf("missing node")
+	}
+
+	var node Node
+	var err error
+
+	switch j.Type {
+	case "string":
+		var s string
+		err = unmarshalValue(j, &s)
+		node = &StringNode{Value: s}
+
+	case "number":
+		var n json.Number
+		if err = unmarshalValue(j, &n); err != nil {
+			break
+		}
+		var f float64
+		if f, err = strconv.ParseFloat(string(n), 64); err != nil {
+			err = fmt.Errorf("invalid value in %s node: %s", j.Type, err)
+			break
+		}
+		node = &NumberNode{Value: f, Literal: string(n)}
+
+	case "value":
+		var v interface{}
+		if err = unmarshalValue(j, &v); err != nil {
+			break
+		}
+		switch v := v.(type) {
+		case bool:
+			node = &BooleanNode{Value: v}
+		case nil:
+			node = &NullNode{}
+		default:
+			err = fmt.Errorf("invalid value %s", j.Value)
+		}
+
+	case "regex":
+		var s string
+		if err = unmarshalValue(j, &s); err != nil {
+			break
+		}
+		var re *regexp.Regexp
+		if re, err = regexp.Compile(s); err == nil {
+			node = &RegexNode{Value: re}
+		}
+
+	case "variable":
+		var s string
+		err = unmarshalValue(j, &s)
+		node = &VariableNode{Name: s}
+
+	case "name":
+		var s string
+		err = unmarshalValue(j, &s)
+		node = &NameNode{Value: s, escaped: j.Escaped}
+
+	case "path":
+		var steps []Node
+		steps, err = fromJSONNodes(j.Steps)
+		node = &PathNode{Steps: steps, KeepArrays: j.KeepArray}
+
+	case "focus", "index":
+		var name string
+		if err = unmarshalValue(j, &name); err != nil {
+			break
+		}
+		var expr Node
+		if expr, err = fromJSONNode(j.Expression); err != nil {
+			break
+		}
+		if j.Type == "focus" {
+			node = &FocusNode{Expr: expr, Name: name}
+		} else {
+			node = &IndexNode{Expr: expr, Name: name}
+		}
+
+	case "unary":
+		node, err = fromJSONUnary(j)
+
+	case "binary":
+		node, err = fromJSONBinary(j)
+
+	case "block":
+		var exprs []Node
+		exprs, err = fromJSONNodes(j.Expressions)
+		node = &BlockNode{Exprs: exprs}
+
+	case "wildcard":
+		node = &WildcardNode{}
+
+	case "descendant":
+		node = &DescendentNode{}
+
+	case "parent":
+		node = &ParentNode{}
+
+	case "transform":
+		n := &ObjectTransformationNode{}
+		if n.Pattern, err = fromJSONNode(j.Pattern); err != nil {
+			break
+		}
+		if n.Updates, err = fromJSONNode(j.Update); err != nil {
+			break
+		}
+		if j.Delete != nil {
+			n.Deletes, err = fromJSONNode(j.Delete)
+		}
+		node = n
+
+	case "lambda":
+		node, err = fromJSONLambda(j)
+
+	case "partial":
+		n := &PartialNode{}
+		if n.Func, err = fromJSONNode(j.Procedure); err != nil {
+			break
+		}
+		n.Args, err = fromJSONNodes(j.Arguments)
+		node = n
+
+	case "operator":
+		var op string
+		if err = unmarshalValue(j, &op); err != nil {
+			break
+		}
+		if op != "?" {
+			err = fmt.Errorf("invalid operator %q", op)
+			break
+		}
+		node = &PlaceholderNode{}
+
+	case "function":
+		n := &FunctionCallNode{}
+		if n.Func, err = fromJSONNode(j.Procedure); err != nil {
+			break
+		}
+		n.Args, err = fromJSONNodes(j.Arguments)
+		node = n
+
+	case "filter":
+		n := &PredicateNode{}
+		if n.Expr, err = fromJSONNode(j.Expression); err != nil {
+			break
+		}
+		n.Filters, err = fromJSONNodes(j.Predicates)
+		node = n
+
+	case "group":
+		n := &GroupNode{}
+		if n.Expr, err = fromJSONNode(j.Expression); err != nil {
+			break
+		}
+		var obj Node
+		if obj, err = fromJSONNode(j.Group); err != nil {
+			break
+		}
+		var ok bool
+		if n.ObjectNode, ok = obj.(*ObjectNode); !ok {
+			err = fmt.Errorf("invalid group: expected object, got %T", obj)
+		}
+		node = n
+
+	case "condition":
+		n := &ConditionalNode{}
+		if n.If, err = fromJSONNode(j.Condition); err != nil {
+			break
+		}
+		if n.Then, err = fromJSONNode(j.Then); err != nil {
+			break
+		}
+		if j.Else != nil {
+			n.Else, err = fromJSONNode(j.Else)
+		}
+		node = n
+
+	case "bind":
+		var name string
+		if j.LHS == nil || j.LHS.Type != "variable" {
+			err = fmt.Errorf("invalid bind: left side must be a variable")
+			break
+		}
+		if err = unmarshalValue(j.LHS, &name); err != nil {
+			break
+		}
+		n := &AssignmentNode{Name: name}
+		n.Value, err = fromJSONNode(j.RHS)
+		node = n
+
+	case "sort":
+		n := &SortNode{}
+		if n.Expr, err = fromJSONNode(j.Expression); err != nil {
+			break
+		}
+		n.Terms = make([]SortTerm, len(j.Terms))
+		for i, term := range j.Terms {
+			if term == nil {
+				err = fmt.Errorf("missing sort term")
+				break
+			}
+			switch {
+			case term.Descending:
+				n.Terms[i].Dir = SortDescending
+			case term.Ascending:
+				n.Terms[i].Dir = SortAscending
+			default:
+				n.Terms[i].Dir = SortDefault
+			}
+			if n.Terms[i].Expr, err = fromJSONNode(term.Expression); err != nil {
+				break
+			}
+		}
+		node = n
+
+	case "apply":
+		n := &FunctionApplicationNode{}
+		if n.LHS, err = fromJSONNode(j.LHS); err != nil {
+			break
+		}
+		n.RHS, err = fromJSONNode(j.RHS)
+		node = n
+
+	default:
+		err = fmt.Errorf("unknown node type %q", j.Type)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if j.Span != nil {
+		node.setSpan(Span{
+			Start: Position(j.Span.Start),
+			End:   Position(j.Span.End),
+		})
+	}
+
+	return node, nil
+}
+
+func fromJSONNodes(js []*jsonNode) ([]Node, error) {
+
+	if js == nil {
+		return nil, nil
+	}
+
+	nodes := make([]Node, len(js))
+
+	for i, j := range js {
+		node, err := fromJSONNode(j)
+		if err != nil {
+			return nil, err
+		}
+		nodes[i] = node
+	}
+
+	return nodes, nil
+}
+
+func fromJSONUnary(j *jsonNode) (Node, error) {
+
+	var op string
+	if err := unmarshalValue(j, &op); err != nil {
+		return nil, err
+	}
+
+	switch op {
+	case "-":
+		rhs, err := fromJSONNode(j.Expression)
+		if err != nil {
+			return nil, err
+		}
+		return &NegationNode{RHS: rhs}, nil
+
+	case "[":
+		items, err := fromJSONNodes(j.Expressions)
+		if err != nil {
+			return nil, err
+		}
+		return &ArrayNode{Items: items}, nil
+
+	case "{":
+		var pairs [][2]Node
+		if j.Pairs != nil {
+			pairs = make([][2]Node, len(j.Pairs))
+		}
+		for i, pair := range j.Pairs {
+			for k := range pair {
+				node, err := fromJSONNode(pair[k])
+				if err != nil {
+					return nil, err
+				}
+				pairs[i][k] = node
+			}
+		}
+		return &ObjectNode{Pairs: pairs}, nil
+
+	default:
+		return nil, fmt.Errorf("invalid unary operator %q", op)
+	}
+}
+
+func fromJSONBinary(j *jsonNode) (Node, error) {
+
+	var op string
+	if err := unmarshalValue(j, &op); err != nil {
+		return nil, err
+	}
+
+	lhs, err := fromJSONNode(j.LHS)
+	if err != nil {
+		return nil, err
+	}
+
+	rhs, err := fromJSONNode(j.RHS)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op {
+	case "..":
+		return &RangeNode{LHS: lhs, RHS: rhs}, nil
+	case "&":
+		return &StringConcatenationNode{LHS: lhs, RHS: rhs}, nil
+	}
+
+	for typ := NumericAdd; typ <= NumericModulo; typ++ {
+		if typ.String() == op {
+			return &NumericOperatorNode{Type: typ, LHS: lhs, RHS: rhs}, nil
+		}
+	}
+
+	for typ := ComparisonEqual; typ <= ComparisonIn; typ++ {
+		if typ.String() == op {
+			return &ComparisonOperatorNode{Type: typ, LHS: lhs, RHS: rhs}, nil
+		}
+	}
+
+	for typ := BooleanAnd; typ <= BooleanOr; typ++ {
+		if typ.String() == op {
+			return &BooleanOperatorNode{Type: typ, LHS: lhs, RHS: rhs}, nil
+		}
+	}
+
+	for typ := CoalesceUndefined; typ <= CoalesceFalsy; typ++ {
+		if typ.String() == op {
+			return &CoalescingOperatorNode{Type: typ, LHS: lhs, RHS: rhs}, nil
+		}
+	}
+
+	return nil, fmt.Errorf("invalid binary operator %q", op)
+}
+
+func fromJSONLambda(j *jsonNode) (Node, error) {
+
+	body, err := fromJSONNode(j.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	if j.Arguments != nil {
+		names = make([]string, len(j.Arguments))
+	}
+
+	for i, arg := range j.Arguments {
+		if arg == nil || arg.Type != "variable" {
+			return nil, fmt.Errorf("invalid lambda: arguments must be variables")
+		}
+		if err := unmarshalValue(arg, &names[i]); err != nil {
+			return nil, err
+		}
+	}
+
+	lambda := &LambdaNode{
+		Body:       body,
+		ParamNames: names,
+		shorthand:  j.Shorthand,
+	}
+
+	if j.Signature == "" {
+		return lambda, nil
+	}
+
+	sig := j.Signature
+	if !strings.HasPrefix(sig, "<") || !strings.HasSuffix(sig, ">") {
+		return nil, fmt.Errorf("invalid signature %q", sig)
+	}
+
+	params, results, err := parseSignature(sig[1 : len(sig)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	if len(params) != len(names) {
+		return nil, fmt.Errorf("invalid lambda: signature has %d parameter(s), lambda has %d", len(params), len(names))
+	}
+
+	return &TypedLambdaNode{
+		LambdaNode: lambda,
+		In:         params,
+		Out:        results,
+		Signature:  sig[1 : len(sig)-1],
+	}, nil
+}
+
+func unmarshalValue(j *jsonNode, v interface{}) error {
+
+	if len(j.Value) == 0 {
+		return fmt.Errorf("missing value in %s node", j.Type)
+	}
+
+	if err := json.Unmarshal(j.Value, v); err != nil {
+		return fmt.Errorf("invalid value in %s node: %s", j.Type, err)
+	}
+
+	return nil
+}
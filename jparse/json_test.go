@@ -0,0 +1,211 @@
+// Copyright 2018 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package jparse_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/blues/jsonata-go/jparse"
+)
+
+func TestJSONRoundTrip(t *testing.T) {
+
+	inputs := []string{
+		`(
+			$x := -a.b[0]{"k": %.v};
+			$f := λ($v)<n:n>{ $v * 2 };
+			$g := $f(?);
+			[1..3, "s", true, null, /re/i] ~> $map(function($i) { $i ? $i : **.c });
+			$x ?? *.d & "e" ?: false;
+			a@$l#$i.$l^(>c, <d, e);
+			| x | {"y": 1}, ["z"] |;
+			| x | {"y": 2} |;
+			$x = 1 and $x != 2 or $x in [3, 4]
+		)`,
+		`[]`,
+		`{}`,
+		`()`,
+		`$now()`,
+		`function(){1}`,
+		`λ($a, $f)<a<n>f:a>{$f($a)}`,
+		`λ($a, $f)<a<n>f<n:n>:a>{$map($a, $f)}`,
+		"`escaped name`.x",
+		`a.b[[1, 2]][0]`,
+		`a[]`,
+		`[a][0]`,
+		`a{b: c}.d`,
+		`x.y{z: $}`,
+		`a > 1 ? "b"`,
+		`1.5e3 / 2 % 3 - 4 >= 5 < 6 <= 7 > 8`,
+		`12345678901234567891 + 1.50`,
+		`/\d+/`,
+	}
+
+	for _, input := range inputs {
+
+		root, err := jparse.Parse(input)
+		if err != nil {
+			t.Fatalf("%s: Parse: %s", input, err)
+		}
+
+		data, err := jparse.MarshalJSON(root)
+		if err != nil {
+			t.Errorf("%s: MarshalJSON: %s", input, err)
+			continue
+		}
+
+		got, err := jparse.UnmarshalJSON(data)
+		if err != nil {
+			t.Errorf("%s: UnmarshalJSON: %s", input, err)
+			continue
+		}
+
+		if got.String() != root.String() {
+			t.Errorf("%s: expected String %q, got %q", input, root.String(), got.String())
+		}
+
+		if !reflect.DeepEqual(got, root) {
+			t.Errorf("%s: round trip produced a different syntax tree", input)
+		}
+	}
+}
+
+func TestMarshalJSON(t *testing.T) {
+
+	data := []struct {
+		Input  string
+		Output string
+	}{
+		{
+			Input:  `a + 1`,
+			Output: `{"version":1,"ast":{"type":"binary","value":"+","lhs":{"type":"path","steps":[{"type":"name","value":"a"}]},"rhs":{"type":"number","value":1}}}`,
+		},
+		{
+			Input:  `$x := [true, null]`,
+			Output: `{"version":1,"ast":{"type":"bind","value":":=","lhs":{"type":"variable","value":"x"},"rhs":{"type":"unary","value":"[","expressions":[{"type":"value","value":true},{"type":"value","value":null}]}}}`,
+		},
+		{
+			Input:  `$f(?, 0)`,
+			Output: `{"version":1,"ast":{"type":"partial","value":"(","procedure":{"type":"variable","value":"f"},"arguments":[{"type":"operator","value":"?"},{"type":"number","value":0}]}}`,
+		},
+		{
+			Input:  `12345678901234567891 - 1.50`,
+			Output: `{"version":1,"ast":{"type":"binary","value":"-","lhs":{"type":"number","value":12345678901234567891},"rhs":{"type":"number","value":1.50}}}`,
+		},
+		{
+			Input:  `a^(>b)`,
+			Output: `{"version":1,"ast":{"type":"sort","expression":{"type":"path","steps":[{"type":"name","value":"a"}]},"terms":[{"descending":true,"expression":{"type":"path","steps":[{"type":"name","value":"b"}]}}]}}`,
+		},
+	}
+
+	for _, test := range data {
+
+		root, err := jparse.Parse(test.Input)
+		if err != nil {
+			t.Fatalf("%s: Parse: %s", test.Input, err)
+		}
+
+		clearSpans(reflect.ValueOf(root))
+
+		output, err := jparse.MarshalJSON(root)
+		if err != nil {
+			t.Fatalf("%s: MarshalJSON: %s", test.Input, err)
+		}
+
+		if string(output) != test.Output {
+			t.Errorf("%s: expected %s, got %s", test.Input, test.Output, output)
+		}
+	}
+}
+
+func TestMarshalJSONSpans(t *testing.T) {
+
+	root, err := jparse.Parse("x")
+	if err != nil {
+		t.Fatalf("Parse: %s", err)
+	}
+
+	output, err := jparse.MarshalJSON(root)
+	if err != nil {
+		t.Fatalf("MarshalJSON: %s", err)
+	}
+
+	exp := `"span":{"start":{"offset":0,"line":1,"column":1},"end":{"offset":1,"line":1,"column":2}}`
+	if !strings.Contains(string(output), exp) {
+		t.Errorf("expected output to contain %s, got %s", exp, output)
+	}
+}
+
+func TestUnmarshalJSONErrors(t *testing.T) {
+
+	data := []struct {
+		Input string
+		Error string
+	}{
+		{
+			Input: `[1, 2]`,
+			Error: "cannot unmarshal",
+		},
+		{
+			Input: `{"version":2,"ast":{"type":"number","value":1}}`,
+			Error: "unsupported AST version 2",
+		},
+		{
+			Input: `{"version":1}`,
+			Error: "missing node",
+		},
+		{
+			Input: `{"version":1,"ast":{"type":"unknown"}}`,
+			Error: `unknown node type "unknown"`,
+		},
+		{
+			Input: `{"version":1,"ast":{"type":"number"}}`,
+			Error: "missing value in number node",
+		},
+		{
+			Input: `{"version":1,"ast":{"type":"string","value":1}}`,
+			Error: "invalid value in string node",
+		},
+		{
+			Input: `{"version":1,"ast":{"type":"binary","value":"^","lhs":{"type":"number","value":1},"rhs":{"type":"number","value":2}}}`,
+			Error: `invalid binary operator "^"`,
+		},
+		{
+			Input: `{"version":1,"ast":{"type":"binary","value":"+","lhs":{"type":"number","value":1}}}`,
+			Error: "missing node",
+		},
+		{
+			Input: `{"version":1,"ast":{"type":"regex","value":"("}}`,
+			Error: "missing closing )",
+		},
+		{
+			Input: `{"version":1,"ast":{"type":"lambda","signature":"<q>","arguments":[{"type":"variable","value":"a"}],"body":{"type":"number","value":1}}}`,
+			Error: "unknown parameter type",
+		},
+		{
+			Input: `{"version":1,"ast":{"type":"bind","value":":=","lhs":{"type":"number","value":1},"rhs":{"type":"number","value":1}}}`,
+			Error: "left side must be a variable",
+		},
+		{
+			Input: `{"version":1,"ast":{"type":"lambda","signature":"<nn>","arguments":[{"type":"variable","value":"a"}],"body":{"type":"number","value":1}}}`,
+			Error: "signature has 2 parameter(s), lambda has 1",
+		},
+	}
+
+	for _, test := range data {
+
+		_, err := jparse.UnmarshalJSON([]byte(test.Input))
+		if err == nil {
+			t.Errorf("%s: expected error, got nil", test.Input)
+			continue
+		}
+
+		if !strings.Contains(err.Error(), test.Error) {
+			t.Errorf("%s: expected error containing %q, got %q", test.Input, test.Error, err)
+		}
+	}
+}
@@ -6,6 +6,7 @@ package jparse
 
 import (
 	"fmt"
+	"strings"
 	"unicode/utf8"
 )
 
@@ -56,6 +57,10 @@ const (
 	typeRange
 	typeAssign
 	typeDescendent
+	typeFocus
+	typeIndex
+	typeCoalesce
+	typeElvis
 
 	// Keyword operators
 	typeAnd
@@ -114,6 +119,8 @@ var symbols1 = [...]tokenType{
 	'>': typeGreater,
 	'^': typeSort,
 	'&': typeConcat,
+	'@': typeFocus,
+	'#': typeIndex,
 }
 
 type runeTokenType struct {
@@ -131,6 +138,7 @@ var symbols2 = [...][]runeTokenType{
 	'~': {{'>', typeApply}},
 	':': {{'=', typeAssign}},
 	'*': {{'*', typeDescendent}},
+	'?': {{'?', typeCoalesce}, {':', typeElvis}},
 }
 
 const (
@@ -175,18 +183,26 @@ type token struct {
 	Type     tokenType
 	Value    string
 	Position int
+
+	// Start and End are the byte offsets of the token in the
+	// input, including any delimiters (e.g. the quotes around
+	// a string literal).
+	Start int
+	End   int
 }
 
 // lexer converts a JSONata expression into a sequence of tokens.
 // The implmentation is based on the technique described in Rob
 // Pike's 'Lexical Scanning in Go' talk.
 type lexer struct {
-	input   string
-	length  int
-	start   int
-	current int
-	width   int
-	err     error
+	input    string
+	length   int
+	start    int
+	current  int
+	width    int
+	err      error
+	comments []Comment
+	lines    []int
 }
 
 // newLexer creates a new lexer from the provided input. The
@@ -195,6 +211,7 @@ func newLexer(input string) lexer {
 	return lexer{
 		input:  input,
 		length: len(input),
+		lines:  lineOffsets(input),
 	}
 }
 
@@ -212,6 +229,26 @@ func (l *lexer) next(allowRegex bool) token {
 
 	l.skipWhitespace()
 
+	// Comments can appear anywhere that whitespace can. Skip
+	// over them (and any whitespace that follows them) before
+	// reading the next token.
+	for strings.HasPrefix(l.input[l.current:], "/*") {
+		if !l.scanComment() {
+			return l.error(ErrUnterminatedComment, "*/")
+		}
+		l.skipWhitespace()
+	}
+
+	start := l.current
+	t := l.scan(allowRegex)
+	t.Start, t.End = start, l.current
+	return t
+}
+
+// scan reads the token at the current position. Any leading
+// whitespace and comments have already been skipped.
+func (l *lexer) scan(allowRegex bool) token {
+
 	ch := l.nextRune()
 	if ch == eof {
 		return l.eof()
@@ -253,6 +290,29 @@ func (l *lexer) next(allowRegex bool) token {
 	return l.scanName()
 }
 
+// scanComment reads a block comment from the current position
+// and adds it to the lexer's list of comments. If the comment is
+// not terminated, scanComment consumes the rest of the input and
+// returns false.
+func (l *lexer) scanComment() bool {
+
+	l.width = 0
+
+	end := strings.Index(l.input[l.current+2:], "*/")
+	if end < 0 {
+		l.current = l.length
+		return false
+	}
+
+	l.current += end + 4
+	l.comments = append(l.comments, Comment{
+		Text:     l.input[l.start:l.current],
+		Position: l.start,
+	})
+	l.ignore()
+	return true
+}
+
 // scanRegex reads a regular expression from the current position
 // and returns a regex token. The opening delimiter has already
 // been consumed.
@@ -443,14 +503,17 @@ func (l *lexer) nextRune() rune {
 	r, w := utf8.DecodeRuneInString(l.input[l.current:])
 	l.width = w
 	l.current += w
-	/*
-		if r == '\n' {
-			l.line++
-		}
-	*/
 	return r
 }
 
+// span converts a pair of byte offsets into a Span.
+func (l *lexer) span(start, end int) Span {
+	return Span{
+		Start: position(l.lines, start),
+		End:   position(l.lines, end),
+	}
+}
+
 func (l *lexer) backup() {
 	// TODO: Support more than one backup operation.
 	// TODO: Store current rune so that when nextRune
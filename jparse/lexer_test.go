@@ -13,6 +13,7 @@ type lexerTestCase struct {
 	Input      string
 	AllowRegex bool
 	Tokens     []token
+	Comments   []Comment
 	Error      error
 }
 
@@ -105,6 +106,90 @@ func TestLexerRegex(t *testing.T) {
 	})
 }
 
+func TestLexerComments(t *testing.T) {
+	testLexer(t, []lexerTestCase{
+		{
+			Input: "/**/",
+			Comments: []Comment{
+				{
+					Text:     "/**/",
+					Position: 0,
+				},
+			},
+		},
+		{
+			Input: "a /* one */ + /* two\n  lines */b",
+			Tokens: []token{
+				tok(typeName, "a", 0),
+				tok(typePlus, "+", 12),
+				tok(typeName, "b", 31),
+			},
+			Comments: []Comment{
+				{
+					Text:     "/* one */",
+					Position: 2,
+				},
+				{
+					Text:     "/* two\n  lines */",
+					Position: 14,
+				},
+			},
+		},
+		{
+			// Comments take precedence over regular expressions.
+			Input:      "/* comment */ /ab+/",
+			AllowRegex: true,
+			Tokens: []token{
+				tok(typeRegex, "ab+", 15),
+			},
+			Comments: []Comment{
+				{
+					Text:     "/* comment */",
+					Position: 0,
+				},
+			},
+		},
+		{
+			// Comment delimiters inside strings are not comments.
+			Input: `"/* not a comment */"`,
+			Tokens: []token{
+				tok(typeString, "/* not a comment */", 1),
+			},
+		},
+		{
+			Input: "a/**/*/**/b",
+			Tokens: []token{
+				tok(typeName, "a", 0),
+				tok(typeMult, "*", 5),
+				tok(typeName, "b", 10),
+			},
+			Comments: []Comment{
+				{
+					Text:     "/**/",
+					Position: 1,
+				},
+				{
+					Text:     "/**/",
+					Position: 6,
+				},
+			},
+		},
+		{
+			Input: "a /* no end *",
+			Tokens: []token{
+				tok(typeName, "a", 0),
+				tok(typeError, "/* no end *", 2),
+			},
+			Error: &Error{
+				Type:     ErrUnterminatedComment,
+				Token:    "/* no end *",
+				Hint:     "*/",
+				Position: 2,
+			},
+		},
+	})
+}
+
 func TestLexerStrings(t *testing.T) {
 	testLexer(t, []lexerTestCase{
 		{
@@ -373,6 +458,10 @@ func testLexer(t *testing.T, data []lexerTestCase) {
 		for i := 0; i < 3; i++ {
 			compareTokens(t, test.Input, eof, l.next(test.AllowRegex))
 		}
+
+		if !reflect.DeepEqual(test.Comments, l.comments) {
+			t.Errorf("%s: expected comments %v, got %v", test.Input, test.Comments, l.comments)
+		}
 	}
 }
 
@@ -17,11 +17,14 @@ import (
 // Node represents an individual node in a syntax tree.
 type Node interface {
 	String() string
+	Location() Span
 	optimize() (Node, error)
+	setSpan(Span)
 }
 
 // A StringNode represents a string literal.
 type StringNode struct {
+	Span
 	Value string
 }
 
@@ -50,9 +53,15 @@ func (n StringNode) String() string {
 	return fmt.Sprintf("%q", n.Value)
 }
 
-// A NumberNode represents a number literal.
+// A NumberNode represents a number literal. Literal is the
+// text of the number in the source expression (e.g. "1.50"),
+// which is more precise than Value for numbers that float64
+// cannot represent exactly. It is empty for nodes that do not
+// come from the parser.
 type NumberNode struct {
-	Value float64
+	Span
+	Value   float64
+	Literal string
 }
 
 func parseNumber(p *parser, t token) (Node, error) {
@@ -68,7 +77,8 @@ func parseNumber(p *parser, t token) (Node, error) {
 	}
 
 	return &NumberNode{
-		Value: n,
+		Value:   n,
+		Literal: t.Value,
 	}, nil
 }
 
@@ -82,6 +92,7 @@ func (n NumberNode) String() string {
 
 // A BooleanNode represents the boolean constant true or false.
 type BooleanNode struct {
+	Span
 	Value bool
 }
 
@@ -112,7 +123,9 @@ func (n BooleanNode) String() string {
 }
 
 // A NullNode represents the JSON null value.
-type NullNode struct{}
+type NullNode struct {
+	Span
+}
 
 func parseNull(p *parser, t token) (Node, error) {
 	return &NullNode{}, nil
@@ -128,6 +141,7 @@ func (NullNode) String() string {
 
 // A RegexNode represents a regular expression.
 type RegexNode struct {
+	Span
 	Value *regexp.Regexp
 }
 
@@ -166,6 +180,7 @@ func (n RegexNode) String() string {
 
 // A VariableNode represents a JSONata variable.
 type VariableNode struct {
+	Span
 	Name string
 }
 
@@ -185,6 +200,7 @@ func (n VariableNode) String() string {
 
 // A NameNode represents a JSON field name.
 type NameNode struct {
+	Span
 	Value   string
 	escaped bool
 }
@@ -204,6 +220,7 @@ func parseEscapedName(p *parser, t token) (Node, error) {
 
 func (n *NameNode) optimize() (Node, error) {
 	return &PathNode{
+		Span:  n.Span,
 		Steps: []Node{n},
 	}, nil
 }
@@ -226,6 +243,7 @@ func (n NameNode) Escaped() bool {
 // A PathNode represents a JSON object path. It consists of one
 // or more 'steps' or Nodes (most commonly NameNode objects).
 type PathNode struct {
+	Span
 	Steps      []Node
 	KeepArrays bool
 }
@@ -242,8 +260,122 @@ func (n PathNode) String() string {
 	return s
 }
 
+// A FocusNode represents the focus variable binding operator
+// (e.g. loans@$l). It binds each value produced by a path step
+// to a variable without changing the context value.
+type FocusNode struct {
+	Span
+	Expr Node
+	Name string
+}
+
+func parseFocus(p *parser, t token, lhs Node) (Node, error) {
+
+	name, err := parseBindingVariable(p, t)
+	if err != nil {
+		return nil, err
+	}
+
+	return &FocusNode{
+		Expr: lhs,
+		Name: name,
+	}, nil
+}
+
+func (n *FocusNode) optimize() (Node, error) {
+
+	var err error
+
+	n.Expr, err = optimizeBindingExpr(n.Expr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PathNode{
+		Span:  n.Span,
+		Steps: []Node{n},
+	}, nil
+}
+
+func (n FocusNode) String() string {
+	return fmt.Sprintf("%s@$%s", n.Expr, n.Name)
+}
+
+// An IndexNode represents the index variable binding operator
+// (e.g. items#$i). It binds the position of each value produced
+// by a path step to a variable.
+type IndexNode struct {
+	Span
+	Expr Node
+	Name string
+}
+
+func parseIndex(p *parser, t token, lhs Node) (Node, error) {
+
+	name, err := parseBindingVariable(p, t)
+	if err != nil {
+		return nil, err
+	}
+
+	return &IndexNode{
+		Expr: lhs,
+		Name: name,
+	}, nil
+}
+
+func (n *IndexNode) optimize() (Node, error) {
+
+	var err error
+
+	n.Expr, err = optimizeBindingExpr(n.Expr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PathNode{
+		Span:  n.Span,
+		Steps: []Node{n},
+	}, nil
+}
+
+func (n IndexNode) String() string {
+	return fmt.Sprintf("%s#$%s", n.Expr, n.Name)
+}
+
+// parseBindingVariable parses the variable on the right side
+// of a focus or index operator and returns its name.
+func parseBindingVariable(p *parser, t token) (string, error) {
+
+	rhs := p.parseExpression(p.bp(t.Type))
+
+	v, ok := rhs.(*VariableNode)
+	if !ok || v.Name == "" {
+		return "", newErrorHint(ErrIllegalBinding, t, rhs.String())
+	}
+
+	return v.Name, nil
+}
+
+// optimizeBindingExpr optimizes the left side of a focus or
+// index operator. Single step paths are reduced to the step
+// itself because the binding operators apply to path steps.
+func optimizeBindingExpr(node Node) (Node, error) {
+
+	node, err := node.optimize()
+	if err != nil {
+		return nil, err
+	}
+
+	if path, ok := node.(*PathNode); ok && len(path.Steps) == 1 && !path.KeepArrays {
+		return path.Steps[0], nil
+	}
+
+	return node, nil
+}
+
 // A NegationNode represents a numeric negation operation.
 type NegationNode struct {
+	Span
 	RHS Node
 }
 
@@ -266,7 +398,9 @@ func (n *NegationNode) optimize() (Node, error) {
 	// instead of waiting for evaluation.
 	if number, ok := n.RHS.(*NumberNode); ok {
 		return &NumberNode{
-			Value: -number.Value,
+			Span:    n.Span,
+			Value:   -number.Value,
+			Literal: negateLiteral(number.Literal),
 		}, nil
 	}
 
@@ -277,8 +411,22 @@ func (n NegationNode) String() string {
 	return fmt.Sprintf("-%s", n.RHS)
 }
 
+// negateLiteral returns the source text of the negation of
+// a number literal. Double negatives are cancelled out.
+func negateLiteral(s string) string {
+	switch {
+	case s == "":
+		return ""
+	case strings.HasPrefix(s, "-"):
+		return s[1:]
+	default:
+		return "-" + s
+	}
+}
+
 // A RangeNode represents the range operator.
 type RangeNode struct {
+	Span
 	LHS Node
 	RHS Node
 }
@@ -306,6 +454,7 @@ func (n RangeNode) String() string {
 
 // An ArrayNode represents an array of items.
 type ArrayNode struct {
+	Span
 	Items []Node
 }
 
@@ -315,6 +464,7 @@ func parseArray(p *parser, t token) (Node, error) {
 
 	for hasItems := p.token.Type != typeBracketClose; hasItems; { // disallow trailing commas
 
+		start := p.token.Start
 		item := p.parseExpression(0)
 
 		if p.token.Type == typeRange {
@@ -325,6 +475,7 @@ func parseArray(p *parser, t token) (Node, error) {
 				LHS: item,
 				RHS: p.parseExpression(0),
 			}
+			item.setSpan(p.lexer.span(start, p.prev.End))
 		}
 
 		items = append(items, item)
@@ -363,6 +514,7 @@ func (n ArrayNode) String() string {
 // An ObjectNode represents an object, an unordered list of
 // key-value pairs.
 type ObjectNode struct {
+	Span
 	Pairs [][2]Node
 }
 
@@ -420,6 +572,7 @@ func (n ObjectNode) String() string {
 
 // A BlockNode represents a block expression.
 type BlockNode struct {
+	Span
 	Exprs []Node
 }
 
@@ -463,7 +616,9 @@ func (n BlockNode) String() string {
 }
 
 // A WildcardNode represents the wildcard operator.
-type WildcardNode struct{}
+type WildcardNode struct {
+	Span
+}
 
 func parseWildcard(p *parser, t token) (Node, error) {
 	return &WildcardNode{}, nil
@@ -478,7 +633,9 @@ func (WildcardNode) String() string {
 }
 
 // A DescendentNode represents the descendent operator.
-type DescendentNode struct{}
+type DescendentNode struct {
+	Span
+}
 
 func parseDescendent(p *parser, t token) (Node, error) {
 	return &DescendentNode{}, nil
@@ -492,9 +649,27 @@ func (DescendentNode) String() string {
 	return "**"
 }
 
+// A ParentNode represents the parent operator.
+type ParentNode struct {
+	Span
+}
+
+func parseParent(p *parser, t token) (Node, error) {
+	return &ParentNode{}, nil
+}
+
+func (n *ParentNode) optimize() (Node, error) {
+	return n, nil
+}
+
+func (ParentNode) String() string {
+	return "%"
+}
+
 // An ObjectTransformationNode represents the object transformation
 // operator.
 type ObjectTransformationNode struct {
+	Span
 	Pattern Node
 	Updates Node
 	Deletes Node
@@ -739,7 +914,6 @@ func parseParams(s string) ([]Param, error) {
 			for _, c := range part {
 				typ, ok := parseParamType(c)
 				if !ok {
-					// TODO: Add position to this error.
 					return nil, &Error{
 						Type: ErrInvalidUnionType,
 						Hint: string(c),
@@ -756,7 +930,6 @@ func parseParams(s string) ([]Param, error) {
 
 		if opt, ok := parseParamOpt(r); ok {
 			if len(params) == 0 {
-				// TODO: Add position to this error.
 				return nil, &Error{
 					Type: ErrUnmatchedOption,
 					Hint: string(r),
@@ -769,14 +942,12 @@ func parseParams(s string) ([]Param, error) {
 
 		if r == '<' {
 			if len(params) == 0 {
-				// TODO: Add position to this error.
 				return nil, &Error{
 					Type: ErrUnmatchedSubtype,
 				}
 			}
 			n := len(params) - 1
 			if params[n].Type != ParamTypeArray && params[n].Type != ParamTypeFunc {
-				// TODO: Add position to this error.
 				return nil, &Error{
 					Type: ErrInvalidSubtype,
 					Hint: params[n].Type.String(),
@@ -792,7 +963,6 @@ func parseParams(s string) ([]Param, error) {
 			continue
 		}
 
-		// TODO: Add position to this error.
 		return nil, &Error{
 			Type: ErrInvalidParamType,
 			Hint: string(r),
@@ -802,6 +972,41 @@ func parseParams(s string) ([]Param, error) {
 	return params, nil
 }
 
+// parseSignature parses the text of a lambda's type signature
+// (without the enclosing angle brackets) into the types of its
+// parameters and, if present, its return type.
+func parseSignature(sig string) ([]Param, []Param, error) {
+
+	params, err := parseParams(sig)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// Find the colon that separates the parameter types from
+	// the return type. Colons in subtypes (e.g. "f<n:n>") are
+	// skipped.
+	var depth int
+
+	for pos, r := range sig {
+		switch r {
+		case '<', '(':
+			depth++
+		case '>', ')':
+			depth--
+		case ':':
+			if depth == 0 {
+				results, err := parseParams(sig[pos+1:])
+				if err != nil {
+					return nil, nil, err
+				}
+				return params, results, nil
+			}
+		}
+	}
+
+	return params, nil, nil
+}
+
 func getBracketedString(s string, open, close rune) string {
 
 	var depth int
@@ -830,6 +1035,7 @@ func getBracketedString(s string, open, close rune) string {
 
 // A LambdaNode represents a user-defined JSONata function.
 type LambdaNode struct {
+	Span
 	Body       Node
 	ParamNames []string
 	shorthand  bool
@@ -873,9 +1079,22 @@ func (n LambdaNode) Shorthand() bool {
 // A TypedLambdaNode represents a user-defined JSONata function
 // with a type signature.
 type TypedLambdaNode struct {
+	Span
 	*LambdaNode
 	In  []Param
 	Out []Param
+
+	// Signature is the text of the type signature without
+	// the enclosing angle brackets, e.g. "nn:n". Format and
+	// MarshalJSON use it because In and Out do not record the
+	// return types of function parameters (e.g. the ":n" in
+	// "f<n:n>"). If it is empty, they use In and Out.
+	Signature string
+}
+
+func (n *TypedLambdaNode) setSpan(span Span) {
+	n.Span = span
+	n.LambdaNode.Span = span
 }
 
 func (n *TypedLambdaNode) optimize() (Node, error) {
@@ -909,8 +1128,32 @@ func (n TypedLambdaNode) String() string {
 	return fmt.Sprintf("%s(%s)<%s>{%s}", name, strings.Join(params, ", "), strings.Join(inputs, ""), n.Body)
 }
 
+// signature returns the type signature of the lambda,
+// including the enclosing angle brackets.
+func (n TypedLambdaNode) signature() string {
+
+	if n.Signature != "" {
+		return "<" + n.Signature + ">"
+	}
+
+	s := "<"
+	for _, p := range n.In {
+		s += p.String()
+	}
+
+	if n.Out != nil {
+		s += ":"
+		for _, p := range n.Out {
+			s += p.String()
+		}
+	}
+
+	return s + ">"
+}
+
 // A PartialNode represents a partially applied function.
 type PartialNode struct {
+	Span
 	Func Node
 	Args []Node
 }
@@ -940,7 +1183,9 @@ func (n PartialNode) String() string {
 
 // A PlaceholderNode represents a placeholder argument
 // in a partially applied function.
-type PlaceholderNode struct{}
+type PlaceholderNode struct {
+	Span
+}
 
 func (n *PlaceholderNode) optimize() (Node, error) {
 	return n, nil
@@ -952,6 +1197,7 @@ func (PlaceholderNode) String() string {
 
 // A FunctionCallNode represents a call to a function.
 type FunctionCallNode struct {
+	Span
 	Func Node
 	Args []Node
 }
@@ -974,6 +1220,7 @@ func parseFunctionCall(p *parser, t token, lhs Node) (Node, error) {
 		if p.token.Type == typePlaceholder {
 			isPartial = true
 			arg = &PlaceholderNode{}
+			arg.setSpan(p.lexer.span(p.token.Start, p.token.End))
 			p.consume(typePlaceholder, true)
 		} else {
 			arg = p.parseExpression(0)
@@ -1036,17 +1283,24 @@ func isLambdaName(n Node) (bool, bool) {
 
 func parseLambdaDefinition(p *parser, shorthand bool) (Node, error) {
 
-	var params []Param
+	var params, results []Param
 
 	paramNames, err := extractParamNames(p)
 	if err != nil {
 		return nil, err
 	}
 
+	sigToken := p.token
 	sig, isTyped := extractSignature(p)
 	if isTyped {
-		params, err = parseParams(sig)
+		params, results, err = parseSignature(sig)
 		if err != nil {
+			// Errors in the type signature are reported
+			// at the start of the signature.
+			if e, ok := err.(*Error); ok {
+				e.Position = sigToken.Position
+				e.Span = p.lexer.span(sigToken.Start, p.prev.End)
+			}
 			return nil, err
 		}
 		if len(params) != len(paramNames) {
@@ -1071,6 +1325,8 @@ func parseLambdaDefinition(p *parser, shorthand bool) (Node, error) {
 	return &TypedLambdaNode{
 		LambdaNode: lambda,
 		In:         params,
+		Out:        results,
+		Signature:  sig,
 	}, nil
 }
 
@@ -1147,6 +1403,7 @@ Loop:
 
 // A PredicateNode represents a predicate expression.
 type PredicateNode struct {
+	Span
 	Expr    Node
 	Filters []Node
 }
@@ -1161,6 +1418,7 @@ func (n PredicateNode) String() string {
 
 // A GroupNode represents a group expression.
 type GroupNode struct {
+	Span
 	Expr Node
 	*ObjectNode
 }
@@ -1172,6 +1430,8 @@ func parseGroup(p *parser, t token, lhs Node) (Node, error) {
 		return nil, err
 	}
 
+	obj.setSpan(p.lexer.span(t.Start, p.prev.End))
+
 	return &GroupNode{
 		Expr:       lhs,
 		ObjectNode: obj.(*ObjectNode),
@@ -1188,9 +1448,15 @@ func (n *GroupNode) optimize() (Node, error) {
 	}
 
 	if _, isGroup := n.Expr.(*GroupNode); isGroup {
-		// TODO: Add position info.
+		// Report the error at the second group.
+		start := n.Expr.Location().End
 		return nil, &Error{
-			Type: ErrGroupGroup,
+			Type:     ErrGroupGroup,
+			Position: start.Offset,
+			Span: Span{
+				Start: start,
+				End:   n.End,
+			},
 		}
 	}
 
@@ -1209,6 +1475,7 @@ func (n GroupNode) String() string {
 
 // A ConditionalNode represents an if-then-else expression.
 type ConditionalNode struct {
+	Span
 	If   Node
 	Then Node
 	Else Node
@@ -1267,6 +1534,7 @@ func (n ConditionalNode) String() string {
 
 // An AssignmentNode represents a variable assignment.
 type AssignmentNode struct {
+	Span
 	Name  string
 	Value Node
 }
@@ -1333,6 +1601,7 @@ func (op NumericOperator) String() string {
 
 // A NumericOperatorNode represents a numeric operation.
 type NumericOperatorNode struct {
+	Span
 	Type NumericOperator
 	LHS  Node
 	RHS  Node
@@ -1423,6 +1692,7 @@ func (op ComparisonOperator) String() string {
 
 // A ComparisonOperatorNode represents a comparison operation.
 type ComparisonOperatorNode struct {
+	Span
 	Type ComparisonOperator
 	LHS  Node
 	RHS  Node
@@ -1503,6 +1773,7 @@ func (op BooleanOperator) String() string {
 
 // A BooleanOperatorNode represents a boolean operation.
 type BooleanOperatorNode struct {
+	Span
 	Type BooleanOperator
 	LHS  Node
 	RHS  Node
@@ -1549,9 +1820,88 @@ func (n BooleanOperatorNode) String() string {
 	return fmt.Sprintf("%s %s %s", n.LHS, n.Type, n.RHS)
 }
 
+// A CoalescingOperator is an operation that returns its left
+// hand side if present and its right hand side otherwise.
+type CoalescingOperator uint8
+
+// Coalescing operations supported by JSONata.
+const (
+	_ CoalescingOperator = iota
+
+	// CoalesceUndefined returns the right hand side if the
+	// left hand side is undefined.
+	CoalesceUndefined
+
+	// CoalesceFalsy returns the right hand side if the left
+	// hand side is undefined or evaluates to false.
+	CoalesceFalsy
+)
+
+func (op CoalescingOperator) String() string {
+	switch op {
+	case CoalesceUndefined:
+		return "??"
+	case CoalesceFalsy:
+		return "?:"
+	default:
+		return ""
+	}
+}
+
+// A CoalescingOperatorNode represents a coalescing operation,
+// i.e. a ?? b or a ?: b.
+type CoalescingOperatorNode struct {
+	Span
+	Type CoalescingOperator
+	LHS  Node
+	RHS  Node
+}
+
+func parseCoalescingOperator(p *parser, t token, lhs Node) (Node, error) {
+
+	var op CoalescingOperator
+
+	switch t.Type {
+	case typeCoalesce:
+		op = CoalesceUndefined
+	case typeElvis:
+		op = CoalesceFalsy
+	default: // should be unreachable
+		panicf("parseCoalescingOperator: unexpected operator %q", t.Value)
+	}
+
+	return &CoalescingOperatorNode{
+		Type: op,
+		LHS:  lhs,
+		RHS:  p.parseExpression(p.bp(t.Type)),
+	}, nil
+}
+
+func (n *CoalescingOperatorNode) optimize() (Node, error) {
+
+	var err error
+
+	n.LHS, err = n.LHS.optimize()
+	if err != nil {
+		return nil, err
+	}
+
+	n.RHS, err = n.RHS.optimize()
+	if err != nil {
+		return nil, err
+	}
+
+	return n, nil
+}
+
+func (n CoalescingOperatorNode) String() string {
+	return fmt.Sprintf("%s %s %s", n.LHS, n.Type, n.RHS)
+}
+
 // A StringConcatenationNode represents a string concatenation
 // operation.
 type StringConcatenationNode struct {
+	Span
 	LHS Node
 	RHS Node
 }
@@ -1603,6 +1953,7 @@ type SortTerm struct {
 
 // A SortNode represents a sort clause on a JSONata path step.
 type SortNode struct {
+	Span
 	Expr  Node
 	Terms []SortTerm
 }
@@ -1687,6 +2038,7 @@ func (n SortNode) String() string {
 // A FunctionApplicationNode represents a function application
 // operation.
 type FunctionApplicationNode struct {
+	Span
 	LHS Node
 	RHS Node
 }
@@ -1723,6 +2075,7 @@ func (n FunctionApplicationNode) String() string {
 // expressions. It is deliberately unexported and creates a PathNode
 // during its optimize phase.
 type dotNode struct {
+	Span
 	lhs Node
 	rhs Node
 }
@@ -1736,7 +2089,9 @@ func parseDot(p *parser, t token, lhs Node) (Node, error) {
 
 func (n *dotNode) optimize() (Node, error) {
 
-	path := &PathNode{}
+	path := &PathNode{
+		Span: n.Span,
+	}
 
 	lhs, err := n.lhs.optimize()
 	if err != nil {
@@ -1745,10 +2100,11 @@ func (n *dotNode) optimize() (Node, error) {
 
 	switch lhs := lhs.(type) {
 	case *NumberNode, *StringNode, *BooleanNode, *NullNode:
-		// TODO: Add position info.
 		return nil, &Error{
-			Type: ErrPathLiteral,
-			Hint: lhs.String(),
+			Type:     ErrPathLiteral,
+			Hint:     lhs.String(),
+			Position: lhs.Location().Start.Offset,
+			Span:     lhs.Location(),
 		}
 	case *PathNode:
 		path.Steps = lhs.Steps
@@ -1766,10 +2122,11 @@ func (n *dotNode) optimize() (Node, error) {
 
 	switch rhs := rhs.(type) {
 	case *NumberNode, *StringNode, *BooleanNode, *NullNode:
-		// TODO: Add position info.
 		return nil, &Error{
-			Type: ErrPathLiteral,
-			Hint: rhs.String(),
+			Type:     ErrPathLiteral,
+			Hint:     rhs.String(),
+			Position: rhs.Location().Start.Offset,
+			Span:     rhs.Location(),
 		}
 	case *PathNode:
 		path.Steps = append(path.Steps, rhs.Steps...)
@@ -1791,6 +2148,7 @@ func (n dotNode) String() string {
 // processing path expressions. It is deliberately unexported
 // and gets converted into a PathNode during optimization.
 type singletonArrayNode struct {
+	Span
 	lhs Node
 }
 
@@ -1803,10 +2161,12 @@ func (n *singletonArrayNode) optimize() (Node, error) {
 
 	switch lhs := lhs.(type) {
 	case *PathNode:
+		lhs.Span = n.Span
 		lhs.KeepArrays = true
 		return lhs, nil
 	default:
 		return &PathNode{
+			Span:       n.Span,
 			Steps:      []Node{lhs},
 			KeepArrays: true,
 		}, nil
@@ -1821,6 +2181,7 @@ func (n singletonArrayNode) String() string {
 // predicate expressions. It is deliberately unexported and gets
 // converted into a PredicateNode during optimization.
 type predicateNode struct {
+	Span
 	lhs Node // the context for this predicate
 	rhs Node // the predicate expression
 }
@@ -1860,25 +2221,38 @@ func (n *predicateNode) optimize() (Node, error) {
 
 	switch lhs := lhs.(type) {
 	case *GroupNode:
+		// Report the error at the predicate.
+		start := lhs.End
 		return nil, &Error{
-			// TODO: Add position info.
-			Type: ErrGroupPredicate,
+			Type:     ErrGroupPredicate,
+			Position: start.Offset,
+			Span: Span{
+				Start: start,
+				End:   n.End,
+			},
 		}
 	case *PathNode:
 		i := len(lhs.Steps) - 1
 		switch last := lhs.Steps[i].(type) {
 		case *PredicateNode:
+			last.End = n.End
 			last.Filters = append(last.Filters, rhs)
 		default:
 			step := &PredicateNode{
+				Span: Span{
+					Start: last.Location().Start,
+					End:   n.End,
+				},
 				Expr:    last,
 				Filters: []Node{rhs},
 			}
 			lhs.Steps = append(lhs.Steps[:i], step)
 		}
+		lhs.Span = n.Span
 		return lhs, nil
 	default:
 		return &PredicateNode{
+			Span:    n.Span,
 			Expr:    lhs,
 			Filters: []Node{rhs},
 		}, nil
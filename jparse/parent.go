@@ -0,0 +1,176 @@
+// Copyright 2018 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package jparse
+
+// unknownLevels means that the number of ancestors of the
+// context value cannot be determined statically, e.g. in the
+// body of a lambda, which can be called from anywhere.
+const unknownLevels = -1
+
+// checkParents returns an error if the parent operator (%)
+// is used where the context value cannot have a parent, e.g.
+// at the start of a top-level path. The input to an expression
+// has no parent, and each path step adds one level of ancestry
+// that the parent operator can refer back to.
+func checkParents(node Node) error {
+
+	var err error
+	Walk(&parentChecker{err: &err}, node)
+	return err
+}
+
+// A parentChecker is a Visitor that keeps track of the number
+// of known ancestors of the context value.
+type parentChecker struct {
+	levels int
+	err    *error
+}
+
+func (c *parentChecker) Visit(node Node) Visitor {
+
+	if node == nil || *c.err != nil {
+		return nil
+	}
+
+	switch n := node.(type) {
+	case *PathNode, *NameNode, *WildcardNode, *DescendentNode,
+		*ParentNode, *FocusNode, *IndexNode, *PredicateNode,
+		*GroupNode, *SortNode:
+		c.step(n, c.levels)
+		return nil
+
+	case *LambdaNode, *TypedLambdaNode, *ObjectTransformationNode:
+		// These are functions, and can be called with any
+		// context value.
+		c.walk(n, unknownLevels)
+		return nil
+
+	default:
+		return c
+	}
+}
+
+// walk checks an expression that is evaluated against a
+// context value with the given number of ancestors.
+func (c *parentChecker) walk(node Node, levels int) {
+
+	if node == nil || *c.err != nil {
+		return
+	}
+
+	w := &parentChecker{
+		levels: levels,
+		err:    c.err,
+	}
+
+	switch n := node.(type) {
+	case *LambdaNode:
+		Walk(w, n.Body)
+	case *TypedLambdaNode:
+		Walk(w, n.Body)
+	case *ObjectTransformationNode:
+		Walk(w, n.Pattern)
+		Walk(w, n.Updates)
+		if n.Deletes != nil {
+			Walk(w, n.Deletes)
+		}
+	default:
+		Walk(w, n)
+	}
+}
+
+// step checks a path step that is evaluated against a context
+// value with the given number of ancestors. It returns the
+// number of ancestors of the values produced by the step.
+func (c *parentChecker) step(node Node, levels int) int {
+
+	if *c.err != nil {
+		return unknownLevels
+	}
+
+	switch n := node.(type) {
+	case *NameNode, *WildcardNode, *DescendentNode:
+		return addLevel(levels)
+
+	case *ParentNode:
+		if levels == 0 {
+			*c.err = &Error{
+				Type:     ErrNoParent,
+				Token:    "%",
+				Position: n.Start.Offset,
+				Span:     n.Span,
+			}
+			return unknownLevels
+		}
+		if levels == unknownLevels {
+			return unknownLevels
+		}
+		return levels - 1
+
+	case *VariableNode:
+		switch n.Name {
+		case "":
+			// The context value.
+			return levels
+		case "$":
+			// The input, which has no parent.
+			return 0
+		default:
+			return unknownLevels
+		}
+
+	case *PathNode:
+		for i, step := range n.Steps {
+			if arr, ok := step.(*ArrayNode); ok && i == 0 {
+				// An array constructor at the start of a
+				// path does not add a level of ancestry.
+				c.walk(arr, levels)
+				continue
+			}
+			levels = c.step(step, levels)
+		}
+		return levels
+
+	case *PredicateNode:
+		levels = c.step(n.Expr, levels)
+		for _, filter := range n.Filters {
+			c.walk(filter, levels)
+		}
+		return levels
+
+	case *SortNode:
+		levels = c.step(n.Expr, levels)
+		for _, term := range n.Terms {
+			c.walk(term.Expr, levels)
+		}
+		return levels
+
+	case *GroupNode:
+		c.walk(n.ObjectNode, c.step(n.Expr, levels))
+		return addLevel(levels)
+
+	case *FocusNode:
+		// The focus operator does not change the context
+		// value.
+		c.step(n.Expr, levels)
+		return levels
+
+	case *IndexNode:
+		return c.step(n.Expr, levels)
+
+	default:
+		// Other steps (e.g. blocks and function calls) produce
+		// values whose parent is the context value.
+		c.walk(n, levels)
+		return addLevel(levels)
+	}
+}
+
+func addLevel(levels int) int {
+	if levels == unknownLevels {
+		return unknownLevels
+	}
+	return levels + 1
+}
@@ -0,0 +1,140 @@
+// Copyright 2018 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package jparse
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// A Position describes a location in a JSONata expression.
+// Line and Column are 1-based. A Position with a Line of 0
+// is invalid, i.e. the location is unknown.
+type Position struct {
+	Offset int // byte offset, starting at 0
+	Line   int // line number, starting at 1
+	Column int // column number, starting at 1 (byte count)
+}
+
+// IsValid returns true if the Position refers to a location
+// in an expression.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+func (p Position) String() string {
+	if !p.IsValid() {
+		return "-"
+	}
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
+// A Span describes a range of text in a JSONata expression.
+// Start is the position of the first byte in the range, End
+// is the position immediately after the last byte.
+type Span struct {
+	Start Position
+	End   Position
+}
+
+// Location returns the Span. Nodes and errors that embed a
+// Span use this method to report their location.
+func (s Span) Location() Span {
+	return s
+}
+
+// IsValid returns true if the Span refers to a range of text
+// in an expression.
+func (s Span) IsValid() bool {
+	return s.Start.IsValid()
+}
+
+func (s *Span) setSpan(span Span) {
+	*s = span
+}
+
+// Excerpt returns the line of the source expression that
+// contains the start of the given span, followed by a line
+// that underlines the span with carets. If the span extends
+// past the end of the line, only the first line is underlined.
+// Excerpt returns an empty string if the span is invalid.
+//
+// For example, the excerpt for an error at the second 'x'
+// in "$x + x" is:
+//
+//	$x + x
+//	     ^
+func Excerpt(src string, span Span) string {
+
+	if !span.IsValid() || span.Start.Offset > len(src) {
+		return ""
+	}
+
+	start := strings.LastIndexByte(src[:span.Start.Offset], '\n') + 1
+	end := strings.IndexByte(src[span.Start.Offset:], '\n')
+	if end < 0 {
+		end = len(src)
+	} else {
+		end += span.Start.Offset
+	}
+
+	line := strings.TrimSuffix(src[start:end], "\r")
+
+	// Preserve tabs in the indentation so that the carets
+	// line up with the source text.
+	var b strings.Builder
+	for _, r := range src[start:span.Start.Offset] {
+		if r == '\t' {
+			b.WriteRune('\t')
+		} else {
+			b.WriteRune(' ')
+		}
+	}
+
+	n := 1
+	if span.End.Offset > span.Start.Offset {
+		last := span.End.Offset
+		if last > end {
+			last = end
+		}
+		n = len([]rune(src[span.Start.Offset:last]))
+		if n == 0 {
+			n = 1
+		}
+	}
+
+	return line + "\n" + b.String() + strings.Repeat("^", n)
+}
+
+// lineOffsets returns the byte offsets of the start of each
+// line in the given string.
+func lineOffsets(s string) []int {
+
+	offsets := []int{0}
+
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\n' {
+			offsets = append(offsets, i+1)
+		}
+	}
+
+	return offsets
+}
+
+// position converts a byte offset into a Position using the
+// line offsets returned by lineOffsets.
+func position(lines []int, offset int) Position {
+
+	line := sort.Search(len(lines), func(i int) bool {
+		return lines[i] > offset
+	})
+
+	return Position{
+		Offset: offset,
+		Line:   line,
+		Column: offset - lines[line-1] + 1,
+	}
+}
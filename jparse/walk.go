@@ -0,0 +1,174 @@
+// Copyright 2018 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package jparse
+
+// A Visitor's Visit method is invoked for each node encountered
+// by Walk. If the result visitor w is not nil, Walk visits each
+// of the children of node with the visitor w, followed by a call
+// of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses a syntax tree in depth-first order. It starts
+// by calling v.Visit(node). If the visitor w returned by
+// v.Visit(node) is not nil, Walk is invoked recursively with
+// visitor w for each of the non-nil children of node, followed
+// by a call of w.Visit(nil).
+//
+// Children are visited in the order in which they appear in
+// the source expression. If node is nil, Walk does nothing.
+func Walk(v Visitor, node Node) {
+
+	if node == nil {
+		return
+	}
+
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *StringNode, *NumberNode, *BooleanNode, *NullNode,
+		*RegexNode, *VariableNode, *NameNode, *WildcardNode,
+		*DescendentNode, *ParentNode, *PlaceholderNode:
+		// Leaf nodes have no children.
+
+	case *PathNode:
+		walkList(v, n.Steps)
+
+	case *FocusNode:
+		Walk(v, n.Expr)
+
+	case *IndexNode:
+		Walk(v, n.Expr)
+
+	case *NegationNode:
+		Walk(v, n.RHS)
+
+	case *RangeNode:
+		Walk(v, n.LHS)
+		Walk(v, n.RHS)
+
+	case *ArrayNode:
+		walkList(v, n.Items)
+
+	case *ObjectNode:
+		for _, pair := range n.Pairs {
+			Walk(v, pair[0])
+			Walk(v, pair[1])
+		}
+
+	case *BlockNode:
+		walkList(v, n.Exprs)
+
+	case *ObjectTransformationNode:
+		Walk(v, n.Pattern)
+		Walk(v, n.Updates)
+		if n.Deletes != nil {
+			Walk(v, n.Deletes)
+		}
+
+	case *LambdaNode:
+		Walk(v, n.Body)
+
+	case *TypedLambdaNode:
+		Walk(v, n.Body)
+
+	case *PartialNode:
+		Walk(v, n.Func)
+		walkList(v, n.Args)
+
+	case *FunctionCallNode:
+		Walk(v, n.Func)
+		walkList(v, n.Args)
+
+	case *PredicateNode:
+		Walk(v, n.Expr)
+		walkList(v, n.Filters)
+
+	case *GroupNode:
+		Walk(v, n.Expr)
+		Walk(v, n.ObjectNode)
+
+	case *ConditionalNode:
+		Walk(v, n.If)
+		Walk(v, n.Then)
+		if n.Else != nil {
+			Walk(v, n.Else)
+		}
+
+	case *AssignmentNode:
+		Walk(v, n.Value)
+
+	case *NumericOperatorNode:
+		Walk(v, n.LHS)
+		Walk(v, n.RHS)
+
+	case *ComparisonOperatorNode:
+		Walk(v, n.LHS)
+		Walk(v, n.RHS)
+
+	case *BooleanOperatorNode:
+		Walk(v, n.LHS)
+		Walk(v, n.RHS)
+
+	case *CoalescingOperatorNode:
+		Walk(v, n.LHS)
+		Walk(v, n.RHS)
+
+	case *StringConcatenationNode:
+		Walk(v, n.LHS)
+		Walk(v, n.RHS)
+
+	case *SortNode:
+		Walk(v, n.Expr)
+		for _, term := range n.Terms {
+			Walk(v, term.Expr)
+		}
+
+	case *FunctionApplicationNode:
+		Walk(v, n.LHS)
+		Walk(v, n.RHS)
+
+	default:
+		panicf("jparse.Walk: unexpected node type %T", n)
+	}
+
+	v.Visit(nil)
+}
+
+func walkList(v Visitor, nodes []Node) {
+	for _, n := range nodes {
+		Walk(v, n)
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses a syntax tree in depth-first order. It
+// starts by calling f(node). If f returns true, Inspect invokes
+// f recursively for each of the non-nil children of node,
+// followed by a call of f(nil).
+//
+// For example, the following prints the name of every variable
+// in an expression:
+//
+//	jparse.Inspect(root, func(n jparse.Node) bool {
+//		if v, ok := n.(*jparse.VariableNode); ok {
+//			fmt.Println(v.Name)
+//		}
+//		return true
+//	})
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
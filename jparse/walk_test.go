@@ -0,0 +1,222 @@
+// Copyright 2018 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package jparse_test
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/blues/jsonata-go/jparse"
+)
+
+func TestInspectOrder(t *testing.T) {
+
+	data := []struct {
+		Input  string
+		Output []string
+	}{
+		{
+			Input: "1 + 2 * 3",
+			Output: []string{
+				"1 + 2 * 3",
+				"1",
+				"2 * 3",
+				"2",
+				"3",
+			},
+		},
+		{
+			Input: "$x ? a.b : [c, d]",
+			Output: []string{
+				"$x ? a.b : [c, d]",
+				"$x",
+				"a.b",
+				"a",
+				"b",
+				"[c, d]",
+				"c",
+				"c",
+				"d",
+				"d",
+			},
+		},
+		{
+			Input: `$sort(items, function($l, $r) { $l.n > $r.n })`,
+			Output: []string{
+				`$sort(items, function($l, $r){$l.n > $r.n})`,
+				"$sort",
+				"items",
+				"items",
+				"function($l, $r){$l.n > $r.n}",
+				"$l.n > $r.n",
+				"$l.n",
+				"$l",
+				"n",
+				"$r.n",
+				"$r",
+				"n",
+			},
+		},
+	}
+
+	for _, test := range data {
+
+		root, err := jparse.Parse(test.Input)
+		if err != nil {
+			t.Fatalf("%s: Parse: %s", test.Input, err)
+		}
+
+		var output []string
+
+		jparse.Inspect(root, func(n jparse.Node) bool {
+			if n != nil {
+				output = append(output, n.String())
+			}
+			return true
+		})
+
+		if !reflect.DeepEqual(output, test.Output) {
+			t.Errorf("%s: expected nodes %q, got %q", test.Input, test.Output, output)
+		}
+	}
+}
+
+func TestInspectAllNodes(t *testing.T) {
+
+	input := `(
+		$x := -a.b[0]{"k": %.v};
+		$f := λ($v)<n:n>{ $v * 2 };
+		$g := $f(?);
+		[1..3, "s", true, null, /re/] ~> $map(function($i) { $i ? $i : **.c });
+		$x ?? *.d & "e";
+		a@$l#$i.$l^(>c);
+		| x | {"y": 1}, ["z"] |;
+		$x = 1 and $x != 2
+	)`
+
+	root, err := jparse.Parse(input)
+	if err != nil {
+		t.Fatalf("Parse: %s", err)
+	}
+
+	var depth int
+	types := map[string]bool{}
+
+	jparse.Inspect(root, func(n jparse.Node) bool {
+		if n == nil {
+			depth--
+			return false
+		}
+		depth++
+		types[fmt.Sprintf("%T", n)] = true
+		return true
+	})
+
+	if depth != 0 {
+		t.Errorf("expected a call of f(nil) for every node, got %d unmatched calls", depth)
+	}
+
+	for _, n := range []jparse.Node{
+		&jparse.StringNode{},
+		&jparse.NumberNode{},
+		&jparse.BooleanNode{},
+		&jparse.NullNode{},
+		&jparse.RegexNode{},
+		&jparse.VariableNode{},
+		&jparse.NameNode{},
+		&jparse.PathNode{},
+		&jparse.FocusNode{},
+		&jparse.IndexNode{},
+		&jparse.NegationNode{},
+		&jparse.RangeNode{},
+		&jparse.ArrayNode{},
+		&jparse.ObjectNode{},
+		&jparse.BlockNode{},
+		&jparse.WildcardNode{},
+		&jparse.DescendentNode{},
+		&jparse.ParentNode{},
+		&jparse.ObjectTransformationNode{},
+		&jparse.LambdaNode{},
+		&jparse.TypedLambdaNode{},
+		&jparse.PartialNode{},
+		&jparse.PlaceholderNode{},
+		&jparse.FunctionCallNode{},
+		&jparse.PredicateNode{},
+		&jparse.GroupNode{},
+		&jparse.ConditionalNode{},
+		&jparse.AssignmentNode{},
+		&jparse.NumericOperatorNode{},
+		&jparse.ComparisonOperatorNode{},
+		&jparse.BooleanOperatorNode{},
+		&jparse.CoalescingOperatorNode{},
+		&jparse.StringConcatenationNode{},
+		&jparse.SortNode{},
+		&jparse.FunctionApplicationNode{},
+	} {
+		if typ := fmt.Sprintf("%T", n); !types[typ] {
+			t.Errorf("expected Inspect to visit a %s", typ)
+		}
+	}
+}
+
+type skipVisitor struct {
+	visited []string
+}
+
+func (v *skipVisitor) Visit(n jparse.Node) jparse.Visitor {
+
+	if n == nil {
+		return nil
+	}
+
+	v.visited = append(v.visited, n.String())
+
+	// Don't descend into function calls.
+	if _, ok := n.(*jparse.FunctionCallNode); ok {
+		return nil
+	}
+
+	return v
+}
+
+func TestWalkSkip(t *testing.T) {
+
+	root, err := jparse.Parse(`a & $string(b) & c`)
+	if err != nil {
+		t.Fatalf("Parse: %s", err)
+	}
+
+	v := &skipVisitor{}
+	jparse.Walk(v, root)
+
+	exp := []string{
+		`a & $string(b) & c`,
+		`a & $string(b)`,
+		"a",
+		"a",
+		"$string(b)",
+		"c",
+		"c",
+	}
+
+	if !reflect.DeepEqual(v.visited, exp) {
+		t.Errorf("expected nodes %q, got %q", exp, v.visited)
+	}
+}
+
+func TestWalkNil(t *testing.T) {
+
+	var calls int
+
+	jparse.Inspect(nil, func(jparse.Node) bool {
+		calls++
+		return true
+	})
+
+	if calls != 0 {
+		t.Errorf("expected no calls for a nil node, got %d", calls)
+	}
+}
@@ -0,0 +1,161 @@
+// Copyright 2018 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/blues/jsonata-go/jparse"
+)
+
+// ext is the file extension of the JSONata files that are
+// formatted when a directory is given on the command line.
+const ext = ".jsonata"
+
+type config struct {
+	list  bool
+	write bool
+	opts  jparse.FormatOptions
+}
+
+func main() {
+
+	var cfg config
+
+	flag.BoolVar(&cfg.list, "l", false, "list files whose formatting differs from jsonata-fmt's")
+	flag.BoolVar(&cfg.write, "w", false, "write result to (source) file instead of stdout")
+	flag.StringVar(&cfg.opts.Indent, "indent", "  ", "the `string` used for each level of indentation")
+	flag.IntVar(&cfg.opts.MaxWidth, "width", 80, "the preferred maximum line `length`")
+	flag.Usage = func() {
+		fmt.Fprintln(os.Stderr, "Syntax: jsonata-fmt [options] [path ...]")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+
+	if flag.NArg() == 0 {
+		if cfg.write {
+			fmt.Fprintln(os.Stderr, "jsonata-fmt: cannot use -w with standard input")
+			os.Exit(2)
+		}
+		if err := processFile("<standard input>", os.Stdin, os.Stdout, cfg); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+		return
+	}
+
+	var failed bool
+
+	for _, path := range flag.Args() {
+		if err := processPath(path, os.Stdout, cfg); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			failed = true
+		}
+	}
+
+	if failed {
+		os.Exit(2)
+	}
+}
+
+// processPath formats a file or, if path is a directory, all of
+// the JSONata files in the directory and its subdirectories.
+func processPath(path string, out io.Writer, cfg config) error {
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir() {
+		return processNamedFile(path, out, cfg)
+	}
+
+	return filepath.Walk(path, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() || filepath.Ext(path) != ext {
+			return nil
+		}
+		return processNamedFile(path, out, cfg)
+	})
+}
+
+func processNamedFile(path string, out io.Writer, cfg config) error {
+
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return processFile(path, f, out, cfg)
+}
+
+// processFile formats the contents of r. Depending on the config,
+// it writes the result to out, lists the filename if formatting
+// changes the file, or writes the result back to the file.
+func processFile(filename string, r io.Reader, out io.Writer, cfg config) error {
+
+	src, err := io.ReadAll(r)
+	if err != nil {
+		return err
+	}
+
+	res, err := formatSource(src, cfg.opts)
+	if err != nil {
+		return fmt.Errorf("%s: %s", filename, err)
+	}
+
+	changed := !bytes.Equal(src, res)
+
+	if cfg.list && changed {
+		fmt.Fprintln(out, filename)
+	}
+
+	if cfg.write {
+		if changed {
+			return os.WriteFile(filename, res, 0644)
+		}
+		return nil
+	}
+
+	if !cfg.list {
+		_, err = out.Write(res)
+	}
+
+	return err
+}
+
+// formatSource formats a JSONata expression, preserving its
+// comments. The result ends with a newline.
+func formatSource(src []byte, opts jparse.FormatOptions) ([]byte, error) {
+
+	expr := string(src)
+
+	root, comments, err := jparse.ParseWithComments(expr)
+	if err != nil {
+		if e, ok := err.(*jparse.Error); ok && e.IsValid() {
+			return nil, fmt.Errorf("%s: %s\n%s", e.Start, err, jparse.Excerpt(expr, e.Span))
+		}
+		return nil, err
+	}
+
+	opts.Comments = comments
+
+	res, err := jparse.Format(root, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	return []byte(strings.TrimRight(res, "\n") + "\n"), nil
+}
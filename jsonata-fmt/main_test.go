@@ -0,0 +1,117 @@
+// Copyright 2018 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/blues/jsonata-go/jparse"
+)
+
+func TestFormatSource(t *testing.T) {
+
+	data := []struct {
+		Input  string
+		Output string
+		Error  string
+	}{
+		{
+			Input:  "a+b",
+			Output: "a + b\n",
+		},
+		{
+			Input:  "/* sum */\n($x := 1; $x + 1)\n\n",
+			Output: "/* sum */\n(\n  $x := 1;\n  $x + 1\n)\n",
+		},
+		{
+			Input: "a +",
+			Error: "1:4: unexpected end of expression",
+		},
+		{
+			Input: "1 + /* one */ 2",
+			Error: "cannot format the comment /* one */",
+		},
+	}
+
+	for _, test := range data {
+
+		output, err := formatSource([]byte(test.Input), jparse.FormatOptions{})
+
+		if test.Error != "" {
+			if err == nil || !strings.HasPrefix(err.Error(), test.Error) {
+				t.Errorf("%q: expected error %q, got %v", test.Input, test.Error, err)
+			}
+			continue
+		}
+
+		if err != nil {
+			t.Errorf("%q: unexpected error: %s", test.Input, err)
+			continue
+		}
+
+		if string(output) != test.Output {
+			t.Errorf("%q: expected %q, got %q", test.Input, test.Output, output)
+		}
+	}
+}
+
+func TestProcessPath(t *testing.T) {
+
+	dir, err := os.MkdirTemp("", "jsonata-fmt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"formatted.jsonata":   "a + b\n",
+		"unformatted.jsonata": "a+b",
+		"ignored.json":        "a+b",
+	}
+
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var out bytes.Buffer
+
+	if err := processPath(dir, &out, config{list: true}); err != nil {
+		t.Fatalf("processPath: %s", err)
+	}
+
+	if exp := filepath.Join(dir, "unformatted.jsonata") + "\n"; out.String() != exp {
+		t.Errorf("-l: expected %q, got %q", exp, out.String())
+	}
+
+	out.Reset()
+
+	if err := processPath(dir, &out, config{write: true}); err != nil {
+		t.Fatalf("processPath: %s", err)
+	}
+
+	if out.Len() != 0 {
+		t.Errorf("-w: expected no output, got %q", out.String())
+	}
+
+	for name, exp := range map[string]string{
+		"formatted.jsonata":   "a + b\n",
+		"unformatted.jsonata": "a + b\n",
+		"ignored.json":        "a+b",
+	} {
+		b, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(b) != exp {
+			t.Errorf("-w: expected %s to contain %q, got %q", name, exp, b)
+		}
+	}
+}
@@ -122,11 +122,14 @@ func eval(input, expression string) (b []byte, status int, err error) {
 func jsonify(v interface{}) ([]byte, error) {
 
 	b := bytes.Buffer{}
-	e := json.NewEncoder(&b)
-	e.SetIndent("", "    ")
-	if err := e.Encode(v); err != nil {
+	err := jsonata.Encode(&b, v, jsonata.EncodeOptions{
+		Indent:     "    ",
+		EscapeHTML: true,
+	})
+	if err != nil {
 		return nil, err
 	}
 
+	b.WriteByte('\n')
 	return b.Bytes(), nil
 }
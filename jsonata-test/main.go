@@ -1,6 +1,7 @@
 package main
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
@@ -11,6 +12,7 @@ import (
 	"reflect"
 	"regexp"
 	"strings"
+	"time"
 
 	jsonata "github.com/blues/jsonata-go"
 	types "github.com/blues/jsonata-go/jtypes"
@@ -121,10 +123,6 @@ func runTest(tc testCase, dataDir string, path string) (bool, error) {
 		return false, nil
 	}
 
-	if tc.TimeLimit != 0 {
-		return false, nil
-	}
-
 	// If this test has an associated dataset, load it
 	data := tc.Data
 	if tc.Dataset != "" {
@@ -138,9 +136,12 @@ func runTest(tc testCase, dataDir string, path string) (bool, error) {
 
 	var failed bool
 	expr, unQuoted := replaceQuotesInPaths(tc.Expr)
-	got, _ := eval(expr, tc.Bindings, data)
+	got, err := eval(expr, tc, data)
+
+	resultOK := equalResults(got, tc.Result)
+	codeOK := tc.Error == "" || errorCode(err) == tc.Error
 
-	if !equalResults(got, tc.Result) {
+	if !resultOK || !codeOK {
 		failed = true
 		printTestCase(os.Stderr, tc, strings.TrimSuffix(filepath.Base(path), ".json"))
 		fmt.Fprintf(os.Stderr, "Test file: %s \n", path)
@@ -156,23 +157,15 @@ func runTest(tc testCase, dataDir string, path string) (bool, error) {
 		if unQuoted {
 			fmt.Fprintf(os.Stderr, "Unquoted: %t\n", unQuoted)
 		}
-		fmt.Fprintf(os.Stderr, "Expected Result: %v [%T]\n", tc.Result, tc.Result)
-		fmt.Fprintf(os.Stderr, "Actual Result:   %v [%T]\n", got, got)
+		if !resultOK {
+			fmt.Fprintf(os.Stderr, "Expected Result: %v [%T]\n", tc.Result, tc.Result)
+			fmt.Fprintf(os.Stderr, "Actual Result:   %v [%T]\n", got, got)
+		}
+		if !codeOK {
+			fmt.Fprintf(os.Stderr, "Actual error code: %v [%v]\n", errorCode(err), err)
+		}
 	}
 
-	// TODO this block is commented out to make staticcheck happy,
-	// but we should check that the error is the same as the js one
-	// var exp error
-	// if tc.Undefined {
-	// 	exp = jsonata.ErrUndefined
-	// } else {
-	// 	exp = convertError(tc.Error)
-	// }
-
-	// if !reflect.DeepEqual(err, exp) {
-	// TODO: Compare actual/expected errors
-	// }
-
 	return failed, nil
 }
 
@@ -267,18 +260,41 @@ func printTestCase(w io.Writer, tc testCase, name string) {
 	}
 }
 
-func eval(expression string, bindings map[string]interface{}, data interface{}) (interface{}, error) {
+func eval(expression string, tc testCase, data interface{}) (interface{}, error) {
 	expr, err := jsonata.Compile(expression)
 	if err != nil {
 		return nil, err
 	}
 
-	err = expr.RegisterVars(bindings)
+	err = expr.RegisterVars(tc.Bindings)
 	if err != nil {
 		return nil, err
 	}
 
-	return expr.Eval(data)
+	// Tests with a time limit or a maximum recursion depth
+	// expect evaluation to fail when the limit is exceeded.
+	expr.SetLimits(jsonata.Limits{
+		MaxDepth: tc.Depth,
+	})
+
+	ctx := context.Background()
+	if tc.TimeLimit > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(tc.TimeLimit)*time.Millisecond)
+		defer cancel()
+	}
+
+	return expr.EvalContext(ctx, data)
+}
+
+// errorCode returns the jsonata-js error code for an error
+// returned by jsonata-go, or an empty string if the error does
+// not have a code.
+func errorCode(err error) string {
+	if e, ok := err.(interface{ Code() string }); ok {
+		return e.Code()
+	}
+	return ""
 }
 
 func equalResults(x, y interface{}) bool {
@@ -1,6 +1,11 @@
 package main
 
-import "testing"
+import (
+	"errors"
+	"testing"
+
+	jsonata "github.com/blues/jsonata-go"
+)
 
 func TestReplaceQuotesInPaths(t *testing.T) {
 
@@ -90,3 +95,19 @@ func TestReplaceQuotesInPathsNoOp(t *testing.T) {
 		}
 	}
 }
+
+func TestErrorCode(t *testing.T) {
+
+	_, err := jsonata.Compile("1 +")
+	if code := errorCode(err); code != "S0207" {
+		t.Errorf("expected error code S0207, got %q", code)
+	}
+
+	if code := errorCode(errors.New("no code")); code != "" {
+		t.Errorf("expected no error code, got %q", code)
+	}
+
+	if code := errorCode(nil); code != "" {
+		t.Errorf("expected no error code, got %q", code)
+	}
+}
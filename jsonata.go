@@ -5,8 +5,11 @@
 package jsonata
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"reflect"
 	"sync"
 	"time"
@@ -85,10 +88,44 @@ func RegisterVars(vars map[string]interface{}) error {
 	return nil
 }
 
+// Limits restricts the resources that a single evaluation
+// of an Expr may use. A zero value for any field means that
+// the corresponding resource is unlimited.
+type Limits struct {
+
+	// MaxDepth is the maximum number of nested calls to
+	// user-defined functions and $eval. It guards against
+	// runaway recursion.
+	MaxDepth int
+
+	// MaxSteps is the maximum number of expression nodes
+	// that may be evaluated.
+	MaxSteps int
+
+	// MaxArrayLength is the maximum number of items in any
+	// array or sequence produced during evaluation. If zero,
+	// the range operator is limited to 10,000,000 items and
+	// other arrays are unlimited.
+	MaxArrayLength int
+
+	// MaxStringLength is the maximum length, in bytes, of
+	// any string produced during evaluation (e.g. by the
+	// concatenation operator or the $join and $pad functions).
+	MaxStringLength int
+}
+
 // An Expr represents a JSONata expression.
+//
+// An Expr may be evaluated by multiple goroutines at once, but
+// its RegisterExts, RegisterVars, SetLimits and SetExactNumbers
+// methods must not be called while it is being evaluated. To
+// create an Expr that cannot be modified, use a Compiler.
 type Expr struct {
-	node     jparse.Node
-	registry map[string]reflect.Value
+	node      jparse.Node
+	registry  map[string]reflect.Value
+	limits    Limits
+	exact     bool
+	immutable bool
 }
 
 // Compile parses a JSONata expression and returns an Expr
@@ -125,6 +162,14 @@ func MustCompile(expr string) *Expr {
 	return e
 }
 
+// AST returns the root node of the expression's syntax tree.
+// Use the jparse package's Walk and Inspect functions to visit
+// the nodes in the tree. The tree is shared with the Expr and
+// must not be modified.
+func (e *Expr) AST() jparse.Node {
+	return e.node
+}
+
 // Eval executes a JSONata expression against the given data
 // source. The input is typically the result of unmarshaling
 // a JSON string. The output is an object suitable for
@@ -134,16 +179,89 @@ func MustCompile(expr string) *Expr {
 // Eval can be called multiple times, with different input
 // data if required.
 func (e *Expr) Eval(data interface{}) (interface{}, error) {
+	return e.EvalContext(context.Background(), data)
+}
+
+// EvalContext is like Eval but it stops evaluating if the
+// given context is cancelled or its deadline passes. In that
+// case, EvalContext returns an EvalError of type ErrCanceled
+// or ErrDeadlineExceeded.
+func (e *Expr) EvalContext(ctx context.Context, data interface{}) (interface{}, error) {
+	return e.eval(ctx, data, nil)
+}
+
+// EvalOptions contains settings that apply to a single call
+// to EvalWith.
+type EvalOptions struct {
+
+	// Context, if non-nil, is used in the same way as the
+	// context passed to EvalContext.
+	Context context.Context
+
+	// Vars contains custom variables for use during this
+	// evaluation only. They take precedence over variables
+	// and functions of the same name registered with the
+	// Expr or at the package level.
+	Vars map[string]interface{}
+}
+
+// EvalWith is like Eval but it accepts options that apply to
+// this evaluation only. Unlike the RegisterVars method, which
+// modifies the Expr, EvalWith leaves the Expr unchanged. It
+// is safe to call EvalWith from multiple goroutines at once,
+// e.g. to evaluate the same Expr with different variables
+// for each incoming request.
+func (e *Expr) EvalWith(data interface{}, opts EvalOptions) (interface{}, error) {
+
+	vars, err := processVars(opts.Vars)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return e.eval(ctx, data, vars)
+}
+
+func (e *Expr) eval(ctx context.Context, data interface{}, vars map[string]reflect.Value) (interface{}, error) {
+
+	result, err := e.evalValue(ctx, data, vars)
+	if err != nil {
+		return nil, err
+	}
+
+	return resultInterface(result)
+}
+
+// evalValue evaluates the expression and returns the result
+// as a reflect.Value. It returns ErrUndefined if the result
+// is undefined.
+func (e *Expr) evalValue(ctx context.Context, data interface{}, vars map[string]reflect.Value) (reflect.Value, error) {
+
 	input, ok := data.(reflect.Value)
 	if !ok {
 		input = reflect.ValueOf(data)
 	}
 
-	result, err := eval(e.node, input, e.newEnv(input))
+	result, err := eval(e.node, input, e.newEnv(ctx, input, vars))
 	if err != nil {
-		return nil, err
+		return undefined, err
 	}
 
+	if !result.IsValid() {
+		return undefined, ErrUndefined
+	}
+
+	return result, nil
+}
+
+// resultInterface converts the result of an evaluation to
+// the value returned by Eval.
+func resultInterface(result reflect.Value) (interface{}, error) {
+
 	if !result.IsValid() {
 		return nil, ErrUndefined
 	}
@@ -160,22 +278,23 @@ func (e *Expr) Eval(data interface{}) (interface{}, error) {
 }
 
 // EvalBytes is like Eval but it accepts and returns byte slices
-// instead of objects.
+// instead of objects. If the Expr uses exact numbers (see
+// SetExactNumbers), numbers in the input are decoded as
+// json.Numbers, so values such as large integers pass through
+// unchanged.
 func (e *Expr) EvalBytes(data []byte) ([]byte, error) {
 
-	var v interface{}
-
-	err := json.Unmarshal(data, &v)
+	v, err := decodeJSON(data, e.exact)
 	if err != nil {
 		return nil, err
 	}
 
-	v, err = e.Eval(v)
+	result, err := e.evalValue(context.Background(), v, nil)
 	if err != nil {
 		return nil, err
 	}
 
-	return json.Marshal(v)
+	return appendJSON(nil, result, &marshalOptions)
 }
 
 // RegisterExts registers custom functions for use during
@@ -185,6 +304,10 @@ func (e *Expr) EvalBytes(data []byte) ([]byte, error) {
 // level RegisterExts function.
 func (e *Expr) RegisterExts(exts map[string]Extension) error {
 
+	if e.immutable {
+		return ErrImmutable
+	}
+
 	values, err := processExts(exts)
 	if err != nil {
 		return err
@@ -199,8 +322,16 @@ func (e *Expr) RegisterExts(exts map[string]Extension) error {
 // are only available to this Expr object. To make custom
 // variables available to all Expr objects, use the package
 // level RegisterVars function.
+//
+// RegisterVars modifies the Expr and must not be called while
+// the Expr is being evaluated. To supply variables for a single
+// evaluation, use EvalWith.
 func (e *Expr) RegisterVars(vars map[string]interface{}) error {
 
+	if e.immutable {
+		return ErrImmutable
+	}
+
 	values, err := processVars(vars)
 	if err != nil {
 		return err
@@ -210,6 +341,55 @@ func (e *Expr) RegisterVars(vars map[string]interface{}) error {
 	return nil
 }
 
+// SetLimits sets the resource limits that apply to each
+// subsequent evaluation of this Expr. When a limit is exceeded,
+// evaluation stops and the evaluation methods return an
+// EvalError of type ErrMaxDepth, ErrMaxSteps, ErrMaxArrayLength
+// or ErrMaxStringLength. A range that exceeds the range operator's
+// default limit of 10,000,000 items returns ErrMaxRangeItems.
+//
+// SetLimits panics if the Expr was created by a Compiler. Use
+// the Compiler's WithLimits method instead.
+func (e *Expr) SetLimits(limits Limits) {
+	if e.immutable {
+		panicf("SetLimits called on an Expr created by a Compiler")
+	}
+	e.limits = limits
+}
+
+// SetExactNumbers enables or disables exact arithmetic for
+// subsequent evaluations of this Expr. By default, JSONata
+// numbers are float64s, which cannot represent some decimal
+// fractions or integers larger than 2^53 exactly. In exact
+// mode:
+//
+//   - Arithmetic, negation and numeric comparisons are carried
+//     out on rational numbers and the arithmetic operators
+//     return json.Numbers.
+//   - The $sum, $max, $min, $average, $round, $number and
+//     $formatNumber functions operate on exact values and all
+//     but $formatNumber return json.Numbers.
+//   - EvalBytes decodes numbers as json.Numbers.
+//   - Number literals in the expression that a float64 cannot
+//     represent exactly (e.g. 12345678901234567891) evaluate to
+//     json.Numbers.
+//
+// Results that have no terminating decimal representation
+// (e.g. 1/3) are rounded to 34 significant digits. Results
+// that need more than around 10,000 digits are out of range
+// and cause an error. Other functions, including $power and
+// $sqrt (whose results are generally irrational), convert
+// their numeric arguments to float64s as usual.
+//
+// SetExactNumbers panics if the Expr was created by a Compiler.
+// Use the Compiler's WithExactNumbers method instead.
+func (e *Expr) SetExactNumbers(exact bool) {
+	if e.immutable {
+		panicf("SetExactNumbers called on an Expr created by a Compiler")
+	}
+	e.exact = exact
+}
+
 // String returns a string representation of an Expr.
 func (e *Expr) String() string {
 	if e.node == nil {
@@ -228,15 +408,61 @@ func (e *Expr) updateRegistry(values map[string]reflect.Value) {
 	}
 }
 
-func (e *Expr) newEnv(input reflect.Value) *environment {
+func (e *Expr) newEnv(ctx context.Context, input reflect.Value, vars map[string]reflect.Value) *environment {
+	return e.newInputEnv(ctx, e.newVarsEnv(vars), input)
+}
+
+// newVarsEnv returns an environment containing the time
+// functions, the Expr's registry and the given variables.
+// It has no evaluation state and can be shared by several
+// evaluations (see newInputEnv).
+func (e *Expr) newVarsEnv(vars map[string]reflect.Value) *environment {
 
 	tc := timeCallables(time.Now())
 
-	env := newEnvironment(baseEnv, len(tc)+len(e.registry)+1)
+	parent := baseEnv
+	if e.exact {
+		parent = exactEnv
+	}
+
+	if max := e.limits.MaxStringLength; max > 0 {
+		parent = initEnv(parent, limitedStringExts(max))
+	}
+
+	env := newEnvironment(parent, len(tc)+len(e.registry)+len(vars))
 
-	env.bind("$", input)
 	env.bindAll(tc)
 	env.bindAll(e.registry)
+	env.bindAll(vars)
+
+	return env
+}
+
+// newInputEnv returns an environment for a single evaluation
+// of the Expr against the given input. Variables assigned at
+// the top level of the expression are bound in the returned
+// environment, so they do not leak into the parent.
+func (e *Expr) newInputEnv(ctx context.Context, parent *environment, input reflect.Value) *environment {
+
+	env := newEnvironment(parent, 1)
+	env.state = newEvalState(ctx, e.limits, e.exact)
+
+	env.bind("$", input)
+
+	// Bind a copy of $eval to this evaluation so that it
+	// shares the evaluation's state when it is called
+	// indirectly (e.g. when passed to $map). Direct calls
+	// use the caller's environment (see evalFunctionCall).
+	// Extensions and variables named eval take precedence.
+	if v := parent.lookup("eval"); v.IsValid() && v.Type() == typeEvalCallable {
+		env.bind("eval", reflect.ValueOf(&evalCallable{
+			callableName: callableName{
+				name: "eval",
+			},
+			env:     env,
+			context: input,
+		}))
+	}
 
 	return env
 }
@@ -249,8 +475,8 @@ var (
 	})
 
 	nowT = mustGoCallable("now", Extension{
-		Func: func(millis int64, picture jtypes.OptionalString, tz jtypes.OptionalString) (string, error) {
-			return jlib.FromMillis(millis, picture, tz)
+		Func: func(millis int64, picture jtypes.OptionalString, tz jtypes.OptionalString, language jtypes.OptionalString) (string, error) {
+			return jlib.FromMillisLanguage(millis, picture, tz, language)
 		},
 	})
 )
@@ -282,6 +508,7 @@ func timeCallables(t time.Time) map[string]reflect.Value {
 			},
 			&jparse.PlaceholderNode{},
 			&jparse.PlaceholderNode{},
+			&jparse.PlaceholderNode{},
 		},
 	}
 
@@ -352,6 +579,31 @@ func updateGlobalRegistry(values map[string]reflect.Value) {
 	globalRegistryMutex.Unlock()
 }
 
+// decodeJSON decodes a single JSON value. If useNumber is true,
+// numbers are decoded as json.Numbers rather than float64s.
+func decodeJSON(data []byte, useNumber bool) (interface{}, error) {
+
+	if !useNumber {
+		var v interface{}
+		err := json.Unmarshal(data, &v)
+		return v, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, fmt.Errorf("invalid JSON: unexpected data after top-level value")
+	}
+
+	return v, nil
+}
+
 func validName(s string) bool {
 
 	if len(s) == 0 {
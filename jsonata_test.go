@@ -5,8 +5,9 @@
 package jsonata
 
 import (
+	"bytes"
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io/ioutil"
 	"math"
@@ -14,11 +15,15 @@ import (
 	"path/filepath"
 	"reflect"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 	"unicode/utf8"
 
+	"github.com/blues/jsonata-go/jlib"
 	"github.com/blues/jsonata-go/jparse"
 	"github.com/blues/jsonata-go/jtypes"
 )
@@ -1896,6 +1901,245 @@ func TestDescendents3(t *testing.T) {
 	})
 }
 
+func TestParentOperator(t *testing.T) {
+
+	runTestCases(t, testdata.account, []*testCase{
+		{
+			Expression: "Account.Order.Product.{ 'Product': `Product Name`, 'Order': %.OrderID, 'Account': %.%.`Account Name` }",
+			Output: []interface{}{
+				map[string]interface{}{
+					"Product": "Bowler Hat",
+					"Order":   "order103",
+					"Account": "Firefly",
+				},
+				map[string]interface{}{
+					"Product": "Trilby hat",
+					"Order":   "order103",
+					"Account": "Firefly",
+				},
+				map[string]interface{}{
+					"Product": "Bowler Hat",
+					"Order":   "order104",
+					"Account": "Firefly",
+				},
+				map[string]interface{}{
+					"Product": "Cloak",
+					"Order":   "order104",
+					"Account": "Firefly",
+				},
+			},
+		},
+		{
+			Expression: "Account.Order.Product[%.OrderID = 'order104'].SKU",
+			Output: []interface{}{
+				"040657863",
+				"0406654603",
+			},
+		},
+		{
+			Expression: "Account.Order.Product.%.OrderID",
+			Output: []interface{}{
+				"order103",
+				"order103",
+				"order104",
+				"order104",
+			},
+		},
+		{
+			Expression: "Account.Order.Product.Price.%.%.OrderID",
+			Output: []interface{}{
+				"order103",
+				"order103",
+				"order104",
+				"order104",
+			},
+		},
+		{
+			Expression: "Account.Order.Product.(%.OrderID & ': ' & `Product Name`)",
+			Output: []interface{}{
+				"order103: Bowler Hat",
+				"order103: Trilby hat",
+				"order104: Bowler Hat",
+				"order104: Cloak",
+			},
+		},
+		{
+			Expression: "(Account.Order.Product^(>%.OrderID, Price)).SKU",
+			Output: []interface{}{
+				"040657863",
+				"0406654603",
+				"0406634348",
+				"0406654608",
+			},
+		},
+		{
+			Expression: "Account.Order.Product^(>%.OrderID, Price).%.OrderID",
+			Output: []interface{}{
+				"order104",
+				"order104",
+				"order103",
+				"order103",
+			},
+		},
+		{
+			Expression: "Account.Order.Product{ %.OrderID: $sum(Price) }",
+			Output: map[string]interface{}{
+				"order103": 56.120000000000005,
+				"order104": 142.44,
+			},
+		},
+		{
+			Expression: "Account.Order[0].Product[0].$.%.OrderID",
+			Output:     "order103",
+		},
+		{
+			Expression: "Account.$.%",
+			Output:     testdata.account,
+		},
+		{
+			Expression: "%",
+			Error: &jparse.Error{
+				Type:     jparse.ErrNoParent,
+				Token:    "%",
+				Position: 0,
+			},
+		},
+		{
+			Expression: "$.%.x",
+			Error: &jparse.Error{
+				Type:     jparse.ErrNoParent,
+				Token:    "%",
+				Position: 2,
+			},
+		},
+		{
+			// The parent of a value that is not derived
+			// from the input is undefined.
+			Expression: "($f := function($v) { $v.% }; $f(Account))",
+			Error:      ErrUndefined,
+		},
+	})
+}
+
+func TestVariableBindings(t *testing.T) {
+
+	runTestCases(t, testdata.library, []*testCase{
+		{
+			Expression: "library.loans@$l.books@$b[$l.isbn=$b.isbn].{ 'title': $b.title, 'customer': $l.customer }",
+			Output: []interface{}{
+				map[string]interface{}{
+					"title":    "Structure and Interpretation of Computer Programs",
+					"customer": "10001",
+				},
+				map[string]interface{}{
+					"title":    "Compilers: Principles, Techniques, and Tools",
+					"customer": "10003",
+				},
+			},
+		},
+		{
+			Expression: "library.loans@$l.books@$b[$l.isbn=$b.isbn].customers[$l.customer=id].{ 'customer': name, 'book': $b.title, 'due': $l.return }",
+			Output: []interface{}{
+				map[string]interface{}{
+					"customer": "Joe Doe",
+					"book":     "Structure and Interpretation of Computer Programs",
+					"due":      "2016-12-05",
+				},
+				map[string]interface{}{
+					"customer": "Jason Arthur",
+					"book":     "Compilers: Principles, Techniques, and Tools",
+					"due":      "2016-10-22",
+				},
+			},
+		},
+		{
+			Expression: "library.loans@$l.books@$b[$l.isbn=$b.isbn]{ $l.customer: $b.title }",
+			Output: map[string]interface{}{
+				"10001": "Structure and Interpretation of Computer Programs",
+				"10003": "Compilers: Principles, Techniques, and Tools",
+			},
+		},
+		{
+			Expression: "library.books@$b^(>$b.copies, $b.title).$b.isbn",
+			Output: []interface{}{
+				"9780131103627",
+				"9780262510875",
+				"9780201100884",
+				"9780201079814",
+			},
+		},
+		{
+			// The context value does not change.
+			Expression: "library.books@$b.$count(books)",
+			Output: []interface{}{
+				4,
+				4,
+				4,
+				4,
+			},
+		},
+		{
+			Expression: "library.books#$i['Kernighan' in authors].{ 'title': title, 'index': $i }",
+			Output: []interface{}{
+				map[string]interface{}{
+					"title": "The C Programming Language",
+					"index": float64(1),
+				},
+				map[string]interface{}{
+					"title": "The AWK Programming Language",
+					"index": float64(2),
+				},
+			},
+		},
+		{
+			Expression: "library.books#$i[$i < 2].title",
+			Output: []interface{}{
+				"Structure and Interpretation of Computer Programs",
+				"The C Programming Language",
+			},
+		},
+		{
+			Expression: "library.books[copies = 1]#$i.(title & ' (' & $i & ')')",
+			Output: []interface{}{
+				"The AWK Programming Language (0)",
+				"Compilers: Principles, Techniques, and Tools (1)",
+			},
+		},
+		{
+			// Positions are relative to each input value.
+			Expression: "library.books[0].authors#$i.($i & ': ' & $)",
+			Output: []interface{}{
+				"0: Abelson",
+				"1: Sussman",
+			},
+		},
+		{
+			// Bindings do not outlive the path.
+			Expression: "(library.books#$i.title; $i)",
+			Error:      ErrUndefined,
+		},
+	})
+}
+
+func TestComments(t *testing.T) {
+
+	runTestCases(t, testdata.account, []*testCase{
+		{
+			Expression: `/* Sum the order totals */
+				$sum(
+					Account.Order.Product.(
+						Price /* per item */ * Quantity
+					)
+				)`,
+			Output: 336.36,
+		},
+		{
+			Expression: `Account.Order[0].OrderID /* trailing comment */`,
+			Output:     "order103",
+		},
+	})
+}
+
 func TestBlockExpressions(t *testing.T) {
 
 	runTestCases(t, nil, []*testCase{
@@ -2697,6 +2941,117 @@ func TestConditionals5(t *testing.T) {
 	})
 }
 
+func TestCoalescingOperators(t *testing.T) {
+
+	runTestCases(t, testdata.account, []*testCase{
+		{
+			Expression: []string{
+				`Account.Missing ?? "default"`,
+				`Account.Missing ?: "default"`,
+				`Account.Order[99] ?? "default"`,
+				`false ?: "default"`,
+				`0 ?: "default"`,
+				`"" ?: "default"`,
+				`[] ?: "default"`,
+				`{} ?: "default"`,
+			},
+			Output: "default",
+		},
+		{
+			Expression: []string{
+				"Account.`Account Name` ?? \"default\"",
+				"Account.`Account Name` ?: \"default\"",
+			},
+			Output: "Firefly",
+		},
+		{
+			// Only undefined values are replaced by the ?? operator.
+			Expression: []string{
+				`false ?? true`,
+				`false ?: false`,
+			},
+			Output: false,
+		},
+		{
+			Expression: `null ?? "default"`,
+			Output:     nil,
+		},
+		{
+			Expression: []string{
+				`0 ?? 1`,
+				`Account.Missing ?? Account.Other ?? 0`,
+				`Account.Missing ?: false ?: 0`,
+			},
+			Output: float64(0),
+		},
+		{
+			Expression: `Account.Order.Product.(Description.Colour ?? "None")`,
+			Output: []interface{}{
+				"Purple",
+				"Orange",
+				"Purple",
+				"Black",
+			},
+		},
+		{
+			Expression: `Account.Order.Product.(Price > 30 ?: Price)`,
+			Output: []interface{}{
+				true,
+				21.67,
+				true,
+				true,
+			},
+		},
+		{
+			// Binds more tightly than the boolean operators...
+			Expression: `Account.Missing ?? false or true`,
+			Output:     true,
+		},
+		{
+			// ...and less tightly than the arithmetic operators.
+			Expression: `Account.Missing ?? 1 + 2`,
+			Output:     float64(3),
+		},
+		{
+			Expression: `Account.Missing ?? Account.Other`,
+			Error:      ErrUndefined,
+		},
+	})
+}
+
+func TestCoalescingOperatorsEvaluateOnce(t *testing.T) {
+
+	for _, expr := range []string{
+		"$next() ?? 0",
+		"$next() ?: 0",
+	} {
+
+		var calls int
+
+		e := MustCompile(expr)
+		err := e.RegisterExts(map[string]Extension{
+			"next": {
+				Func: func() int {
+					calls++
+					return calls
+				},
+			},
+		})
+		if err != nil {
+			t.Fatalf("%s: RegisterExts: %s", expr, err)
+		}
+
+		v, err := e.Eval(nil)
+		if err != nil {
+			t.Fatalf("%s: Eval: %s", expr, err)
+		}
+
+		if v != 1 || calls != 1 {
+			t.Errorf("%s: expected result 1 after 1 call, got %v after %d call(s)", expr, v, calls)
+		}
+	}
+}
+
 func TestBooleanExpressions(t *testing.T) {
 
 	runTestCases(t, nil, []*testCase{
@@ -3681,7 +4036,10 @@ func TestFuncSort2(t *testing.T) {
 		},
 		{
 			Expression: "$sort(Account.Order.Product)",
-			Error:      fmt.Errorf("argument 1 of function sort must be an array of strings or numbers"), // TODO: Use a proper error
+			Error: &jlib.Error{
+				Func: "sort",
+				Type: jlib.ErrSortMismatch,
+			},
 		},
 	})
 }
@@ -3923,7 +4281,10 @@ func TestFuncZip(t *testing.T) {
 		},
 		{
 			Expression: `$zip()`,
-			Error:      fmt.Errorf("cannot call zip with no arguments"),
+			Error: &jlib.Error{
+				Func: "zip",
+				Type: jlib.ErrNoArgs,
+			},
 		},
 	})
 }
@@ -3962,14 +4323,20 @@ func TestFuncSum(t *testing.T) {
 				"$sum(true)",
 				`$sum({"one":1})`,
 			},
-			Error: errors.New("cannot call sum on a non-array type"), // TODO: Don't rely on error strings
+			Error: &jlib.Error{
+				Func: "sum",
+				Type: jlib.ErrNonArray,
+			},
 		},
 		{
 			Expression: []string{
 				`$sum([1,2,"3"])`,
 				"$sum([1,2,true])",
 			},
-			Error: errors.New("cannot call sum on an array with non-number types"), // TODO: Don't rely on error strings
+			Error: &jlib.Error{
+				Func: "sum",
+				Type: jlib.ErrNonNumberArray,
+			},
 		},
 		{
 			Expression: "$sum()",
@@ -4018,7 +4385,10 @@ func TestFuncSum2(t *testing.T) {
 		},
 		{
 			Expression: "$sum(Account.Order)",
-			Error:      fmt.Errorf("cannot call sum on an array with non-number types"), // TODO: relying on error strings is bad
+			Error: &jlib.Error{
+				Func: "sum",
+				Type: jlib.ErrNonNumberArray,
+			},
 		},
 	})
 }
@@ -4057,14 +4427,20 @@ func TestFuncMax(t *testing.T) {
 				`$max(true)`,
 				`$max({"one":1})`,
 			},
-			Error: fmt.Errorf("cannot call max on a non-array type"), // TODO: Don't rely on the error string
+			Error: &jlib.Error{
+				Func: "max",
+				Type: jlib.ErrNonArray,
+			},
 		},
 		{
 			Expression: []string{
 				`$max(["1","2","3"])`,
 				`$max(["1","2",3])`,
 			},
-			Error: fmt.Errorf("cannot call max on an array with non-number types"), // TODO: Don't rely on the error string
+			Error: &jlib.Error{
+				Func: "max",
+				Type: jlib.ErrNonNumberArray,
+			},
 		},
 		{
 			Expression: "$max()",
@@ -4150,14 +4526,20 @@ func TestFuncMin(t *testing.T) {
 				`$min(true)`,
 				`$min({"one":1})`,
 			},
-			Error: fmt.Errorf("cannot call min on a non-array type"), // TODO: Don't rely on error strings
+			Error: &jlib.Error{
+				Func: "min",
+				Type: jlib.ErrNonArray,
+			},
 		},
 		{
 			Expression: []string{
 				`$min(["1","2","3"])`,
 				`$min(["1","2",3])`,
 			},
-			Error: fmt.Errorf("cannot call min on an array with non-number types"), // TODO: Don't rely on error strings
+			Error: &jlib.Error{
+				Func: "min",
+				Type: jlib.ErrNonNumberArray,
+			},
 		},
 		{
 			Expression: "$min()",
@@ -4240,14 +4622,20 @@ func TestFuncAverage(t *testing.T) {
 				`$average(true)`,
 				`$average({"one":1})`,
 			},
-			Error: fmt.Errorf("cannot call average on a non-array type"), // TODO: Don't rely on the error string
+			Error: &jlib.Error{
+				Func: "average",
+				Type: jlib.ErrNonArray,
+			},
 		},
 		{
 			Expression: []string{
 				`$average(["1","2","3"])`,
 				`$average(["1","2",3])`,
 			},
-			Error: fmt.Errorf("cannot call average on an array with non-number types"), // TODO: Don't rely on the error string
+			Error: &jlib.Error{
+				Func: "average",
+				Type: jlib.ErrNonNumberArray,
+			},
 		},
 		{
 			Expression: "$average()",
@@ -4846,7 +5234,10 @@ func TestFuncReduce(t *testing.T) {
 					$seq := 1;
 					$reduce($seq, function($x){$x})
 				)`,
-			Error: fmt.Errorf("second argument of function \"reduce\" must be a function that takes two arguments"),
+			Error: &jlib.Error{
+				Func: "reduce",
+				Type: jlib.ErrReduceArity,
+			},
 		},
 	})
 }
@@ -5590,7 +5981,10 @@ func TestFuncSplit(t *testing.T) {
 		},
 		{
 			Expression: `$split("a, b, c, d", ", ", -3)`,
-			Error:      fmt.Errorf("third argument of the split function must evaluate to a positive number"), // TODO: Use a proper error for this
+			Error: &jlib.Error{
+				Func: "split",
+				Type: jlib.ErrSplitLimit,
+			},
 		},
 		{
 			Expression: []string{
@@ -5649,11 +6043,17 @@ func TestFuncJoin(t *testing.T) {
 		},
 		{
 			Expression: `$join(true, ", ")`,
-			Error:      fmt.Errorf("function join takes an array of strings"), // TODO: Use a proper error
+			Error: &jlib.Error{
+				Func: "join",
+				Type: jlib.ErrNonStringArray,
+			},
 		},
 		{
 			Expression: `$join([1,2,3], ", ")`,
-			Error:      fmt.Errorf("function join takes an array of strings"), // TODO: Use a proper error
+			Error: &jlib.Error{
+				Func: "join",
+				Type: jlib.ErrNonStringArray,
+			},
 		},
 		{
 			Expression: `$join("hello", 3)`,
@@ -5753,11 +6153,17 @@ func TestFuncReplace(t *testing.T) {
 		},
 		{
 			Expression: `$replace("hello", "l", "1", -2)`,
-			Error:      fmt.Errorf("fourth argument of function replace must evaluate to a positive number"), // TODO: Use a proper error
+			Error: &jlib.Error{
+				Func: "replace",
+				Type: jlib.ErrReplaceLimit,
+			},
 		},
 		{
 			Expression: `$replace("hello", "", "bye")`,
-			Error:      fmt.Errorf("second argument of function replace can't be an empty string"), // TODO: Use a proper error
+			Error: &jlib.Error{
+				Func: "replace",
+				Type: jlib.ErrReplaceEmpty,
+			},
 		},
 	})
 }
@@ -5853,59 +6259,115 @@ func TestFormatNumber(t *testing.T) {
 
 		{
 			Expression: `$formatNumber(20,"#;#;#")`,
-			Error:      fmt.Errorf("picture string must contain 1 or 2 subpictures"),
+			Error: &jlib.Error{
+				Func:  "formatNumber",
+				Type:  jlib.ErrNumberPicture,
+				Value: "picture string must contain 1 or 2 subpictures",
+			},
 		},
 		{
 			Expression: `$formatNumber(20,"#.0.0")`,
-			Error:      fmt.Errorf("a subpicture cannot contain more than one decimal separator"),
+			Error: &jlib.Error{
+				Func:  "formatNumber",
+				Type:  jlib.ErrNumberPicture,
+				Value: "a subpicture cannot contain more than one decimal separator",
+			},
 		},
 		{
 			Expression: `$formatNumber(20,"#0%%")`,
-			Error:      fmt.Errorf("a subpicture cannot contain more than one percent character"),
+			Error: &jlib.Error{
+				Func:  "formatNumber",
+				Type:  jlib.ErrNumberPicture,
+				Value: "a subpicture cannot contain more than one percent character",
+			},
 		},
 		{
 			Expression: `$formatNumber(20,"#0‰‰")`,
-			Error:      fmt.Errorf("a subpicture cannot contain more than one per-mille character"),
+			Error: &jlib.Error{
+				Func:  "formatNumber",
+				Type:  jlib.ErrNumberPicture,
+				Value: "a subpicture cannot contain more than one per-mille character",
+			},
 		},
 		{
 			Expression: `$formatNumber(20,"#0%‰")`,
-			Error:      fmt.Errorf("a subpicture cannot contain both percent and per-mille characters"),
+			Error: &jlib.Error{
+				Func:  "formatNumber",
+				Type:  jlib.ErrNumberPicture,
+				Value: "a subpicture cannot contain both percent and per-mille characters",
+			},
 		},
 		{
 			Expression: `$formatNumber(20,".e0")`,
-			Error:      fmt.Errorf("a mantissa part must contain at least one decimal or optional digit"),
+			Error: &jlib.Error{
+				Func:  "formatNumber",
+				Type:  jlib.ErrNumberPicture,
+				Value: "a mantissa part must contain at least one decimal or optional digit",
+			},
 		},
 		{
 			Expression: `$formatNumber(20,"0+.e0")`,
-			Error:      fmt.Errorf("a subpicture cannot contain a passive character that is both preceded by and followed by an active character"),
+			Error: &jlib.Error{
+				Func:  "formatNumber",
+				Type:  jlib.ErrNumberPicture,
+				Value: "a subpicture cannot contain a passive character that is both preceded by and followed by an active character",
+			},
 		},
 		{
 			Expression: `$formatNumber(20,"0,.e0")`,
-			Error:      fmt.Errorf("a group separator cannot be adjacent to a decimal separator"),
+			Error: &jlib.Error{
+				Func:  "formatNumber",
+				Type:  jlib.ErrNumberPicture,
+				Value: "a group separator cannot be adjacent to a decimal separator",
+			},
 		},
 		{
 			Expression: `$formatNumber(20,"0,")`,
-			Error:      fmt.Errorf("an integer part cannot end with a group separator"),
+			Error: &jlib.Error{
+				Func:  "formatNumber",
+				Type:  jlib.ErrNumberPicture,
+				Value: "an integer part cannot end with a group separator",
+			},
 		},
 		{
 			Expression: `$formatNumber(20,"0,,0")`,
-			Error:      fmt.Errorf("a subpicture cannot contain adjacent group separators"),
+			Error: &jlib.Error{
+				Func:  "formatNumber",
+				Type:  jlib.ErrNumberPicture,
+				Value: "a subpicture cannot contain adjacent group separators",
+			},
 		},
 		{
 			Expression: `$formatNumber(20,"0#.e0")`,
-			Error:      fmt.Errorf("an integer part cannot contain a decimal digit followed by an optional digit"),
+			Error: &jlib.Error{
+				Func:  "formatNumber",
+				Type:  jlib.ErrNumberPicture,
+				Value: "an integer part cannot contain a decimal digit followed by an optional digit",
+			},
 		},
 		{
 			Expression: `$formatNumber(20,"#0.#0e0")`,
-			Error:      fmt.Errorf("a fractional part cannot contain an optional digit followed by a decimal digit"),
+			Error: &jlib.Error{
+				Func:  "formatNumber",
+				Type:  jlib.ErrNumberPicture,
+				Value: "a fractional part cannot contain an optional digit followed by a decimal digit",
+			},
 		},
 		{
 			Expression: `$formatNumber(20,"#0.0e0%")`,
-			Error:      fmt.Errorf("a subpicture cannot contain a percent/per-mille character and an exponent separator"),
+			Error: &jlib.Error{
+				Func:  "formatNumber",
+				Type:  jlib.ErrNumberPicture,
+				Value: "a subpicture cannot contain a percent/per-mille character and an exponent separator",
+			},
 		},
 		{
 			Expression: `$formatNumber(20,"#0.0e0,0")`,
-			Error:      fmt.Errorf("an exponent part must consist solely of one or more decimal digits"),
+			Error: &jlib.Error{
+				Func:  "formatNumber",
+				Type:  jlib.ErrNumberPicture,
+				Value: "an exponent part must consist solely of one or more decimal digits",
+			},
 		},
 	})
 }
@@ -5934,7 +6396,10 @@ func TestFuncFormatBase(t *testing.T) {
 		},
 		{
 			Expression: "$formatBase(100, 1)",
-			Error:      fmt.Errorf("the second argument to formatBase must be between 2 and 36"),
+			Error: &jlib.Error{
+				Func: "formatBase",
+				Type: jlib.ErrFormatBase,
+			},
 			/*Error: &EvalError1{
 				Errno:    ErrInvalidBase,
 				Position: -3,
@@ -5943,7 +6408,10 @@ func TestFuncFormatBase(t *testing.T) {
 		},
 		{
 			Expression: "$formatBase(100, 37)",
-			Error:      fmt.Errorf("the second argument to formatBase must be between 2 and 36"),
+			Error: &jlib.Error{
+				Func: "formatBase",
+				Type: jlib.ErrFormatBase,
+			},
 			/*Error: &EvalError1{
 				Errno:    ErrInvalidBase,
 				Position: -3,
@@ -5953,30 +6421,227 @@ func TestFuncFormatBase(t *testing.T) {
 	})
 }
 
-func TestFuncBase64Encode(t *testing.T) {
+func TestFuncFormatInteger(t *testing.T) {
 
 	runTestCases(t, nil, []*testCase{
 		{
-			Expression: `$base64encode("hello:world")`,
-			Output:     "aGVsbG86d29ybGQ=",
+			Expression: `$formatInteger(1234, "w")`,
+			Output:     "one thousand, two hundred and thirty-four",
 		},
 		{
-			Expression: `$base64encode(nothing)`,
-			Error:      ErrUndefined,
+			Expression: `$formatInteger(21, "Ww;o")`,
+			Output:     "Twenty-First",
 		},
-	})
-}
-
-func TestFuncBase64Decode(t *testing.T) {
-
-	runTestCases(t, nil, []*testCase{
 		{
-			Expression: `$base64decode("aGVsbG86d29ybGQ=")`,
-			Output:     "hello:world",
+			Expression: `$formatInteger(1999, "I")`,
+			Output:     "MCMXCIX",
 		},
 		{
-			Expression: `$base64decode(nothing)`,
-			Error:      ErrUndefined,
+			Expression: `$formatInteger(1234567.9, "#,##0")`,
+			Output:     "1,234,567",
+		},
+		{
+			Expression: `$formatInteger(7, "000")`,
+			Output:     "007",
+		},
+		{
+			Expression: `3 ~> $formatInteger("1;o")`,
+			Output:     "3rd",
+		},
+		{
+			Expression: `$formatInteger(nothing, "w")`,
+			Error:      ErrUndefined,
+		},
+		{
+			Expression: `$formatInteger(1, "")`,
+			Error: &jlib.Error{
+				Func:  "formatInteger",
+				Type:  jlib.ErrIntegerPicture,
+				Value: "picture string cannot be empty",
+			},
+		},
+	})
+}
+
+func TestFuncParseInteger(t *testing.T) {
+
+	runTestCases(t, nil, []*testCase{
+		{
+			Expression: []string{
+				`$parseInteger("one thousand, two hundred and thirty-four", "w")`,
+				`$parseInteger("MCCXXXIV", "I")`,
+				`$parseInteger("1,234", "#,##0")`,
+				`$parseInteger("1234th", "0;o")`,
+			},
+			Output: float64(1234),
+		},
+		{
+			Expression: `"twenty-first" ~> $parseInteger("w;o")`,
+			Output:     float64(21),
+		},
+		{
+			Expression: `$parseInteger($formatInteger(-987654321, "W"), "W")`,
+			Output:     float64(-987654321),
+		},
+		{
+			Expression: `$parseInteger(nothing, "w")`,
+			Error:      ErrUndefined,
+		},
+		{
+			Expression: `$parseInteger("twelvety", "w")`,
+			Error: &jlib.Error{
+				Func:  "parseInteger",
+				Type:  jlib.ErrIntegerPicture,
+				Value: "cannot parse \"twelvety\" using picture \"w\"",
+			},
+		},
+	})
+}
+
+func TestFuncEval(t *testing.T) {
+
+	data := map[string]interface{}{
+		"a": 1,
+		"b": 2,
+		"exprs": []interface{}{
+			"a + b",
+			"a * b",
+		},
+	}
+
+	runTestCases(t, data, []*testCase{
+		{
+			Expression: []string{
+				`$eval("a + b")`,
+				`$eval("$.a + $.b")`,
+				`$eval("x + y", {"x": 1, "y": 2})`,
+				`$eval("[1, 2]") ~> $sum()`,
+			},
+			Output: float64(3),
+		},
+		{
+			Expression: `exprs.$eval($, $$)`,
+			Output: []interface{}{
+				float64(3),
+				float64(2),
+			},
+		},
+		{
+			// Indirect calls use the evaluation's input
+			// as the context.
+			Expression: `$map(exprs, $eval(?))`,
+			Output: []interface{}{
+				float64(3),
+				float64(2),
+			},
+		},
+		{
+			// The expression can see local variables, but
+			// variables it assigns are not visible outside.
+			Expression: `($x := 10; $eval("$x := $x + a"); $x)`,
+			Output:     float64(10),
+		},
+		{
+			Expression: `$eval("$twice(a)")`,
+			Exts: map[string]Extension{
+				"twice": {
+					Func: func(n float64) float64 {
+						return 2 * n
+					},
+				},
+			},
+			Output: float64(2),
+		},
+		{
+			Expression: `$eval(nothing)`,
+			Error:      ErrUndefined,
+		},
+		{
+			Expression: `$eval("nothing")`,
+			Error:      ErrUndefined,
+		},
+		{
+			Expression: `$eval(1)`,
+			Error: &ArgTypeError{
+				Func:  "eval",
+				Which: 1,
+			},
+		},
+		{
+			Expression: `$eval("1 +")`,
+			Error: &EvalError{
+				Type:  ErrEvalSyntax,
+				Value: `unexpected end of expression`,
+			},
+		},
+		{
+			Expression: `$eval("1", "2", "3")`,
+			Error: &ArgCountError{
+				Func:     "eval",
+				Expected: 2,
+				Received: 3,
+				Optional: 1,
+			},
+		},
+	})
+}
+
+func TestFuncEvalArgCount(t *testing.T) {
+
+	err := &ArgCountError{
+		Func:     "eval",
+		Expected: 2,
+		Received: 3,
+		Optional: 1,
+	}
+
+	exp := `function "eval" takes 1 to 2 arguments, got 3`
+	if got := err.Error(); got != exp {
+		t.Errorf("expected message %q, got %q", exp, got)
+	}
+}
+
+func TestFuncEvalCache(t *testing.T) {
+
+	e := MustCompile(`[1..100].$eval("$ * 2")`)
+
+	if _, err := e.Eval(nil); err != nil {
+		t.Fatalf("Eval: %s", err)
+	}
+
+	evalCache.Lock()
+	_, ok := evalCache.nodes["$ * 2"]
+	evalCache.Unlock()
+
+	if !ok {
+		t.Errorf("expected the $eval expression to be cached")
+	}
+}
+
+func TestFuncBase64Encode(t *testing.T) {
+
+	runTestCases(t, nil, []*testCase{
+		{
+			Expression: `$base64encode("hello:world")`,
+			Output:     "aGVsbG86d29ybGQ=",
+		},
+		{
+			Expression: `$base64encode(nothing)`,
+			Error:      ErrUndefined,
+		},
+	})
+}
+
+func TestFuncBase64Decode(t *testing.T) {
+
+	runTestCases(t, nil, []*testCase{
+		{
+			Expression: `$base64decode("aGVsbG86d29ybGQ=")`,
+			Output:     "hello:world",
+		},
+		{
+			Expression: `$base64decode(nothing)`,
+			Error:      ErrUndefined,
 		},
 	})
 }
@@ -6023,7 +6688,11 @@ func TestFuncNumber(t *testing.T) {
 		},
 		{
 			Expression: `$number("10e500")`,
-			Error:      fmt.Errorf("unable to cast %q to a number", "10e500"),
+			Error: &jlib.Error{
+				Func:  "number",
+				Type:  jlib.ErrCastNumber,
+				Value: "10e500",
+			},
 			/*Error: &EvalError1{
 				Errno:    ErrCastNumber,
 				Position: -10,
@@ -6032,7 +6701,11 @@ func TestFuncNumber(t *testing.T) {
 		},
 		{
 			Expression: `$number("Hello world")`,
-			Error:      fmt.Errorf("unable to cast %q to a number", "Hello world"),
+			Error: &jlib.Error{
+				Func:  "number",
+				Type:  jlib.ErrCastNumber,
+				Value: "Hello world",
+			},
 			/*Error: &EvalError1{
 				Errno:    ErrCastNumber,
 				Position: -10,
@@ -6041,7 +6714,11 @@ func TestFuncNumber(t *testing.T) {
 		},
 		{
 			Expression: `$number("1/2")`,
-			Error:      fmt.Errorf("unable to cast %q to a number", "1/2"),
+			Error: &jlib.Error{
+				Func:  "number",
+				Type:  jlib.ErrCastNumber,
+				Value: "1/2",
+			},
 			/*Error: &EvalError1{
 				Errno:    ErrCastNumber,
 				Position: -10,
@@ -6050,7 +6727,11 @@ func TestFuncNumber(t *testing.T) {
 		},
 		{
 			Expression: `$number("1234 hello")`,
-			Error:      fmt.Errorf("unable to cast %q to a number", "1234 hello"),
+			Error: &jlib.Error{
+				Func:  "number",
+				Type:  jlib.ErrCastNumber,
+				Value: "1234 hello",
+			},
 			/*Error: &EvalError1{
 				Errno:    ErrCastNumber,
 				Position: -10,
@@ -6059,7 +6740,11 @@ func TestFuncNumber(t *testing.T) {
 		},
 		{
 			Expression: `$number("")`,
-			Error:      fmt.Errorf("unable to cast %q to a number", ""),
+			Error: &jlib.Error{
+				Func:  "number",
+				Type:  jlib.ErrCastNumber,
+				Value: "",
+			},
 			/*Error: &EvalError1{
 				Errno:    ErrCastNumber,
 				Position: -10,
@@ -6068,7 +6753,11 @@ func TestFuncNumber(t *testing.T) {
 		},
 		{
 			Expression: `$number("[1]")`,
-			Error:      fmt.Errorf("unable to cast %q to a number", "[1]"),
+			Error: &jlib.Error{
+				Func:  "number",
+				Type:  jlib.ErrCastNumber,
+				Value: "[1]",
+			},
 			/*Error: &EvalError1{
 				Errno:    ErrCastNumber,
 				Position: -10,
@@ -6305,7 +6994,10 @@ func TestFuncSqrt(t *testing.T) {
 		},
 		{
 			Expression: "$sqrt(-2)",
-			Error:      fmt.Errorf("the sqrt function cannot be applied to a negative number"),
+			Error: &jlib.Error{
+				Func: "sqrt",
+				Type: jlib.ErrSqrtNegative,
+			},
 		},
 		{
 			Expression: "$sqrt(nothing)",
@@ -6349,11 +7041,17 @@ func TestFuncPower(t *testing.T) {
 		},
 		{
 			Expression: "$power(-2,1/3)",
-			Error:      fmt.Errorf("the power function has resulted in a value that cannot be represented as a JSON number"),
+			Error: &jlib.Error{
+				Func: "power",
+				Type: jlib.ErrPowerRange,
+			},
 		},
 		{
 			Expression: "$power(100,1000)",
-			Error:      fmt.Errorf("the power function has resulted in a value that cannot be represented as a JSON number"),
+			Error: &jlib.Error{
+				Func: "power",
+				Type: jlib.ErrPowerRange,
+			},
 		},
 	})
 }
@@ -7250,7 +7948,10 @@ func TestRegexMatch(t *testing.T) {
 		},
 		{
 			Expression: `$match("a, b, c, d", /ab/, -3)`,
-			Error:      fmt.Errorf("third argument of function match must evaluate to a positive number"), // TODO: use a proper error
+			Error: &jlib.Error{
+				Func: "match",
+				Type: jlib.ErrMatchLimit,
+			},
 		},
 		{
 			Expression: `$match(12345, 3)`,
@@ -7426,12 +8127,18 @@ func TestRegexReplace2(t *testing.T) {
 		{
 			Expression: `Account.Order.Product.$replace($.` + "`Product Name`" + `, /(?i)hat/,
 				function($match) { true })`,
-			Error: fmt.Errorf("third argument of function replace must be a function that returns a string"),
+			Error: &jlib.Error{
+				Func: "replace",
+				Type: jlib.ErrReplaceResult,
+			},
 		},
 		{
 			Expression: `Account.Order.Product.$replace($.` + "`Product Name`" + `, /(?i)hat/,
 				function($match) { 42 })`,
-			Error: fmt.Errorf("third argument of function replace must be a function that returns a string"),
+			Error: &jlib.Error{
+				Func: "replace",
+				Type: jlib.ErrReplaceResult,
+			},
 		},
 	})
 }
@@ -7553,6 +8260,22 @@ func TestFuncNow2(t *testing.T) {
 			Expression: `{"now": $now(), "delay": $sum([1..10000]), "later": $now()}.(now = later)`,
 			Output:     true,
 		},
+		{
+			Expression: `$now("[MNn]", "+0000", "fr") = $fromMillis($millis(), "[MNn]", "+0000", "fr")`,
+			Output:     true,
+		},
+		{
+			Expression: `$now("[FNn]", "+0000", "de") = $fromMillis($millis(), "[FNn]", "+0000", "de")`,
+			Output:     true,
+		},
+		{
+			Expression: `$now("[MNn]", "+0000", "xx")`,
+			Error: &jlib.Error{
+				Func:  "fromMillis",
+				Type:  jlib.ErrUnknownLanguage,
+				Value: "xx",
+			},
+		},
 		{
 			Expression: `$now()`,
 			Exts: map[string]Extension{
@@ -7628,7 +8351,11 @@ func TestFuncToMillis(t *testing.T) {
 		},
 		{
 			Expression: `$toMillis("foo")`,
-			Error:      fmt.Errorf(`could not parse time "foo"`),
+			Error: &jlib.Error{
+				Func:  "toMillis",
+				Type:  jlib.ErrParseTime,
+				Value: "foo",
+			},
 		},
 	})
 }
@@ -7651,125 +8378,348 @@ func TestFuncFromMillis(t *testing.T) {
 	})
 }
 
-func TestLambdaSignatures(t *testing.T) {
+func TestFuncFromMillisTimeZone(t *testing.T) {
 
 	runTestCases(t, nil, []*testCase{
 		{
-			Expression: `λ($arg)<b:b>{$not($arg)}(true)`,
-			Output:     false,
+			Expression: `$fromMillis(1509380732935, "[Y]-[M01]-[D01] [H01]:[m01] [Z]", "Europe/London")`,
+			Output:     "2017-10-30 16:25 +00:00",
 		},
 		{
-			Expression: `λ($arg)<b:b>{$not($arg)}(foo)`,
-			Output:     true,
+			Expression: `$fromMillis(1509380732935, "[Y]-[M01]-[D01] [H01]:[m01] [Z]", "Europe/Berlin")`,
+			Output:     "2017-10-30 17:25 +01:00",
 		},
 		{
-			Expression: `λ($arg)<x:b>{$not($arg)}(null)`,
-			Output:     true,
+			Expression: `$fromMillis(1509380732935, undefined, "Nowhere/Special")`,
+			Error: &jlib.Error{
+				Type:  jlib.ErrUnknownTimeZone,
+				Value: "Nowhere/Special",
+			},
 		},
+	})
+}
+
+func TestFuncDateLanguage(t *testing.T) {
+
+	runTestCases(t, nil, []*testCase{
 		{
-			Expression: `function($x,$y)<n-n:n>{$x+$y}(2, 6)`,
-			Output:     float64(8),
+			Expression: `$fromMillis(1509380732935, "[FNn], [D]. [MNn]", undefined, "de")`,
+			Output:     "Montag, 30. Oktober",
 		},
 		{
-			Expression: `[1..5].function($x,$y)<n-n:n>{$x+$y}(2, 6)`,
-			Output: []interface{}{
-				float64(8),
-				float64(8),
-				float64(8),
-				float64(8),
-				float64(8),
-			},
+			Expression: `$fromMillis(1509380732935, "[FNn,*-3] [D] [MNn,*-3]", "Europe/Paris", "fr-FR")`,
+			Output:     "Lun 30 Oct",
 		},
 		{
-			Expression: `[1..5].function($x,$y)<n-n:n>{$x+$y}(6)`,
-			Output: []interface{}{
-				float64(7),
-				float64(8),
-				float64(9),
-				float64(10),
-				float64(11),
-			},
+			Expression: `$toMillis("30. September 2018", "[D]. [MNn] [Y]", undefined, "de")`,
+			Output:     int64(1538265600000),
 		},
 		{
-			Expression: `λ($str)<s->{$uppercase($str)}("hello")`,
-			Output:     "HELLO",
+			Expression: `$toMillis("30 septiembre 2018", "[D] [MNn] [Y]", undefined, "es") ~> $fromMillis("[D] [MNn] [Y]", undefined, "it")`,
+			Output:     "30 Settembre 2018",
 		},
 		{
-			Expression: `λ($str, $prefix)<s-s>{$prefix & $str}("World", "Hello ")`,
-			Output:     "Hello World",
+			Expression: `$fromMillis(1509380732935, undefined, undefined, "xx")`,
+			Error: &jlib.Error{
+				Func:  "fromMillis",
+				Type:  jlib.ErrUnknownLanguage,
+				Value: "xx",
+			},
 		},
 		{
-			Expression: `λ($arr, $sep)<a<s>s?:s>{$join($arr, $sep)}("a")`,
-			Output:     "a",
+			Expression: `$toMillis("2024-03-10 02:30", "[Y]-[M]-[D] [H]:[m]", "America/New_York") ~> $fromMillis(undefined, "America/New_York")`,
+			Output:     "2024-03-10T03:30:00.000-04:00",
 		},
 		{
-			Expression: `λ($arr, $sep)<a<s>s?:s>{$join($arr, $sep)}("a", "-")`,
-			Output:     "a",
+			Expression: `$toMillis("2024-03-10 02:30", "[Y]-[M]-[D] [H]:[m]", "Mars/Base")`,
+			Error: &jlib.Error{
+				Type:  jlib.ErrUnknownTimeZone,
+				Value: "Mars/Base",
+			},
 		},
 		{
-			Expression: `λ($arr, $sep)<a<s>s?:s>{$join($arr, $sep)}(["a"], "-")`,
-			Output:     "a",
+			Expression: `$toMillis("30 October 2018", "[D] [MNn] [Y]", undefined, "xx")`,
+			Error: &jlib.Error{
+				Func:  "toMillis",
+				Type:  jlib.ErrUnknownLanguage,
+				Value: "xx",
+			},
 		},
+	})
+}
+
+func TestFuncDateAdd(t *testing.T) {
+
+	runTestCases(t, nil, []*testCase{
 		{
-			Expression: `λ($arr, $sep)<a<s>s?:s>{$join($arr, $sep)}(["a", "b"], "-")`,
-			Output:     "a-b",
+			Expression: `$dateAdd($toMillis("2024-03-09T12:00:00-05:00"), 1, "day", "America/New_York") ~> $fromMillis(undefined, "America/New_York")`,
+			Output:     "2024-03-10T12:00:00.000-04:00",
 		},
 		{
-			Expression: `λ($arr, $sep)<as?:s>{$join($arr, $sep)}(["a", "b"], "-")`,
-			Output:     "a-b",
+			Expression: `$toMillis("2024-01-31T10:00:00Z") ~> $dateAdd(1, "month") ~> $fromMillis()`,
+			Output:     "2024-02-29T10:00:00.000Z",
 		},
 		{
-			Expression: `λ($arr, $sep)<a<s>s?:s>{$join($arr, $sep)}([], "-")`,
-			Output:     "",
+			Expression: `$dateAdd($toMillis("2262-04-11T00:00:00Z"), 1, "day") ~> $fromMillis()`,
+			Output:     "2262-04-12T00:00:00.000Z",
 		},
 		{
-			Expression: `λ($arr, $sep)<a<s>s?:s>{$join($arr, $sep)}(foo, "-")`,
+			Expression: `$dateAdd(0, 90, "minutes")`,
+			Output:     int64(5400000),
+		},
+		{
+			Expression: `$dateAdd(nothing, 1, "day")`,
 			Error:      ErrUndefined,
 		},
 		{
-			Expression: `λ($obj)<o>{$obj}({"hello": "world"})`,
-			Output: map[string]interface{}{
-				"hello": "world",
+			Expression: `$dateAdd(0, 1, "fortnight")`,
+			Error: &jlib.Error{
+				Type:  jlib.ErrUnknownDateUnit,
+				Value: "fortnight",
 			},
 		},
+	})
+}
+
+func TestFuncDateTrunc(t *testing.T) {
+
+	runTestCases(t, nil, []*testCase{
 		{
-			Expression: `λ($arr)<a<a<n>>>{$arr}([[1]])`,
-			Output: []interface{}{
-				[]interface{}{
-					float64(1),
-				},
-			},
+			Expression: `$toMillis("2018-09-30T15:58:05.762Z") ~> $dateTrunc("week") ~> $fromMillis()`,
+			Output:     "2018-09-24T00:00:00.000Z",
 		},
 		{
-			Expression: `λ($num)<(ns)-:n>{$number($num)}(5)`,
-			Output:     float64(5),
+			Expression: `$toMillis("2018-09-30T15:58:05.762Z") ~> $dateTrunc("day", "Asia/Tokyo") ~> $fromMillis(undefined, "Asia/Tokyo")`,
+			Output:     "2018-10-01T00:00:00.000+09:00",
 		},
 		{
-			Expression: `λ($num)<(ns)-:n>{$number($num)}("5")`,
-			Output:     float64(5),
+			Expression: `$dateTrunc(nothing, "day")`,
+			Error:      ErrUndefined,
 		},
+	})
+}
+
+func TestFuncDateDiff(t *testing.T) {
+
+	runTestCases(t, nil, []*testCase{
 		{
-			Expression: `[1..5].λ($num)<(ns)-:n>{$number($num)}()`,
-			Output: []interface{}{
-				float64(1),
-				float64(2),
-				float64(3),
-				float64(4),
-				float64(5),
-			},
+			Expression: `$dateDiff($toMillis("2024-03-09T12:00:00-05:00"), $toMillis("2024-03-10T12:00:00-04:00"), "days", "America/New_York")`,
+			Output:     int64(1),
 		},
 		{
-			Expression: `
-				(
-					$twice := function($f)<f:f>{function($x)<n:n>{$f($f($x))}};
-					$add2 := function($x)<n:n>{$x+2};
-					$add4 := $twice($add2);
-					$add4(5)
-				)`,
-			Output: float64(9),
+			Expression: `$dateDiff($toMillis("2024-03-09T12:00:00-05:00"), $toMillis("2024-03-10T12:00:00-04:00"), "hours")`,
+			Output:     int64(23),
 		},
 		{
-			Expression: `
+			Expression: []string{
+				`$dateDiff(nothing, 0, "days")`,
+				`$dateDiff(0, nothing, "days")`,
+			},
+			Error: ErrUndefined,
+		},
+	})
+}
+
+func TestFuncDatePart(t *testing.T) {
+
+	runTestCases(t, nil, []*testCase{
+		{
+			Expression: `$toMillis("2021-01-03T23:30:00Z") ~> $datePart("isoWeek")`,
+			Output:     53,
+		},
+		{
+			Expression: `$toMillis("2021-01-03T23:30:00Z") ~> $datePart("isoWeek", "Europe/Paris")`,
+			Output:     1,
+		},
+		{
+			Expression: `$toMillis("2021-01-03T23:30:00Z") ~> $datePart("ISOWeeks")`,
+			Output:     53,
+		},
+		{
+			Expression: `$datePart(nothing, "year")`,
+			Error:      ErrUndefined,
+		},
+		{
+			Expression: `$datePart(0, "century")`,
+			Error: &jlib.Error{
+				Func:  "datePart",
+				Type:  jlib.ErrUnknownDatePart,
+				Value: "century",
+			},
+		},
+	})
+}
+
+func TestFuncParseDuration(t *testing.T) {
+
+	runTestCases(t, nil, []*testCase{
+		{
+			Expression: `$parseDuration("P1DT2H")`,
+			Output:     int64(93600000),
+		},
+		{
+			Expression: `$fromMillis($toMillis("2017-10-30T16:25:32.935Z") + $parseDuration("PT1H"))`,
+			Output:     "2017-10-30T17:25:32.935Z",
+		},
+		{
+			Expression: `"PT15M" ~> $parseDuration()`,
+			Output:     int64(900000),
+		},
+		{
+			Expression: `$parseDuration(nothing)`,
+			Error:      ErrUndefined,
+		},
+		{
+			Expression: `$parseDuration("15 minutes")`,
+			Error: &jlib.Error{
+				Func:  "parseDuration",
+				Type:  jlib.ErrParseDuration,
+				Value: "15 minutes",
+			},
+		},
+	})
+}
+
+func TestFuncFormatDuration(t *testing.T) {
+
+	runTestCases(t, nil, []*testCase{
+		{
+			Expression: []string{
+				`$formatDuration(93600000)`,
+				`$formatDuration($parseDuration("PT26H"))`,
+			},
+			Output: "P1DT2H",
+		},
+		{
+			Expression: `$formatDuration($toMillis("2017-10-31T18:30:05Z") - $toMillis("2017-10-30T16:25:00Z"), "[H]:[m01]:[s01]")`,
+			Output:     "26:05:05",
+		},
+		{
+			Expression: `$formatDuration(nothing)`,
+			Error:      ErrUndefined,
+		},
+	})
+}
+
+func TestLambdaSignatures(t *testing.T) {
+
+	runTestCases(t, nil, []*testCase{
+		{
+			Expression: `λ($arg)<b:b>{$not($arg)}(true)`,
+			Output:     false,
+		},
+		{
+			Expression: `λ($arg)<b:b>{$not($arg)}(foo)`,
+			Output:     true,
+		},
+		{
+			Expression: `λ($arg)<x:b>{$not($arg)}(null)`,
+			Output:     true,
+		},
+		{
+			Expression: `function($x,$y)<n-n:n>{$x+$y}(2, 6)`,
+			Output:     float64(8),
+		},
+		{
+			Expression: `[1..5].function($x,$y)<n-n:n>{$x+$y}(2, 6)`,
+			Output: []interface{}{
+				float64(8),
+				float64(8),
+				float64(8),
+				float64(8),
+				float64(8),
+			},
+		},
+		{
+			Expression: `[1..5].function($x,$y)<n-n:n>{$x+$y}(6)`,
+			Output: []interface{}{
+				float64(7),
+				float64(8),
+				float64(9),
+				float64(10),
+				float64(11),
+			},
+		},
+		{
+			Expression: `λ($str)<s->{$uppercase($str)}("hello")`,
+			Output:     "HELLO",
+		},
+		{
+			Expression: `λ($str, $prefix)<s-s>{$prefix & $str}("World", "Hello ")`,
+			Output:     "Hello World",
+		},
+		{
+			Expression: `λ($arr, $sep)<a<s>s?:s>{$join($arr, $sep)}("a")`,
+			Output:     "a",
+		},
+		{
+			Expression: `λ($arr, $sep)<a<s>s?:s>{$join($arr, $sep)}("a", "-")`,
+			Output:     "a",
+		},
+		{
+			Expression: `λ($arr, $sep)<a<s>s?:s>{$join($arr, $sep)}(["a"], "-")`,
+			Output:     "a",
+		},
+		{
+			Expression: `λ($arr, $sep)<a<s>s?:s>{$join($arr, $sep)}(["a", "b"], "-")`,
+			Output:     "a-b",
+		},
+		{
+			Expression: `λ($arr, $sep)<as?:s>{$join($arr, $sep)}(["a", "b"], "-")`,
+			Output:     "a-b",
+		},
+		{
+			Expression: `λ($arr, $sep)<a<s>s?:s>{$join($arr, $sep)}([], "-")`,
+			Output:     "",
+		},
+		{
+			Expression: `λ($arr, $sep)<a<s>s?:s>{$join($arr, $sep)}(foo, "-")`,
+			Error:      ErrUndefined,
+		},
+		{
+			Expression: `λ($obj)<o>{$obj}({"hello": "world"})`,
+			Output: map[string]interface{}{
+				"hello": "world",
+			},
+		},
+		{
+			Expression: `λ($arr)<a<a<n>>>{$arr}([[1]])`,
+			Output: []interface{}{
+				[]interface{}{
+					float64(1),
+				},
+			},
+		},
+		{
+			Expression: `λ($num)<(ns)-:n>{$number($num)}(5)`,
+			Output:     float64(5),
+		},
+		{
+			Expression: `λ($num)<(ns)-:n>{$number($num)}("5")`,
+			Output:     float64(5),
+		},
+		{
+			Expression: `[1..5].λ($num)<(ns)-:n>{$number($num)}()`,
+			Output: []interface{}{
+				float64(1),
+				float64(2),
+				float64(3),
+				float64(4),
+				float64(5),
+			},
+		},
+		{
+			Expression: `
+				(
+					$twice := function($f)<f:f>{function($x)<n:n>{$f($f($x))}};
+					$add2 := function($x)<n:n>{$x+2};
+					$add4 := $twice($add2);
+					$add4(5)
+				)`,
+			Output: float64(9),
+		},
+		{
+			Expression: `
 				(
 					$twice := function($f)<f<n:n>:f<n:n>>{function($x)<n:n>{$f($f($x))}};
 					$add2 := function($x)<n:n>{$x+2};
@@ -7781,9 +8731,9 @@ func TestLambdaSignatures(t *testing.T) {
 		{
 			Expression: `λ($arg)<n<n>>{$arg}(5)`,
 			Error: &jparse.Error{
-				// TODO: Get position info.
-				Type: jparse.ErrInvalidSubtype,
-				Hint: "n",
+				Type:     jparse.ErrInvalidSubtype,
+				Hint:     "n",
+				Position: 8,
 			},
 		},
 	})
@@ -7882,9 +8832,9 @@ func TestLambdaSignatureViolations(t *testing.T) {
 		{
 			Expression: `λ($arr)<(sa<n>)>>{$arr}([[1]])`,
 			Error: &jparse.Error{
-				// TODO: Get position info.
-				Type: jparse.ErrInvalidUnionType,
-				Hint: "<",
+				Type:     jparse.ErrInvalidUnionType,
+				Hint:     "<",
+				Position: 8,
 			},
 		},
 	})
@@ -7945,32 +8895,1316 @@ func TestTransform(t *testing.T) {
 	})
 }
 
-// Helper functions
+func TestStructTags(t *testing.T) {
 
-type compareFunc func(interface{}, interface{}) bool
+	type sensor struct {
+		ID     string `json:"id"`
+		Secret string `json:"-"`
+		Note   string `json:"note,omitempty"`
+	}
 
-func runTestCases(t *testing.T, input interface{}, tests []*testCase) {
-	runTestCasesFunc(t, reflect.DeepEqual, input, tests)
+	type reading struct {
+		Temp     float64 `json:"env_temp"`
+		Humidity float64 `json:"humidity,omitempty"`
+		*sensor
+		Tags []string `json:"tags,omitempty"`
+	}
+
+	input := []reading{
+		{
+			Temp:     21.5,
+			Humidity: 40,
+			sensor: &sensor{
+				ID:     "a",
+				Secret: "x",
+			},
+		},
+		{
+			Temp: 19,
+			sensor: &sensor{
+				ID:   "b",
+				Note: "n",
+			},
+			Tags: []string{"outdoor"},
+		},
+		{
+			Temp: 25,
+		},
+	}
+
+	runTestCases(t, input, []*testCase{
+		{
+			Expression: `env_temp`,
+			Output: []interface{}{
+				21.5,
+				float64(19),
+				float64(25),
+			},
+		},
+		{
+			// Fields with omitempty are omitted when empty.
+			Expression: `humidity`,
+			Output:     float64(40),
+		},
+		{
+			// Fields of embedded structs are promoted. Fields
+			// of nil embedded structs are omitted.
+			Expression: `id`,
+			Output: []interface{}{
+				"a",
+				"b",
+			},
+		},
+		{
+			Expression: []string{
+				`Temp`,
+				`Secret`,
+				`sensor`,
+				`$[0].note`,
+			},
+			Error: ErrUndefined,
+		},
+		{
+			Expression: `$[1].*`,
+			Output: []interface{}{
+				float64(19),
+				"b",
+				"n",
+				"outdoor",
+			},
+		},
+		{
+			Expression: `**.note`,
+			Output:     "n",
+		},
+		{
+			Expression: `$keys($[0])`,
+			Output: []string{
+				"env_temp",
+				"humidity",
+				"id",
+			},
+		},
+		{
+			Expression: `$each($[1], function($v, $k) { $k })`,
+			Output: []interface{}{
+				"env_temp",
+				"id",
+				"note",
+				"tags",
+			},
+		},
+		{
+			Expression: `$sift($[1], function($v) { $type($v) = "string" })`,
+			Output: map[string]interface{}{
+				"id":   "b",
+				"note": "n",
+			},
+		},
+		{
+			Expression: `$spread($[0])`,
+			Output: []interface{}{
+				map[string]interface{}{
+					"env_temp": 21.5,
+				},
+				map[string]interface{}{
+					"humidity": float64(40),
+				},
+				map[string]interface{}{
+					"id": "a",
+				},
+			},
+		},
+	})
 }
 
-func runTestCasesFunc(t *testing.T, compare compareFunc, input interface{}, tests []*testCase) {
+// textLevel implements encoding.TextMarshaler.
+type textLevel int
 
-	for _, test := range tests {
-		if test.Skip {
-			t.Logf("Skipping: %q", test.Expression)
-			continue
-		}
-		runTestCase(t, compare, input, test)
-	}
+func (l textLevel) MarshalText() ([]byte, error) {
+	return []byte([]string{"debug", "info", "warning"}[l]), nil
 }
 
-func runTestCase(t *testing.T, equal compareFunc, input interface{}, test *testCase) {
+// jsonPoint implements json.Marshaler with a pointer receiver.
+type jsonPoint struct {
+	x, y float64
+}
 
-	var exps []string
+func (p *jsonPoint) MarshalJSON() ([]byte, error) {
+	return json.Marshal([]float64{p.x, p.y})
+}
 
-	switch e := test.Expression.(type) {
-	case string:
-		exps = append(exps, e)
+func TestMarshalers(t *testing.T) {
+
+	data := map[string]interface{}{
+		"time":  time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		"raw":   json.RawMessage(`{"a": [1, 2, {"b": "c"}], "n": null}`),
+		"big":   json.Number("12345678901234567890"),
+		"price": json.Number("1.50"),
+		"level": textLevel(2),
+		"point": &jsonPoint{x: 1, y: 2},
+	}
+
+	runTestCases(t, data, []*testCase{
+		{
+			Expression: []string{
+				`time & ""`,
+				`$string(time)`,
+			},
+			Output: "2020-01-02T03:04:05Z",
+		},
+		{
+			Expression: `$substring(time, 0, 4)`,
+			Output:     "2020",
+		},
+		{
+			Expression: `$type(time)`,
+			Output:     "string",
+		},
+		{
+			// Functions that accept the original type get
+			// the original value.
+			Expression: `$year(time)`,
+			Exts: map[string]Extension{
+				"year": {
+					Func: func(t time.Time) int { return t.Year() },
+				},
+			},
+			Output: 2020,
+		},
+		{
+			Expression: `raw.a[2].b`,
+			Output:     "c",
+		},
+		{
+			Expression: `raw.a[0] + 1`,
+			Output:     float64(2),
+		},
+		{
+			Expression: `$sort($keys(raw))`,
+			Output: []interface{}{
+				"a",
+				"n",
+			},
+		},
+		{
+			// Numbers are not converted unless they are
+			// used in a calculation.
+			Expression: `big`,
+			Output:     json.Number("12345678901234567890"),
+		},
+		{
+			Expression: `$string(big)`,
+			Output:     "12345678901234567890",
+		},
+		{
+			Expression: `$type(price)`,
+			Output:     "number",
+		},
+		{
+			Expression: []string{
+				`price * 2`,
+				`$sum([price, price])`,
+				`$round(price) + 1`,
+			},
+			Output: float64(3),
+		},
+		{
+			Expression: []string{
+				`price = 1.5`,
+				`price > 1`,
+				`level = "warning"`,
+				`$contains(level, "warn")`,
+			},
+			Output: true,
+		},
+		{
+			Expression: `point[1]`,
+			Output:     float64(2),
+		},
+		{
+			Expression: `$count(point)`,
+			Output:     2,
+		},
+	})
+}
+
+// countedDate implements encoding.TextMarshaler and counts the
+// number of times that it is marshalled.
+type countedDate struct {
+	year, day int
+	calls     *int32
+}
+
+func (d countedDate) MarshalText() ([]byte, error) {
+	atomic.AddInt32(d.calls, 1)
+	return []byte(fmt.Sprintf("%04d-%03d", d.year, d.day)), nil
+}
+
+func TestMarshalerSort(t *testing.T) {
+
+	exprs := []string{
+		`$sort(dates)`,
+		`dates^($)`,
+		`$sort(dates, function($a, $b) { $a > $b })`,
+	}
+
+	for _, expr := range exprs {
+
+		var calls int32
+
+		dates := make([]countedDate, 100)
+		for i := range dates {
+			dates[i] = countedDate{
+				year:  1900 + (i*37)%100,
+				day:   i,
+				calls: &calls,
+			}
+		}
+
+		data := map[string]interface{}{
+			"dates": dates,
+		}
+
+		res, err := MustCompile(expr).Eval(data)
+		if err != nil {
+			t.Fatalf("%s: %s", expr, err)
+		}
+
+		// Each date should be marshalled a fixed number of
+		// times, not once per comparison.
+		if n := atomic.LoadInt32(&calls); n > int32(2*len(dates)) {
+			t.Errorf("%s: expected at most %d calls to MarshalText, got %d", expr, 2*len(dates), n)
+		}
+
+		items, ok := res.([]interface{})
+		if !ok || len(items) != len(dates) {
+			t.Errorf("%s: expected %d results, got %v", expr, len(dates), res)
+			continue
+		}
+
+		for i := 1; i < len(items); i++ {
+			prev, _ := jtypes.AsString(reflect.ValueOf(items[i-1]))
+			s, _ := jtypes.AsString(reflect.ValueOf(items[i]))
+			if prev > s {
+				t.Errorf("%s: results are not sorted: %q > %q", expr, prev, s)
+				break
+			}
+		}
+	}
+}
+
+// pointerDate implements json.Marshaler by reading a value
+// through a pointer, so its encoding changes when the value
+// it points to changes.
+type pointerDate struct {
+	p *int
+}
+
+func (d pointerDate) MarshalJSON() ([]byte, error) {
+	return []byte(strconv.Itoa(*d.p)), nil
+}
+
+func TestMarshalerChanges(t *testing.T) {
+
+	n := 1
+	data := map[string]interface{}{
+		"foo": pointerDate{p: &n},
+	}
+
+	e := MustCompile(`foo + 0`)
+
+	for _, want := range []float64{1, 2, 3} {
+
+		n = int(want)
+
+		res, err := e.Eval(data)
+		if err != nil {
+			t.Fatalf("foo = %d: %s", n, err)
+		}
+
+		if res != want {
+			t.Errorf("foo = %d: expected %v, got %v", n, want, res)
+		}
+	}
+}
+
+func TestExactNumbers(t *testing.T) {
+
+	data := map[string]interface{}{
+		"big":    json.Number("9007199254740993"),
+		"prices": []interface{}{json.Number("0.1"), json.Number("0.2"), 0.3},
+		"id":     json.Number("12345678901234567891"),
+		"huge":   json.Number("1e400"),
+		"vast":   json.Number("1e20000"),
+		"wide":   json.Number("1e9000"),
+		"thin":   json.Number("1e-9000"),
+	}
+
+	tests := []struct {
+		Expression string
+		Output     interface{}
+		Error      error
+	}{
+		{
+			Expression: `0.1 + 0.2`,
+			Output:     json.Number("0.3"),
+		},
+		{
+			Expression: `0.1 + 0.2 = 0.3`,
+			Output:     true,
+		},
+		{
+			Expression: `big + 1`,
+			Output:     json.Number("9007199254740994"),
+		},
+		{
+			Expression: `big > 9007199254740992`,
+			Output:     true,
+		},
+		{
+			Expression: `-big`,
+			Output:     json.Number("-9007199254740993"),
+		},
+		{
+			Expression: `12345678901234567891 + 1`,
+			Output:     json.Number("12345678901234567892"),
+		},
+		{
+			Expression: `-12345678901234567891`,
+			Output:     json.Number("-12345678901234567891"),
+		},
+		{
+			Expression: `id = 12345678901234567891`,
+			Output:     true,
+		},
+		{
+			Expression: `id = 12345678901234567890`,
+			Output:     false,
+		},
+		{
+			// Numbers beyond the range of float64 can
+			// still be used in calculations.
+			Expression: `huge - huge / 2 = huge / 2`,
+			Output:     true,
+		},
+		{
+			Expression: `1.5 * 4`,
+			Output:     json.Number("6"),
+		},
+		{
+			Expression: `1 / 8`,
+			Output:     json.Number("0.125"),
+		},
+		{
+			// Results without a terminating decimal
+			// representation are rounded to 34 digits.
+			Expression: `2 / 3`,
+			Output:     json.Number("0.6666666666666666666666666666666667"),
+		},
+		{
+			Expression: `-7.5 % 2`,
+			Output:     json.Number("-1.5"),
+		},
+		{
+			Expression: `$sum(prices)`,
+			Output:     json.Number("0.6"),
+		},
+		{
+			Expression: `$max([id, 1])`,
+			Output:     json.Number("12345678901234567891"),
+		},
+		{
+			Expression: `$min([id, big])`,
+			Output:     json.Number("9007199254740993"),
+		},
+		{
+			Expression: `$max(prices)`,
+			Output:     json.Number("0.3"),
+		},
+		{
+			Expression: `$average([0.1, 0.2])`,
+			Output:     json.Number("0.15"),
+		},
+		{
+			Expression: `$average([1, 2, 2])`,
+			Output:     json.Number("1.666666666666666666666666666666667"),
+		},
+		{
+			Expression: `$average(["1"])`,
+			Error: &jlib.Error{
+				Func: "average",
+				Type: jlib.ErrNonNumberArray,
+			},
+		},
+		{
+			Expression: `$number("12345678901234567891")`,
+			Output:     json.Number("12345678901234567891"),
+		},
+		{
+			Expression: `$number("0.1") + $number("0.2")`,
+			Output:     json.Number("0.3"),
+		},
+		{
+			Expression: `$number(id)`,
+			Output:     json.Number("12345678901234567891"),
+		},
+		{
+			Expression: `$number(true)`,
+			Output:     json.Number("1"),
+		},
+		{
+			Expression: `$number("1e99999")`,
+			Error: &jlib.Error{
+				Func:  "number",
+				Type:  jlib.ErrCastNumber,
+				Value: "1e99999",
+			},
+		},
+		{
+			Expression: `$round(2.675, 2)`,
+			Output:     json.Number("2.68"),
+		},
+		{
+			Expression: `$round(big, -1)`,
+			Output:     json.Number("9007199254740990"),
+		},
+		{
+			Expression: `$round(0.125, 2)`,
+			Output:     json.Number("0.12"),
+		},
+		{
+			Expression: `$formatNumber(big, "#,##0.00")`,
+			Output:     "9,007,199,254,740,993.00",
+		},
+		{
+			Expression: `$formatNumber(1.005, "0.00")`,
+			Output:     "1.00",
+		},
+		{
+			Expression: `$formatNumber(0.1 + 0.2, "0.0e0")`,
+			Output:     "3.0e-1",
+		},
+		{
+			Expression: `1 / 0`,
+			Error: &EvalError{
+				Type:  ErrNumberInf,
+				Value: "/",
+			},
+		},
+		{
+			Expression: `0 % 0`,
+			Error: &EvalError{
+				Type:  ErrNumberNaN,
+				Value: "%",
+			},
+		},
+		{
+			Expression: `vast * 2`,
+			Error: &EvalError{
+				Type:  ErrNumberInf,
+				Value: "*",
+			},
+		},
+		{
+			Expression: `$round(vast)`,
+			Error: &jlib.Error{
+				Func: "round",
+				Type: jlib.ErrExactRange,
+			},
+		},
+		{
+			Expression: `$formatNumber(vast, "0")`,
+			Error: &jlib.Error{
+				Func: "formatNumber",
+				Type: jlib.ErrExactRange,
+			},
+		},
+		{
+			// Results are limited to around 10,000 digits.
+			Expression: `wide * wide`,
+			Error: &EvalError{
+				Type:  ErrNumberInf,
+				Value: "*",
+			},
+		},
+		{
+			Expression: `thin / wide`,
+			Error: &EvalError{
+				Type:  ErrNumberInf,
+				Value: "/",
+			},
+		},
+		{
+			Expression: `wide * 1000 = 1000 * wide`,
+			Output:     true,
+		},
+		{
+			Expression: `$round(1.5, 10000)`,
+			Output:     json.Number("1.5"),
+		},
+		{
+			Expression: `$round(1.5, 1000000)`,
+			Error: &jlib.Error{
+				Func: "round",
+				Type: jlib.ErrExactRange,
+			},
+		},
+		{
+			Expression: `$round(1.5, -1000000)`,
+			Error: &jlib.Error{
+				Func: "round",
+				Type: jlib.ErrExactRange,
+			},
+		},
+	}
+
+	c := NewCompiler().WithExactNumbers(true)
+
+	for _, test := range tests {
+
+		e, err := c.Compile(test.Expression)
+		if err != nil {
+			t.Errorf("%s: Compile: %s", test.Expression, err)
+			continue
+		}
+
+		output, err := e.Eval(data)
+		clearErrorSpan(err, test.Error)
+
+		if !reflect.DeepEqual(output, test.Output) {
+			t.Errorf("%s: expected output %#v, got %#v", test.Expression, test.Output, output)
+		}
+
+		if !reflect.DeepEqual(err, test.Error) {
+			t.Errorf("%s: expected error %v, got %v", test.Expression, test.Error, err)
+		}
+	}
+}
+
+func TestExactNumbersEvalBytes(t *testing.T) {
+
+	input := []byte(`{"id": 12345678901234567890, "amounts": [0.1, 0.2]}`)
+
+	e := MustCompile(`{"id": id, "next": id + 1, "total": $sum(amounts)}`)
+	e.SetExactNumbers(true)
+
+	output, err := e.EvalBytes(input)
+	if err != nil {
+		t.Fatalf("EvalBytes: %s", err)
+	}
+
+	exp := `{"id":12345678901234567890,"next":12345678901234567891,"total":0.3}`
+	if string(output) != exp {
+		t.Errorf("expected output %s, got %s", exp, output)
+	}
+
+	if _, err := e.EvalBytes([]byte(`{} {}`)); err == nil {
+		t.Errorf("expected error for trailing data, got nil")
+	}
+
+	// Without exact numbers, large integers lose precision.
+	e.SetExactNumbers(false)
+
+	output, err = e.EvalBytes(input)
+	if err != nil {
+		t.Fatalf("EvalBytes: %s", err)
+	}
+
+	if bytes.Contains(output, []byte("12345678901234567890")) {
+		t.Errorf("expected a loss of precision, got %s", output)
+	}
+}
+
+func TestExprAST(t *testing.T) {
+
+	e := MustCompile(`$x + $sum(Account.Order.Product.Price) * $y`)
+
+	var names []string
+
+	jparse.Inspect(e.AST(), func(n jparse.Node) bool {
+		if v, ok := n.(*jparse.VariableNode); ok {
+			names = append(names, v.Name)
+		}
+		return true
+	})
+
+	exp := []string{"x", "sum", "y"}
+
+	if !reflect.DeepEqual(names, exp) {
+		t.Errorf("expected variables %q, got %q", exp, names)
+	}
+}
+
+func TestErrorCodes(t *testing.T) {
+
+	reCode := regexp.MustCompile(`^[TDU]\d{4}$`)
+
+	for typ := ErrNonIntegerLHS; typ <= ErrEvalSyntax; typ++ {
+		e := EvalError{
+			Type: typ,
+		}
+		if code := e.Code(); !reCode.MatchString(code) {
+			t.Errorf("%s: invalid error code %q", e, code)
+		}
+	}
+
+	// Codes that are specific to this library should each
+	// identify a single type of error.
+	types := map[string]ErrType{}
+
+	for typ := ErrNonIntegerLHS; typ <= ErrEvalSyntax; typ++ {
+		e := EvalError{
+			Type: typ,
+		}
+		code := e.Code()
+		if !strings.HasPrefix(code, "U") {
+			continue
+		}
+		if prev, ok := types[code]; ok {
+			t.Errorf("%s: error code %q is also used by %s", e, code, EvalError{Type: prev})
+		}
+		types[code] = typ
+	}
+
+	data := map[string]string{
+		`"one" + 1`:            "T2001",
+		`1 + "one"`:            "T2002",
+		`[1.5..3]`:             "T2003",
+		`$uppercase("a", "b")`: "T0410",
+		`$uppercase(1)`:        "T0410",
+		`{ 1: "one" }`:         "T1003",
+		`$string(1/0)`:         "D1001",
+		`1 ~> 2`:               "T2006",
+		`[1, "1"]^($)`:         "T2007",
+		`"a" < 1`:              "T2009",
+		`($x := 1; $x())`:      "T1006",
+		`1 +`:                  "S0207",
+		`$sum("a")`:            "T0412",
+		`$number("one")`:       "D3030",
+		`$sqrt(-1)`:            "D3060",
+		`$power(10, 1000)`:     "D3061",
+		`$split("a", "", -1)`:  "D3020",
+		`$replace("a","","b")`: "D3010",
+		`$formatBase(1, 99)`:   "D3100",
+		`$formatNumber(1, "")`: "D3080",
+		`$toMillis("foo")`:     "D3110",
+	}
+
+	type coder interface {
+		Code() string
+	}
+
+	for expr, exp := range data {
+
+		e, err := Compile(expr)
+		if err == nil {
+			_, err = e.Eval(nil)
+		}
+
+		c, ok := err.(coder)
+		if !ok {
+			t.Errorf("%s: expected an error with a code, got %v", expr, err)
+			continue
+		}
+
+		if code := c.Code(); code != exp {
+			t.Errorf("%s: expected error code %q, got %q (%s)", expr, exp, code, err)
+		}
+	}
+}
+
+func TestErrorLocations(t *testing.T) {
+
+	data := []struct {
+		Expression string
+		Excerpt    string
+		Line       int
+		Column     int
+	}{
+		{
+			Expression: "(\n  $x := 1;\n  $x + \"one\"\n)",
+			Excerpt:    "  $x + \"one\"\n  ^^^^^^^^^^",
+			Line:       3,
+			Column:     3,
+		},
+		{
+			// Argument count errors are reported at the
+			// function call.
+			Expression: "$sum(\n  $uppercase(\"a\", \"b\")\n)",
+			Excerpt:    "  $uppercase(\"a\", \"b\")\n  ^^^^^^^^^^^^^^^^^^^^",
+			Line:       2,
+			Column:     3,
+		},
+		{
+			Expression: "[1, 2, 3].$uppercase($)",
+			Excerpt:    "[1, 2, 3].$uppercase($)\n          ^^^^^^^^^^^^^",
+			Line:       1,
+			Column:     11,
+		},
+		{
+			// Errors in lambda bodies are reported in the
+			// body, not at the call site.
+			Expression: "(\n  $f := function($v) { $v.x + \"a\" };\n  $f({\"x\": 1})\n)",
+			Excerpt:    "  $f := function($v) { $v.x + \"a\" };\n                       ^^^^^^^^^^",
+			Line:       2,
+			Column:     24,
+		},
+		{
+			// Errors in expressions passed to $eval are
+			// reported at the call to $eval.
+			Expression: `{"pad": "xxxxxxxxxxxxxxxx", "r": $eval("1 + 'a'")}`,
+			Excerpt:    "{\"pad\": \"xxxxxxxxxxxxxxxx\", \"r\": $eval(\"1 + 'a'\")}\n                                 ^^^^^^^^^^^^^^^^",
+			Line:       1,
+			Column:     34,
+		},
+	}
+
+	type locator interface {
+		Location() jparse.Span
+	}
+
+	for _, test := range data {
+
+		_, err := MustCompile(test.Expression).Eval(nil)
+
+		e, ok := err.(locator)
+		if !ok {
+			t.Errorf("%s: expected an error with a location, got %v", test.Expression, err)
+			continue
+		}
+
+		span := e.Location()
+
+		if span.Start.Line != test.Line || span.Start.Column != test.Column {
+			t.Errorf("%s: expected error at %d:%d, got %s", test.Expression, test.Line, test.Column, span.Start)
+		}
+
+		if got := jparse.Excerpt(test.Expression, span); got != test.Excerpt {
+			t.Errorf("%s: expected excerpt %q, got %q", test.Expression, test.Excerpt, got)
+		}
+	}
+}
+
+func TestEvalContext(t *testing.T) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// The long running expressions below call $stop part way
+	// through evaluation. This ends the context with the given
+	// error. The evaluation should fail with the corresponding
+	// EvalError rather than run to completion.
+	tests := []struct {
+		Expression string
+		Context    context.Context
+		Stop       error
+		Error      error
+	}{
+		{
+			Expression: `1 + 2`,
+			Context:    ctx,
+			Error: &EvalError{
+				Type: ErrCanceled,
+			},
+		},
+		{
+			// Long running path.
+			Expression: `$sum([1..100000].($ = 10 ? $stop() : $ * $))`,
+			Stop:       context.DeadlineExceeded,
+			Error: &EvalError{
+				Type: ErrDeadlineExceeded,
+			},
+		},
+		{
+			// Long running higher order function.
+			Expression: `$count($map([1..100000], function($v) { $v = 10 ? $stop() : $v * 2 }))`,
+			Stop:       context.Canceled,
+			Error: &EvalError{
+				Type: ErrCanceled,
+			},
+		},
+		{
+			// Long running recursive function.
+			Expression: `
+				(
+					$f := function($n) { $n = 0 ? 0 : 1 + $f($n - 1 + ($n = 500 ? $stop() : 0)) };
+					$f(1000)
+				)`,
+			Stop: context.DeadlineExceeded,
+			Error: &EvalError{
+				Type: ErrDeadlineExceeded,
+			},
+		},
+	}
+
+	for _, test := range tests {
+
+		ctx := test.Context
+		stop := &stopContext{
+			Context: context.Background(),
+			done:    make(chan struct{}),
+		}
+		if ctx == nil {
+			ctx = stop
+		}
+
+		e := MustCompile(test.Expression)
+		must(t, "RegisterExts", e.RegisterExts(map[string]Extension{
+			"stop": {
+				Func: func() int {
+					stop.stop(test.Stop)
+					return 0
+				},
+			},
+		}))
+
+		_, err := e.EvalContext(ctx, nil)
+
+		clearErrorSpan(err, test.Error)
+
+		if !reflect.DeepEqual(err, test.Error) {
+			t.Errorf("%s: expected error %v, got %v", test.Expression, test.Error, err)
+		}
+	}
+}
+
+// A stopContext is a context that ends when its stop method
+// is called.
+type stopContext struct {
+	context.Context
+	done chan struct{}
+	err  error
+}
+
+func (c *stopContext) stop(err error) {
+	if c.err == nil {
+		c.err = err
+		close(c.done)
+	}
+}
+
+func (c *stopContext) Done() <-chan struct{} {
+	return c.done
+}
+
+func (c *stopContext) Err() error {
+	return c.err
+}
+
+func TestEvalWith(t *testing.T) {
+
+	e := MustCompile(`$tenant & ":" & $uppercase(name)`)
+	if err := e.RegisterVars(map[string]interface{}{
+		"tenant": "default",
+	}); err != nil {
+		t.Fatalf("RegisterVars: %s", err)
+	}
+
+	data := map[string]interface{}{
+		"name": "widget",
+	}
+
+	tests := []struct {
+		Vars   map[string]interface{}
+		Output interface{}
+		Error  error
+	}{
+		{
+			Output: "default:WIDGET",
+		},
+		{
+			Vars: map[string]interface{}{
+				"tenant": "acme",
+			},
+			Output: "acme:WIDGET",
+		},
+		{
+			// Bindings do not persist between evaluations.
+			Output: "default:WIDGET",
+		},
+		{
+			Vars: map[string]interface{}{
+				"bad name": 1,
+			},
+			Error: fmt.Errorf("bad name is not a valid name"),
+		},
+	}
+
+	for _, test := range tests {
+
+		output, err := e.EvalWith(data, EvalOptions{
+			Vars: test.Vars,
+		})
+
+		if !reflect.DeepEqual(output, test.Output) {
+			t.Errorf("%v: expected output %v, got %v", test.Vars, test.Output, output)
+		}
+
+		if !reflect.DeepEqual(err, test.Error) {
+			t.Errorf("%v: expected error %v, got %v", test.Vars, test.Error, err)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := e.EvalWith(data, EvalOptions{
+		Context: ctx,
+	})
+
+	exp := &EvalError{
+		Type: ErrCanceled,
+	}
+
+	clearErrorSpan(err, exp)
+
+	if !reflect.DeepEqual(err, exp) {
+		t.Errorf("expected error %v, got %v", exp, err)
+	}
+}
+
+func TestEvalRepeatable(t *testing.T) {
+
+	// The function application operator must not modify
+	// the syntax tree when it inserts its left hand side
+	// into a function call.
+	e := MustCompile(`"abcdef" ~> $substring(1, 2)`)
+
+	for i := 0; i < 3; i++ {
+
+		output, err := e.Eval(nil)
+		if err != nil {
+			t.Fatalf("Eval #%d: %s", i+1, err)
+		}
+
+		if output != "bc" {
+			t.Errorf("Eval #%d: expected output %q, got %v", i+1, "bc", output)
+		}
+	}
+}
+
+func TestEvalWithConcurrent(t *testing.T) {
+
+	// Run with the race detector to check that concurrent
+	// evaluations of an Expr do not share mutable state.
+	e := MustCompile(`
+		(
+			$prefix := $tenant ~> $substring(0, 3);
+			($join(items.$string($), ",") ~> $pad(-12, "-")) & "@" & $prefix
+		)`)
+
+	data := map[string]interface{}{
+		"items": []interface{}{1.0, 2.0, 3.0},
+	}
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 20; i++ {
+
+		tenant := fmt.Sprintf("t%02d-tenant", i)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			for j := 0; j < 20; j++ {
+
+				output, err := e.EvalWith(data, EvalOptions{
+					Vars: map[string]interface{}{
+						"tenant": tenant,
+					},
+				})
+				if err != nil {
+					t.Errorf("%s: %s", tenant, err)
+					return
+				}
+
+				exp := "-------1,2,3@" + tenant[:3]
+				if output != exp {
+					t.Errorf("%s: expected output %q, got %v", tenant, exp, output)
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+func TestLimits(t *testing.T) {
+
+	tests := []struct {
+		Expression string
+		Limits     Limits
+		Exts       map[string]Extension
+		Output     interface{}
+		Error      error
+	}{
+		{
+			Expression: `
+				(
+					$f := function($n) { $n = 0 ? 0 : 1 + $f($n - 1) };
+					$f(10)
+				)`,
+			Limits: Limits{
+				MaxDepth: 11,
+			},
+			Output: float64(10),
+		},
+		{
+			Expression: `
+				(
+					$f := function($n) { $n = 0 ? 0 : 1 + $f($n - 1) };
+					$f(10)
+				)`,
+			Limits: Limits{
+				MaxDepth: 10,
+			},
+			Error: &EvalError{
+				Type:  ErrMaxDepth,
+				Value: "10",
+			},
+		},
+		{
+			Expression: `1 + 2`,
+			Limits: Limits{
+				MaxSteps: 3,
+			},
+			Output: float64(3),
+		},
+		{
+			Expression: `1 + 2 + 3`,
+			Limits: Limits{
+				MaxSteps: 3,
+			},
+			Error: &EvalError{
+				Type:  ErrMaxSteps,
+				Value: "3",
+			},
+		},
+		{
+			Expression: `$eval("$eval(\"$eval('1')\")")`,
+			Limits: Limits{
+				MaxDepth: 2,
+			},
+			Error: &EvalError{
+				Type:  ErrMaxDepth,
+				Value: "2",
+			},
+		},
+		{
+			Expression: `$eval("1 + 2 + 3")`,
+			Limits: Limits{
+				MaxSteps: 5,
+			},
+			Error: &EvalError{
+				Type:  ErrMaxSteps,
+				Value: "5",
+			},
+		},
+		{
+			Expression: `[1..5]`,
+			Limits: Limits{
+				MaxArrayLength: 5,
+			},
+			Output: []interface{}{
+				float64(1),
+				float64(2),
+				float64(3),
+				float64(4),
+				float64(5),
+			},
+		},
+		{
+			Expression: `[1..6]`,
+			Limits: Limits{
+				MaxArrayLength: 5,
+			},
+			Error: &EvalError{
+				Type:  ErrMaxArrayLength,
+				Value: "5",
+			},
+		},
+		{
+			Expression: `[1..99999999999999999999]`,
+			Limits: Limits{
+				MaxArrayLength: 5,
+			},
+			Error: &EvalError{
+				Type:  ErrMaxArrayLength,
+				Value: "5",
+			},
+		},
+		{
+			Expression: `[1..10000001]`,
+			Limits: Limits{
+				MaxArrayLength: 20000000,
+			},
+			Error: &EvalError{
+				Type:  ErrMaxRangeItems,
+				Token: "..",
+			},
+		},
+		{
+			Expression: `$append([1, 2, 3], [4, 5, 6])`,
+			Limits: Limits{
+				MaxArrayLength: 5,
+			},
+			Error: &EvalError{
+				Type:  ErrMaxArrayLength,
+				Value: "5",
+			},
+		},
+		{
+			Expression: `"hello" & "world"`,
+			Limits: Limits{
+				MaxStringLength: 5,
+			},
+			Error: &EvalError{
+				Type:  ErrMaxStringLength,
+				Value: "5",
+			},
+		},
+		{
+			Expression: `$join(["hello", "world"])`,
+			Limits: Limits{
+				MaxStringLength: 5,
+			},
+			Error: &EvalError{
+				Type:  ErrMaxStringLength,
+				Value: "5",
+			},
+		},
+		{
+			Expression: `$pad("hello", 6)`,
+			Limits: Limits{
+				MaxStringLength: 5,
+			},
+			Error: &EvalError{
+				Type:  ErrMaxStringLength,
+				Value: "5",
+			},
+		},
+		{
+			// The length of the padded string is checked
+			// before it is allocated.
+			Expression: `$pad("hello", 999999999999)`,
+			Limits: Limits{
+				MaxStringLength: 5,
+			},
+			Error: &EvalError{
+				Type:  ErrMaxStringLength,
+				Value: "5",
+			},
+		},
+		{
+			Expression: `$pad("hi", -5, "€$")`,
+			Limits: Limits{
+				MaxStringLength: 9,
+			},
+			Output: "€$€hi",
+		},
+		{
+			Expression: `$pad("hi", -6, "€$")`,
+			Limits: Limits{
+				MaxStringLength: 9,
+			},
+			Error: &EvalError{
+				Type:  ErrMaxStringLength,
+				Value: "9",
+			},
+		},
+		{
+			Expression: `"hi" ~> $pad(5)`,
+			Limits: Limits{
+				MaxStringLength: 5,
+			},
+			Output: "hi   ",
+		},
+		{
+			Expression: `$join(["he", "llo"])`,
+			Limits: Limits{
+				MaxStringLength: 5,
+			},
+			Output: "hello",
+		},
+		{
+			Expression: `$join(["he", "llo"], " ")`,
+			Limits: Limits{
+				MaxStringLength: 5,
+			},
+			Error: &EvalError{
+				Type:  ErrMaxStringLength,
+				Value: "5",
+			},
+		},
+		{
+			// Extensions take precedence over the limited
+			// versions of the built-in functions.
+			Expression: `$pad("hello", 6)`,
+			Limits: Limits{
+				MaxStringLength: 5,
+			},
+			Exts: map[string]Extension{
+				"pad": {
+					Func: func(s string, width int) string {
+						return s[:1]
+					},
+				},
+			},
+			Output: "h",
+		},
+	}
+
+	for _, test := range tests {
+
+		e := MustCompile(test.Expression)
+		e.SetLimits(test.Limits)
+
+		if err := e.RegisterExts(test.Exts); err != nil {
+			t.Fatalf("%s: RegisterExts: %s", test.Expression, err)
+		}
+
+		output, err := e.Eval(nil)
+		clearErrorSpan(err, test.Error)
+
+		if !reflect.DeepEqual(output, test.Output) {
+			t.Errorf("%s: expected output %v, got %v", test.Expression, test.Output, output)
+		}
+
+		if !reflect.DeepEqual(err, test.Error) {
+			t.Errorf("%s: expected error %v, got %v", test.Expression, test.Error, err)
+		}
+	}
+}
+
+// Helper functions
+
+type compareFunc func(interface{}, interface{}) bool
+
+func runTestCases(t *testing.T, input interface{}, tests []*testCase) {
+	runTestCasesFunc(t, reflect.DeepEqual, input, tests)
+}
+
+func runTestCasesFunc(t *testing.T, compare compareFunc, input interface{}, tests []*testCase) {
+
+	for _, test := range tests {
+		if test.Skip {
+			t.Logf("Skipping: %q", test.Expression)
+			continue
+		}
+		runTestCase(t, compare, input, test)
+	}
+}
+
+func runTestCase(t *testing.T, equal compareFunc, input interface{}, test *testCase) {
+
+	var exps []string
+
+	switch e := test.Expression.(type) {
+	case string:
+		exps = append(exps, e)
 	case []string:
 		exps = append(exps, e...)
 	default:
@@ -7988,6 +10222,8 @@ func runTestCase(t *testing.T, equal compareFunc, input interface{}, test *testC
 			output, err = expr.Eval(input)
 		}
 
+		clearErrorSpan(err, test.Error)
+
 		if !equal(output, test.Output) {
 			t.Errorf("\nExpression: %s\nExp. Value: %v [%T]\nAct. Value: %v [%T]", exp, test.Output, test.Output, output, output)
 		}
@@ -7997,6 +10233,244 @@ func runTestCase(t *testing.T, equal compareFunc, input interface{}, test *testC
 	}
 }
 
+func TestValidate(t *testing.T) {
+
+	data := []struct {
+		Expression string
+		Exts       map[string]Extension
+		Vars       map[string]interface{}
+		Errors     []error
+		Positions  []string
+	}{
+		{
+			Expression: `$sum(price) + $string() & $substring("abc", 1) & $substring(1) & $now() & $millis()`,
+		},
+		{
+			// Local variables shadow built-in functions.
+			Expression: `($sum := function($a) { $a }; $sum(1, 2, 3))`,
+		},
+		{
+			Expression: `$sumx(price)`,
+			Errors: []error{
+				&EvalError{
+					Type:  ErrNonCallable,
+					Token: "$sumx",
+				},
+			},
+			Positions: []string{"1:1"},
+		},
+		{
+			Expression: `$eval("a") & $eval("a", {"a": 1}) & $eval()`,
+			Errors: []error{
+				&ArgCountError{
+					Func:     "eval",
+					Expected: 2,
+					Received: 0,
+					Optional: 1,
+				},
+			},
+			Positions: []string{"1:37"},
+		},
+		{
+			Expression: `$substring()`,
+			Errors: []error{
+				&ArgCountError{
+					Func:     "substring",
+					Expected: 3,
+					Received: 0,
+				},
+			},
+			Positions: []string{"1:1"},
+		},
+		{
+			// All problems are reported.
+			Expression: "(\n  $x := $uppercase(\"a\", \"b\");\n  $y := $foo($x);\n  $bar()\n)",
+			Errors: []error{
+				&ArgCountError{
+					Func:     "uppercase",
+					Expected: 1,
+					Received: 2,
+				},
+				&EvalError{
+					Type:  ErrNonCallable,
+					Token: "$foo",
+				},
+				&EvalError{
+					Type:  ErrNonCallable,
+					Token: "$bar",
+				},
+			},
+			Positions: []string{"2:9", "3:9", "4:3"},
+		},
+		{
+			// The left side of the function application
+			// operator counts as an argument.
+			Expression: `"abc" ~> $substring(1) ~> $uppercase ~> $uppercase("x")`,
+			Errors: []error{
+				&ArgCountError{
+					Func:     "uppercase",
+					Expected: 1,
+					Received: 2,
+				},
+			},
+			Positions: []string{"1:1"},
+		},
+		{
+			Expression: `$join(["a"], ",", "extra")`,
+			Errors: []error{
+				&ArgCountError{
+					Func:     "join",
+					Expected: 2,
+					Received: 3,
+				},
+			},
+			Positions: []string{"1:1"},
+		},
+		{
+			// Typed lambdas are checked against their signatures.
+			// Untyped lambdas and lambda parameters are not checked.
+			Expression: "(\n  $add := λ($a, $b)<nn?:n>{$a + $b};\n  $add(1) + $add(1, 2) + $add(1, 2, 3);\n  $f := function($x) { $x };\n  $f(1, 2, 3);\n  $apply := function($g) { $g(1, 2, 3) }\n)",
+			Errors: []error{
+				&ArgCountError{
+					Func:     "add",
+					Expected: 2,
+					Received: 3,
+				},
+			},
+			Positions: []string{"3:26"},
+		},
+		{
+			Expression: `$sumAll([1, 2]) + $sumAll([1], [2]) + $double(2, 3)`,
+			Exts: map[string]Extension{
+				"sumAll": {
+					Func: func(nums ...[]float64) float64 {
+						return 0
+					},
+				},
+				"double": {
+					Func: func(n float64) float64 {
+						return 2 * n
+					},
+				},
+			},
+			Errors: []error{
+				&ArgCountError{
+					Func:     "double",
+					Expected: 1,
+					Received: 2,
+				},
+			},
+			Positions: []string{"1:39"},
+		},
+		{
+			// Variables that are not functions cannot be called.
+			Expression: `$x() + $y`,
+			Vars: map[string]interface{}{
+				"x": 1,
+			},
+			Errors: []error{
+				&EvalError{
+					Type:  ErrNonCallable,
+					Token: "$x",
+				},
+			},
+			Positions: []string{"1:1"},
+		},
+	}
+
+	for _, test := range data {
+
+		e := MustCompile(test.Expression)
+
+		if test.Exts != nil {
+			if err := e.RegisterExts(test.Exts); err != nil {
+				t.Fatalf("%s: RegisterExts: %s", test.Expression, err)
+			}
+		}
+
+		if test.Vars != nil {
+			if err := e.RegisterVars(test.Vars); err != nil {
+				t.Fatalf("%s: RegisterVars: %s", test.Expression, err)
+			}
+		}
+
+		err := e.Validate()
+		if test.Errors == nil {
+			if err != nil {
+				t.Errorf("%s: unexpected error: %s", test.Expression, err)
+			}
+			continue
+		}
+
+		errs, ok := err.(ErrorList)
+		if !ok {
+			t.Errorf("%s: expected an ErrorList, got %v", test.Expression, err)
+			continue
+		}
+
+		var positions []string
+
+		for _, err := range errs {
+			if e, ok := err.(interface{ Location() jparse.Span }); ok {
+				positions = append(positions, e.Location().Start.String())
+			}
+			clearErrorSpan(err, nil)
+		}
+
+		if !reflect.DeepEqual([]error(errs), test.Errors) {
+			t.Errorf("%s: expected errors %v, got %v", test.Expression, test.Errors, errs)
+		}
+
+		if !reflect.DeepEqual(positions, test.Positions) {
+			t.Errorf("%s: expected errors at %v, got %v", test.Expression, test.Positions, positions)
+		}
+	}
+}
+
+func TestCompileStrict(t *testing.T) {
+
+	if _, err := CompileStrict(`$sum([1, 2])`); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	_, err := CompileStrict(`$sumx([1, 2]) + $sum()`)
+
+	exp := `cannot call non-function $sumx (and 1 more errors)`
+	if err == nil || err.Error() != exp {
+		t.Errorf("expected error %q, got %v", exp, err)
+	}
+
+	_, err = CompileStrict(`$sum(`)
+	if _, ok := err.(*jparse.Error); !ok {
+		t.Errorf("expected a syntax error, got %v", err)
+	}
+}
+
+// clearErrorSpan removes the source location from an error
+// unless the expected error specifies one. This lets test cases
+// omit locations when they are not relevant to the test.
+func clearErrorSpan(err error, exp error) {
+
+	type locator interface {
+		Location() jparse.Span
+	}
+
+	if e, ok := exp.(locator); ok && e.Location().IsValid() {
+		return
+	}
+
+	switch err := err.(type) {
+	case *EvalError:
+		err.Span = jparse.Span{}
+	case *ArgCountError:
+		err.Span = jparse.Span{}
+	case *ArgTypeError:
+		err.Span = jparse.Span{}
+	case *jparse.Error:
+		err.Span = jparse.Span{}
+	}
+}
+
 func equalRegexMatches(v1 interface{}, v2 interface{}) bool {
 
 	makeMap := func(in interface{}) map[string]interface{} {
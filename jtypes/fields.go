@@ -0,0 +1,352 @@
+// Copyright 2018 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package jtypes
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// A StructField describes a struct field as it appears to a
+// JSONata expression. Field names and visibility follow the
+// rules used by the encoding/json package: a field's name is
+// taken from its json tag if it has one, fields tagged "-"
+// are ignored and the fields of embedded structs are promoted
+// to the parent struct.
+type StructField struct {
+
+	// Name is the JSON name of the field.
+	Name string
+
+	// Index is the index sequence of the field, suitable for
+	// use with reflect.Value.FieldByIndex.
+	Index []int
+
+	// OmitEmpty is true if the field's json tag includes the
+	// omitempty option.
+	OmitEmpty bool
+
+	// Quoted is true if the field's json tag includes the
+	// string option and the field is a bool, number or string
+	// (or a pointer to one). The encoding/json package writes
+	// the JSON encoding of such fields inside a JSON string.
+	Quoted bool
+}
+
+// Value returns the value of the field f in the struct v. It
+// returns an invalid Value if the field is inside a nil embedded
+// pointer, or if the field has the omitempty option and its
+// value is empty.
+func (f *StructField) Value(v reflect.Value) reflect.Value {
+
+	for i, x := range f.Index {
+		if i > 0 && v.Kind() == reflect.Ptr {
+			if v.IsNil() {
+				return undefined
+			}
+			v = v.Elem()
+		}
+		v = v.Field(x)
+	}
+
+	if f.OmitEmpty && isEmptyValue(v) {
+		return undefined
+	}
+
+	return v
+}
+
+type structFields struct {
+	list   []StructField
+	byName map[string]*StructField
+}
+
+var fieldCache sync.Map // map[reflect.Type]*structFields
+
+// StructFields returns the fields of the struct type t that are
+// visible to JSONata expressions, in the order in which they
+// are declared. The results are cached, so the returned slice
+// must not be modified.
+func StructFields(t reflect.Type) []StructField {
+	return cachedFields(t).list
+}
+
+// FieldByName returns the value of the field in the struct v
+// whose JSON name is name (see StructField). It returns an
+// invalid Value if there is no such field, or if the field
+// is omitted (see StructField.Value).
+func FieldByName(v reflect.Value, name string) reflect.Value {
+
+	f := cachedFields(v.Type()).byName[name]
+	if f == nil {
+		return undefined
+	}
+
+	return f.Value(v)
+}
+
+func cachedFields(t reflect.Type) *structFields {
+
+	if f, ok := fieldCache.Load(t); ok {
+		return f.(*structFields)
+	}
+
+	f, _ := fieldCache.LoadOrStore(t, typeFields(t))
+	return f.(*structFields)
+}
+
+type candidateField struct {
+	StructField
+	tagged bool
+}
+
+// typeFields returns the JSON-visible fields of the struct type
+// t. It implements the same algorithm as encoding/json: embedded
+// structs are searched breadth first and, where more than one
+// field has the same name, the shallowest field wins. If there
+// is a tie, a tagged field beats an untagged one and otherwise
+// all of the tied fields are dropped.
+func typeFields(t reflect.Type) *structFields {
+
+	var candidates []candidateField
+
+	type embedded struct {
+		typ   reflect.Type
+		index []int
+	}
+
+	current := []embedded{}
+	next := []embedded{{typ: t}}
+	visited := map[reflect.Type]bool{}
+
+	for len(next) > 0 {
+
+		current, next = next, current[:0]
+		level := map[reflect.Type]bool{}
+
+		for _, e := range current {
+
+			if visited[e.typ] {
+				continue
+			}
+			level[e.typ] = true
+
+			for i := 0; i < e.typ.NumField(); i++ {
+
+				sf := e.typ.Field(i)
+
+				ft := sf.Type
+				if ft.Name() == "" && ft.Kind() == reflect.Ptr {
+					ft = ft.Elem()
+				}
+
+				if sf.Anonymous {
+					// Unexported embedded structs can
+					// still have exported fields.
+					if sf.PkgPath != "" && ft.Kind() != reflect.Struct {
+						continue
+					}
+				} else if sf.PkgPath != "" {
+					continue
+				}
+
+				tag := sf.Tag.Get("json")
+				if tag == "-" {
+					continue
+				}
+
+				name, opts := parseTag(tag)
+				if !isValidTag(name) {
+					name = ""
+				}
+
+				index := make([]int, len(e.index)+1)
+				copy(index, e.index)
+				index[len(e.index)] = i
+
+				if name == "" && sf.Anonymous && ft.Kind() == reflect.Struct {
+					next = append(next, embedded{
+						typ:   ft,
+						index: index,
+					})
+					continue
+				}
+
+				if sf.PkgPath != "" {
+					// A tagged, unexported embedded struct.
+					// Its value is not accessible.
+					continue
+				}
+
+				candidates = append(candidates, candidateField{
+					StructField: StructField{
+						Name:      nameOrDefault(name, sf.Name),
+						Index:     index,
+						OmitEmpty: hasOption(opts, "omitempty"),
+						Quoted:    hasOption(opts, "string") && isQuotable(ft),
+					},
+					tagged: name != "",
+				})
+			}
+		}
+
+		for typ := range level {
+			visited[typ] = true
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.Name != b.Name {
+			return a.Name < b.Name
+		}
+		if len(a.Index) != len(b.Index) {
+			return len(a.Index) < len(b.Index)
+		}
+		return a.tagged && !b.tagged
+	})
+
+	var list []StructField
+
+	for i := 0; i < len(candidates); {
+
+		j := i + 1
+		for j < len(candidates) && candidates[j].Name == candidates[i].Name {
+			j++
+		}
+
+		if f, ok := dominantField(candidates[i:j]); ok {
+			list = append(list, f)
+		}
+
+		i = j
+	}
+
+	sort.Slice(list, func(i, j int) bool {
+		return indexLess(list[i].Index, list[j].Index)
+	})
+
+	byName := make(map[string]*StructField, len(list))
+	for i := range list {
+		byName[list[i].Name] = &list[i]
+	}
+
+	return &structFields{
+		list:   list,
+		byName: byName,
+	}
+}
+
+// dominantField returns the field that takes precedence among
+// fields with the same name. The fields must be sorted by depth
+// and then by tagged status.
+func dominantField(fields []candidateField) (StructField, bool) {
+
+	if len(fields) > 1 &&
+		len(fields[0].Index) == len(fields[1].Index) &&
+		fields[0].tagged == fields[1].tagged {
+		return StructField{}, false
+	}
+
+	return fields[0].StructField, true
+}
+
+func indexLess(a, b []int) bool {
+
+	for i := range a {
+		if i >= len(b) {
+			return false
+		}
+		if a[i] != b[i] {
+			return a[i] < b[i]
+		}
+	}
+
+	return len(a) < len(b)
+}
+
+func parseTag(tag string) (string, string) {
+	if i := strings.Index(tag, ","); i >= 0 {
+		return tag[:i], tag[i+1:]
+	}
+	return tag, ""
+}
+
+func hasOption(opts string, name string) bool {
+	for opts != "" {
+		var opt string
+		opt, opts = parseTag(opts)
+		if opt == name {
+			return true
+		}
+	}
+	return false
+}
+
+func isValidTag(s string) bool {
+
+	if s == "" {
+		return false
+	}
+
+	for _, c := range s {
+		switch {
+		case strings.ContainsRune("!#$%&()*+-./:;<=>?@[]^_{|}~ ", c):
+			// Backslash and quote chars are reserved, but
+			// otherwise any punctuation chars are allowed
+			// in a tag name.
+		case !unicode.IsLetter(c) && !unicode.IsDigit(c):
+			return false
+		}
+	}
+
+	return true
+}
+
+// isQuotable returns true if the string option applies to
+// fields of type t.
+func isQuotable(t reflect.Type) bool {
+
+	switch t.Kind() {
+	case reflect.Bool,
+		reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr,
+		reflect.Float32, reflect.Float64,
+		reflect.String:
+		return true
+	default:
+		return false
+	}
+}
+
+func nameOrDefault(name string, def string) string {
+	if name != "" {
+		return name
+	}
+	return def
+}
+
+func isEmptyValue(v reflect.Value) bool {
+
+	switch v.Kind() {
+	case reflect.Array, reflect.Map, reflect.Slice, reflect.String:
+		return v.Len() == 0
+	case reflect.Bool:
+		return !v.Bool()
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int() == 0
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		return v.Uint() == 0
+	case reflect.Float32, reflect.Float64:
+		return v.Float() == 0
+	case reflect.Interface, reflect.Ptr:
+		return v.IsNil()
+	}
+
+	return false
+}
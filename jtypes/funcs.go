@@ -6,10 +6,21 @@
 package jtypes
 
 import (
+	"math"
+	"math/big"
 	"reflect"
+	"strconv"
+	"strings"
 )
 
-// Resolve (golint)
+// Resolve dereferences pointers and interfaces until it
+// reaches a concrete value.
+//
+// Values that implement json.Marshaler or encoding.TextMarshaler
+// (e.g. time.Time and json.RawMessage) are replaced with the
+// result of decoding their JSON or text encoding. A time.Time,
+// for example, resolves to a string. If the encoding fails,
+// Resolve returns an invalid Value. Callables are not affected.
 func Resolve(v reflect.Value) reflect.Value {
 	for {
 		switch v.Kind() {
@@ -18,26 +29,35 @@ func Resolve(v reflect.Value) reflect.Value {
 				v = v.Elem()
 				break
 			}
-			fallthrough
-		default:
 			return v
+		case reflect.Invalid:
+			return v
+		default:
+			n, ok := normalize(v)
+			if !ok {
+				return v
+			}
+			v = n
 		}
 	}
 }
 
 // IsBool (golint)
 func IsBool(v reflect.Value) bool {
-	return v.Kind() == reflect.Bool || resolvedKind(v) == reflect.Bool
+	return resolvedKind(v) == reflect.Bool
 }
 
-// IsString (golint)
+// IsString reports whether v resolves to a string. A
+// json.Number resolves to a number, not a string.
 func IsString(v reflect.Value) bool {
-	return v.Kind() == reflect.String || resolvedKind(v) == reflect.String
+	v = Resolve(v)
+	return v.Kind() == reflect.String && v.Type() != typeJSONNumber
 }
 
-// IsNumber (golint)
+// IsNumber reports whether v resolves to a number, including
+// a json.Number.
 func IsNumber(v reflect.Value) bool {
-	return isFloat(v) || isInt(v) || isUint(v)
+	return isFloat(v) || isInt(v) || isUint(v) || isJSONNumber(v)
 }
 
 // IsCallable (golint)
@@ -49,7 +69,7 @@ func IsCallable(v reflect.Value) bool {
 
 // IsArray (golint)
 func IsArray(v reflect.Value) bool {
-	return isArrayKind(v.Kind()) || isArrayKind(resolvedKind(v))
+	return isArrayKind(resolvedKind(v))
 }
 
 func isArrayKind(k reflect.Kind) bool {
@@ -115,11 +135,56 @@ func AsNumber(v reflect.Value) (float64, bool) {
 		return v.Float(), true
 	case isInt(v), isUint(v):
 		return v.Convert(typeFloat64).Float(), true
+	case isJSONNumber(v):
+		return jsonNumberToFloat(v)
 	default:
 		return 0, false
 	}
 }
 
+// AsRat converts v to an exact rational number. Floats are
+// converted via their shortest decimal representation, so
+// the float64 value 0.1 converts to exactly 1/10. AsRat
+// returns false if v is not a number, or if it is NaN or
+// infinite.
+func AsRat(v reflect.Value) (*big.Rat, bool) {
+	v = Resolve(v)
+
+	switch {
+	case isJSONNumber(v):
+		return parseRat(v.String())
+	case isFloat(v):
+		f := v.Float()
+		if math.IsNaN(f) || math.IsInf(f, 0) {
+			return nil, false
+		}
+		return parseRat(strconv.FormatFloat(f, 'g', -1, v.Type().Bits()))
+	case isInt(v):
+		return new(big.Rat).SetInt64(v.Int()), true
+	case isUint(v):
+		return new(big.Rat).SetInt(new(big.Int).SetUint64(v.Uint())), true
+	default:
+		return nil, false
+	}
+}
+
+// maxRatExponent is the largest decimal exponent that AsRat
+// accepts. It prevents numbers like 1e999999999 from using
+// vast amounts of memory.
+const maxRatExponent = 10000
+
+func parseRat(s string) (*big.Rat, bool) {
+
+	if i := strings.IndexAny(s, "eE"); i >= 0 {
+		exp, err := strconv.Atoi(s[i+1:])
+		if err != nil || exp > maxRatExponent || exp < -maxRatExponent {
+			return nil, false
+		}
+	}
+
+	return new(big.Rat).SetString(s)
+}
+
 // AsCallable (golint)
 func AsCallable(v reflect.Value) (Callable, bool) {
 	v = Resolve(v)
@@ -136,7 +201,7 @@ func AsCallable(v reflect.Value) (Callable, bool) {
 }
 
 func isInt(v reflect.Value) bool {
-	return isIntKind(v.Kind()) || isIntKind(resolvedKind(v))
+	return isIntKind(resolvedKind(v))
 }
 
 func isIntKind(k reflect.Kind) bool {
@@ -149,7 +214,7 @@ func isIntKind(k reflect.Kind) bool {
 }
 
 func isUint(v reflect.Value) bool {
-	return isUintKind(v.Kind()) || isUintKind(resolvedKind(v))
+	return isUintKind(resolvedKind(v))
 }
 
 func isUintKind(k reflect.Kind) bool {
@@ -162,7 +227,7 @@ func isUintKind(k reflect.Kind) bool {
 }
 
 func isFloat(v reflect.Value) bool {
-	return isFloatKind(v.Kind()) || isFloatKind(resolvedKind(v))
+	return isFloatKind(resolvedKind(v))
 }
 
 func isFloatKind(k reflect.Kind) bool {
@@ -0,0 +1,138 @@
+// Copyright 2018 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package jtypes
+
+import (
+	"encoding"
+	"encoding/json"
+	"reflect"
+	"strconv"
+	"sync"
+)
+
+var (
+	typeJSONMarshaler = reflect.TypeOf((*json.Marshaler)(nil)).Elem()
+	typeTextMarshaler = reflect.TypeOf((*encoding.TextMarshaler)(nil)).Elem()
+	typeJSONNumber    = reflect.TypeOf((*json.Number)(nil)).Elem()
+)
+
+// A marshalKind describes how a type marshals itself to JSON.
+type marshalKind int
+
+const (
+	marshalNone marshalKind = iota
+	marshalJSON
+	marshalJSONPtr
+	marshalText
+	marshalTextPtr
+)
+
+var marshalKinds sync.Map // map[reflect.Type]marshalKind
+
+func marshalKindOf(t reflect.Type) marshalKind {
+
+	if k, ok := marshalKinds.Load(t); ok {
+		return k.(marshalKind)
+	}
+
+	var k marshalKind
+	pt := reflect.PtrTo(t)
+
+	switch {
+	case t.Implements(TypeCallable), pt.Implements(TypeCallable):
+		// Callables implement json.Marshaler but they are
+		// functions, not data.
+	case t.Implements(typeJSONMarshaler):
+		k = marshalJSON
+	case pt.Implements(typeJSONMarshaler):
+		k = marshalJSONPtr
+	case t.Implements(typeTextMarshaler):
+		k = marshalText
+	case pt.Implements(typeTextMarshaler):
+		k = marshalTextPtr
+	}
+
+	marshalKinds.Store(t, k)
+	return k
+}
+
+// normalize converts a value that implements json.Marshaler
+// or encoding.TextMarshaler to the value that its JSON encoding
+// decodes to, i.e. a map, slice, string, number, bool or nil.
+// As with encoding/json, methods with pointer receivers are
+// only used if the value is addressable. If the value cannot
+// be normalized because its marshal method fails, normalize
+// returns undefined.
+func normalize(v reflect.Value) (reflect.Value, bool) {
+
+	t := v.Type()
+
+	// Only named types can have methods. Check for them first
+	// to skip the common JSON types (maps, slices, strings
+	// etc.) as quickly as possible.
+	if t.PkgPath() == "" || !v.CanInterface() {
+		return v, false
+	}
+
+	switch marshalKindOf(t) {
+	case marshalJSON:
+		return unmarshalJSON(v.Interface().(json.Marshaler)), true
+	case marshalJSONPtr:
+		if v.CanAddr() {
+			return unmarshalJSON(v.Addr().Interface().(json.Marshaler)), true
+		}
+	case marshalText:
+		return unmarshalText(v.Interface().(encoding.TextMarshaler)), true
+	case marshalTextPtr:
+		if v.CanAddr() {
+			return unmarshalText(v.Addr().Interface().(encoding.TextMarshaler)), true
+		}
+	}
+
+	return v, false
+}
+
+func unmarshalJSON(m json.Marshaler) reflect.Value {
+
+	b, err := m.MarshalJSON()
+	if err != nil {
+		return undefined
+	}
+
+	var dest interface{}
+	if err := json.Unmarshal(b, &dest); err != nil {
+		return undefined
+	}
+
+	// Return the interface rather than its contents so
+	// that JSON null is represented by a nil interface,
+	// as it would be in a decoded JSON object.
+	return reflect.ValueOf(&dest).Elem()
+}
+
+func unmarshalText(m encoding.TextMarshaler) reflect.Value {
+
+	b, err := m.MarshalText()
+	if err != nil {
+		return undefined
+	}
+
+	return reflect.ValueOf(string(b))
+}
+
+func isJSONNumber(v reflect.Value) bool {
+	v = Resolve(v)
+	return v.IsValid() && v.Type() == typeJSONNumber
+}
+
+func jsonNumberToFloat(v reflect.Value) (float64, bool) {
+
+	n, err := strconv.ParseFloat(v.String(), 64)
+	if err != nil {
+		return 0, false
+	}
+
+	return n, true
+}
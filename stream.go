@@ -0,0 +1,228 @@
+// Copyright 2018 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package jsonata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"reflect"
+)
+
+// An UndefinedAction specifies what EvalStream does with a
+// record whose result is undefined.
+type UndefinedAction int
+
+const (
+	// UndefinedSkip omits the record from the output.
+	UndefinedSkip UndefinedAction = iota
+
+	// UndefinedNull writes JSON null for the record.
+	UndefinedNull
+
+	// UndefinedError treats the record as an error. The
+	// error passed to OnError wraps ErrUndefined.
+	UndefinedError
+)
+
+// StreamOptions contains settings for EvalStream.
+type StreamOptions struct {
+
+	// Context, if non-nil, is used in the same way as the
+	// context passed to EvalContext. It applies to the whole
+	// stream: once the context is done, EvalStream stops.
+	Context context.Context
+
+	// Vars contains custom variables for use during this
+	// call to EvalStream (see EvalOptions).
+	Vars map[string]interface{}
+
+	// Array, if true, specifies that the input is a single
+	// JSON array. The expression is evaluated against each
+	// element of the array in turn. Otherwise the input is
+	// a sequence of JSON values, e.g. newline-delimited JSON.
+	Array bool
+
+	// Undefined determines what happens to records whose
+	// result is undefined. The default is UndefinedSkip.
+	Undefined UndefinedAction
+
+	// OnError, if non-nil, is called when a record cannot be
+	// evaluated or its result cannot be encoded. If OnError
+	// returns nil, the record is skipped and EvalStream moves
+	// on to the next record. Otherwise EvalStream stops and
+	// returns the error returned by OnError. If OnError is
+	// nil, EvalStream stops at the first failed record.
+	//
+	// OnError is not called for errors reading the input or
+	// writing the output, which always stop EvalStream.
+	OnError func(err *StreamError) error
+}
+
+// A StreamError describes an error in a single record of a
+// call to EvalStream.
+type StreamError struct {
+
+	// Record is the zero-based index of the record in the
+	// input stream (or of the element in the input array).
+	Record int
+
+	// Err is the underlying error.
+	Err error
+}
+
+func (e *StreamError) Error() string {
+	return fmt.Sprintf("record %d: %s", e.Record, e.Err)
+}
+
+// Unwrap returns the underlying error.
+func (e *StreamError) Unwrap() error {
+	return e.Err
+}
+
+// EvalStream evaluates the expression against each JSON value
+// read from r and writes the results to w as newline-delimited
+// JSON. Unlike EvalBytes, it reads the input one record at a
+// time, so it can process inputs that are too large to hold in
+// memory. Set the Array option to stream the elements of a
+// top-level JSON array.
+//
+// Each record is evaluated separately: variables assigned in
+// one record are not visible to the next, and resource limits
+// apply to each record rather than to the stream as a whole.
+// The functions and variables available to the expression are
+// set up once, so the values returned by $now() and $millis()
+// are the same for every record.
+//
+// EvalStream returns nil once it has processed all of the input.
+// Evaluation errors are reported as StreamErrors (see the
+// OnError option).
+func (e *Expr) EvalStream(r io.Reader, w io.Writer, opts StreamOptions) error {
+
+	vars, err := processVars(opts.Vars)
+	if err != nil {
+		return err
+	}
+
+	ctx := opts.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	dec := json.NewDecoder(r)
+	if e.exact {
+		dec.UseNumber()
+	}
+
+	if opts.Array {
+		if err := expectDelim(dec, '['); err != nil {
+			return err
+		}
+	}
+
+	shared := e.newVarsEnv(vars)
+
+	var buf []byte
+
+	for n := 0; ; n++ {
+
+		if err := contextError(ctx); err != nil {
+			return err
+		}
+
+		if opts.Array && !dec.More() {
+			return endArray(dec)
+		}
+
+		var data interface{}
+		if err := dec.Decode(&data); err != nil {
+			if err == io.EOF && !opts.Array {
+				return nil
+			}
+			return err
+		}
+
+		b, err := e.evalRecord(ctx, shared, data, buf[:0], opts.Undefined)
+		if err != nil {
+			serr := &StreamError{
+				Record: n,
+				Err:    err,
+			}
+			if opts.OnError == nil {
+				return serr
+			}
+			if err := opts.OnError(serr); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if b == nil {
+			continue
+		}
+
+		buf = append(b, '\n')
+		if _, err := w.Write(buf); err != nil {
+			return err
+		}
+	}
+}
+
+// evalRecord evaluates the expression against a single record
+// and appends the encoded result to dst. It returns nil if the
+// record is skipped.
+func (e *Expr) evalRecord(ctx context.Context, shared *environment, data interface{}, dst []byte, undef UndefinedAction) ([]byte, error) {
+
+	input := reflect.ValueOf(data)
+
+	result, err := eval(e.node, input, e.newInputEnv(ctx, shared, input))
+	if err != nil {
+		return nil, err
+	}
+
+	if !result.IsValid() {
+		switch undef {
+		case UndefinedSkip:
+			return nil, nil
+		case UndefinedError:
+			return nil, ErrUndefined
+		}
+	}
+
+	return appendJSON(dst, result, &marshalOptions)
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+
+	tok, err := dec.Token()
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return err
+	}
+
+	if tok != delim {
+		return fmt.Errorf("invalid JSON: expected %s, got %v", delim, tok)
+	}
+
+	return nil
+}
+
+// endArray reads the closing bracket of a top-level array and
+// checks that there is no more input.
+func endArray(dec *json.Decoder) error {
+
+	if err := expectDelim(dec, ']'); err != nil {
+		return err
+	}
+
+	if _, err := dec.Token(); err != io.EOF {
+		return fmt.Errorf("invalid JSON: unexpected data after top-level value")
+	}
+
+	return nil
+}
@@ -0,0 +1,225 @@
+// Copyright 2018 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package jsonata
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestEvalStream(t *testing.T) {
+
+	tests := []struct {
+		Expression string
+		Input      string
+		Options    StreamOptions
+		Output     string
+		Error      string
+		Errors     []int
+	}{
+		{
+			Expression: `name & "!"`,
+			Input:      "{\"name\": \"a\"}\n{\"name\": \"b\"}\n\n{\"name\": \"c\"}\n",
+			Output:     "\"a!\"\n\"b!\"\n\"c!\"\n",
+		},
+		{
+			Expression: `{"total": price * qty}`,
+			Input:      `[{"price": 2, "qty": 3}, {"price": 1.5, "qty": 2}]`,
+			Options: StreamOptions{
+				Array: true,
+			},
+			Output: "{\"total\":6}\n{\"total\":3}\n",
+		},
+		{
+			Expression: `$`,
+			Input:      `[]`,
+			Options: StreamOptions{
+				Array: true,
+			},
+			Output: "",
+		},
+		{
+			// Variables assigned in one record are not
+			// visible to the next.
+			Expression: `$n := $exists($n) ? $n + 1 : 1`,
+			Input:      "1 2 3",
+			Output:     "1\n1\n1\n",
+		},
+		{
+			Expression: `$prefix & id`,
+			Input:      `{"id": "x"} {"id": "y"}`,
+			Options: StreamOptions{
+				Vars: map[string]interface{}{
+					"prefix": "id-",
+				},
+			},
+			Output: "\"id-x\"\n\"id-y\"\n",
+		},
+		{
+			// Undefined results are skipped by default.
+			Expression: `a`,
+			Input:      `{"a": 1} {"b": 2} {"a": 3}`,
+			Output:     "1\n3\n",
+		},
+		{
+			Expression: `a`,
+			Input:      `{"a": 1} {"b": 2} {"a": 3}`,
+			Options: StreamOptions{
+				Undefined: UndefinedNull,
+			},
+			Output: "1\nnull\n3\n",
+		},
+		{
+			Expression: `a`,
+			Input:      `{"a": 1} {"b": 2} {"a": 3}`,
+			Options: StreamOptions{
+				Undefined: UndefinedError,
+			},
+			Output: "1\n",
+			Error:  "record 1: no results found",
+		},
+		{
+			Expression: `a + 1`,
+			Input:      `{"a": 1} {"a": "x"} {"a": 3}`,
+			Output:     "2\n",
+			Error:      `record 1: left side of the "+" operator must evaluate to a number`,
+		},
+		{
+			// Errors returned by OnError stop the stream.
+			Expression: `a + 1`,
+			Input:      `{"a": 1} {"a": "x"} {"a": 3}`,
+			Options: StreamOptions{
+				OnError: func(err *StreamError) error {
+					return errors.New("stop")
+				},
+			},
+			Output: "2\n",
+			Error:  "stop",
+		},
+		{
+			Expression: `a + 1`,
+			Input:      `[{"a": 1}, {"a": "x"}, {"b": 1}, {"a": 3}]`,
+			Options: StreamOptions{
+				Array:     true,
+				Undefined: UndefinedError,
+			},
+			Output: "2\n4\n",
+			Errors: []int{1, 2},
+		},
+		{
+			Expression: `$`,
+			Input:      `{"a": 1} {"a": `,
+			Output:     "{\"a\":1}\n",
+			Error:      "unexpected EOF",
+		},
+		{
+			Expression: `$`,
+			Input:      `{"a": 1}`,
+			Options: StreamOptions{
+				Array: true,
+			},
+			Error: `invalid JSON: expected [, got {`,
+		},
+		{
+			Expression: `$`,
+			Input:      `[1, 2`,
+			Options: StreamOptions{
+				Array: true,
+			},
+			Output: "1\n2\n",
+			Error:  "unexpected end of JSON input",
+		},
+		{
+			Expression: `$`,
+			Input:      `[1, 2] 3`,
+			Options: StreamOptions{
+				Array: true,
+			},
+			Output: "1\n2\n",
+			Error:  "invalid JSON: unexpected data after top-level value",
+		},
+	}
+
+	for _, test := range tests {
+
+		var errs []int
+		if test.Errors != nil {
+			test.Options.OnError = func(err *StreamError) error {
+				errs = append(errs, err.Record)
+				return nil
+			}
+		}
+
+		var w bytes.Buffer
+		err := MustCompile(test.Expression).EvalStream(strings.NewReader(test.Input), &w, test.Options)
+
+		if w.String() != test.Output {
+			t.Errorf("%s: expected output %q, got %q", test.Expression, test.Output, w.String())
+		}
+
+		switch {
+		case test.Error == "" && err != nil:
+			t.Errorf("%s: unexpected error: %s", test.Expression, err)
+		case test.Error != "" && (err == nil || err.Error() != test.Error):
+			t.Errorf("%s: expected error %q, got %v", test.Expression, test.Error, err)
+		}
+
+		if !reflect.DeepEqual(errs, test.Errors) {
+			t.Errorf("%s: expected errors in records %v, got %v", test.Expression, test.Errors, errs)
+		}
+	}
+}
+
+func TestEvalStreamErrors(t *testing.T) {
+
+	e := MustCompile(`a`)
+
+	err := e.EvalStream(strings.NewReader(`{"b": 1}`), io.Discard, StreamOptions{
+		Undefined: UndefinedError,
+	})
+
+	if !errors.Is(err, ErrUndefined) {
+		t.Errorf("expected an error wrapping ErrUndefined, got %v", err)
+	}
+
+	var serr *StreamError
+	if !errors.As(err, &serr) || serr.Record != 0 {
+		t.Errorf("expected a StreamError for record 0, got %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err = e.EvalStream(strings.NewReader(`{"a": 1}`), io.Discard, StreamOptions{
+		Context: ctx,
+	})
+
+	if err, ok := err.(*EvalError); !ok || err.Type != ErrCanceled {
+		t.Errorf("expected ErrCanceled, got %v", err)
+	}
+}
+
+func TestEvalStreamExactNumbers(t *testing.T) {
+
+	e := NewCompiler().WithExactNumbers(true).MustCompile(`id + 1`)
+
+	var w bytes.Buffer
+	err := e.EvalStream(strings.NewReader(`[{"id": 12345678901234567890}]`), &w, StreamOptions{
+		Array: true,
+	})
+	if err != nil {
+		t.Fatalf("EvalStream: %s", err)
+	}
+
+	exp := "12345678901234567891\n"
+	if w.String() != exp {
+		t.Errorf("expected output %q, got %q", exp, w.String())
+	}
+}
@@ -0,0 +1,128 @@
+// Copyright 2018 Blues Inc.  All rights reserved.
+// Use of this source code is governed by licenses granted by the
+// copyright holder including that found in the LICENSE file.
+
+package jsonata
+
+import (
+	"reflect"
+	"time"
+
+	"github.com/blues/jsonata-go/jparse"
+	"github.com/blues/jsonata-go/jtypes"
+)
+
+// CompileStrict is like Compile except that it also validates
+// the expression's function calls (see Validate) against the
+// built-in functions and the extensions registered with the
+// package level RegisterExts function. If validation fails,
+// CompileStrict returns an ErrorList.
+//
+// To validate an expression against extensions that are
+// registered with a specific Expr, call Compile, followed by
+// the Expr's RegisterExts and Validate methods, or use a
+// Compiler with strict mode enabled.
+func CompileStrict(expr string) (*Expr, error) {
+
+	e, err := Compile(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := e.Validate(); err != nil {
+		return nil, err
+	}
+
+	return e, nil
+}
+
+// Validate checks the function calls in an expression without
+// evaluating it. It reports calls to functions that are not
+// defined (as an EvalError of type ErrNonCallable) and calls
+// with the wrong number of arguments (as an ArgCountError).
+// Functions are looked up in the expression itself (lambdas
+// and their parameters), the Expr's registered extensions and
+// variables, the package level registry and the built-in
+// functions.
+//
+// Argument counts are checked for built-in functions, Go
+// extensions and lambdas with a type signature. Functions
+// that accept the evaluation context as an optional first
+// argument may be called with one argument fewer than they
+// declare.
+//
+// If there are any problems, Validate returns all of them
+// as an ErrorList, in source order. Each error's Span gives
+// the location of the function call.
+func (e *Expr) Validate() error {
+
+	var errs ErrorList
+
+	for _, call := range jparse.Analyze(e.node).Calls {
+		if err := e.validateCall(call); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return errs
+}
+
+func (e *Expr) validateCall(call jparse.Call) error {
+
+	if call.Local {
+		// Only lambdas with a type signature have a fixed
+		// number of parameters.
+		lambda, ok := call.Lambda.(*jparse.TypedLambdaNode)
+		if ok && !validLambdaArgCount(lambda.In, call.Args) {
+			return &ArgCountError{
+				Func:     call.Name,
+				Expected: len(lambda.In),
+				Received: call.Args,
+				Span:     call.Span,
+			}
+		}
+		return nil
+	}
+
+	v := e.lookupGlobal(call.Name)
+	if !jtypes.IsCallable(v) {
+		err := newEvalError(ErrNonCallable, "$"+call.Name, nil)
+		err.Span = call.Span
+		return err
+	}
+
+	if f, ok := v.Interface().(*goCallable); ok && !f.validArgCount(call.Args) {
+		err := newArgCountError(f, call.Args)
+		err.Func = call.Name
+		err.Span = call.Span
+		return err
+	}
+
+	if f, ok := v.Interface().(*evalCallable); ok && (call.Args < 1 || call.Args > 2) {
+		err := f.argCountError(call.Args)
+		err.Func = call.Name
+		err.Span = call.Span
+		return err
+	}
+
+	return nil
+}
+
+// lookupGlobal returns the value that a variable would have
+// in the top level environment of an evaluation (see newEnv).
+func (e *Expr) lookupGlobal(name string) reflect.Value {
+
+	if v, ok := e.registry[name]; ok {
+		return v
+	}
+
+	if v, ok := timeCallables(time.Time{})[name]; ok {
+		return v
+	}
+
+	return baseEnv.lookup(name)
+}